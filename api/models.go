@@ -1,19 +1,269 @@
 package api
 
-import "math/big"
+import (
+	"math/big"
+	"time"
+)
 
 type AuthToken = string
 
 type AuthRequest struct {
 	Password string `json:"password"`
 	Username string `json:"username"`
+	// ClientIP is set by the handler, sign-ins are throttled per IP
+	ClientIP string `json:"-"`
 }
 
 type AuthResponse struct {
-	Token *AuthToken `json:"token"`
+	Token        *AuthToken `json:"token"`
+	RefreshToken string     `json:"refreshToken"`
+	TokenType    string     `json:"tokenType"`
+	// ExpiresIn is the lifetime of the access token in seconds
+	ExpiresIn int64 `json:"expiresIn"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Jwk is a public key in JSON Web Key format (RFC 7517)
+type Jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type Jwks struct {
+	Keys []Jwk `json:"keys"`
+}
+
+// SiweNonce is the nonce a Sign-In with Ethereum message has to include
+type SiweNonce struct {
+	Nonce     string    `json:"nonce"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SiweRequest is an EIP-4361 message and its personal_sign signature
+type SiweRequest struct {
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// ChangePasswordRequest is confirmed with the current password, or with Siwe
+// by accounts without one
+type ChangePasswordRequest struct {
+	CurrentPassword string       `json:"currentPassword"`
+	NewPassword     string       `json:"newPassword"`
+	Siwe            *SiweRequest `json:"siwe,omitempty"`
+}
+
+// DeleteUserRequest is confirmed like ChangePasswordRequest
+type DeleteUserRequest struct {
+	Password string       `json:"password"`
+	Siwe     *SiweRequest `json:"siwe,omitempty"`
+}
+
+type User struct {
+	Id        uint64    `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Address   *string   `json:"address,omitempty"`
+	OrgId     *uint64   `json:"orgId,omitempty"`
+	OrgRole   string    `json:"orgRole,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type UsersResponse struct {
+	Users []User `json:"users"`
+}
+
+type CreateApiKeyRequest struct {
+	Name string `json:"name"`
+	// Scopes default to fetching and reading transactions
+	Scopes    []string   `json:"scopes,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type ApiKey struct {
+	Id         uint64     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// CreatedApiKey is only returned once, the key can't be retrieved later
+type CreatedApiKey struct {
+	ApiKey
+	Key string `json:"key"`
+}
+
+type ApiKeysResponse struct {
+	ApiKeys []ApiKey `json:"apiKeys"`
+}
+
+type CreateOrgRequest struct {
+	Name string `json:"name"`
+}
+
+type Organization struct {
+	Id      uint64      `json:"id"`
+	Name    string      `json:"name"`
+	Members []OrgMember `json:"members"`
+	// Invites are the invites not accepted yet
+	Invites   []OrgInvite `json:"invites"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+type OrgMember struct {
+	UserId   uint64 `json:"userId"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type InviteOrgMemberRequest struct {
+	Username string `json:"username"`
+	// Role defaults to member
+	Role string `json:"role,omitempty"`
+}
+
+// OrgInvite invites a user to an organization, they become a member with the
+// role when they accept it
+type OrgInvite struct {
+	Id        uint64    `json:"id"`
+	OrgId     uint64    `json:"orgId"`
+	OrgName   string    `json:"orgName"`
+	UserId    uint64    `json:"userId"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	InvitedBy string    `json:"invitedBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type OrgInvitesResponse struct {
+	Invites []OrgInvite `json:"invites"`
+}
+
+type CreateCollectionRequest struct {
+	Name string `json:"name"`
+	// Org shares the collection with the organization of the user
+	Org bool `json:"org"`
+}
+
+type Collection struct {
+	Id uint64 `json:"id"`
+	// OrgId is set for collections shared with an organization
+	OrgId            *uint64   `json:"orgId,omitempty"`
+	Name             string    `json:"name"`
+	TransactionCount int64     `json:"transactionCount"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+type CollectionsResponse struct {
+	Collections []Collection `json:"collections"`
+}
+
+type AddToCollectionRequest struct {
+	TransactionHashes []string `json:"transactionHashes"`
+}
+
+type SetTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type SetNoteRequest struct {
+	// Note replaces the note on the transaction, empty removes it
+	Note string `json:"note"`
+}
+
+// CreateShareRequest shares either a list of transactions or a collection
+type CreateShareRequest struct {
+	TransactionHashes []string `json:"transactionHashes,omitempty"`
+	CollectionId      *uint64  `json:"collectionId,omitempty"`
+	// ExpiresAt defaults to a week from now
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type Share struct {
+	Id uint64 `json:"id"`
+	// Token opens the share at /lime/shared/{token} without an account
+	Token             string   `json:"token"`
+	TransactionHashes []string `json:"transactionHashes,omitempty"`
+	// ChainId is the chain of the shared transactions, collections carry
+	// the chain of each of theirs
+	ChainId      *uint64    `json:"chainId,omitempty"`
+	CollectionId *uint64    `json:"collectionId,omitempty"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+type SharesResponse struct {
+	Shares []Share `json:"shares"`
+}
+
+// Usage counts requests and node calls. Per day and API key for a user's
+// own usage, per user over the whole period in the admin report.
+type Usage struct {
+	UserId    uint64 `json:"userId"`
+	ApiKeyId  uint64 `json:"apiKeyId,omitempty"`
+	Day       string `json:"day,omitempty"`
+	Requests  uint64 `json:"requests"`
+	NodeCalls uint64 `json:"nodeCalls"`
+}
+
+type UsageResponse struct {
+	From  string  `json:"from"`
+	To    string  `json:"to"`
+	Usage []Usage `json:"usage"`
+}
+
+type CacheStats struct {
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	Evictions  uint64 `json:"evictions"`
+	Rejections uint64 `json:"rejections"`
+	Entries    int    `json:"entries"`
+	Bytes      int64  `json:"bytes"`
+}
+
+type CacheStatus struct {
+	Backend string `json:"backend"`
+	// Stats are only kept by the in-process bounded cache, summed over the
+	// caches of every chain
+	Stats *CacheStats `json:"stats,omitempty"`
+}
+
+type DbStatus struct {
+	Dialect       string           `json:"dialect"`
+	SchemaVersion uint64           `json:"schemaVersion"`
+	LatestVersion uint64           `json:"latestVersion"`
+	RowCounts     map[string]int64 `json:"rowCounts"`
 }
 
 type Transaction struct {
+	ChainId           uint64   `json:"chainId"`
 	TransactionHash   string   `json:"transactionHash"`
 	TransactionStatus int      `json:"transactionStatus"`
 	BlockHash         string   `json:"blockHash"`
@@ -24,12 +274,131 @@ type Transaction struct {
 	LogsCount         int      `json:"logsCount"`
 	Input             string   `json:"input"`
 	Value             string   `json:"value"`
+	Type              uint64   `json:"type"`
+	GasUsed           uint64   `json:"gasUsed,omitempty"`
+	EffectiveGasPrice string   `json:"effectiveGasPrice,omitempty"`
+	// TotalFee is what the sender paid in wei, on rollups including the fee
+	// for posting the transaction to L1
+	TotalFee string     `json:"totalFee,omitempty"`
+	L2       *L2Receipt `json:"l2,omitempty"`
+	// BlockTimestamp is when the block was mined in seconds since the epoch,
+	// missing for transactions stored before it was recorded
+	BlockTimestamp *uint64 `json:"blockTimestamp,omitempty"`
+	// Block is embedded with embed=block
+	Block *Block `json:"block,omitempty"`
+	// Tags and Note are what the user put on the transaction, only listed in
+	// their history
+	Tags []string `json:"tags,omitempty"`
+	Note *string  `json:"note,omitempty"`
+}
+
+// L2Receipt are the fields rollup nodes add to transactions and receipts, the
+// OP Stack L1 data fee and deposit fields or the Arbitrum L1 gas. Amounts are
+// in wei.
+type L2Receipt struct {
+	L1Fee                 *string `json:"l1Fee,omitempty"`
+	L1GasUsed             *uint64 `json:"l1GasUsed,omitempty"`
+	L1GasPrice            *string `json:"l1GasPrice,omitempty"`
+	L1BlobBaseFee         *string `json:"l1BlobBaseFee,omitempty"`
+	L1FeeScalar           *string `json:"l1FeeScalar,omitempty"`
+	L1BaseFeeScalar       *uint64 `json:"l1BaseFeeScalar,omitempty"`
+	L1BlobBaseFeeScalar   *uint64 `json:"l1BlobBaseFeeScalar,omitempty"`
+	SourceHash            *string `json:"sourceHash,omitempty"`
+	Mint                  *string `json:"mint,omitempty"`
+	IsSystemTx            *bool   `json:"isSystemTx,omitempty"`
+	DepositNonce          *uint64 `json:"depositNonce,omitempty"`
+	DepositReceiptVersion *uint64 `json:"depositReceiptVersion,omitempty"`
+	GasUsedForL1          *uint64 `json:"gasUsedForL1,omitempty"`
 }
 
 type TransactionResponse struct {
 	Transactions *[]Transaction `json:"transactions"`
 }
 
+// TransactionTrace is the call tree of a transaction with the value moved by
+// its internal calls flattened into Transfers
+type TransactionTrace struct {
+	ChainId         uint64             `json:"chainId"`
+	TransactionHash string             `json:"transactionHash"`
+	Calls           CallFrame          `json:"calls"`
+	Transfers       []InternalTransfer `json:"transfers"`
+}
+
+// CallFrame is a call of the callTracer, values are in wei
+type CallFrame struct {
+	Type         string      `json:"type"`
+	From         string      `json:"from"`
+	To           string      `json:"to,omitempty"`
+	Value        string      `json:"value,omitempty"`
+	Gas          uint64      `json:"gas"`
+	GasUsed      uint64      `json:"gasUsed"`
+	Input        string      `json:"input"`
+	Output       string      `json:"output,omitempty"`
+	Error        string      `json:"error,omitempty"`
+	RevertReason string      `json:"revertReason,omitempty"`
+	Calls        []CallFrame `json:"calls,omitempty"`
+}
+
+// InternalTransfer is ether an internal call moved, Depth is 1 for the calls
+// the transaction makes
+type InternalTransfer struct {
+	Type  string `json:"type"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value string `json:"value"`
+	Depth int    `json:"depth"`
+}
+
+// Block is the header of a block, amounts are in wei
+type Block struct {
+	ChainId    uint64 `json:"chainId"`
+	Number     uint64 `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+	// Timestamp is in seconds since the epoch
+	Timestamp uint64 `json:"timestamp"`
+	// Miner is the fee recipient since the merge
+	Miner            string  `json:"miner"`
+	BaseFeePerGas    *string `json:"baseFeePerGas,omitempty"`
+	GasUsed          uint64  `json:"gasUsed"`
+	GasLimit         uint64  `json:"gasLimit"`
+	BlobGasUsed      *uint64 `json:"blobGasUsed,omitempty"`
+	ExcessBlobGas    *uint64 `json:"excessBlobGas,omitempty"`
+	WithdrawalsCount *uint64 `json:"withdrawalsCount,omitempty"`
+	TransactionCount uint64  `json:"transactionCount"`
+}
+
+// Chain is a chain transactions can be looked up on, by its name or id
+type Chain struct {
+	Id   uint64 `json:"id"`
+	Name string `json:"name"`
+	// Type is ethereum, optimism (OP Stack) or arbitrum
+	Type string `json:"type"`
+	// Default is the chain used when a request doesn't name one
+	Default bool `json:"default"`
+	// Traces tells whether transactions on the chain can be traced
+	Traces bool `json:"traces"`
+}
+
+type ChainsResponse struct {
+	Chains []Chain `json:"chains"`
+}
+
+type Status struct {
+	Status string `json:"status"`
+}
+
+// Replacement links a transaction that will never be mined to the one of the
+// same sender that took its nonce, Cancelled when that one sends nothing to
+// the sender itself
+type Replacement struct {
+	TransactionHash string `json:"transactionHash"`
+	ReplacedBy      string `json:"replacedBy"`
+	Cancelled       bool   `json:"cancelled"`
+}
+
 type Error struct {
 	Msg string `json:"error"`
+	// ReplacedBy is set when the transactions looked up were replaced
+	ReplacedBy []Replacement `json:"replacedBy,omitempty"`
 }
@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"ethereum_fetcher/db"
+	"ethereum_fetcher/db/migrations"
+	"ethereum_fetcher/internal/config"
+)
+
+const usage = `usage: migrate <command>
+
+commands:
+  up            apply all pending migrations
+  down [steps]  roll back the last applied migration(s), defaults to 1
+  to <version>  migrate up or down to the given version
+  status        list migrations and whether they are applied`
+
+func Run(args []string) {
+	if len(args) == 0 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	dbConn, err := db.Open(config.LoadDB())
+	if err != nil {
+		log.Fatalf("Database connection failed:  %v", err)
+	}
+
+	migrator, err := migrations.New(dbConn)
+	if err != nil {
+		log.Fatalf("Failed to load migrations:  %v", err)
+	}
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		err = migrator.Down(parseArgOrDefault(args, 1))
+	case "to":
+		if len(args) < 2 {
+			log.Fatalln("'to' requires a target version")
+		}
+		err = migrator.To(uint64(parseArgOrDefault(args, 0)))
+	case "status":
+		err = printStatus(migrator)
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("Migration failed:  %v", err)
+	}
+
+	if args[0] != "status" {
+		version, err := migrator.Version()
+		if err != nil {
+			log.Fatalf("Failed to read schema version:  %v", err)
+		}
+		log.Printf("Database schema is at version %d (latest %d)", version, migrator.Latest())
+	}
+}
+
+func printStatus(migrator *migrations.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%04d  %-40s %s\n", status.Version, status.Name, applied)
+	}
+	return nil
+}
+
+func parseArgOrDefault(args []string, defaultValue int) int {
+	if len(args) < 2 {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(args[1])
+	if err != nil || value < 0 {
+		log.Fatalf("invalid argument '%s'", args[1])
+	}
+	return value
+}
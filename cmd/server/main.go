@@ -1,7 +1,13 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -12,29 +18,58 @@ import (
 	"ethereum_fetcher/internal/services"
 )
 
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	Run()
 }
 
 func Run() {
 	cfg := config.Load()
-	db := initDb(cfg.DBConnectionURL)
+	db := initDb(cfg)
 
 	services, err := services.Init(db, cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize services:  %v", err)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// requests are served while the cache warms up, /ready reports when it's done
+	go services.Warmer.WarmUp(ctx)
+
 	r := gin.Default()
+	// the client IP throttles sign-ins, only trusted proxies may override it
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies:  %v", err)
+	}
 	routes.SetupRoutes(services, r)
 
-	if err := r.Run(":" + cfg.APIPort); err != nil {
-		log.Fatalf("Failed to start server:  %v", err)
+	srv := &http.Server{Addr: ":" + cfg.APIPort, Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server:  %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server gracefully:  %v", err)
+	}
+
+	if err := services.Warmer.SaveSnapshot(); err != nil {
+		log.Printf("Failed to save the cache:  %v", err)
 	}
 }
 
-func initDb(dbUrl string) *gorm.DB {
-	dbConn, err := db.InitDB(dbUrl)
+func initDb(cfg config.Config) *gorm.DB {
+	dbConn, err := db.InitDB(cfg.DBConnectionURL, cfg.DBAutoMigrate, cfg.SeedDemoUsers)
 	if err != nil {
 		log.Fatalf("Database connection failed:  %v", err)
 	}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqliteParams are appended to every SQLite DSN: foreign keys are enforced,
+// WAL lets readers proceed during writes, and immediate transactions plus a
+// busy timeout make concurrent writers wait instead of failing
+const sqliteParams = "_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+
+// Open connects to the database described by dbUrl. The driver is picked from
+// the URL scheme: postgres:// and postgresql:// use Postgres, sqlite:// and
+// file: use an embedded SQLite database file.
+func Open(dbUrl string) (*gorm.DB, error) {
+	dialector, err := dialectorFor(dbUrl)
+	if err != nil {
+		return nil, err
+	}
+	return gorm.Open(dialector, &gorm.Config{})
+}
+
+func dialectorFor(dbUrl string) (gorm.Dialector, error) {
+	switch {
+	case strings.HasPrefix(dbUrl, "postgres://"), strings.HasPrefix(dbUrl, "postgresql://"):
+		return postgres.Open(dbUrl), nil
+	case strings.HasPrefix(dbUrl, "sqlite://"):
+		return sqlite.Open(sqliteDsn("file:" + strings.TrimPrefix(dbUrl, "sqlite://"))), nil
+	case strings.HasPrefix(dbUrl, "file:"):
+		return sqlite.Open(sqliteDsn(dbUrl)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database URL scheme in '%s', expected postgres://, sqlite:// or file:", redact(dbUrl))
+	}
+}
+
+func sqliteDsn(dsn string) string {
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + sqliteParams
+	}
+	return dsn + "?" + sqliteParams
+}
+
+// redact drops everything after the scheme so credentials don't end up in logs
+func redact(dbUrl string) string {
+	if scheme, _, ok := strings.Cut(dbUrl, "://"); ok {
+		return scheme + "://..."
+	}
+	return "..."
+}
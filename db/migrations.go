@@ -1,27 +1,49 @@
 package db
 
 import (
+	"errors"
+	"ethereum_fetcher/db/migrations"
 	"ethereum_fetcher/db/models"
 	"ethereum_fetcher/pkg/passwords"
+	"fmt"
 	"log"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-func InitDB(dbUrl string) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(dbUrl), &gorm.Config{})
+// InitDB opens the database and makes sure its schema matches this build.
+// Pending migrations are applied when autoMigrate is set, otherwise the
+// schema has to be brought up to date with the migrate subcommand first.
+// seedDemoUsers creates well known demo accounts and must stay off in
+// production.
+func InitDB(dbUrl string, autoMigrate bool, seedDemoUsers bool) (*gorm.DB, error) {
+	db, err := Open(dbUrl)
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.UserTransaction{})
+	migrator, err := migrations.New(db)
 	if err != nil {
-		log.Fatalf("Migration failed <- %v", err)
+		return nil, err
+	}
+
+	if autoMigrate {
+		if err := migrator.Up(); err != nil {
+			return nil, fmt.Errorf("migration failed <- %w", err)
+		}
 	}
 
-	prepopulateUsers(db)
+	if err := migrator.Verify(); err != nil {
+		if errors.Is(err, migrations.ErrPendingMigrations) {
+			return nil, fmt.Errorf("%w, run the 'migrate up' command first", err)
+		}
+		return nil, err
+	}
+
+	if seedDemoUsers {
+		prepopulateUsers(db)
+	}
 
 	return db, nil
 }
@@ -0,0 +1,311 @@
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql
+var scripts embed.FS
+
+var (
+	ErrUnknownVersion     = errors.New("database schema version is newer than this build understands")
+	ErrPendingMigrations  = errors.New("database schema has pending migrations")
+	ErrUnsupportedDialect = errors.New("no migrations available for database dialect")
+)
+
+// Migration is a single versioned schema change with its rollback script
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a known migration and when, if ever, it was applied
+type Status struct {
+	Version   uint64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// SchemaMigration is a row of the schema_migrations table
+type SchemaMigration struct {
+	Version   uint64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New loads the embedded migrations for the dialect of the given connection
+func New(db *gorm.DB) (*Migrator, error) {
+	migrations, err := load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func load(dialect string) ([]Migration, error) {
+	dir := path.Join("sql", dialect)
+	entries, err := fs.ReadDir(scripts, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrUnsupportedDialect, dialect)
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		version, name, direction, err := parseFileName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		script, err := fs.ReadFile(scripts, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both up and down scripts", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// parseFileName splits '0001_init.up.sql' into its version, name and direction
+func parseFileName(fileName string) (uint64, string, string, error) {
+	base, ok := strings.CutSuffix(fileName, ".sql")
+	if !ok {
+		return 0, "", "", fmt.Errorf("unexpected migration file '%s'", fileName)
+	}
+
+	ext := path.Ext(base)
+	direction := strings.TrimPrefix(ext, ".")
+	if direction != "up" && direction != "down" {
+		return 0, "", "", fmt.Errorf("migration file '%s' must end in .up.sql or .down.sql", fileName)
+	}
+
+	versionStr, name, ok := strings.Cut(strings.TrimSuffix(base, ext), "_")
+	if !ok {
+		return 0, "", "", fmt.Errorf("migration file '%s' must be named <version>_<name>", fileName)
+	}
+
+	version, err := strconv.ParseUint(versionStr, 10, 64)
+	if err != nil || version == 0 {
+		return 0, "", "", fmt.Errorf("invalid version in migration file '%s'", fileName)
+	}
+
+	return version, name, direction, nil
+}
+
+// Latest returns the highest version known to this build
+func (m *Migrator) Latest() uint64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the highest version applied to the database
+func (m *Migrator) Version() (uint64, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	var version uint64
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Down rolls back the given number of most recently applied migrations
+func (m *Migrator) Down(steps int) error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := m.rollback(migration); err != nil {
+			return err
+		}
+		steps--
+	}
+
+	return nil
+}
+
+// To migrates up or down until exactly the migrations up to version are applied
+func (m *Migrator) To(version uint64) error {
+	if version > m.Latest() {
+		return fmt.Errorf("%w: target %d, latest %d", ErrUnknownVersion, version, m.Latest())
+	}
+	if err := m.Verify(); err != nil && !errors.Is(err, ErrPendingMigrations) {
+		return err
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; ok && migration.Version > version {
+			if err := m.rollback(migration); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+			if err := m.apply(migration); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Status lists every known migration together with its applied timestamp
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Verify fails if the database has migrations this build doesn't know about
+// or is missing migrations this build expects
+func (m *Migrator) Verify() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[uint64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("%w: found version %d, latest known %d", ErrUnknownVersion, version, m.Latest())
+		}
+	}
+
+	if len(applied) < len(m.migrations) {
+		return fmt.Errorf("%w: %d of %d applied", ErrPendingMigrations, len(applied), len(m.migrations))
+	}
+	return nil
+}
+
+func (m *Migrator) applied() (map[uint64]SchemaMigration, error) {
+	err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var rows []SchemaMigration
+	if err := m.db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	applied := make(map[uint64]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (m *Migrator) apply(migration Migration) error {
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := execScript(tx, migration.Up); err != nil {
+			return err
+		}
+		return tx.Create(&SchemaMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) rollback(migration Migration) error {
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := execScript(tx, migration.Down); err != nil {
+			return err
+		}
+		return tx.Delete(&SchemaMigration{}, migration.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to roll back migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// execScript runs a whole script at once, both pgx (simple protocol) and
+// go-sqlite3 accept several statements in a single Exec
+func execScript(tx *gorm.DB, script string) error {
+	return tx.Exec(script).Error
+}
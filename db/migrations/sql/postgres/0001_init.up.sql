@@ -0,0 +1,32 @@
+-- Baseline schema. Tables are created only if missing so databases that were
+-- previously managed by gorm.AutoMigrate can adopt versioned migrations.
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    username TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    transaction_hash VARCHAR(66) PRIMARY KEY,
+    transaction_status BIGINT,
+    block_hash TEXT,
+    block_number BIGINT,
+    from_address TEXT,
+    to_address TEXT,
+    contract_address TEXT,
+    logs_count BIGINT,
+    input TEXT,
+    value TEXT,
+    created_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS user_transactions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL,
+    requested_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_transaction
+    ON user_transactions (transaction_hash);
@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_user_transactions_user_hash;
+
+ALTER TABLE user_transactions ADD COLUMN requested_at TIMESTAMPTZ;
+UPDATE user_transactions SET requested_at = first_requested_at;
+
+-- the old index only allows one row per hash, keep the earliest request
+DELETE FROM user_transactions a
+    USING user_transactions b
+    WHERE a.transaction_hash = b.transaction_hash AND a.id > b.id;
+
+ALTER TABLE user_transactions
+    DROP COLUMN request_count,
+    DROP COLUMN first_requested_at,
+    DROP COLUMN last_requested_at;
+
+CREATE UNIQUE INDEX idx_user_transaction ON user_transactions (transaction_hash);
@@ -0,0 +1,22 @@
+-- Track how often each user requested a hash and allow several users to
+-- record the same hash.
+DROP INDEX IF EXISTS idx_user_transaction;
+
+ALTER TABLE user_transactions
+    ADD COLUMN IF NOT EXISTS request_count BIGINT NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS first_requested_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS last_requested_at TIMESTAMPTZ;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'user_transactions' AND column_name = 'requested_at') THEN
+        UPDATE user_transactions
+        SET first_requested_at = requested_at, last_requested_at = requested_at
+        WHERE first_requested_at IS NULL;
+        ALTER TABLE user_transactions DROP COLUMN requested_at;
+    END IF;
+END $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_transactions_user_hash
+    ON user_transactions (user_id, transaction_hash);
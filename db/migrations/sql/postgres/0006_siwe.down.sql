@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS siwe_nonces;
+DROP INDEX IF EXISTS idx_users_address;
+ALTER TABLE users DROP COLUMN address;
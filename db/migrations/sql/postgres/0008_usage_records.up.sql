@@ -0,0 +1,14 @@
+-- Daily usage per user and API key, for reporting and billing. Sessions
+-- signed in with a password have api_key_id 0, anonymous lookups user_id 0.
+CREATE TABLE usage_records (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    api_key_id BIGINT NOT NULL,
+    day VARCHAR(10) NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    node_calls BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX idx_usage_records_user_key_day ON usage_records (user_id, api_key_id, day);
+CREATE INDEX idx_usage_records_day ON usage_records (day);
@@ -0,0 +1,18 @@
+-- Keep one row per user and hash so the old unique index can be restored
+DELETE FROM user_transactions
+WHERE EXISTS (SELECT 1 FROM user_transactions AS other
+              WHERE other.user_id = user_transactions.user_id
+                AND other.transaction_hash = user_transactions.transaction_hash
+                AND other.org_id < user_transactions.org_id);
+
+DROP INDEX IF EXISTS idx_user_transactions_org_id;
+DROP INDEX IF EXISTS idx_user_transactions_user_org_hash;
+ALTER TABLE user_transactions DROP COLUMN org_id;
+CREATE UNIQUE INDEX idx_user_transactions_user_hash
+    ON user_transactions (user_id, transaction_hash);
+
+DROP INDEX IF EXISTS idx_users_org_id;
+ALTER TABLE users DROP COLUMN org_role;
+ALTER TABLE users DROP COLUMN org_id;
+
+DROP TABLE IF EXISTS organizations;
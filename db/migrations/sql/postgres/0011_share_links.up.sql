@@ -0,0 +1,13 @@
+-- Read-only links to a list of transactions or a collection. The link token
+-- is signed and only carries the id and expiry, not the owner.
+CREATE TABLE share_links (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    collection_id BIGINT,
+    hashes TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_share_links_user_id ON share_links (user_id);
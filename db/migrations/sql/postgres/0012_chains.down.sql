@@ -0,0 +1,22 @@
+-- Keep the mainnet row of hashes known on several chains so the old keys can
+-- be restored
+DELETE FROM user_transactions
+WHERE EXISTS (SELECT 1 FROM user_transactions AS other
+              WHERE other.user_id = user_transactions.user_id
+                AND other.org_id = user_transactions.org_id
+                AND other.transaction_hash = user_transactions.transaction_hash
+                AND other.chain_id < user_transactions.chain_id);
+
+DROP INDEX IF EXISTS idx_user_transactions_user_org_chain_hash;
+ALTER TABLE user_transactions DROP COLUMN chain_id;
+CREATE UNIQUE INDEX idx_user_transactions_user_org_hash
+    ON user_transactions (user_id, org_id, transaction_hash);
+
+DELETE FROM transactions
+WHERE EXISTS (SELECT 1 FROM transactions AS other
+              WHERE other.transaction_hash = transactions.transaction_hash
+                AND other.chain_id < transactions.chain_id);
+
+ALTER TABLE transactions DROP CONSTRAINT transactions_pkey;
+ALTER TABLE transactions DROP COLUMN chain_id;
+ALTER TABLE transactions ADD PRIMARY KEY (transaction_hash);
@@ -0,0 +1,14 @@
+-- Transactions are keyed by the chain they are on and their hash. Rows from
+-- before several chains were supported are assigned to mainnet, deployments
+-- that looked transactions up on another chain update chain_id afterwards.
+ALTER TABLE transactions ADD COLUMN chain_id BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE transactions ALTER COLUMN chain_id DROP DEFAULT;
+ALTER TABLE transactions DROP CONSTRAINT transactions_pkey;
+ALTER TABLE transactions ADD PRIMARY KEY (chain_id, transaction_hash);
+
+-- Lookups are recorded for the chain they were made on
+ALTER TABLE user_transactions ADD COLUMN chain_id BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE user_transactions ALTER COLUMN chain_id DROP DEFAULT;
+DROP INDEX IF EXISTS idx_user_transactions_user_org_hash;
+CREATE UNIQUE INDEX idx_user_transactions_user_org_chain_hash
+    ON user_transactions (user_id, org_id, chain_id, transaction_hash);
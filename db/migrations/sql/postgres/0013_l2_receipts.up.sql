@@ -0,0 +1,26 @@
+-- What a transaction cost: its type, the gas it used at its effective price
+-- and the total fee in wei, on rollups including the L1 data fee. Rows stored
+-- before have none of it.
+ALTER TABLE transactions ADD COLUMN tx_type BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN gas_used BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN effective_gas_price TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN total_fee TEXT NOT NULL DEFAULT '';
+
+-- Receipt fields OP Stack nodes add for the L1 data fee
+ALTER TABLE transactions ADD COLUMN l1_fee TEXT;
+ALTER TABLE transactions ADD COLUMN l1_gas_used BIGINT;
+ALTER TABLE transactions ADD COLUMN l1_gas_price TEXT;
+ALTER TABLE transactions ADD COLUMN l1_blob_base_fee TEXT;
+ALTER TABLE transactions ADD COLUMN l1_fee_scalar TEXT;
+ALTER TABLE transactions ADD COLUMN l1_base_fee_scalar BIGINT;
+ALTER TABLE transactions ADD COLUMN l1_blob_base_fee_scalar BIGINT;
+
+-- OP Stack deposit transactions
+ALTER TABLE transactions ADD COLUMN source_hash VARCHAR(66);
+ALTER TABLE transactions ADD COLUMN mint TEXT;
+ALTER TABLE transactions ADD COLUMN is_system_tx BOOLEAN;
+ALTER TABLE transactions ADD COLUMN deposit_nonce BIGINT;
+ALTER TABLE transactions ADD COLUMN deposit_receipt_version BIGINT;
+
+-- The part of the gas used Arbitrum charges for posting to L1
+ALTER TABLE transactions ADD COLUMN gas_used_for_l1 BIGINT;
@@ -0,0 +1,9 @@
+-- Call trees of transactions from debug_traceTransaction with the callTracer,
+-- stored as JSON once a transaction was traced.
+CREATE TABLE transaction_traces (
+    chain_id BIGINT NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL,
+    trace TEXT NOT NULL,
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (chain_id, transaction_hash)
+);
@@ -0,0 +1,13 @@
+-- Transactions seen pending with their sender and nonce, to tell when another
+-- transaction of the sender took the nonce and replaced them.
+CREATE TABLE pending_transactions (
+    chain_id BIGINT NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL,
+    from_address VARCHAR(42) NOT NULL,
+    nonce BIGINT NOT NULL,
+    seen_at_block BIGINT NOT NULL,
+    replaced_by VARCHAR(66),
+    cancelled BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (chain_id, transaction_hash)
+);
@@ -0,0 +1,20 @@
+-- Headers of the blocks looked up or embedded in transaction responses.
+CREATE TABLE blocks (
+    chain_id BIGINT NOT NULL,
+    number BIGINT NOT NULL,
+    hash VARCHAR(66) NOT NULL,
+    parent_hash VARCHAR(66) NOT NULL,
+    timestamp BIGINT NOT NULL,
+    miner VARCHAR(42) NOT NULL,
+    base_fee_per_gas TEXT,
+    gas_used BIGINT NOT NULL,
+    gas_limit BIGINT NOT NULL,
+    blob_gas_used BIGINT,
+    excess_blob_gas BIGINT,
+    withdrawals_count BIGINT,
+    transaction_count BIGINT NOT NULL,
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (chain_id, number)
+);
+
+CREATE UNIQUE INDEX idx_blocks_chain_hash ON blocks (chain_id, hash);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_block_timestamp;
+
+ALTER TABLE transactions DROP COLUMN block_timestamp;
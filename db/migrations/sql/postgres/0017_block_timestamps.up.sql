@@ -0,0 +1,5 @@
+-- When the block of a transaction was mined, in seconds since the epoch. NULL
+-- for transactions stored before it was recorded.
+ALTER TABLE transactions ADD COLUMN block_timestamp BIGINT;
+
+CREATE INDEX idx_transactions_block_timestamp ON transactions (block_timestamp);
@@ -0,0 +1,13 @@
+-- Users join an organization by accepting an invite from one of its admins,
+-- so nobody's lookups are shared without their consent.
+CREATE TABLE org_invites (
+    id BIGSERIAL PRIMARY KEY,
+    org_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    invited_by BIGINT NOT NULL,
+    created_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX idx_org_invites_org_user ON org_invites (org_id, user_id);
+CREATE INDEX idx_org_invites_user_id ON org_invites (user_id);
@@ -0,0 +1,8 @@
+DELETE FROM collection_transactions WHERE collection_id IN (SELECT id FROM collections WHERE org_id IS NOT NULL);
+DELETE FROM collections WHERE org_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_collections_org_name;
+DROP INDEX IF EXISTS idx_collections_user_name;
+CREATE UNIQUE INDEX idx_collections_user_name ON collections (user_id, name);
+
+ALTER TABLE collections DROP COLUMN org_id;
@@ -0,0 +1,7 @@
+-- Collections of an organization are shared by its members, org_id is NULL
+-- for the collections of a single user. Names are unique per owner.
+ALTER TABLE collections ADD COLUMN org_id BIGINT;
+
+DROP INDEX idx_collections_user_name;
+CREATE UNIQUE INDEX idx_collections_user_name ON collections (user_id, name) WHERE org_id IS NULL;
+CREATE UNIQUE INDEX idx_collections_org_name ON collections (org_id, name) WHERE org_id IS NOT NULL;
@@ -0,0 +1,31 @@
+ALTER TABLE share_links DROP COLUMN chain_id;
+
+-- Keep the row of the lowest chain of hashes annotated on several chains so
+-- the old keys can be restored
+DELETE FROM transaction_notes
+WHERE EXISTS (SELECT 1 FROM transaction_notes AS other
+              WHERE other.user_id = transaction_notes.user_id
+                AND other.transaction_hash = transaction_notes.transaction_hash
+                AND other.chain_id < transaction_notes.chain_id);
+ALTER TABLE transaction_notes DROP CONSTRAINT transaction_notes_pkey;
+ALTER TABLE transaction_notes DROP COLUMN chain_id;
+ALTER TABLE transaction_notes ADD PRIMARY KEY (user_id, transaction_hash);
+
+DELETE FROM transaction_tags
+WHERE EXISTS (SELECT 1 FROM transaction_tags AS other
+              WHERE other.user_id = transaction_tags.user_id
+                AND other.transaction_hash = transaction_tags.transaction_hash
+                AND other.tag = transaction_tags.tag
+                AND other.chain_id < transaction_tags.chain_id);
+ALTER TABLE transaction_tags DROP CONSTRAINT transaction_tags_pkey;
+ALTER TABLE transaction_tags DROP COLUMN chain_id;
+ALTER TABLE transaction_tags ADD PRIMARY KEY (user_id, transaction_hash, tag);
+
+DELETE FROM collection_transactions
+WHERE EXISTS (SELECT 1 FROM collection_transactions AS other
+              WHERE other.collection_id = collection_transactions.collection_id
+                AND other.transaction_hash = collection_transactions.transaction_hash
+                AND other.chain_id < collection_transactions.chain_id);
+ALTER TABLE collection_transactions DROP CONSTRAINT collection_transactions_pkey;
+ALTER TABLE collection_transactions DROP COLUMN chain_id;
+ALTER TABLE collection_transactions ADD PRIMARY KEY (collection_id, transaction_hash);
@@ -0,0 +1,31 @@
+-- Collections, tags, notes and share links refer to transactions by chain and
+-- hash, like the transactions themselves. Rows from before take the chain of
+-- the stored transaction with their hash, mainnet when there is none.
+ALTER TABLE collection_transactions ADD COLUMN chain_id BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE collection_transactions ALTER COLUMN chain_id DROP DEFAULT;
+UPDATE collection_transactions SET chain_id = COALESCE(
+    (SELECT MIN(chain_id) FROM transactions WHERE transactions.transaction_hash = collection_transactions.transaction_hash), 1);
+ALTER TABLE collection_transactions DROP CONSTRAINT collection_transactions_pkey;
+ALTER TABLE collection_transactions ADD PRIMARY KEY (collection_id, chain_id, transaction_hash);
+
+ALTER TABLE transaction_tags ADD COLUMN chain_id BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE transaction_tags ALTER COLUMN chain_id DROP DEFAULT;
+UPDATE transaction_tags SET chain_id = COALESCE(
+    (SELECT MIN(chain_id) FROM transactions WHERE transactions.transaction_hash = transaction_tags.transaction_hash), 1);
+ALTER TABLE transaction_tags DROP CONSTRAINT transaction_tags_pkey;
+ALTER TABLE transaction_tags ADD PRIMARY KEY (user_id, chain_id, transaction_hash, tag);
+
+ALTER TABLE transaction_notes ADD COLUMN chain_id BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE transaction_notes ALTER COLUMN chain_id DROP DEFAULT;
+UPDATE transaction_notes SET chain_id = COALESCE(
+    (SELECT MIN(chain_id) FROM transactions WHERE transactions.transaction_hash = transaction_notes.transaction_hash), 1);
+ALTER TABLE transaction_notes DROP CONSTRAINT transaction_notes_pkey;
+ALTER TABLE transaction_notes ADD PRIMARY KEY (user_id, chain_id, transaction_hash);
+
+-- Links to a list of transactions resolve on the chain they were created on,
+-- links to a collection on the chains of its transactions and have none
+ALTER TABLE share_links ADD COLUMN chain_id BIGINT;
+UPDATE share_links SET chain_id = COALESCE(
+    (SELECT MIN(chain_id) FROM transactions
+     WHERE (' ' || share_links.hashes || ' ') LIKE ('% ' || transactions.transaction_hash || ' %')), 1)
+WHERE collection_id IS NULL;
@@ -0,0 +1,2 @@
+-- The copied timestamps are those of the blocks, they are kept.
+SELECT 1;
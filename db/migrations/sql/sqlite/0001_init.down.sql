@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_transactions;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS users;
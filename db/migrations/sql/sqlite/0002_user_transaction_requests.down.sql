@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_user_transactions_user_hash;
+
+ALTER TABLE user_transactions ADD COLUMN requested_at DATETIME;
+UPDATE user_transactions SET requested_at = first_requested_at;
+
+-- the old index only allows one row per hash, keep the earliest request
+DELETE FROM user_transactions
+    WHERE id NOT IN (SELECT MIN(id) FROM user_transactions GROUP BY transaction_hash);
+
+ALTER TABLE user_transactions DROP COLUMN request_count;
+ALTER TABLE user_transactions DROP COLUMN first_requested_at;
+ALTER TABLE user_transactions DROP COLUMN last_requested_at;
+
+CREATE UNIQUE INDEX idx_user_transaction ON user_transactions (transaction_hash);
@@ -0,0 +1,15 @@
+-- Track how often each user requested a hash and allow several users to
+-- record the same hash.
+DROP INDEX IF EXISTS idx_user_transaction;
+
+ALTER TABLE user_transactions ADD COLUMN request_count INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE user_transactions ADD COLUMN first_requested_at DATETIME;
+ALTER TABLE user_transactions ADD COLUMN last_requested_at DATETIME;
+
+UPDATE user_transactions
+SET first_requested_at = requested_at, last_requested_at = requested_at;
+
+ALTER TABLE user_transactions DROP COLUMN requested_at;
+
+CREATE UNIQUE INDEX idx_user_transactions_user_hash
+    ON user_transactions (user_id, transaction_hash);
@@ -0,0 +1,16 @@
+-- API keys for machine clients, stored as SHA-256 hashes. The prefix is the
+-- non secret part of the key shown when listing keys.
+CREATE TABLE api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    expires_at DATETIME,
+    last_used_at DATETIME,
+    revoked_at DATETIME,
+    created_at DATETIME
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys (user_id);
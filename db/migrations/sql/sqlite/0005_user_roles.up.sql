@@ -0,0 +1,3 @@
+-- Every existing user keeps fetching transactions from the node, admins are
+-- promoted explicitly.
+ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'ingester';
@@ -0,0 +1,13 @@
+-- Sign-In with Ethereum. Users may link one address, accounts created by
+-- signing in with an address have no password.
+ALTER TABLE users ADD COLUMN address VARCHAR(42);
+CREATE UNIQUE INDEX idx_users_address ON users (address);
+
+-- Nonces handed out for sign-in messages, deleted when used so that a signed
+-- message can't be replayed.
+CREATE TABLE siwe_nonces (
+    nonce VARCHAR(32) PRIMARY KEY,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_siwe_nonces_expires_at ON siwe_nonces (expires_at);
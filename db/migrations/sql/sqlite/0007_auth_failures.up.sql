@@ -0,0 +1,12 @@
+-- Audit trail of failed password sign-ins, also used to throttle further
+-- attempts per username and per client IP.
+CREATE TABLE auth_failures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(64) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    reason VARCHAR(16) NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_auth_failures_username ON auth_failures (username, created_at);
+CREATE INDEX idx_auth_failures_ip ON auth_failures (ip, created_at);
@@ -0,0 +1,14 @@
+-- Daily usage per user and API key, for reporting and billing. Sessions
+-- signed in with a password have api_key_id 0, anonymous lookups user_id 0.
+CREATE TABLE usage_records (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    api_key_id INTEGER NOT NULL,
+    day VARCHAR(10) NOT NULL,
+    requests INTEGER NOT NULL DEFAULT 0,
+    node_calls INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME
+);
+
+CREATE UNIQUE INDEX idx_usage_records_user_key_day ON usage_records (user_id, api_key_id, day);
+CREATE INDEX idx_usage_records_day ON usage_records (day);
@@ -0,0 +1,19 @@
+-- Organizations share the lookup history of their members. A user belongs to
+-- at most one organization, as its admin or a member.
+CREATE TABLE organizations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(64) NOT NULL UNIQUE,
+    created_at DATETIME
+);
+
+ALTER TABLE users ADD COLUMN org_id INTEGER;
+ALTER TABLE users ADD COLUMN org_role VARCHAR(16) NOT NULL DEFAULT '';
+CREATE INDEX idx_users_org_id ON users (org_id);
+
+-- Lookups are recorded for the organization the user was in at the time,
+-- org_id 0 outside of one. A user has a row per organization for a hash.
+ALTER TABLE user_transactions ADD COLUMN org_id INTEGER NOT NULL DEFAULT 0;
+DROP INDEX IF EXISTS idx_user_transactions_user_hash;
+CREATE UNIQUE INDEX idx_user_transactions_user_org_hash
+    ON user_transactions (user_id, org_id, transaction_hash);
+CREATE INDEX idx_user_transactions_org_id ON user_transactions (org_id);
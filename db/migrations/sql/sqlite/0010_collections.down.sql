@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS transaction_notes;
+DROP TABLE IF EXISTS transaction_tags;
+DROP TABLE IF EXISTS collection_transactions;
+DROP TABLE IF EXISTS collections;
@@ -0,0 +1,34 @@
+-- Collections, tags and notes users keep on the transactions they looked up.
+CREATE TABLE collections (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    created_at DATETIME
+);
+
+CREATE UNIQUE INDEX idx_collections_user_name ON collections (user_id, name);
+
+CREATE TABLE collection_transactions (
+    collection_id INTEGER NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL,
+    added_at DATETIME,
+    PRIMARY KEY (collection_id, transaction_hash)
+);
+
+CREATE TABLE transaction_tags (
+    user_id INTEGER NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL,
+    tag VARCHAR(32) NOT NULL,
+    created_at DATETIME,
+    PRIMARY KEY (user_id, transaction_hash, tag)
+);
+
+CREATE INDEX idx_transaction_tags_user_tag ON transaction_tags (user_id, tag);
+
+CREATE TABLE transaction_notes (
+    user_id INTEGER NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL,
+    note TEXT NOT NULL,
+    updated_at DATETIME,
+    PRIMARY KEY (user_id, transaction_hash)
+);
@@ -0,0 +1,13 @@
+-- Read-only links to a list of transactions or a collection. The link token
+-- is signed and only carries the id and expiry, not the owner.
+CREATE TABLE share_links (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    collection_id INTEGER,
+    hashes TEXT NOT NULL DEFAULT '',
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME,
+    created_at DATETIME
+);
+
+CREATE INDEX idx_share_links_user_id ON share_links (user_id);
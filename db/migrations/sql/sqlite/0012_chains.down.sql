@@ -0,0 +1,39 @@
+-- Keep the mainnet row of hashes known on several chains so the old keys can
+-- be restored
+DELETE FROM user_transactions
+WHERE EXISTS (SELECT 1 FROM user_transactions AS other
+              WHERE other.user_id = user_transactions.user_id
+                AND other.org_id = user_transactions.org_id
+                AND other.transaction_hash = user_transactions.transaction_hash
+                AND other.chain_id < user_transactions.chain_id);
+
+DROP INDEX IF EXISTS idx_user_transactions_user_org_chain_hash;
+ALTER TABLE user_transactions DROP COLUMN chain_id;
+CREATE UNIQUE INDEX idx_user_transactions_user_org_hash
+    ON user_transactions (user_id, org_id, transaction_hash);
+
+CREATE TABLE transactions_hashes (
+    transaction_hash VARCHAR(66) PRIMARY KEY,
+    transaction_status INTEGER,
+    block_hash TEXT,
+    block_number INTEGER,
+    from_address TEXT,
+    to_address TEXT,
+    contract_address TEXT,
+    logs_count INTEGER,
+    input TEXT,
+    value TEXT,
+    created_at DATETIME
+);
+
+INSERT INTO transactions_hashes (transaction_hash, transaction_status, block_hash, block_number,
+                                 from_address, to_address, contract_address, logs_count, input, value, created_at)
+SELECT transaction_hash, transaction_status, block_hash, block_number,
+       from_address, to_address, contract_address, logs_count, input, value, created_at
+FROM transactions
+WHERE NOT EXISTS (SELECT 1 FROM transactions AS other
+                  WHERE other.transaction_hash = transactions.transaction_hash
+                    AND other.chain_id < transactions.chain_id);
+
+DROP TABLE transactions;
+ALTER TABLE transactions_hashes RENAME TO transactions;
@@ -0,0 +1,34 @@
+-- Transactions are keyed by the chain they are on and their hash. Rows from
+-- before several chains were supported are assigned to mainnet, deployments
+-- that looked transactions up on another chain update chain_id afterwards.
+-- SQLite can't change a primary key, the table is rebuilt.
+CREATE TABLE transactions_chains (
+    chain_id INTEGER NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL,
+    transaction_status INTEGER,
+    block_hash TEXT,
+    block_number INTEGER,
+    from_address TEXT,
+    to_address TEXT,
+    contract_address TEXT,
+    logs_count INTEGER,
+    input TEXT,
+    value TEXT,
+    created_at DATETIME,
+    PRIMARY KEY (chain_id, transaction_hash)
+);
+
+INSERT INTO transactions_chains (chain_id, transaction_hash, transaction_status, block_hash, block_number,
+                                 from_address, to_address, contract_address, logs_count, input, value, created_at)
+SELECT 1, transaction_hash, transaction_status, block_hash, block_number,
+       from_address, to_address, contract_address, logs_count, input, value, created_at
+FROM transactions;
+
+DROP TABLE transactions;
+ALTER TABLE transactions_chains RENAME TO transactions;
+
+-- Lookups are recorded for the chain they were made on
+ALTER TABLE user_transactions ADD COLUMN chain_id INTEGER NOT NULL DEFAULT 1;
+DROP INDEX IF EXISTS idx_user_transactions_user_org_hash;
+CREATE UNIQUE INDEX idx_user_transactions_user_org_chain_hash
+    ON user_transactions (user_id, org_id, chain_id, transaction_hash);
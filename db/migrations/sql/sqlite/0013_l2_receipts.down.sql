@@ -0,0 +1,17 @@
+ALTER TABLE transactions DROP COLUMN gas_used_for_l1;
+ALTER TABLE transactions DROP COLUMN deposit_receipt_version;
+ALTER TABLE transactions DROP COLUMN deposit_nonce;
+ALTER TABLE transactions DROP COLUMN is_system_tx;
+ALTER TABLE transactions DROP COLUMN mint;
+ALTER TABLE transactions DROP COLUMN source_hash;
+ALTER TABLE transactions DROP COLUMN l1_blob_base_fee_scalar;
+ALTER TABLE transactions DROP COLUMN l1_base_fee_scalar;
+ALTER TABLE transactions DROP COLUMN l1_fee_scalar;
+ALTER TABLE transactions DROP COLUMN l1_blob_base_fee;
+ALTER TABLE transactions DROP COLUMN l1_gas_price;
+ALTER TABLE transactions DROP COLUMN l1_gas_used;
+ALTER TABLE transactions DROP COLUMN l1_fee;
+ALTER TABLE transactions DROP COLUMN total_fee;
+ALTER TABLE transactions DROP COLUMN effective_gas_price;
+ALTER TABLE transactions DROP COLUMN gas_used;
+ALTER TABLE transactions DROP COLUMN tx_type;
@@ -0,0 +1,20 @@
+-- Headers of the blocks looked up or embedded in transaction responses.
+CREATE TABLE blocks (
+    chain_id INTEGER NOT NULL,
+    number INTEGER NOT NULL,
+    hash VARCHAR(66) NOT NULL,
+    parent_hash VARCHAR(66) NOT NULL,
+    timestamp INTEGER NOT NULL,
+    miner VARCHAR(42) NOT NULL,
+    base_fee_per_gas TEXT,
+    gas_used INTEGER NOT NULL,
+    gas_limit INTEGER NOT NULL,
+    blob_gas_used INTEGER,
+    excess_blob_gas INTEGER,
+    withdrawals_count INTEGER,
+    transaction_count INTEGER NOT NULL,
+    created_at DATETIME,
+    PRIMARY KEY (chain_id, number)
+);
+
+CREATE UNIQUE INDEX idx_blocks_chain_hash ON blocks (chain_id, hash);
@@ -0,0 +1,13 @@
+-- Users join an organization by accepting an invite from one of its admins,
+-- so nobody's lookups are shared without their consent.
+CREATE TABLE org_invites (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    org_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    invited_by INTEGER NOT NULL,
+    created_at DATETIME
+);
+
+CREATE UNIQUE INDEX idx_org_invites_org_user ON org_invites (org_id, user_id);
+CREATE INDEX idx_org_invites_user_id ON org_invites (user_id);
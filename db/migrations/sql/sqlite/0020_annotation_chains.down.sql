@@ -0,0 +1,61 @@
+ALTER TABLE share_links DROP COLUMN chain_id;
+
+-- Keep the row of the lowest chain of hashes annotated on several chains so
+-- the old keys can be restored
+CREATE TABLE transaction_notes_hashes (
+    user_id INTEGER NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL,
+    note TEXT NOT NULL,
+    updated_at DATETIME,
+    PRIMARY KEY (user_id, transaction_hash)
+);
+
+INSERT INTO transaction_notes_hashes (user_id, transaction_hash, note, updated_at)
+SELECT user_id, transaction_hash, note, updated_at
+FROM transaction_notes
+WHERE NOT EXISTS (SELECT 1 FROM transaction_notes AS other
+                  WHERE other.user_id = transaction_notes.user_id
+                    AND other.transaction_hash = transaction_notes.transaction_hash
+                    AND other.chain_id < transaction_notes.chain_id);
+
+DROP TABLE transaction_notes;
+ALTER TABLE transaction_notes_hashes RENAME TO transaction_notes;
+
+CREATE TABLE transaction_tags_hashes (
+    user_id INTEGER NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL,
+    tag VARCHAR(32) NOT NULL,
+    created_at DATETIME,
+    PRIMARY KEY (user_id, transaction_hash, tag)
+);
+
+INSERT INTO transaction_tags_hashes (user_id, transaction_hash, tag, created_at)
+SELECT user_id, transaction_hash, tag, created_at
+FROM transaction_tags
+WHERE NOT EXISTS (SELECT 1 FROM transaction_tags AS other
+                  WHERE other.user_id = transaction_tags.user_id
+                    AND other.transaction_hash = transaction_tags.transaction_hash
+                    AND other.tag = transaction_tags.tag
+                    AND other.chain_id < transaction_tags.chain_id);
+
+DROP TABLE transaction_tags;
+ALTER TABLE transaction_tags_hashes RENAME TO transaction_tags;
+CREATE INDEX idx_transaction_tags_user_tag ON transaction_tags (user_id, tag);
+
+CREATE TABLE collection_transactions_hashes (
+    collection_id INTEGER NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL,
+    added_at DATETIME,
+    PRIMARY KEY (collection_id, transaction_hash)
+);
+
+INSERT INTO collection_transactions_hashes (collection_id, transaction_hash, added_at)
+SELECT collection_id, transaction_hash, added_at
+FROM collection_transactions
+WHERE NOT EXISTS (SELECT 1 FROM collection_transactions AS other
+                  WHERE other.collection_id = collection_transactions.collection_id
+                    AND other.transaction_hash = collection_transactions.transaction_hash
+                    AND other.chain_id < collection_transactions.chain_id);
+
+DROP TABLE collection_transactions;
+ALTER TABLE collection_transactions_hashes RENAME TO collection_transactions;
@@ -0,0 +1,68 @@
+-- Collections, tags, notes and share links refer to transactions by chain and
+-- hash, like the transactions themselves. Rows from before take the chain of
+-- the stored transaction with their hash, mainnet when there is none.
+-- SQLite can't change a primary key, the tables are rebuilt.
+CREATE TABLE collection_transactions_chains (
+    collection_id INTEGER NOT NULL,
+    chain_id INTEGER NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL,
+    added_at DATETIME,
+    PRIMARY KEY (collection_id, chain_id, transaction_hash)
+);
+
+INSERT INTO collection_transactions_chains (collection_id, chain_id, transaction_hash, added_at)
+SELECT collection_id,
+       COALESCE((SELECT MIN(chain_id) FROM transactions
+                 WHERE transactions.transaction_hash = collection_transactions.transaction_hash), 1),
+       transaction_hash, added_at
+FROM collection_transactions;
+
+DROP TABLE collection_transactions;
+ALTER TABLE collection_transactions_chains RENAME TO collection_transactions;
+
+CREATE TABLE transaction_tags_chains (
+    user_id INTEGER NOT NULL,
+    chain_id INTEGER NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL,
+    tag VARCHAR(32) NOT NULL,
+    created_at DATETIME,
+    PRIMARY KEY (user_id, chain_id, transaction_hash, tag)
+);
+
+INSERT INTO transaction_tags_chains (user_id, chain_id, transaction_hash, tag, created_at)
+SELECT user_id,
+       COALESCE((SELECT MIN(chain_id) FROM transactions
+                 WHERE transactions.transaction_hash = transaction_tags.transaction_hash), 1),
+       transaction_hash, tag, created_at
+FROM transaction_tags;
+
+DROP TABLE transaction_tags;
+ALTER TABLE transaction_tags_chains RENAME TO transaction_tags;
+CREATE INDEX idx_transaction_tags_user_tag ON transaction_tags (user_id, tag);
+
+CREATE TABLE transaction_notes_chains (
+    user_id INTEGER NOT NULL,
+    chain_id INTEGER NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL,
+    note TEXT NOT NULL,
+    updated_at DATETIME,
+    PRIMARY KEY (user_id, chain_id, transaction_hash)
+);
+
+INSERT INTO transaction_notes_chains (user_id, chain_id, transaction_hash, note, updated_at)
+SELECT user_id,
+       COALESCE((SELECT MIN(chain_id) FROM transactions
+                 WHERE transactions.transaction_hash = transaction_notes.transaction_hash), 1),
+       transaction_hash, note, updated_at
+FROM transaction_notes;
+
+DROP TABLE transaction_notes;
+ALTER TABLE transaction_notes_chains RENAME TO transaction_notes;
+
+-- Links to a list of transactions resolve on the chain they were created on,
+-- links to a collection on the chains of its transactions and have none
+ALTER TABLE share_links ADD COLUMN chain_id INTEGER;
+UPDATE share_links SET chain_id = COALESCE(
+    (SELECT MIN(chain_id) FROM transactions
+     WHERE (' ' || share_links.hashes || ' ') LIKE ('% ' || transactions.transaction_hash || ' %')), 1)
+WHERE collection_id IS NULL;
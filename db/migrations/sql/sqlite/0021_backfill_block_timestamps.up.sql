@@ -0,0 +1,12 @@
+-- Transactions stored before block timestamps were recorded take the timestamp
+-- of their block when it is stored. The others are dated when they are listed.
+UPDATE transactions
+SET block_timestamp = (
+    SELECT blocks.timestamp FROM blocks
+    WHERE blocks.chain_id = transactions.chain_id AND blocks.number = transactions.block_number
+)
+WHERE block_timestamp IS NULL
+  AND EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocks.chain_id = transactions.chain_id AND blocks.number = transactions.block_number
+  );
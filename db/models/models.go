@@ -8,10 +8,40 @@ type User struct {
 	ID           uint64 `gorm:"primaryKey" json:"id"`
 	Username     string `gorm:"unique;not null"`
 	PasswordHash string `gorm:"not null" json:"-"`
-	CreatedAt    time.Time
+	// Role is one of admin, ingester or reader
+	Role string `gorm:"size:16;not null;default:ingester" json:"role"`
+	// Address is the EIP-55 checksummed Ethereum address the user signs in
+	// with, if they linked one. Users created by signing in with it have no
+	// password.
+	Address *string `gorm:"size:42;uniqueIndex:idx_users_address" json:"address"`
+	// OrgId is the organization the user belongs to, if any, and OrgRole
+	// their role in it, admin or member
+	OrgId     *uint64 `gorm:"index:idx_users_org_id" json:"orgId"`
+	OrgRole   string  `gorm:"size:16;not null;default:''" json:"orgRole"`
+	CreatedAt time.Time
 }
 
+// Organization shares the lookup history of its members
+type Organization struct {
+	ID        uint64 `gorm:"primaryKey"`
+	Name      string `gorm:"size:64;not null;unique"`
+	CreatedAt time.Time
+}
+
+// OrgInvite invites a user to an organization with a role, they join when
+// they accept it
+type OrgInvite struct {
+	ID        uint64 `gorm:"primaryKey"`
+	OrgId     uint64 `gorm:"not null;uniqueIndex:idx_org_invites_org_user,priority:1"`
+	UserId    uint64 `gorm:"not null;uniqueIndex:idx_org_invites_org_user,priority:2;index:idx_org_invites_user_id"`
+	Role      string `gorm:"size:16;not null"`
+	InvitedBy uint64 `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+// Transaction is keyed by the chain it is on and its hash
 type Transaction struct {
+	ChainId           uint64 `gorm:"primaryKey;autoIncrement:false"`
 	TransactionHash   string `gorm:"primaryKey;size:66"`
 	TransactionStatus int
 	BlockHash         string
@@ -22,13 +52,217 @@ type Transaction struct {
 	LogsCount         int
 	Input             string
 	Value             string
-	CreatedAt         time.Time
+	TxType            uint64 `gorm:"column:tx_type;not null;default:0"`
+	GasUsed           uint64 `gorm:"not null;default:0"`
+	// EffectiveGasPrice and TotalFee are in wei, TotalFee is what the sender
+	// paid including the L1 data fee on rollups
+	EffectiveGasPrice string    `gorm:"not null;default:''"`
+	TotalFee          string    `gorm:"not null;default:''"`
+	L2                L2Receipt `gorm:"embedded"`
+	// BlockTimestamp is when the block was mined in seconds since the epoch,
+	// nil for transactions stored before it was recorded. CreatedAt is when
+	// the transaction was fetched.
+	BlockTimestamp *uint64 `gorm:"index:idx_transactions_block_timestamp"`
+	CreatedAt      time.Time
 }
 
-// UserTransaction stores which users requested which transactions
+// L2Receipt holds the fields rollup nodes add to transactions and receipts,
+// all nil on L1 chains
+type L2Receipt struct {
+	// L1Fee is the OP Stack fee for posting the transaction to L1, in wei
+	L1Fee               *string `gorm:"column:l1_fee"`
+	L1GasUsed           *uint64 `gorm:"column:l1_gas_used"`
+	L1GasPrice          *string `gorm:"column:l1_gas_price"`
+	L1BlobBaseFee       *string `gorm:"column:l1_blob_base_fee"`
+	L1FeeScalar         *string `gorm:"column:l1_fee_scalar"`
+	L1BaseFeeScalar     *uint64 `gorm:"column:l1_base_fee_scalar"`
+	L1BlobBaseFeeScalar *uint64 `gorm:"column:l1_blob_base_fee_scalar"`
+	// SourceHash, Mint and IsSystemTx are set on OP Stack deposits
+	SourceHash            *string `gorm:"size:66"`
+	Mint                  *string
+	IsSystemTx            *bool
+	DepositNonce          *uint64
+	DepositReceiptVersion *uint64
+	// GasUsedForL1 is the part of the gas used Arbitrum charges for L1
+	GasUsedForL1 *uint64 `gorm:"column:gas_used_for_l1"`
+}
+
+// UserTransaction stores which users requested which transactions,
+// how many times and when they first and last did so. Lookups are recorded
+// for the organization the user was in at the time, OrgId 0 outside of one,
+// and the chain they were made on.
 type UserTransaction struct {
-	ID              uint64 `gorm:"primaryKey"`
-	UserId          uint64 `gorm:"size:255;not null;"`
-	TransactionHash string `gorm:"size:66;not null;uniqueIndex:idx_user_transaction"`
-	RequestedAt     time.Time
+	ID               uint64 `gorm:"primaryKey"`
+	UserId           uint64 `gorm:"not null;uniqueIndex:idx_user_transactions_user_org_chain_hash,priority:1"`
+	OrgId            uint64 `gorm:"not null;default:0;uniqueIndex:idx_user_transactions_user_org_chain_hash,priority:2;index:idx_user_transactions_org_id"`
+	ChainId          uint64 `gorm:"not null;uniqueIndex:idx_user_transactions_user_org_chain_hash,priority:3"`
+	TransactionHash  string `gorm:"size:66;not null;uniqueIndex:idx_user_transactions_user_org_chain_hash,priority:4"`
+	RequestCount     uint64 `gorm:"not null;default:1"`
+	FirstRequestedAt time.Time
+	LastRequestedAt  time.Time
+}
+
+// RefreshToken is a hashed refresh token. Refreshing revokes the token and
+// issues its replacement in the same family.
+type RefreshToken struct {
+	ID        uint64    `gorm:"primaryKey"`
+	UserId    uint64    `gorm:"not null;index:idx_refresh_tokens_user_id"`
+	TokenHash string    `gorm:"size:64;not null;unique"`
+	FamilyId  string    `gorm:"size:32;not null;index:idx_refresh_tokens_family_id"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// RevokedToken is an access token revoked before it expires
+type RevokedToken struct {
+	Jti       string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
+
+// ApiKey is a hashed long lived key a user created for machine clients
+type ApiKey struct {
+	ID      uint64 `gorm:"primaryKey"`
+	UserId  uint64 `gorm:"not null;index:idx_api_keys_user_id"`
+	Name    string `gorm:"not null"`
+	Prefix  string `gorm:"size:16;not null"`
+	KeyHash string `gorm:"size:64;not null;unique"`
+	// Scopes is a space separated list of the scopes granted to the key
+	Scopes     string `gorm:"not null"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// SiweNonce is a nonce handed out for a Sign-In with Ethereum message, it is
+// deleted when used
+type SiweNonce struct {
+	Nonce     string    `gorm:"primaryKey;size:32"`
+	ExpiresAt time.Time `gorm:"not null;index:idx_siwe_nonces_expires_at"`
+}
+
+// AuthFailure is a failed password sign-in
+type AuthFailure struct {
+	ID       uint64 `gorm:"primaryKey"`
+	Username string `gorm:"size:64;not null;index:idx_auth_failures_username,priority:1"`
+	IP       string `gorm:"column:ip;size:45;not null;index:idx_auth_failures_ip,priority:1"`
+	// Reason is unknown_user, bad_password or throttled, or pending while the
+	// attempt is verified
+	Reason    string    `gorm:"size:16;not null"`
+	CreatedAt time.Time `gorm:"not null;index:idx_auth_failures_username,priority:2;index:idx_auth_failures_ip,priority:2"`
+}
+
+// UsageRecord counts the requests and node calls of a user, or one of their
+// API keys, on a UTC day
+type UsageRecord struct {
+	ID       uint64 `gorm:"primaryKey"`
+	UserId   uint64 `gorm:"not null;uniqueIndex:idx_usage_records_user_key_day,priority:1"`
+	ApiKeyId uint64 `gorm:"not null;uniqueIndex:idx_usage_records_user_key_day,priority:2"`
+	// Day is formatted as 2006-01-02
+	Day       string `gorm:"size:10;not null;uniqueIndex:idx_usage_records_user_key_day,priority:3;index:idx_usage_records_day"`
+	Requests  uint64 `gorm:"not null;default:0"`
+	NodeCalls uint64 `gorm:"not null;default:0"`
+	UpdatedAt time.Time
+}
+
+// Collection is a named set of transactions a user looked up. It belongs to
+// the user or, when OrgId is set, to their organization.
+type Collection struct {
+	ID        uint64  `gorm:"primaryKey"`
+	UserId    uint64  `gorm:"not null;uniqueIndex:idx_collections_user_name,priority:1,where:org_id IS NULL"`
+	OrgId     *uint64 `gorm:"uniqueIndex:idx_collections_org_name,priority:1,where:org_id IS NOT NULL"`
+	Name      string  `gorm:"size:64;not null;uniqueIndex:idx_collections_user_name,priority:2,where:org_id IS NULL;uniqueIndex:idx_collections_org_name,priority:2,where:org_id IS NOT NULL"`
+	CreatedAt time.Time
+}
+
+type CollectionTransaction struct {
+	CollectionId    uint64 `gorm:"primaryKey"`
+	ChainId         uint64 `gorm:"primaryKey"`
+	TransactionHash string `gorm:"primaryKey;size:66"`
+	AddedAt         time.Time
+}
+
+// TransactionTag is a tag a user put on a transaction
+type TransactionTag struct {
+	UserId          uint64 `gorm:"primaryKey;index:idx_transaction_tags_user_tag,priority:1"`
+	ChainId         uint64 `gorm:"primaryKey"`
+	TransactionHash string `gorm:"primaryKey;size:66"`
+	Tag             string `gorm:"primaryKey;size:32;index:idx_transaction_tags_user_tag,priority:2"`
+	CreatedAt       time.Time
+}
+
+// TransactionNote is the note a user wrote on a transaction
+type TransactionNote struct {
+	UserId          uint64 `gorm:"primaryKey"`
+	ChainId         uint64 `gorm:"primaryKey"`
+	TransactionHash string `gorm:"primaryKey;size:66"`
+	Note            string `gorm:"not null"`
+	UpdatedAt       time.Time
+}
+
+// ShareLink gives anyone with its token read access to a list of
+// transactions or to a collection, as it is when the link is opened
+type ShareLink struct {
+	ID           uint64 `gorm:"primaryKey"`
+	UserId       uint64 `gorm:"not null;index:idx_share_links_user_id"`
+	CollectionId *uint64
+	// ChainId is the chain of the shared transactions, nil for a collection
+	// whose transactions carry their own
+	ChainId *uint64
+	// Hashes is a space separated list of the shared transactions, empty for
+	// a collection
+	Hashes    string    `gorm:"not null;default:''"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// TransactionTrace is the call tree of a transaction as the callTracer of
+// debug_traceTransaction returns it, stored as JSON
+type TransactionTrace struct {
+	ChainId         uint64 `gorm:"primaryKey;autoIncrement:false"`
+	TransactionHash string `gorm:"primaryKey;size:66"`
+	Trace           string `gorm:"not null"`
+	CreatedAt       time.Time
+}
+
+// PendingTransaction is a transaction seen pending, kept to find the
+// transaction that replaces it when another transaction of the sender is
+// mined with its nonce
+type PendingTransaction struct {
+	ChainId         uint64 `gorm:"primaryKey;autoIncrement:false"`
+	TransactionHash string `gorm:"primaryKey;size:66"`
+	FromAddress     string `gorm:"size:42;not null"`
+	Nonce           uint64 `gorm:"not null"`
+	// SeenAtBlock was the latest block when the transaction was seen pending,
+	// the search for its replacement starts there
+	SeenAtBlock uint64  `gorm:"not null"`
+	ReplacedBy  *string `gorm:"size:66"`
+	// Cancelled replacements send nothing to the sender itself
+	Cancelled bool `gorm:"not null;default:false"`
+	CreatedAt time.Time
+}
+
+// Block is the header of a block, with the number of transactions and
+// withdrawals it holds
+type Block struct {
+	ChainId    uint64 `gorm:"primaryKey;autoIncrement:false;uniqueIndex:idx_blocks_chain_hash,priority:1"`
+	Number     uint64 `gorm:"primaryKey;autoIncrement:false"`
+	Hash       string `gorm:"size:66;not null;uniqueIndex:idx_blocks_chain_hash,priority:2"`
+	ParentHash string `gorm:"size:66;not null"`
+	// Timestamp is in seconds since the epoch
+	Timestamp uint64 `gorm:"not null"`
+	// Miner is the fee recipient since the merge
+	Miner         string `gorm:"size:42;not null"`
+	BaseFeePerGas *string
+	GasUsed       uint64 `gorm:"not null"`
+	GasLimit      uint64 `gorm:"not null"`
+	// BlobGasUsed, ExcessBlobGas and WithdrawalsCount are nil before the
+	// upgrades that introduced them
+	BlobGasUsed      *uint64
+	ExcessBlobGas    *uint64
+	WithdrawalsCount *uint64
+	TransactionCount uint64 `gorm:"not null"`
+	CreatedAt        time.Time
 }
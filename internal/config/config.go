@@ -3,15 +3,107 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	APIPort         string
-	EthNodeURL      string
+	APIPort string
+	// Chains are the chains transactions are looked up on, the first is the
+	// default
+	Chains          []ChainConfig
 	DBConnectionURL string
+	DBAutoMigrate   bool
+	// JWTSecret signs tokens with HS256 unless JWTKeysDir holds PEM keys
 	JWTSecret       string
+	JWTKeysDir      string
+	JWTIssuer       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// RegistrationEnabled allows anyone to create an account at /lime/users
+	RegistrationEnabled bool
+	// AdminUsers are promoted to the admin role on startup, to bootstrap the
+	// first admin
+	AdminUsers []string
+	// SeedDemoUsers creates the alice, bob, carol and dave accounts with their
+	// username as password, for local development only
+	SeedDemoUsers bool
+	// SiweDomain is the host Sign-In with Ethereum messages have to be issued
+	// for, empty disables it. SiweChainId restricts them to one chain, zero
+	// accepts any.
+	SiweDomain   string
+	SiweChainId  uint64
+	SiweNonceTTL time.Duration
+	// LoginMaxUserFailures and LoginMaxIpFailures are the failed sign-ins
+	// allowed per username and client IP within LoginFailureWindow before
+	// further attempts are delayed, by up to LoginMaxDelay
+	LoginMaxUserFailures int
+	LoginMaxIpFailures   int
+	LoginFailureWindow   time.Duration
+	LoginMaxDelay        time.Duration
+	// TrustedProxies may set X-Forwarded-For, without any the client IP is
+	// the address of the connection
+	TrustedProxies []string
+	// ShareSecret signs share links, empty disables sharing. ShareMaxTTL is
+	// the longest a link may be valid for.
+	ShareSecret string
+	ShareMaxTTL time.Duration
+	Cache       CacheConfig
+	Usage       UsageConfig
+}
+
+// ChainConfig is a chain and the node transactions are looked up on
+type ChainConfig struct {
+	Name    string
+	ChainId uint64
+	NodeURL string
+	// Type is ethereum, optimism (OP Stack) or arbitrum, rollups have their
+	// receipts read with the fields their nodes add
+	Type string
+	// Traces enables tracing transactions, the node has to serve the debug
+	// namespace
+	Traces bool
+}
+
+// CacheConfig selects and sizes the transaction cache backend, every chain
+// has a cache of its own
+type CacheConfig struct {
+	// Backend is one of memory, bounded, redis or tiered (bounded in front of redis)
+	Backend string
+	TTL     time.Duration
+	// NegativeTTL is how long hashes the node doesn't know are remembered
+	NegativeTTL time.Duration
+	MaxEntries  int
+	MaxBytes    int64
+	// Eviction is the bounded cache eviction policy, lru or lfu
+	Eviction  string
+	Admission bool
+	RedisURL  string
+	// WarmUp fills the cache on startup from none, popular (the most requested
+	// transactions) or snapshot (the file written at the last shutdown)
+	WarmUp        string
+	WarmUpLimit   int
+	WarmUpTimeout time.Duration
+	// SnapshotPath is where the cache is saved on shutdown, empty disables it
+	SnapshotPath string
+}
+
+// UsageConfig limits the requests per second and the node calls per day of
+// each user, API key and anonymous client, zero disables a limit
+type UsageConfig struct {
+	UserRPS        float64
+	UserBurst      int
+	ApiKeyRPS      float64
+	ApiKeyBurst    int
+	AnonymousRPS   float64
+	AnonymousBurst int
+	// AnonymousNodeCallsPerDay is shared by all anonymous clients
+	UserNodeCallsPerDay      uint64
+	ApiKeyNodeCallsPerDay    uint64
+	AnonymousNodeCallsPerDay uint64
 }
 
 func Load() Config {
@@ -21,9 +113,112 @@ func Load() Config {
 
 	return Config{
 		APIPort:         getConfigOrFail("API_PORT"),
-		EthNodeURL:      getConfigOrFail("ETH_NODE_URL"),
+		Chains:          loadChainsConfig(),
 		DBConnectionURL: getConfigOrFail("DB_CONNECTION_URL"),
-		JWTSecret:       getConfigOrFail("JWT_SECRET"),
+		DBAutoMigrate:   getBoolConfigOrDefault("DB_AUTO_MIGRATE", true),
+		JWTSecret:       getConfigOrDefault("JWT_SECRET", ""),
+		JWTKeysDir:      getConfigOrDefault("JWT_KEYS_DIR", ""),
+		JWTIssuer:       getConfigOrDefault("JWT_ISSUER", "ethereum-fetcher"),
+		AccessTokenTTL:  getDurationConfigOrDefault("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getDurationConfigOrDefault("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		RegistrationEnabled: getBoolConfigOrDefault("REGISTRATION_ENABLED", false),
+		AdminUsers:          getListConfigOrDefault("ADMIN_USERS", nil),
+		SeedDemoUsers:       getBoolConfigOrDefault("SEED_DEMO_USERS", false),
+
+		SiweDomain:   getConfigOrDefault("SIWE_DOMAIN", ""),
+		SiweChainId:  uint64(getIntConfigOrDefault("SIWE_CHAIN_ID", 0)),
+		SiweNonceTTL: getDurationConfigOrDefault("SIWE_NONCE_TTL", 5*time.Minute),
+
+		LoginMaxUserFailures: int(getIntConfigOrDefault("LOGIN_MAX_USER_FAILURES", 5)),
+		LoginMaxIpFailures:   int(getIntConfigOrDefault("LOGIN_MAX_IP_FAILURES", 20)),
+		LoginFailureWindow:   getDurationConfigOrDefault("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		LoginMaxDelay:        getDurationConfigOrDefault("LOGIN_MAX_DELAY", 15*time.Minute),
+		TrustedProxies:       getListConfigOrDefault("TRUSTED_PROXIES", nil),
+		ShareSecret:          getConfigOrDefault("SHARE_SECRET", ""),
+		ShareMaxTTL:          getDurationConfigOrDefault("SHARE_MAX_TTL", 30*24*time.Hour),
+		Cache:                loadCacheConfig(),
+		Usage:                loadUsageConfig(),
+	}
+}
+
+// LoadDB reads only the database connection, for commands like migrate that
+// run without the server environment. The .env file is optional here.
+func LoadDB() string {
+	_ = godotenv.Load()
+	return getConfigOrFail("DB_CONNECTION_URL")
+}
+
+// loadChainsConfig reads CHAINS, a comma separated list of name=chainId
+// pairs with the node of each chain at ETH_NODE_URL_<NAME>. Without it the
+// node at ETH_NODE_URL serves the single chain ETH_CHAIN_NAME with the id
+// ETH_CHAIN_ID. The type of a chain is read from CHAIN_TYPE_<NAME> or
+// ETH_CHAIN_TYPE for the single chain. TRACES_ENABLED enables traces on every
+// chain, TRACES_ENABLED_<NAME> on a single one.
+func loadChainsConfig() []ChainConfig {
+	traces := getBoolConfigOrDefault("TRACES_ENABLED", false)
+	pairs := getListConfigOrDefault("CHAINS", nil)
+	if len(pairs) == 0 {
+		return []ChainConfig{{
+			Name:    getConfigOrDefault("ETH_CHAIN_NAME", "mainnet"),
+			ChainId: uint64(getIntConfigOrDefault("ETH_CHAIN_ID", 1)),
+			NodeURL: getConfigOrFail("ETH_NODE_URL"),
+			Type:    getConfigOrDefault("ETH_CHAIN_TYPE", "ethereum"),
+			Traces:  traces,
+		}}
+	}
+
+	chains := make([]ChainConfig, 0, len(pairs))
+	for _, pair := range pairs {
+		name, id, ok := strings.Cut(pair, "=")
+		chainId, err := strconv.ParseUint(strings.TrimSpace(id), 10, 64)
+		name = strings.TrimSpace(name)
+		if !ok || err != nil || name == "" {
+			log.Fatalf("environment variable CHAINS must list name=chainId pairs, got '%s'", pair)
+		}
+
+		suffix := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		chains = append(chains, ChainConfig{
+			Name:    name,
+			ChainId: chainId,
+			NodeURL: getConfigOrFail("ETH_NODE_URL_" + suffix),
+			Type:    getConfigOrDefault("CHAIN_TYPE_"+suffix, "ethereum"),
+			Traces:  getBoolConfigOrDefault("TRACES_ENABLED_"+suffix, traces),
+		})
+	}
+	return chains
+}
+
+func loadCacheConfig() CacheConfig {
+	return CacheConfig{
+		Backend:     getConfigOrDefault("CACHE_BACKEND", "memory"),
+		TTL:         getDurationConfigOrDefault("CACHE_TTL", time.Hour),
+		NegativeTTL: getDurationConfigOrDefault("CACHE_NEGATIVE_TTL", 30*time.Second),
+		MaxEntries:  int(getIntConfigOrDefault("CACHE_MAX_ENTRIES", 100_000)),
+		MaxBytes:    getIntConfigOrDefault("CACHE_MAX_BYTES", 64<<20),
+		Eviction:    getConfigOrDefault("CACHE_EVICTION", "lru"),
+		Admission:   getBoolConfigOrDefault("CACHE_ADMISSION", false),
+		RedisURL:    getConfigOrDefault("CACHE_REDIS_URL", ""),
+
+		WarmUp:        getConfigOrDefault("CACHE_WARMUP", "none"),
+		WarmUpLimit:   int(getIntConfigOrDefault("CACHE_WARMUP_LIMIT", 10_000)),
+		WarmUpTimeout: getDurationConfigOrDefault("CACHE_WARMUP_TIMEOUT", 30*time.Second),
+		SnapshotPath:  getConfigOrDefault("CACHE_SNAPSHOT_PATH", ""),
+	}
+}
+
+func loadUsageConfig() UsageConfig {
+	return UsageConfig{
+		UserRPS:        getFloatConfigOrDefault("RATE_LIMIT_USER_RPS", 10),
+		UserBurst:      int(getIntConfigOrDefault("RATE_LIMIT_USER_BURST", 20)),
+		ApiKeyRPS:      getFloatConfigOrDefault("RATE_LIMIT_API_KEY_RPS", 10),
+		ApiKeyBurst:    int(getIntConfigOrDefault("RATE_LIMIT_API_KEY_BURST", 20)),
+		AnonymousRPS:   getFloatConfigOrDefault("RATE_LIMIT_ANONYMOUS_RPS", 1),
+		AnonymousBurst: int(getIntConfigOrDefault("RATE_LIMIT_ANONYMOUS_BURST", 5)),
+
+		UserNodeCallsPerDay:      uint64(getIntConfigOrDefault("QUOTA_USER_NODE_CALLS_PER_DAY", 10_000)),
+		ApiKeyNodeCallsPerDay:    uint64(getIntConfigOrDefault("QUOTA_API_KEY_NODE_CALLS_PER_DAY", 0)),
+		AnonymousNodeCallsPerDay: uint64(getIntConfigOrDefault("QUOTA_ANONYMOUS_NODE_CALLS_PER_DAY", 1_000)),
 	}
 }
 
@@ -34,3 +229,74 @@ func getConfigOrFail(key string) string {
 	}
 	return value
 }
+
+func getConfigOrDefault(key string, defaultValue string) string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	return value
+}
+
+func getIntConfigOrDefault(key string, defaultValue int64) int64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Fatalf("environment variable %s must be an integer: %v", key, err)
+	}
+	return parsed
+}
+
+func getFloatConfigOrDefault(key string, defaultValue float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("environment variable %s must be a number: %v", key, err)
+	}
+	return parsed
+}
+
+func getDurationConfigOrDefault(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("environment variable %s must be a duration: %v", key, err)
+	}
+	return parsed
+}
+
+func getBoolConfigOrDefault(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("environment variable %s must be a boolean: %v", key, err)
+	}
+	return parsed
+}
+
+// getListConfigOrDefault reads a comma separated list, ignoring blank items
+func getListConfigOrDefault(key string, defaultValue []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
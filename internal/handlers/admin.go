@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/internal/services/admin"
+	"ethereum_fetcher/internal/services/auth"
+)
+
+type AdminHandler struct {
+	userService  auth.UserService
+	adminService admin.AdminService
+}
+
+func NewAdminHandler(userService auth.UserService, adminService admin.AdminService) AdminHandler {
+	return AdminHandler{userService: userService, adminService: adminService}
+}
+
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	users, err := h.userService.List()
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.UsersResponse{Users: users})
+}
+
+func (h *AdminHandler) SetRole(c *gin.Context) {
+	userId, ok := userIdParam(c)
+	if !ok {
+		return
+	}
+
+	var req api.SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+		return
+	}
+
+	if err := h.userService.SetRole(c.GetUint64(auth.UserClaim), userId, req.Role); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AdminHandler) DeleteUser(c *gin.Context) {
+	userId, ok := userIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.DeleteUser(c.GetUint64(auth.UserClaim), userId); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AdminHandler) CacheStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.adminService.CacheStatus())
+}
+
+func (h *AdminHandler) PurgeCache(c *gin.Context) {
+	if err := h.adminService.PurgeCache(); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AdminHandler) DbStatus(c *gin.Context) {
+	status, err := h.adminService.DbStatus()
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func userIdParam(c *gin.Context) (uint64, bool) {
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "'id' must be a number"})
+		return 0, false
+	}
+	return userId, true
+}
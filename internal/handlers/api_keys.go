@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/internal/services/auth"
+)
+
+type ApiKeyHandler struct {
+	apiKeys auth.ApiKeyService
+}
+
+func NewApiKeyHandler(apiKeys auth.ApiKeyService) ApiKeyHandler {
+	return ApiKeyHandler{apiKeys: apiKeys}
+}
+
+func (h *ApiKeyHandler) Create(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	var req api.CreateApiKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+		return
+	}
+
+	key, err := h.apiKeys.Create(userId, req)
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+func (h *ApiKeyHandler) List(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.apiKeys.List(userId)
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.ApiKeysResponse{ApiKeys: keys})
+}
+
+func (h *ApiKeyHandler) Revoke(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	keyId, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "'id' must be a number"})
+		return
+	}
+
+	if err := h.apiKeys.Revoke(userId, keyId); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,7 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -17,33 +22,188 @@ func Authenticate(authService auth.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		jwt, err := authService.Authenticate(req)
+		req.ClientIP = c.ClientIP()
+
+		tokens, err := authService.Authenticate(req)
 		if err != nil {
+			var throttled auth.ThrottledError
+			if errors.As(err, &throttled) {
+				setRetryAfter(c, throttled.RetryAfter)
+			}
 			c.JSON(toStatusCode(err), mapError(err))
 			return
 		}
 
-		c.JSON(http.StatusOK, api.AuthResponse{Token: jwt})
+		c.JSON(http.StatusOK, tokens)
 	}
 }
 
-const AuthTokenHeader = "AUTH_TOKEN"
-
-func JwtMiddleware(authService auth.AuthService) gin.HandlerFunc {
+func RefreshToken(authService auth.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString := c.GetHeader(AuthTokenHeader)
-		if tokenString == "" {
-			c.Next()
+		var req api.RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+			c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+			return
+		}
+
+		tokens, err := authService.Refresh(req.RefreshToken)
+		if err != nil {
+			c.JSON(toStatusCode(err), mapError(err))
 			return
 		}
 
-		userId, err := authService.GetUserId(tokenString)
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// Logout revokes the access token in the Authorization or AUTH_TOKEN header and, when given, the
+// refresh token in the body
+func Logout(authService auth.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req api.LogoutRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+				return
+			}
+		}
+
+		token, err := accessToken(c)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, mapError(err))
+			c.JSON(http.StatusUnauthorized, mapError(err))
+			return
+		}
+		if token == "" && req.RefreshToken == "" {
+			c.JSON(http.StatusUnauthorized, mapError(auth.NotAuthenticated))
 			return
 		}
 
-		c.Set(auth.UserClaim, userId)
+		if err := authService.Logout(token, req.RefreshToken); err != nil {
+			c.JSON(toStatusCode(err), mapError(err))
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+const (
+	// AuthTokenHeader is the legacy header for access tokens, the standard
+	// Authorization: Bearer header is preferred
+	AuthTokenHeader     = "AUTH_TOKEN"
+	AuthorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+)
+
+// RequireAuth identifies the user from an access token or an API key and
+// rejects anonymous requests
+func RequireAuth(authService auth.AuthService, apiKeys auth.ApiKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authenticate(c, authService, apiKeys) {
+			return
+		}
+		if c.GetUint64(auth.UserClaim) == 0 {
+			unauthorized(c, auth.NotAuthenticated)
+			return
+		}
+		c.Next()
+	}
+}
+
+// OptionalAuth identifies the user like RequireAuth but lets anonymous
+// requests through as user 0. Credentials that are sent have to be valid.
+func OptionalAuth(authService auth.AuthService, apiKeys auth.ApiKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authenticate(c, authService, apiKeys) {
+			c.Next()
+		}
+	}
+}
+
+// authenticate sets the user and their scopes from an API key in the
+// X-API-Key header or an access token, aborting the request when they are
+// invalid. Access tokens carry the scopes of the role of the user when they
+// were issued, API keys only those they were also created with.
+func authenticate(c *gin.Context, authService auth.AuthService, apiKeys auth.ApiKeyService) bool {
+	if key := c.GetHeader(auth.ApiKeyHeader); key != "" {
+		identity, err := apiKeys.Authenticate(key)
+		if err != nil {
+			unauthorized(c, err)
+			return false
+		}
+
+		c.Set(auth.UserClaim, identity.UserId)
+		c.Set(auth.ApiKeyClaim, identity.KeyId)
+		c.Set(auth.ScopesClaim, identity.Scopes)
+		return true
+	}
+
+	tokenString, err := accessToken(c)
+	if err != nil {
+		unauthorized(c, err)
+		return false
+	}
+	if tokenString == "" {
+		return true
+	}
+
+	identity, err := authService.Identify(tokenString)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.AbortWithStatusJSON(toStatusCode(err), mapError(err))
+		return false
+	}
+
+	c.Set(auth.UserClaim, identity.UserId)
+	c.Set(auth.ScopesClaim, identity.Scopes)
+	return true
+}
+
+// accessToken reads the token from the Authorization header or, failing
+// that, the legacy AUTH_TOKEN header. It is empty for anonymous requests.
+func accessToken(c *gin.Context) (api.AuthToken, error) {
+	authorization := c.GetHeader(AuthorizationHeader)
+	if authorization == "" {
+		return c.GetHeader(AuthTokenHeader), nil
+	}
+
+	// the scheme is case insensitive (RFC 7235)
+	if len(authorization) < len(bearerPrefix) || !strings.EqualFold(authorization[:len(bearerPrefix)], bearerPrefix) {
+		return "", auth.InvalidToken
+	}
+	token := strings.TrimSpace(authorization[len(bearerPrefix):])
+	if token == "" {
+		return "", auth.InvalidToken
+	}
+	return token, nil
+}
+
+// setRetryAfter tells the client in whole seconds when to try again
+func setRetryAfter(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.FormatInt(int64(math.Ceil(wait.Seconds())), 10))
+}
+
+func unauthorized(c *gin.Context, err error) {
+	c.Header("WWW-Authenticate", "Bearer")
+	c.AbortWithStatusJSON(http.StatusUnauthorized, mapError(err))
+}
+
+// RequireScope rejects authenticated requests lacking the scope. Anonymous
+// requests let through by OptionalAuth are left to the handler.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetUint64(auth.UserClaim) != 0 && !auth.HasScope(c.GetStringSlice(auth.ScopesClaim), scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, mapError(auth.InsufficientScope))
+			return
+		}
 		c.Next()
 	}
 }
+
+// Jwks serves the public signing keys, verifiers may cache them for a while
+func Jwks(authService auth.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, authService.Jwks())
+	}
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/internal/services/collections"
+	"ethereum_fetcher/internal/services/transactions"
+)
+
+type CollectionHandler struct {
+	collections collections.CollectionService
+	txService   transactions.TxnService
+}
+
+func NewCollectionHandler(collections collections.CollectionService, txService transactions.TxnService) CollectionHandler {
+	return CollectionHandler{collections: collections, txService: txService}
+}
+
+func (h *CollectionHandler) Create(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	var req api.CreateCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+		return
+	}
+
+	collection, err := h.collections.Create(userId, req)
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, collection)
+}
+
+func (h *CollectionHandler) List(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	collections, err := h.collections.List(userId)
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.CollectionsResponse{Collections: collections})
+}
+
+func (h *CollectionHandler) Delete(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	collectionId, ok := collectionIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.collections.Delete(userId, collectionId); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *CollectionHandler) AddTransactions(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	collectionId, ok := collectionIdParam(c)
+	if !ok {
+		return
+	}
+	chainId, ok := lookupChain(c, h.txService)
+	if !ok {
+		return
+	}
+
+	var req api.AddToCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+		return
+	}
+
+	collection, err := h.collections.AddTransactions(userId, collectionId, chainId, req.TransactionHashes)
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+func (h *CollectionHandler) RemoveTransaction(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	collectionId, ok := collectionIdParam(c)
+	if !ok {
+		return
+	}
+	chainId, ok := lookupChain(c, h.txService)
+	if !ok {
+		return
+	}
+
+	if err := h.collections.RemoveTransaction(userId, collectionId, chainId, c.Param("hash")); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *CollectionHandler) SetTags(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	chainId, ok := lookupChain(c, h.txService)
+	if !ok {
+		return
+	}
+
+	var req api.SetTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+		return
+	}
+
+	if err := h.collections.SetTags(userId, chainId, c.Param("hash"), req.Tags); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *CollectionHandler) SetNote(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	chainId, ok := lookupChain(c, h.txService)
+	if !ok {
+		return
+	}
+
+	var req api.SetNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+		return
+	}
+
+	if err := h.collections.SetNote(userId, chainId, c.Param("hash"), req.Note); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func collectionIdParam(c *gin.Context) (uint64, bool) {
+	collectionId, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "'id' must be a number"})
+		return 0, false
+	}
+	return collectionId, true
+}
@@ -1,38 +1,166 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"ethereum_fetcher/api"
 	"ethereum_fetcher/internal/services/auth"
+	"ethereum_fetcher/internal/services/collections"
+	"ethereum_fetcher/internal/services/orgs"
+	"ethereum_fetcher/internal/services/shares"
 	txnerrors "ethereum_fetcher/internal/services/transactions/types"
+	"ethereum_fetcher/internal/services/usage"
 )
 
 func mapError(err error) api.Error {
+	var replacedErr txnerrors.ReplacedError
+	if errors.As(err, &replacedErr) {
+		return api.Error{Msg: err.Error(), ReplacedBy: replacedErr.Replacements}
+	}
 	return api.Error{Msg: err.Error()}
 }
 
 func toStatusCode(err error) int {
 	// Authentication Errors
-	if err == auth.InvalidToken || err == auth.NoSubject {
+	if err == auth.InvalidToken || err == auth.NoSubject || err == auth.NotAuthenticated ||
+		err == auth.ExpiredToken || err == auth.RevokedToken || err == auth.InvalidRefreshToken ||
+		err == auth.InvalidApiKey || err == auth.InvalidCredentials {
+		return http.StatusUnauthorized
+	}
+	var throttledErr auth.ThrottledError
+	if errors.As(err, &throttledErr) {
+		return http.StatusTooManyRequests
+	}
+
+	// Sign-In with Ethereum Errors
+	if err == auth.SiweDomainMismatch || err == auth.SiweMessageExpired || err == auth.InvalidSiweSignature ||
+		err == auth.InvalidSiweNonce || err == auth.AddressNotLinked {
 		return http.StatusUnauthorized
 	}
-	if err == auth.InvalidSignature {
+	var messageErr auth.SiweMessageError
+	if errors.As(err, &messageErr) {
+		return http.StatusBadRequest
+	}
+	if err == auth.SiweDisabled {
+		return http.StatusForbidden
+	}
+	if err == auth.InvalidSignature || err == auth.NotAnAdmin || err == auth.InsufficientScope {
 		return http.StatusForbidden
 	}
-	if err == auth.TokenSigningFailed {
+
+	// API Key Errors
+	if err == auth.InvalidApiKeyName || err == auth.InvalidApiKeyExpiry || err == auth.InvalidScope ||
+		err == auth.TooManyApiKeys {
+		return http.StatusBadRequest
+	}
+	if err == auth.ApiKeyNotFound {
+		return http.StatusNotFound
+	}
+	if err == auth.TokenSigningFailed || err == auth.TokenStoreFailed {
 		return http.StatusInternalServerError
 	}
 
 	// User Errors
-	if err == auth.UsernameNotFound || err == auth.InvalidPassword {
+	if err == auth.UsernameNotFound || err == auth.InvalidPassword || err == auth.InvalidUsername ||
+		err == auth.InvalidRole || err == auth.CannotModifySelf || err == auth.SiweConfirmationRequired {
+		return http.StatusBadRequest
+	}
+	var policyErr auth.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return http.StatusBadRequest
+	}
+	if err == auth.UsernameTaken || err == auth.AddressTaken {
+		return http.StatusConflict
+	}
+	if err == auth.RegistrationDisabled {
+		return http.StatusForbidden
+	}
+	if err == auth.UserNotFound {
+		return http.StatusNotFound
+	}
+
+	// Organization Errors
+	if err == orgs.InvalidOrgName || err == orgs.InvalidOrgRole {
+		return http.StatusBadRequest
+	}
+	if err == orgs.OrgNameTaken || err == orgs.AlreadyInOrg || err == orgs.AlreadyInvited ||
+		err == orgs.LastOrgAdmin {
+		return http.StatusConflict
+	}
+	if err == orgs.OrgNotFound || err == orgs.MemberNotFound || err == orgs.UserNotFound ||
+		err == orgs.InviteNotFound {
+		return http.StatusNotFound
+	}
+	if err == orgs.NotAnOrgAdmin {
+		return http.StatusForbidden
+	}
+
+	// Collection Errors
+	if err == collections.InvalidCollectionName || err == collections.TooManyCollections || err == collections.InvalidTag ||
+		err == collections.TooManyTags || err == collections.NoteTooLong {
+		return http.StatusBadRequest
+	}
+	if err == collections.CollectionNameTaken {
+		return http.StatusConflict
+	}
+	if err == collections.NotInOrganization || err == collections.NotCollectionOwner {
+		return http.StatusForbidden
+	}
+	if err == collections.CollectionNotFound || err == collections.NotInCollection || err == collections.NotInHistory {
+		return http.StatusNotFound
+	}
+
+	// Share Errors
+	if err == shares.InvalidShare || err == shares.TooManyHashes || err == shares.InvalidExpiry {
+		return http.StatusBadRequest
+	}
+	if err == shares.ShareNotFound || err == shares.InvalidShareToken {
+		return http.StatusNotFound
+	}
+	if err == shares.ShareExpired {
+		return http.StatusGone
+	}
+	if err == shares.SharingDisabled {
+		return http.StatusForbidden
+	}
+
+	// Usage Errors
+	var limitErr usage.LimitError
+	if errors.As(err, &limitErr) {
+		return http.StatusTooManyRequests
+	}
+	if err == usage.InvalidUsagePeriod {
 		return http.StatusBadRequest
 	}
 
 	// Transaction Errors
-	if err == txnerrors.FailedToFetchTransaction {
+	if err == txnerrors.FailedToFetchTransaction || err == txnerrors.TransactionPending {
+		return http.StatusNotFound
+	}
+	var replacedErr txnerrors.ReplacedError
+	if errors.As(err, &replacedErr) {
+		return http.StatusNotFound
+	}
+
+	if err == txnerrors.BlockNotFound {
 		return http.StatusNotFound
 	}
+	if err == txnerrors.FailedToFetchTrace || err == txnerrors.FailedToFetchBlock {
+		return http.StatusBadGateway
+	}
+	if err == txnerrors.TracesDisabled {
+		return http.StatusNotImplemented
+	}
+
+	if err == txnerrors.InvalidHistoryScope || err == txnerrors.UnknownChain || err == txnerrors.InvalidTxnHash ||
+		err == txnerrors.InvalidBlockId || err == txnerrors.InvalidEmbed || err == txnerrors.UnboundedEmbed ||
+		err == txnerrors.InvalidMinedPeriod {
+		return http.StatusBadRequest
+	}
+	if err == txnerrors.NotInOrganization {
+		return http.StatusForbidden
+	}
 
 	// RLP Decoding Errors
 	if err == txnerrors.InvalidHexEncoding ||
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ethereum_fetcher/api"
+)
+
+type ReadinessCheck interface {
+	Ready() bool
+}
+
+// Readiness answers 503 until the check passes so that load balancers hold
+// traffic back while the service is warming up
+func Readiness(check ReadinessCheck) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !check.Ready() {
+			c.JSON(http.StatusServiceUnavailable, api.Status{Status: "warming up"})
+			return
+		}
+		c.JSON(http.StatusOK, api.Status{Status: "ready"})
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/internal/services/orgs"
+)
+
+type OrgHandler struct {
+	orgService orgs.OrgService
+}
+
+func NewOrgHandler(orgService orgs.OrgService) OrgHandler {
+	return OrgHandler{orgService: orgService}
+}
+
+func (h *OrgHandler) Create(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	var req api.CreateOrgRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+		return
+	}
+
+	org, err := h.orgService.Create(userId, req)
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+func (h *OrgHandler) Get(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	org, err := h.orgService.Get(userId)
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+func (h *OrgHandler) Delete(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.orgService.Delete(userId); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *OrgHandler) Invite(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	var req api.InviteOrgMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+		return
+	}
+
+	invite, err := h.orgService.Invite(userId, req)
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+// Invites lists the invites the user received
+func (h *OrgHandler) Invites(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	invites, err := h.orgService.Invites(userId)
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.OrgInvitesResponse{Invites: invites})
+}
+
+func (h *OrgHandler) AcceptInvite(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	inviteId, ok := inviteIdParam(c)
+	if !ok {
+		return
+	}
+
+	org, err := h.orgService.AcceptInvite(userId, inviteId)
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// RemoveInvite declines an invite, admins use it to withdraw the invites of
+// their organization
+func (h *OrgHandler) RemoveInvite(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	inviteId, ok := inviteIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.orgService.RemoveInvite(userId, inviteId); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *OrgHandler) SetMemberRole(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	memberId, ok := userIdParam(c)
+	if !ok {
+		return
+	}
+
+	var req api.SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+		return
+	}
+
+	if err := h.orgService.SetMemberRole(userId, memberId, req.Role); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveMember removes a member, members remove themselves to leave
+func (h *OrgHandler) RemoveMember(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	memberId, ok := userIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.orgService.RemoveMember(userId, memberId); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func inviteIdParam(c *gin.Context) (uint64, bool) {
+	inviteId, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "'id' must be a number"})
+		return 0, false
+	}
+	return inviteId, true
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/internal/services/shares"
+	"ethereum_fetcher/internal/services/transactions"
+)
+
+type ShareHandler struct {
+	shares    shares.ShareService
+	txService transactions.TxnService
+}
+
+func NewShareHandler(shares shares.ShareService, txService transactions.TxnService) ShareHandler {
+	return ShareHandler{shares: shares, txService: txService}
+}
+
+func (h *ShareHandler) Create(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	chainId, ok := lookupChain(c, h.txService)
+	if !ok {
+		return
+	}
+
+	var req api.CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+		return
+	}
+
+	share, err := h.shares.Create(userId, chainId, req)
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, share)
+}
+
+func (h *ShareHandler) List(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	shares, err := h.shares.List(userId)
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.SharesResponse{Shares: shares})
+}
+
+func (h *ShareHandler) Revoke(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	shareId, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "'id' must be a number"})
+		return
+	}
+
+	if err := h.shares.Revoke(userId, shareId); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Resolve serves the transactions behind a share link to anyone holding it,
+// without saying whose they are
+func (h *ShareHandler) Resolve(c *gin.Context) {
+	txns, err := h.shares.Resolve(c.Param("token"))
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.TransactionResponse{Transactions: &txns})
+}
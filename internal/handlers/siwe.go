@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/internal/services/auth"
+)
+
+func SiweNonce(siweService auth.SiweService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		nonce, err := siweService.Nonce()
+		if err != nil {
+			c.JSON(toStatusCode(err), mapError(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, nonce)
+	}
+}
+
+// SiweSignIn exchanges a signed EIP-4361 message for the same tokens as
+// Authenticate
+func SiweSignIn(siweService auth.SiweService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req api.SiweRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Message == "" || req.Signature == "" {
+			c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+			return
+		}
+
+		tokens, err := siweService.SignIn(req)
+		if err != nil {
+			c.JSON(toStatusCode(err), mapError(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// SiweLink links the address that signed the message to the signed in user
+func SiweLink(siweService auth.SiweService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authenticatedUser(c)
+		if !ok {
+			return
+		}
+
+		var req api.SiweRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Message == "" || req.Signature == "" {
+			c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+			return
+		}
+
+		user, err := siweService.Link(userId, req)
+		if err != nil {
+			c.JSON(toStatusCode(err), mapError(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, user)
+	}
+}
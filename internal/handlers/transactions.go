@@ -2,20 +2,26 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"ethereum_fetcher/api"
 	"ethereum_fetcher/internal/services/auth"
+	"ethereum_fetcher/internal/services/collections"
 	"ethereum_fetcher/internal/services/transactions"
+	types "ethereum_fetcher/internal/services/transactions/types"
 
 	"github.com/gin-gonic/gin"
 )
 
 type TxnHandler struct {
-	txService transactions.TxnService
+	txService   transactions.TxnService
+	collections collections.CollectionService
 }
 
-func NewTxnHandler(txService transactions.TxnService) TxnHandler {
-	return TxnHandler{txService: txService}
+func NewTxnHandler(txService transactions.TxnService, collections collections.CollectionService) TxnHandler {
+	return TxnHandler{txService: txService, collections: collections}
 }
 
 func (h *TxnHandler) FetchTransactions(c *gin.Context) {
@@ -26,8 +32,20 @@ func (h *TxnHandler) FetchTransactions(c *gin.Context) {
 		return
 	}
 
+	chainId, ok := lookupChain(c, h.txService)
+	if !ok {
+		return
+	}
+	blocks, ok := embedBlocks(c)
+	if !ok {
+		return
+	}
+
 	user := c.GetUint64(auth.UserClaim)
-	txns, err := h.txService.ByHashes(hashes, user)
+	txns, err := h.txService.ByHashes(chainId, hashes, user, fetchOptions(c))
+	if err == nil && blocks {
+		err = h.txService.EmbedBlocks(txns, fetchOptions(c))
+	}
 	response(&txns, err)(c)
 }
 
@@ -38,26 +56,229 @@ func (h *TxnHandler) FetchTransactionsByRLP(c *gin.Context) {
 		return
 	}
 
+	chainId, ok := lookupChain(c, h.txService)
+	if !ok {
+		return
+	}
+	blocks, ok := embedBlocks(c)
+	if !ok {
+		return
+	}
+
 	user := c.GetUint64(auth.UserClaim)
-	txns, err := h.txService.FromRLPHex(rlpHex, user)
+	txns, err := h.txService.FromRLPHex(chainId, rlpHex, user, fetchOptions(c))
+	if err == nil && blocks {
+		err = h.txService.EmbedBlocks(txns, fetchOptions(c))
+	}
 	response(&txns, err)(c)
 }
 
+// Trace returns the call tree of the transaction in the path and the ether
+// its internal calls moved
+func (h *TxnHandler) Trace(c *gin.Context) {
+	chainId, ok := lookupChain(c, h.txService)
+	if !ok {
+		return
+	}
+
+	user := c.GetUint64(auth.UserClaim)
+	trace, err := h.txService.Trace(chainId, c.Param("rlphex"), user, fetchOptions(c))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, trace)
+}
+
+// Block returns the block in the path, by its number or hash
+func (h *TxnHandler) Block(c *gin.Context) {
+	chainId, ok := lookupChain(c, h.txService)
+	if !ok {
+		return
+	}
+
+	block, err := h.txService.Block(chainId, c.Param("numberOrHash"), fetchOptions(c))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, block)
+}
+
 func (h *TxnHandler) AllTransactions(c *gin.Context) {
-	txns, err := h.txService.All()
+	chainId, ok := h.listChain(c)
+	if !ok {
+		return
+	}
+	period, ok := minedPeriod(c)
+	if !ok {
+		return
+	}
+	blocks, ok := embedBlocks(c)
+	if !ok {
+		return
+	}
+	// every stored transaction may be in a different block
+	if blocks && period.From == 0 && period.To == 0 {
+		response(nil, types.UnboundedEmbed)(c)
+		return
+	}
+
+	txns, err := h.txService.All(chainId, period, fetchOptions(c))
+	if err == nil && blocks {
+		err = h.txService.EmbedBlocks(txns, fetchOptions(c))
+	}
 	response(&txns, err)(c)
 }
 
+func (h *TxnHandler) Chains(c *gin.Context) {
+	c.JSON(http.StatusOK, api.ChainsResponse{Chains: h.txService.Chains()})
+}
+
+// ForUser lists the lookups of the user, or with scope=org those of their
+// organization, with the tags and notes of the user. They can be narrowed down
+// to a chain, a collection or a tag of the user.
 func (h *TxnHandler) ForUser(c *gin.Context) {
+	query, ok := historyQuery(c)
+	if !ok {
+		return
+	}
+	if query.ChainId, ok = h.listChain(c); !ok {
+		return
+	}
+	blocks, ok := embedBlocks(c)
+	if !ok {
+		return
+	}
+
+	query.Fetch = fetchOptions(c)
+
 	user := c.GetUint64(auth.UserClaim)
-	txns, err := h.txService.ForUser(user)
+	txns, err := h.txService.ForUser(user, query)
+	if err == nil {
+		err = h.collections.Annotate(user, txns)
+	}
+	if err == nil && blocks {
+		err = h.txService.EmbedBlocks(txns, fetchOptions(c))
+	}
 	response(&txns, err)(c)
 }
 
+func historyQuery(c *gin.Context) (types.HistoryQuery, bool) {
+	query := types.HistoryQuery{
+		Scope:         types.HistoryScope(c.DefaultQuery("scope", string(types.UserHistory))),
+		HistoryFilter: types.HistoryFilter{Tag: strings.ToLower(c.Query("tag"))},
+	}
+	if query.Scope != types.UserHistory && query.Scope != types.OrgHistory {
+		response(nil, types.InvalidHistoryScope)(c)
+		return query, false
+	}
+
+	if collection := c.Query("collection"); collection != "" {
+		id, err := strconv.ParseUint(collection, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, api.Error{Msg: "'collection' must be a number"})
+			return query, false
+		}
+		query.CollectionId = id
+	}
+
+	period, ok := minedPeriod(c)
+	query.MinedPeriod = period
+	return query, ok
+}
+
+// minedPeriod reads the from and to query parameters, days or RFC 3339
+// times. A day as to includes the whole day.
+func minedPeriod(c *gin.Context) (types.MinedPeriod, bool) {
+	var period types.MinedPeriod
+	var ok bool
+	if from := c.Query("from"); from != "" {
+		if period.From, ok = parseMinedTime(from, false); !ok {
+			response(nil, types.InvalidMinedPeriod)(c)
+			return period, false
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if period.To, ok = parseMinedTime(to, true); !ok {
+			response(nil, types.InvalidMinedPeriod)(c)
+			return period, false
+		}
+	}
+	if period.To != 0 && period.From > period.To {
+		response(nil, types.InvalidMinedPeriod)(c)
+		return period, false
+	}
+	return period, true
+}
+
+// parseMinedTime is the time in seconds since the epoch, the last second of
+// a day with endOfDay
+func parseMinedTime(value string, endOfDay bool) (uint64, bool) {
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		if endOfDay {
+			day = day.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		return uint64(max(day.Unix(), 0)), true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil && t.Unix() >= 0 {
+		return uint64(t.Unix()), true
+	}
+	return 0, false
+}
+
+// lookupChain is the chain named in the path or the chain query parameter,
+// the default chain when there is none
+func lookupChain(c *gin.Context, txService transactions.TxnService) (uint64, bool) {
+	chainId, err := txService.ChainId(chainParam(c))
+	if err != nil {
+		response(nil, err)(c)
+		return 0, false
+	}
+	return chainId, true
+}
+
+// listChain narrows a listing down to the chain named in the path or the
+// chain query parameter, 0 lists every chain
+func (h *TxnHandler) listChain(c *gin.Context) (uint64, bool) {
+	if chainParam(c) == "" {
+		return 0, true
+	}
+	return lookupChain(c, h.txService)
+}
+
+func chainParam(c *gin.Context) string {
+	if chain := c.Param("chain"); chain != "" {
+		return chain
+	}
+	return c.Query("chain")
+}
+
+// embedBlocks reads the embed query parameter, true for embed=block. It is
+// read before the lookup so an invalid one costs nothing.
+func embedBlocks(c *gin.Context) (bool, bool) {
+	switch c.Query("embed") {
+	case "":
+		return false, true
+	case "block":
+		return true, true
+	default:
+		response(nil, types.InvalidEmbed)(c)
+		return false, false
+	}
+}
+
+// fetchOptions reads the optional lookup flags from the query string and the
+// node call budget of the caller
+func fetchOptions(c *gin.Context) types.FetchOptions {
+	bypass, _ := strconv.ParseBool(c.Query("bypassNegativeCache"))
+	return types.FetchOptions{BypassNegativeCache: bypass, Budget: nodeCallBudget(c)}
+}
+
 func response(txns *[]api.Transaction, err error) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if err != nil {
-			c.JSON(toStatusCode(err), mapError(err))
+			abortWithError(c, err)
 			return
 		}
 
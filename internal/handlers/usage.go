@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ethereum_fetcher/internal/services/auth"
+	types "ethereum_fetcher/internal/services/transactions/types"
+	"ethereum_fetcher/internal/services/usage"
+)
+
+// budgetKey holds the node call budget of the request set by RateLimit
+const budgetKey = "nodeCallBudget"
+
+// RateLimit accounts the request to the API key, the user or the client IP
+// of anonymous requests and rejects it once their rate limit is used up. It
+// has to run after the request is authenticated.
+func RateLimit(usageService usage.UsageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := usage.Principal{
+			UserId:   c.GetUint64(auth.UserClaim),
+			ApiKeyId: c.GetUint64(auth.ApiKeyClaim),
+			IP:       c.ClientIP(),
+		}
+
+		if err := usageService.Allow(principal); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set(budgetKey, usageService.Budget(principal))
+		c.Next()
+	}
+}
+
+// nodeCallBudget is nil for routes without rate limiting
+func nodeCallBudget(c *gin.Context) types.NodeCallBudget {
+	budget, _ := c.Get(budgetKey)
+	nodeCalls, _ := budget.(types.NodeCallBudget)
+	return nodeCalls
+}
+
+// abortWithError responds with the error, telling the client when to retry
+// requests over a limit
+func abortWithError(c *gin.Context, err error) {
+	var limitErr usage.LimitError
+	if errors.As(err, &limitErr) {
+		setRetryAfter(c, limitErr.RetryAfter)
+	}
+	c.AbortWithStatusJSON(toStatusCode(err), mapError(err))
+}
+
+type UsageHandler struct {
+	usageService usage.UsageService
+}
+
+func NewUsageHandler(usageService usage.UsageService) UsageHandler {
+	return UsageHandler{usageService: usageService}
+}
+
+// ForUser reports the usage of the user per day and API key, the period
+// defaults to the current month
+func (h *UsageHandler) ForUser(c *gin.Context) {
+	report, err := h.usageService.ForUser(c.GetUint64(auth.UserClaim), c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Report sums up the usage of every user for billing
+func (h *UsageHandler) Report(c *gin.Context) {
+	report, err := h.usageService.Report(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/internal/services/auth"
+)
+
+type UserHandler struct {
+	userService auth.UserService
+}
+
+func NewUserHandler(userService auth.UserService) UserHandler {
+	return UserHandler{userService: userService}
+}
+
+func (h *UserHandler) Register(c *gin.Context) {
+	var req api.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+		return
+	}
+
+	user, err := h.userService.Register(req)
+	if err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	var req api.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+		return
+	}
+
+	if err := h.userService.ChangePassword(userId, req); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UserHandler) Delete(c *gin.Context) {
+	userId, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	var req api.DeleteUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.Error{Msg: "Invalid request"})
+		return
+	}
+
+	if err := h.userService.Delete(userId, req); err != nil {
+		c.JSON(toStatusCode(err), mapError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// authenticatedUser returns the user set by RequireAuth, answering 401 for
+// anonymous requests that got past OptionalAuth
+func authenticatedUser(c *gin.Context) (uint64, bool) {
+	userId := c.GetUint64(auth.UserClaim)
+	if userId == 0 {
+		c.JSON(http.StatusUnauthorized, mapError(auth.NotAuthenticated))
+		return 0, false
+	}
+	return userId, true
+}
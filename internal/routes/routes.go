@@ -5,19 +5,101 @@ import (
 
 	"ethereum_fetcher/internal/handlers"
 	"ethereum_fetcher/internal/services"
+	"ethereum_fetcher/internal/services/auth"
 )
 
 func SetupRoutes(services *services.Services, r *gin.Engine) {
 	r.Use(gin.Recovery())
 
+	r.GET("/ready", handlers.Readiness(services.Warmer))
+	r.GET("/.well-known/jwks.json", handlers.Jwks(services.Auth))
+
 	r.POST("/lime/authenticate", handlers.Authenticate(services.Auth))
+	r.POST("/lime/token/refresh", handlers.RefreshToken(services.Auth))
+	r.POST("/lime/logout", handlers.Logout(services.Auth))
+	r.GET("/lime/siwe/nonce", handlers.SiweNonce(services.Siwe))
+	r.POST("/lime/siwe/verify", handlers.SiweSignIn(services.Siwe))
+
+	// anonymous callers can look up transactions, everything else needs a user
+	optionalAuth := handlers.OptionalAuth(services.Auth, services.ApiKeys)
+	requireAuth := handlers.RequireAuth(services.Auth, services.ApiKeys)
+	fetchScope := handlers.RequireScope(auth.ScopeFetchTransactions)
+	readScope := handlers.RequireScope(auth.ScopeReadTransactions)
+	manageScope := handlers.RequireScope(auth.ScopeManageAccount)
+	readAllScope := handlers.RequireScope(auth.ScopeReadAllTransactions)
+	usersScope := handlers.RequireScope(auth.ScopeManageUsers)
+	maintenanceScope := handlers.RequireScope(auth.ScopeMaintenance)
+	rateLimit := handlers.RateLimit(services.Usage)
+
+	txHandler := handlers.NewTxnHandler(services.Tx, services.Collections)
+	userHandler := handlers.NewUserHandler(services.Users)
+	apiKeyHandler := handlers.NewApiKeyHandler(services.ApiKeys)
+	adminHandler := handlers.NewAdminHandler(services.Users, services.Admin)
+	usageHandler := handlers.NewUsageHandler(services.Usage)
+	orgHandler := handlers.NewOrgHandler(services.Orgs)
+	collectionHandler := handlers.NewCollectionHandler(services.Collections, services.Tx)
+	shareHandler := handlers.NewShareHandler(services.Shares, services.Tx)
+
+	r.POST("/lime/users", userHandler.Register)
+	r.PUT("/lime/users/me/password", requireAuth, manageScope, userHandler.ChangePassword)
+	r.DELETE("/lime/users/me", requireAuth, manageScope, userHandler.Delete)
+	r.PUT("/lime/users/me/address", requireAuth, manageScope, handlers.SiweLink(services.Siwe))
+
+	r.POST("/lime/api-keys", requireAuth, manageScope, apiKeyHandler.Create)
+	r.GET("/lime/api-keys", requireAuth, manageScope, apiKeyHandler.List)
+	r.DELETE("/lime/api-keys/:id", requireAuth, manageScope, apiKeyHandler.Revoke)
+
+	r.POST("/lime/orgs", requireAuth, manageScope, orgHandler.Create)
+	r.GET("/lime/orgs/me", requireAuth, manageScope, orgHandler.Get)
+	r.DELETE("/lime/orgs/me", requireAuth, manageScope, orgHandler.Delete)
+	r.POST("/lime/orgs/me/invites", requireAuth, manageScope, orgHandler.Invite)
+	r.GET("/lime/orgs/invites", requireAuth, manageScope, orgHandler.Invites)
+	r.POST("/lime/orgs/invites/:id/accept", requireAuth, manageScope, orgHandler.AcceptInvite)
+	r.DELETE("/lime/orgs/invites/:id", requireAuth, manageScope, orgHandler.RemoveInvite)
+	r.PUT("/lime/orgs/me/members/:id/role", requireAuth, manageScope, orgHandler.SetMemberRole)
+	r.DELETE("/lime/orgs/me/members/:id", requireAuth, manageScope, orgHandler.RemoveMember)
+
+	r.POST("/lime/collections", requireAuth, readScope, collectionHandler.Create)
+	r.GET("/lime/collections", requireAuth, readScope, collectionHandler.List)
+	r.DELETE("/lime/collections/:id", requireAuth, readScope, collectionHandler.Delete)
+	r.POST("/lime/collections/:id/transactions", requireAuth, readScope, collectionHandler.AddTransactions)
+	r.DELETE("/lime/collections/:id/transactions/:hash", requireAuth, readScope, collectionHandler.RemoveTransaction)
+	r.PUT("/lime/my/:hash/tags", requireAuth, readScope, collectionHandler.SetTags)
+	r.PUT("/lime/my/:hash/note", requireAuth, readScope, collectionHandler.SetNote)
+
+	r.POST("/lime/shares", requireAuth, readScope, shareHandler.Create)
+	r.GET("/lime/shares", requireAuth, readScope, shareHandler.List)
+	r.DELETE("/lime/shares/:id", requireAuth, readScope, shareHandler.Revoke)
+	// share links are public, whoever holds one is rate limited by IP
+	r.GET("/lime/shared/:token", rateLimit, shareHandler.Resolve)
+
+	r.GET("/lime/eth", optionalAuth, fetchScope, rateLimit, txHandler.FetchTransactions)
+	r.GET("/lime/eth/:rlphex", optionalAuth, fetchScope, rateLimit, txHandler.FetchTransactionsByRLP)
+	// the path parameter is a transaction hash here, gin needs it named alike
+	r.GET("/lime/eth/:rlphex/trace", optionalAuth, fetchScope, rateLimit, txHandler.Trace)
+	r.GET("/lime/blocks/:numberOrHash", optionalAuth, fetchScope, rateLimit, txHandler.Block)
+	r.GET("/lime/all", requireAuth, readAllScope, rateLimit, txHandler.AllTransactions)
+	r.GET("/lime/my", requireAuth, readScope, rateLimit, txHandler.ForUser)
 
-	authMiddleware := handlers.JwtMiddleware(services.Auth)
-	txHandler := handlers.NewTxnHandler(services.Tx)
+	// every chain can also be named in the path instead of the chain query
+	// parameter
+	r.GET("/lime/chains", txHandler.Chains)
+	r.GET("/lime/chains/:chain/eth", optionalAuth, fetchScope, rateLimit, txHandler.FetchTransactions)
+	r.GET("/lime/chains/:chain/eth/:rlphex", optionalAuth, fetchScope, rateLimit, txHandler.FetchTransactionsByRLP)
+	r.GET("/lime/chains/:chain/eth/:rlphex/trace", optionalAuth, fetchScope, rateLimit, txHandler.Trace)
+	r.GET("/lime/chains/:chain/blocks/:numberOrHash", optionalAuth, fetchScope, rateLimit, txHandler.Block)
+	r.GET("/lime/chains/:chain/all", requireAuth, readAllScope, rateLimit, txHandler.AllTransactions)
+	r.GET("/lime/chains/:chain/my", requireAuth, readScope, rateLimit, txHandler.ForUser)
+	r.PUT("/lime/chains/:chain/my/:hash/tags", requireAuth, readScope, collectionHandler.SetTags)
+	r.PUT("/lime/chains/:chain/my/:hash/note", requireAuth, readScope, collectionHandler.SetNote)
 
-	r.GET("/lime/eth", authMiddleware, txHandler.FetchTransactions)
-	r.GET("/lime/eth/:rlphex", authMiddleware, txHandler.FetchTransactionsByRLP)
-	r.GET("/lime/all", txHandler.AllTransactions)
-	r.GET("/lime/my", authMiddleware, txHandler.ForUser)
+	r.GET("/lime/usage", requireAuth, manageScope, usageHandler.ForUser)
 
+	r.GET("/lime/admin/users", requireAuth, usersScope, adminHandler.ListUsers)
+	r.PUT("/lime/admin/users/:id/role", requireAuth, usersScope, adminHandler.SetRole)
+	r.DELETE("/lime/admin/users/:id", requireAuth, usersScope, adminHandler.DeleteUser)
+	r.GET("/lime/admin/cache", requireAuth, maintenanceScope, adminHandler.CacheStatus)
+	r.DELETE("/lime/admin/cache", requireAuth, maintenanceScope, adminHandler.PurgeCache)
+	r.GET("/lime/admin/db", requireAuth, maintenanceScope, adminHandler.DbStatus)
+	r.GET("/lime/admin/usage", requireAuth, usersScope, usageHandler.Report)
 }
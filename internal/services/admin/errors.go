@@ -0,0 +1,16 @@
+package admin
+
+import "ethereum_fetcher/internal/services/errors"
+
+type AdminError struct {
+	errors.ServiceError
+}
+
+func adminError(msg string) AdminError {
+	return AdminError{errors.NewServiceError(msg)}
+}
+
+var (
+	CachePurgeFailed = adminError("failed to purge the cache")
+	DbStatusFailed   = adminError("failed to read the database status")
+)
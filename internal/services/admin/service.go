@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/migrations"
+	"ethereum_fetcher/internal/services/transactions"
+	"ethereum_fetcher/pkg/logging"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// countedTables are reported with their row counts in the database status
+var countedTables = []string{"users", "transactions", "user_transactions", "api_keys", "refresh_tokens", "auth_failures", "usage_records", "organizations", "collections", "share_links", "transaction_traces", "blocks"}
+
+// AdminService exposes cache and database maintenance to admins
+type AdminService interface {
+	CacheStatus() api.CacheStatus
+	PurgeCache() error
+	DbStatus() (api.DbStatus, error)
+}
+
+type impl struct {
+	db           *gorm.DB
+	migrator     *migrations.Migrator
+	chains       []transactions.Chain
+	cacheBackend string
+	logger       *logrus.Logger
+}
+
+// NewAdminService reports on and purges the caches of the chains
+func NewAdminService(db *gorm.DB, chains []transactions.Chain, cacheBackend string) (AdminService, error) {
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return nil, err
+	}
+	return &impl{db: db, migrator: migrator, chains: chains, cacheBackend: cacheBackend, logger: logging.New()}, nil
+}
+
+func (s *impl) CacheStatus() api.CacheStatus {
+	status := api.CacheStatus{Backend: s.cacheBackend}
+	for _, chain := range s.chains {
+		reporter, ok := chain.Cache.(transactions.StatsReporter)
+		if !ok {
+			continue
+		}
+		if status.Stats == nil {
+			status.Stats = &api.CacheStats{}
+		}
+		stats := reporter.Stats()
+		status.Stats.Hits += stats.Hits
+		status.Stats.Misses += stats.Misses
+		status.Stats.Evictions += stats.Evictions
+		status.Stats.Rejections += stats.Rejections
+		status.Stats.Entries += stats.Entries
+		status.Stats.Bytes += stats.Bytes
+	}
+	return status
+}
+
+func (s *impl) PurgeCache() error {
+	for _, chain := range s.chains {
+		if err := chain.Cache.Purge(); err != nil {
+			s.logger.Errorf("failed to purge the cache of chain '%s':  %v", chain.Name, err)
+			return CachePurgeFailed
+		}
+	}
+	s.logger.Infof("Purged the '%s' cache", s.cacheBackend)
+	return nil
+}
+
+func (s *impl) DbStatus() (api.DbStatus, error) {
+	version, err := s.migrator.Version()
+	if err != nil {
+		s.logger.Errorf("failed to read the schema version:  %v", err)
+		return api.DbStatus{}, DbStatusFailed
+	}
+
+	status := api.DbStatus{
+		Dialect:       s.db.Dialector.Name(),
+		SchemaVersion: version,
+		LatestVersion: s.migrator.Latest(),
+		RowCounts:     make(map[string]int64, len(countedTables)),
+	}
+
+	for _, table := range countedTables {
+		var count int64
+		if err := s.db.Table(table).Count(&count).Error; err != nil {
+			s.logger.Errorf("failed to count the rows of '%s':  %v", table, err)
+			return api.DbStatus{}, DbStatusFailed
+		}
+		status.RowCounts[table] = count
+	}
+
+	return status, nil
+}
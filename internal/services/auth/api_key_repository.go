@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"time"
+
+	"ethereum_fetcher/db/models"
+
+	"gorm.io/gorm"
+)
+
+// lastUsedResolution limits how often using a key writes its last used time
+const lastUsedResolution = time.Minute
+
+type ApiKeyRepo struct {
+	db *gorm.DB
+}
+
+func (r *ApiKeyRepo) Create(key *models.ApiKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *ApiKeyRepo) FindByHash(keyHash string) (models.ApiKey, error) {
+	var key models.ApiKey
+	err := r.db.Where("key_hash = ?", keyHash).First(&key).Error
+	return key, err
+}
+
+// ListForUser returns the keys of the user that aren't revoked
+func (r *ApiKeyRepo) ListForUser(userId uint64) ([]models.ApiKey, error) {
+	var keys []models.ApiKey
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL", userId).Order("id").Find(&keys).Error
+	return keys, err
+}
+
+func (r *ApiKeyRepo) CountForUser(userId uint64) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.ApiKey{}).Where("user_id = ? AND revoked_at IS NULL", userId).Count(&count).Error
+	return count, err
+}
+
+// Revoke revokes a key of the user, failing with ApiKeyNotFound for keys of
+// other users
+func (r *ApiKeyRepo) Revoke(userId uint64, id uint64) error {
+	result := r.db.Model(&models.ApiKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ApiKeyNotFound
+	}
+	return nil
+}
+
+func (r *ApiKeyRepo) TouchLastUsed(id uint64, now time.Time) error {
+	return r.db.Model(&models.ApiKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-lastUsedResolution)).
+		Update("last_used_at", now).Error
+}
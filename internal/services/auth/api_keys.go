@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/pkg/logging"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	ApiKeyHeader = "X-API-Key"
+
+	// keys look like lime_<prefix>_<secret>, the prefix identifies the key
+	// in listings without revealing it
+	apiKeyMarker      = "lime_"
+	maxApiKeysPerUser = 25
+	maxApiKeyName     = 64
+)
+
+type ApiKeyService interface {
+	Create(userId uint64, req api.CreateApiKeyRequest) (*api.CreatedApiKey, error)
+	List(userId uint64) ([]api.ApiKey, error)
+	Revoke(userId uint64, keyId uint64) error
+	// Authenticate returns the user of a valid key and the scopes of the key
+	// that the role of the user still grants
+	Authenticate(key string) (ApiKeyIdentity, error)
+}
+
+// ApiKeyIdentity is who a request authenticated with an API key acts as
+type ApiKeyIdentity struct {
+	UserId uint64
+	KeyId  uint64
+	Scopes []string
+}
+
+type apiKeyServiceImpl struct {
+	repo   *ApiKeyRepo
+	users  *UserRepo
+	logger *logrus.Logger
+}
+
+func NewApiKeyService(db *gorm.DB) ApiKeyService {
+	return &apiKeyServiceImpl{repo: &ApiKeyRepo{db: db}, users: &UserRepo{db: db}, logger: logging.New()}
+}
+
+func (s *apiKeyServiceImpl) Create(userId uint64, req api.CreateApiKeyRequest) (*api.CreatedApiKey, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxApiKeyName {
+		return nil, InvalidApiKeyName
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, InvalidApiKeyExpiry
+	}
+
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = DefaultApiKeyScopes
+	}
+	if !validScopes(scopes) {
+		return nil, InvalidScope
+	}
+
+	count, err := s.repo.CountForUser(userId)
+	if err != nil {
+		s.logger.Errorf("failed to count api keys of user '%d':  %v", userId, err)
+		return nil, TokenStoreFailed
+	}
+	if count >= maxApiKeysPerUser {
+		return nil, TooManyApiKeys
+	}
+
+	prefix, key, err := newApiKey()
+	if err != nil {
+		s.logger.Errorf("failed to generate api key:  %v", err)
+		return nil, TokenSigningFailed
+	}
+
+	record := &models.ApiKey{
+		UserId:    userId,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(key),
+		Scopes:    joinScopes(scopes),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.repo.Create(record); err != nil {
+		s.logger.Errorf("failed to store api key of user '%d':  %v", userId, err)
+		return nil, TokenStoreFailed
+	}
+
+	s.logger.Infof("Created api key '%s' for user '%d'", prefix, userId)
+	return &api.CreatedApiKey{ApiKey: toApiKey(*record), Key: key}, nil
+}
+
+func (s *apiKeyServiceImpl) List(userId uint64) ([]api.ApiKey, error) {
+	keys, err := s.repo.ListForUser(userId)
+	if err != nil {
+		s.logger.Errorf("failed to list api keys of user '%d':  %v", userId, err)
+		return nil, TokenStoreFailed
+	}
+
+	apiKeys := make([]api.ApiKey, 0, len(keys))
+	for _, key := range keys {
+		apiKeys = append(apiKeys, toApiKey(key))
+	}
+	return apiKeys, nil
+}
+
+func (s *apiKeyServiceImpl) Revoke(userId uint64, keyId uint64) error {
+	err := s.repo.Revoke(userId, keyId)
+	if err != nil && err != ApiKeyNotFound {
+		s.logger.Errorf("failed to revoke api key '%d':  %v", keyId, err)
+		return TokenStoreFailed
+	}
+	return err
+}
+
+func (s *apiKeyServiceImpl) Authenticate(key string) (ApiKeyIdentity, error) {
+	if !strings.HasPrefix(key, apiKeyMarker) {
+		return ApiKeyIdentity{}, InvalidApiKey
+	}
+
+	stored, err := s.repo.FindByHash(hashToken(key))
+	if err != nil {
+		return ApiKeyIdentity{}, InvalidApiKey
+	}
+
+	now := time.Now()
+	if stored.RevokedAt != nil || (stored.ExpiresAt != nil && !now.Before(*stored.ExpiresAt)) {
+		return ApiKeyIdentity{}, InvalidApiKey
+	}
+
+	roleScopes, err := userScopes(s.users, stored.UserId)
+	if err != nil {
+		return ApiKeyIdentity{}, InvalidApiKey
+	}
+
+	if err := s.repo.TouchLastUsed(stored.ID, now); err != nil {
+		s.logger.Warnf("failed to record use of api key '%s':  %v", stored.Prefix, err)
+	}
+
+	return ApiKeyIdentity{
+		UserId: stored.UserId,
+		KeyId:  stored.ID,
+		Scopes: intersectScopes(splitScopes(stored.Scopes), roleScopes),
+	}, nil
+}
+
+func newApiKey() (string, string, error) {
+	prefixBytes := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	prefix := hex.EncodeToString(prefixBytes)
+	return prefix, apiKeyMarker + prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func toApiKey(key models.ApiKey) api.ApiKey {
+	return api.ApiKey{
+		Id:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     splitScopes(key.Scopes),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"time"
+
+	"ethereum_fetcher/db/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// failurePending is an attempt still being verified, it counts as a
+	// failure until it is resolved
+	failurePending     = "pending"
+	failureUnknownUser = "unknown_user"
+	failureBadPassword = "bad_password"
+	failureThrottled   = "throttled"
+
+	maxAuditedUsername = 64
+)
+
+type AuditRepo struct {
+	db *gorm.DB
+}
+
+// ReserveAttempt records a sign-in attempt as pending before the password is
+// verified, so that concurrent attempts count each other
+func (r *AuditRepo) ReserveAttempt(username string, ip string) (uint64, error) {
+	attempt := models.AuthFailure{Username: auditedUsername(username), IP: ip, Reason: failurePending}
+	if err := r.db.Create(&attempt).Error; err != nil {
+		return 0, err
+	}
+	return attempt.ID, nil
+}
+
+// ResolveAttempt records why a reserved attempt failed
+func (r *AuditRepo) ResolveAttempt(id uint64, reason string) error {
+	return r.db.Model(&models.AuthFailure{}).Where("id = ?", id).Update("reason", reason).Error
+}
+
+// DropAttempt forgets a reserved attempt that succeeded
+func (r *AuditRepo) DropAttempt(id uint64) error {
+	return r.db.Delete(&models.AuthFailure{}, id).Error
+}
+
+// failureStats counts the failures since the given time, attempts rejected by
+// the throttle don't count so that the backoff doesn't grow without bound
+type failureStats struct {
+	count int64
+	last  time.Time
+}
+
+// UserFailures and IpFailures leave out the attempt being checked
+func (r *AuditRepo) UserFailures(username string, since time.Time, attempt uint64) (failureStats, error) {
+	return r.failures("username = ?", auditedUsername(username), since, attempt)
+}
+
+func (r *AuditRepo) IpFailures(ip string, since time.Time, attempt uint64) (failureStats, error) {
+	return r.failures("ip = ?", ip, since, attempt)
+}
+
+func (r *AuditRepo) failures(condition string, value string, since time.Time, attempt uint64) (failureStats, error) {
+	var stats failureStats
+	query := r.db.Model(&models.AuthFailure{}).
+		Where(condition, value).
+		Where("created_at >= ? AND reason <> ? AND id <> ?", since, failureThrottled, attempt).
+		Session(&gorm.Session{})
+
+	if err := query.Count(&stats.count).Error; err != nil || stats.count == 0 {
+		return stats, err
+	}
+
+	var last models.AuthFailure
+	if err := query.Order("created_at DESC").First(&last).Error; err != nil {
+		return stats, err
+	}
+	stats.last = last.CreatedAt
+	return stats, nil
+}
+
+func auditedUsername(username string) string {
+	if len(username) > maxAuditedUsername {
+		return username[:maxAuditedUsername]
+	}
+	return username
+}
@@ -1,6 +1,10 @@
 package auth
 
-import "ethereum_fetcher/internal/services/errors"
+import (
+	"time"
+
+	"ethereum_fetcher/internal/services/errors"
+)
 
 type AuthError struct {
 	errors.ServiceError
@@ -23,6 +27,23 @@ var (
 	InvalidSignature   = jwtError("invalid jwt signature")
 	InvalidToken       = jwtError("invalid token")
 	NoSubject          = jwtError("no subject found")
+	ExpiredToken       = jwtError("token has expired")
+	RevokedToken       = jwtError("token has been revoked")
+)
+
+var (
+	// InvalidCredentials doesn't tell unknown usernames from wrong passwords
+	// so that usernames can't be enumerated
+	InvalidCredentials  = authError("invalid username or password")
+	InvalidApiKey       = authError("invalid api key")
+	ApiKeyNotFound      = authError("api key not found")
+	InvalidApiKeyName   = authError("api key name must be 1 to 64 characters")
+	InvalidApiKeyExpiry = authError("api key must expire in the future")
+	InvalidScope        = authError("unknown scope")
+	InsufficientScope   = authError("insufficient scope")
+	TooManyApiKeys      = authError("too many api keys")
+	InvalidRefreshToken = authError("invalid refresh token")
+	TokenStoreFailed    = authError("failed to store token")
 )
 
 type UserError struct {
@@ -34,6 +55,58 @@ func userError(msg string) UserError {
 }
 
 var (
-	UsernameNotFound = userError("username not found")
-	InvalidPassword  = userError("invalid password")
+	UsernameNotFound         = userError("username not found")
+	InvalidPassword          = userError("invalid password")
+	InvalidUsername          = userError("username must be 3 to 32 letters, digits, '.', '_' or '-'")
+	UsernameTaken            = userError("username is already taken")
+	UserNotFound             = userError("user not found")
+	RegistrationDisabled     = userError("registration is disabled")
+	UserStoreFailed          = userError("failed to store user")
+	InvalidRole              = userError("role must be one of admin, ingester or reader")
+	CannotModifySelf         = userError("admins can't change the role of or delete their own account")
+	AddressTaken             = userError("address is linked to another account")
+	SiweConfirmationRequired = userError("accounts without a password confirm with a message signed by their address")
+)
+
+// ThrottledError is returned for sign-ins attempted too soon after too many
+// failures
+type ThrottledError struct {
+	AuthError
+	RetryAfter time.Duration
+}
+
+func throttledError(retryAfter time.Duration) ThrottledError {
+	return ThrottledError{authError("too many failed sign-ins, try again later"), retryAfter}
+}
+
+var (
+	SiweDisabled         = authError("sign-in with ethereum is disabled")
+	SiweDomainMismatch   = authError("sign-in message is for another domain or chain")
+	SiweMessageExpired   = authError("sign-in message has expired or is not valid yet")
+	InvalidSiweSignature = authError("signature doesn't match the address")
+	InvalidSiweNonce     = authError("nonce is unknown, expired or already used")
+	AddressNotLinked     = authError("no account is linked to this address")
 )
+
+var (
+	NotAuthenticated = authError("authentication required")
+	NotAnAdmin       = authError("admin privileges required")
+)
+
+// PasswordPolicyError is returned for passwords the password policy rejects
+type PasswordPolicyError struct {
+	UserError
+}
+
+func passwordPolicyError(err error) PasswordPolicyError {
+	return PasswordPolicyError{userError(err.Error())}
+}
+
+// SiweMessageError is returned for sign-in messages that aren't valid EIP-4361
+type SiweMessageError struct {
+	AuthError
+}
+
+func siweMessageError(err error) SiweMessageError {
+	return SiweMessageError{authError(err.Error())}
+}
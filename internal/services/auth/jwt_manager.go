@@ -1,36 +1,154 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"strconv"
+	"time"
+
+	"ethereum_fetcher/api"
 
 	jwt "github.com/golang-jwt/jwt/v5"
 )
 
 const UserClaim = "user"
 
+// ApiKeyClaim is the id of the API key a request authenticated with, unset
+// for access tokens
+const ApiKeyClaim = "apiKey"
+
+const (
+	DefaultIssuer          = "ethereum-fetcher"
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// TokenConfig configures the access and refresh tokens issued to users.
+// Tokens are signed with the asymmetric Keys when set, with the HS256 Secret
+// otherwise.
+type TokenConfig struct {
+	Secret     string
+	Keys       *KeySet
+	Issuer     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// Throttle limits failed password sign-ins, zero values take the defaults
+	Throttle ThrottleConfig
+}
+
+func (c TokenConfig) withDefaults() TokenConfig {
+	if c.Issuer == "" {
+		c.Issuer = DefaultIssuer
+	}
+	if c.AccessTTL <= 0 {
+		c.AccessTTL = DefaultAccessTokenTTL
+	}
+	if c.RefreshTTL <= 0 {
+		c.RefreshTTL = DefaultRefreshTokenTTL
+	}
+	return c
+}
+
+// AccessClaims are the claims of access tokens. Scopes are those the role of
+// the user granted when the token was issued, a role change takes effect when
+// the token is refreshed.
+type AccessClaims struct {
+	jwt.RegisteredClaims
+	Scopes []string `json:"scopes,omitempty"`
+}
+
 type JwtManager struct {
-	secretKey string
+	cfg TokenConfig
 }
 
-func NewJwtManager(secretKey string) JwtManager {
-	return JwtManager{secretKey: secretKey}
+func NewJwtManager(cfg TokenConfig) JwtManager {
+	return JwtManager{cfg: cfg.withDefaults()}
 }
 
-func (jm *JwtManager) GenerateJwt(userId uint64) (string, error) {
-	claims := jwt.MapClaims{"sub": strconv.FormatUint(userId, 10)}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(jm.secretKey))
+// Jwks publishes the verification keys, empty for HS256 which has no public key
+func (jm *JwtManager) Jwks() api.Jwks {
+	if jm.cfg.Keys == nil {
+		return api.Jwks{Keys: []api.Jwk{}}
+	}
+	return jm.cfg.Keys.Jwks(time.Now())
+}
+
+// GenerateJwt issues a short lived access token for the user with the scopes
+func (jm *JwtManager) GenerateJwt(userId uint64, scopes []string) (string, error) {
+	jti, err := randomId()
+	if err != nil {
+		return "", TokenSigningFailed
+	}
+
+	now := time.Now()
+	claims := AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(userId, 10),
+			Issuer:    jm.cfg.Issuer,
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(jm.cfg.AccessTTL)),
+		},
+		Scopes: scopes,
+	}
+
+	if jm.cfg.Keys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		signedToken, err := token.SignedString([]byte(jm.cfg.Secret))
+		if err != nil {
+			return "", TokenSigningFailed
+		}
+		return signedToken, nil
+	}
+
+	key, err := jm.cfg.Keys.signingKey(now)
+	if err != nil {
+		return "", TokenSigningFailed
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.Kid
+	signedToken, err := token.SignedString(key.private)
 	if err != nil {
 		return "", TokenSigningFailed
 	}
 	return signedToken, nil
 }
 
+// ParseJwt verifies the signature, issuer and expiry of an access token
 func (jm *JwtManager) ParseJwt(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &AccessClaims{}, jm.verificationKey,
+		jwt.WithIssuer(jm.cfg.Issuer), jwt.WithExpirationRequired(), jwt.WithIssuedAt())
+
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return token, ExpiredToken
+	}
+	return token, err
+}
+
+// verificationKey picks the key by the kid header and only accepts the
+// algorithm of that key, so a token can't pick a weaker one
+func (jm *JwtManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	if jm.cfg.Keys == nil {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, InvalidSignature
 		}
-		return []byte(jm.secretKey), nil
-	})
+		return []byte(jm.cfg.Secret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := jm.cfg.Keys.verificationKey(kid, time.Now())
+	if !ok || token.Method.Alg() != key.Alg() {
+		return nil, InvalidSignature
+	}
+	return key.public, nil
+}
+
+// randomId returns 128 random bits, hex encoded
+func randomId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
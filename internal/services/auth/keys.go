@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ethereum_fetcher/api"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// PEM headers scheduling a key. A key signs new tokens from Activates-At
+// until a newer key activates, and verifies tokens until Expires-At.
+const (
+	KidHeader         = "Kid"
+	ActivatesAtHeader = "Activates-At"
+	ExpiresAtHeader   = "Expires-At"
+)
+
+// SigningKey is an asymmetric key identified by its kid. Keys loaded from a
+// PUBLIC KEY block only verify tokens.
+type SigningKey struct {
+	Kid         string
+	ActivatesAt time.Time
+	// ExpiresAt is when the key stops verifying tokens, zero never
+	ExpiresAt time.Time
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	public    crypto.PublicKey
+}
+
+func (k *SigningKey) Alg() string {
+	return k.method.Alg()
+}
+
+func (k *SigningKey) active(now time.Time) bool {
+	return !now.Before(k.ActivatesAt) && !k.expired(now)
+}
+
+func (k *SigningKey) expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// KeySet holds the keys tokens are signed and verified with. Publishing a key
+// ahead of its activation and expiring the previous one only after the access
+// token lifetime gives verifiers an overlap to pick up the rotation.
+type KeySet struct {
+	keys []*SigningKey
+}
+
+// LoadKeySet reads every *.pem file in dir. The kid defaults to the file
+// name without extension.
+func LoadKeySet(dir string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*SigningKey, 0, len(paths))
+	kids := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		key, err := loadKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load signing key '%s':  %w", path, err)
+		}
+		if kids[key.Kid] {
+			return nil, fmt.Errorf("duplicate signing key id '%s'", key.Kid)
+		}
+		kids[key.Kid] = true
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(keys...)
+}
+
+func NewKeySet(keys ...*SigningKey) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing keys found")
+	}
+	// latest activation first, so the first active key is the signing key
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].ActivatesAt.Equal(keys[j].ActivatesAt) {
+			return keys[i].Kid > keys[j].Kid
+		}
+		return keys[i].ActivatesAt.After(keys[j].ActivatesAt)
+	})
+	return &KeySet{keys: keys}, nil
+}
+
+// NewSigningKey wraps a private key, picking the algorithm from its type
+func NewSigningKey(kid string, private crypto.Signer, activatesAt time.Time, expiresAt time.Time) (*SigningKey, error) {
+	method, err := signingMethod(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{
+		Kid:         kid,
+		ActivatesAt: activatesAt,
+		ExpiresAt:   expiresAt,
+		method:      method,
+		private:     private,
+		public:      private.Public(),
+	}, nil
+}
+
+// signingKey is the most recently activated key with a private part
+func (ks *KeySet) signingKey(now time.Time) (*SigningKey, error) {
+	for _, key := range ks.keys {
+		if key.private != nil && key.active(now) {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("no active signing key")
+}
+
+func (ks *KeySet) verificationKey(kid string, now time.Time) (*SigningKey, bool) {
+	for _, key := range ks.keys {
+		if key.Kid == kid {
+			return key, !key.expired(now)
+		}
+	}
+	return nil, false
+}
+
+// Jwks returns the public part of every key that isn't expired, including
+// keys that aren't active yet
+func (ks *KeySet) Jwks(now time.Time) api.Jwks {
+	jwks := api.Jwks{Keys: make([]api.Jwk, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		if key.expired(now) {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, toJwk(key))
+	}
+	return jwks
+}
+
+func loadKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	key := &SigningKey{Kid: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+	if kid, ok := block.Headers[KidHeader]; ok {
+		key.Kid = kid
+	}
+	if key.ActivatesAt, err = timeHeader(block, ActivatesAtHeader); err != nil {
+		return nil, err
+	}
+	if key.ExpiresAt, err = timeHeader(block, ExpiresAtHeader); err != nil {
+		return nil, err
+	}
+
+	if block.Type == "PUBLIC KEY" {
+		key.public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	} else {
+		key.private, err = parsePrivateKey(block)
+		if key.private != nil {
+			key.public = key.private.Public()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key.method, err = signingMethod(key.public)
+	return key, err
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type '%s'", block.Type)
+	}
+}
+
+func timeHeader(block *pem.Block, header string) (time.Time, error) {
+	value, ok := block.Headers[header]
+	if !ok {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s header:  %w", header, err)
+	}
+	return parsed, nil
+}
+
+// signingMethod maps RSA keys to RS256, P-256 keys to ES256 and Ed25519 keys
+// to EdDSA
+func signingMethod(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA keys need at least 2048 bits")
+		}
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("only P-256 EC keys are supported")
+		}
+		return jwt.SigningMethodES256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", public)
+	}
+}
+
+func toJwk(key *SigningKey) api.Jwk {
+	jwk := api.Jwk{Kid: key.Kid, Use: "sig", Alg: key.Alg()}
+
+	switch public := key.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(public.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(public.E)).Bytes())
+	case *ecdsa.PublicKey:
+		// the uncompressed point is 0x04 || X || Y
+		point, _ := public.ECDH()
+		coords := point.Bytes()[1:]
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		jwk.X = b64(coords[:len(coords)/2])
+		jwk.Y = b64(coords[len(coords)/2:])
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(public)
+	}
+
+	return jwk
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
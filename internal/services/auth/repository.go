@@ -1,16 +1,26 @@
 package auth
 
 import (
+	"sync"
+
 	"ethereum_fetcher/db/models"
 	"ethereum_fetcher/pkg/passwords"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepo struct {
 	db *gorm.DB
 }
 
+// dummyPasswordHash is compared against when there is no password to check,
+// so that unknown usernames take as long as wrong passwords
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := passwords.HashPassword("not-a-password")
+	return hash
+})
+
 func (r *UserRepo) FindUser(username string, password string) (models.User, error) {
 	var user models.User
 
@@ -18,12 +28,144 @@ func (r *UserRepo) FindUser(username string, password string) (models.User, erro
 		Select("users.*").
 		Where("users.username = ?", username).
 		First(&user).Error; err != nil {
+		_ = passwords.ComparePasswords(dummyPasswordHash(), password)
 		return user, UsernameNotFound
 	}
 
+	// users who only sign in with Ethereum have no password
+	if user.PasswordHash == "" {
+		_ = passwords.ComparePasswords(dummyPasswordHash(), password)
+		return user, InvalidPassword
+	}
 	if err := passwords.ComparePasswords(user.PasswordHash, password); err != nil {
 		return user, InvalidPassword
 	}
 
 	return user, nil
 }
+
+// Create inserts a new user, failing with UsernameTaken if the name is in use
+func (r *UserRepo) Create(username string, passwordHash string) (models.User, error) {
+	user := models.User{Username: username, PasswordHash: passwordHash, Role: DefaultRole}
+
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&user)
+	if result.Error != nil {
+		return user, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return user, UsernameTaken
+	}
+	return user, nil
+}
+
+// CreateWithAddress inserts a user without a password who signs in with the
+// address, the address doubles as the username
+func (r *UserRepo) CreateWithAddress(address string) (models.User, error) {
+	user := models.User{Username: address, PasswordHash: "", Role: DefaultRole, Address: &address}
+	if err := r.db.Create(&user).Error; err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+func (r *UserRepo) FindByAddress(address string) (models.User, error) {
+	var user models.User
+	if err := r.db.Where("address = ?", address).First(&user).Error; err != nil {
+		return user, UserNotFound
+	}
+	return user, nil
+}
+
+func (r *UserRepo) SetAddress(id uint64, address string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Update("address", address).Error
+}
+
+func (r *UserRepo) FindById(id uint64) (models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return user, UserNotFound
+	}
+	return user, nil
+}
+
+func (r *UserRepo) UpdatePasswordHash(id uint64, passwordHash string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Update("password_hash", passwordHash).Error
+}
+
+// Delete removes the user together with the record of their lookups, their
+// collections, tags, notes and share links, their refresh tokens, API keys and
+// the organization invites they received
+func (r *UserRepo) Delete(id uint64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// collections of their organization stay with it
+		collections := tx.Model(&models.Collection{}).Select("id").Where("user_id = ? AND org_id IS NULL", id)
+		if err := tx.Where("collection_id IN (?)", collections).Delete(&models.CollectionTransaction{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND org_id IS NULL", id).Delete(&models.Collection{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.UserTransaction{}, &models.TransactionTag{},
+			&models.TransactionNote{}, &models.ShareLink{}, &models.RefreshToken{}, &models.ApiKey{}, &models.OrgInvite{}} {
+			if err := tx.Where("user_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		var user models.User
+		if err := tx.First(&user, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.User{}, id).Error; err != nil {
+			return err
+		}
+		if user.OrgId != nil && user.OrgRole == orgAdminRole {
+			return handOverOrg(tx, *user.OrgId)
+		}
+		return nil
+	})
+}
+
+// orgAdminRole is the organization role that manages its members
+const orgAdminRole = "admin"
+
+// handOverOrg keeps an organization managed after an admin is deleted, the
+// member with the oldest account becomes admin when none is left. An
+// organization without members is deleted.
+func handOverOrg(tx *gorm.DB, orgId uint64) error {
+	var members []models.User
+	if err := tx.Where("org_id = ?", orgId).Order("id").Find(&members).Error; err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return tx.Delete(&models.Organization{}, orgId).Error
+	}
+	for _, member := range members {
+		if member.OrgRole == orgAdminRole {
+			return nil
+		}
+	}
+	return tx.Model(&models.User{}).Where("id = ?", members[0].ID).Update("org_role", orgAdminRole).Error
+}
+
+func (r *UserRepo) SetRole(id uint64, role string) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", id).Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return UserNotFound
+	}
+	return nil
+}
+
+// PromoteToAdmin makes the existing users with the given usernames admins
+func (r *UserRepo) PromoteToAdmin(usernames []string) error {
+	return r.db.Model(&models.User{}).Where("username IN ?", usernames).Update("role", RoleAdmin).Error
+}
+
+func (r *UserRepo) List() ([]models.User, error) {
+	var users []models.User
+	err := r.db.Order("id").Find(&users).Error
+	return users, err
+}
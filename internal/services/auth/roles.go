@@ -0,0 +1,29 @@
+package auth
+
+const (
+	// RoleAdmin can do everything, including managing users and maintenance
+	RoleAdmin = "admin"
+	// RoleIngester looks up transactions, fetching unknown ones from the node
+	RoleIngester = "ingester"
+	// RoleReader only lists the transactions it looked up before
+	RoleReader = "reader"
+
+	DefaultRole = RoleIngester
+)
+
+var roleScopes = map[string][]string{
+	RoleAdmin:    AllScopes,
+	RoleIngester: {ScopeFetchTransactions, ScopeReadTransactions, ScopeManageAccount},
+	RoleReader:   {ScopeReadTransactions, ScopeManageAccount},
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleScopes[role]
+	return ok
+}
+
+// RoleScopes are the scopes a user with the role signs in with, unknown roles
+// get none
+func RoleScopes(role string) []string {
+	return roleScopes[role]
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+)
+
+const ScopesClaim = "scopes"
+
+const (
+	// ScopeFetchTransactions allows looking up transactions by hash
+	ScopeFetchTransactions = "transactions:fetch"
+	// ScopeReadTransactions allows listing the transactions a user looked up
+	ScopeReadTransactions = "transactions:read"
+	// ScopeManageAccount allows changing the account and its API keys
+	ScopeManageAccount = "account:manage"
+	// ScopeReadAllTransactions allows listing every stored transaction
+	ScopeReadAllTransactions = "transactions:read-all"
+	// ScopeManageUsers allows listing users, changing their role and deleting them
+	ScopeManageUsers = "users:manage"
+	// ScopeMaintenance allows inspecting and purging the cache and the database
+	ScopeMaintenance = "maintenance"
+)
+
+var AllScopes = []string{
+	ScopeFetchTransactions, ScopeReadTransactions, ScopeManageAccount,
+	ScopeReadAllTransactions, ScopeManageUsers, ScopeMaintenance,
+}
+
+// DefaultApiKeyScopes are granted to API keys created without scopes
+var DefaultApiKeyScopes = []string{ScopeFetchTransactions, ScopeReadTransactions}
+
+func validScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(AllScopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// intersectScopes keeps the scopes of a that are also in b
+func intersectScopes(a []string, b []string) []string {
+	scopes := make([]string, 0, len(a))
+	for _, scope := range a {
+		if slices.Contains(b, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func HasScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, scope)
+}
+
+func joinScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+func splitScopes(scopes string) []string {
+	return strings.Fields(scopes)
+}
@@ -1,71 +1,342 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/models"
 	"ethereum_fetcher/pkg/logging"
 	"strconv"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+const tokenType = "Bearer"
+
 type AuthService interface {
-	Authenticate(req api.AuthRequest) (*string, error)
+	Authenticate(req api.AuthRequest) (*api.AuthResponse, error)
+	// Refresh exchanges a refresh token for new access and refresh tokens
+	Refresh(refreshToken string) (*api.AuthResponse, error)
+	// Logout revokes the access token and the refresh token family, either
+	// may be empty
+	Logout(accessToken api.AuthToken, refreshToken string) error
 	GetUserId(tokenString api.AuthToken) (uint64, error)
+	// Identify returns the user of a valid access token and the scopes the
+	// token was issued with, without reading the user
+	Identify(tokenString api.AuthToken) (TokenIdentity, error)
+	// UserScopes are the scopes the role of the user grants
+	UserScopes(userId uint64) ([]string, error)
+	// Jwks publishes the keys other services can verify access tokens with
+	Jwks() api.Jwks
+}
+
+// TokenIdentity is who a request authenticated with an access token acts as
+type TokenIdentity struct {
+	UserId uint64
+	Scopes []string
 }
 
 type impl struct {
-	repo   *UserRepo
-	jm     JwtManager
-	logger *logrus.Logger
+	repo     *UserRepo
+	tokens   *TokenRepo
+	audit    *AuditRepo
+	throttle *loginThrottle
+	jm       JwtManager
+	logger   *logrus.Logger
 }
 
-func NewAuthService(db *gorm.DB, jwtSecretKey string) (AuthService, error) {
+func NewAuthService(db *gorm.DB, cfg TokenConfig) (AuthService, error) {
+	return newAuthService(db, cfg)
+}
+
+// newAuthService is shared with the services that sign users in some other
+// way and issue the same tokens
+func newAuthService(db *gorm.DB, cfg TokenConfig) (*impl, error) {
 	logger := logging.New()
 
+	if cfg.Secret == "" && cfg.Keys == nil {
+		return nil, errors.New("a JWT secret or signing keys are required")
+	}
+	if cfg.Keys != nil {
+		if _, err := cfg.Keys.signingKey(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
 	repo := &UserRepo{db: db}
-	JwtManager := NewJwtManager(jwtSecretKey)
+	tokens := &TokenRepo{db: db}
+	audit := &AuditRepo{db: db}
+	JwtManager := NewJwtManager(cfg)
 
 	return &impl{
-		repo:   repo,
-		jm:     JwtManager,
-		logger: logger,
+		repo:     repo,
+		tokens:   tokens,
+		audit:    audit,
+		throttle: newLoginThrottle(audit, cfg.Throttle),
+		jm:       JwtManager,
+		logger:   logger,
 	}, nil
 }
 
-func (s *impl) Authenticate(req api.AuthRequest) (*string, error) {
+// Authenticate signs in with a password. Failures are audited and throttle
+// further attempts for the username and the client IP, unknown usernames and
+// wrong passwords fail alike. The attempt is reserved before it is checked
+// against the throttle.
+func (s *impl) Authenticate(req api.AuthRequest) (*api.AuthResponse, error) {
+	attempt, err := s.audit.ReserveAttempt(req.Username, req.ClientIP)
+	if err != nil {
+		s.logger.Errorf("failed to record sign-in attempt : %s", err)
+		return nil, TokenStoreFailed
+	}
+
+	wait, err := s.throttle.retryAfter(req.Username, req.ClientIP, attempt, time.Now())
+	if err != nil {
+		s.logger.Errorf("failed to check sign-in throttling : %s", err)
+		s.dropAttempt(attempt)
+		return nil, TokenStoreFailed
+	}
+	if wait > 0 {
+		s.recordFailure(req, attempt, failureThrottled)
+		return nil, throttledError(wait)
+	}
+
 	user, err := s.repo.FindUser(req.Username, req.Password)
+	if err == UsernameNotFound {
+		s.recordFailure(req, attempt, failureUnknownUser)
+		return nil, InvalidCredentials
+	}
 	if err != nil {
-		s.logger.Debugf("failed to authenticate user '%s' : %s", req.Username, err)
-		return nil, err
+		s.recordFailure(req, attempt, failureBadPassword)
+		return nil, InvalidCredentials
+	}
+
+	s.dropAttempt(attempt)
+	return s.startSession(user.ID)
+}
+
+func (s *impl) recordFailure(req api.AuthRequest, attempt uint64, reason string) {
+	s.logger.Warnf("failed sign-in for user '%s' from '%s' : %s", req.Username, req.ClientIP, reason)
+	if err := s.audit.ResolveAttempt(attempt, reason); err != nil {
+		s.logger.Errorf("failed to record failed sign-in : %s", err)
+	}
+}
+
+// dropAttempt forgets an attempt that didn't fail, a leftover pending one
+// only counts until the window passes
+func (s *impl) dropAttempt(attempt uint64) {
+	if err := s.audit.DropAttempt(attempt); err != nil {
+		s.logger.Errorf("failed to drop sign-in attempt : %s", err)
 	}
+}
 
-	jwt, err := s.jm.GenerateJwt(user.ID)
+func (s *impl) Refresh(refreshToken string) (*api.AuthResponse, error) {
+	stored, err := s.tokens.FindRefreshToken(hashToken(refreshToken))
 	if err != nil {
-		s.logger.Warnf("failed to generate JWT for user '%s' : %s", req.Username, err)
-		return nil, err
+		return nil, InvalidRefreshToken
+	}
+
+	if stored.RevokedAt != nil {
+		// a rotated token was replayed, whoever holds the family may have
+		// stolen it so the whole family is logged out
+		s.logger.Warnf("revoked refresh token reused for user '%d', revoking its family", stored.UserId)
+		if err := s.tokens.RevokeFamily(stored.FamilyId); err != nil {
+			s.logger.Errorf("failed to revoke refresh token family : %s", err)
+		}
+		return nil, InvalidRefreshToken
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, InvalidRefreshToken
+	}
+
+	return s.issueTokens(stored.UserId, stored.FamilyId, func(replacement *models.RefreshToken) error {
+		return s.tokens.RotateRefreshToken(stored.ID, replacement)
+	})
+}
+
+func (s *impl) Logout(accessToken api.AuthToken, refreshToken string) error {
+	if accessToken != "" {
+		claims, err := s.parseClaims(accessToken)
+		switch {
+		case err == ExpiredToken:
+			// nothing to revoke, the token can't be used anymore
+		case err != nil:
+			return err
+		default:
+			if err := s.tokens.RevokeAccessToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+				s.logger.Errorf("failed to revoke access token : %s", err)
+				return TokenStoreFailed
+			}
+		}
 	}
 
-	return &jwt, nil
+	if refreshToken != "" {
+		stored, err := s.tokens.FindRefreshToken(hashToken(refreshToken))
+		if err != nil {
+			return InvalidRefreshToken
+		}
+		if err := s.tokens.RevokeFamily(stored.FamilyId); err != nil {
+			s.logger.Errorf("failed to revoke refresh token family : %s", err)
+			return TokenStoreFailed
+		}
+	}
+
+	return nil
 }
 
 func (s *impl) GetUserId(authToken api.AuthToken) (uint64, error) {
+	identity, err := s.Identify(authToken)
+	return identity.UserId, err
+}
+
+func (s *impl) Identify(authToken api.AuthToken) (TokenIdentity, error) {
+	claims, err := s.parseClaims(authToken)
+	if err != nil {
+		return TokenIdentity{}, err
+	}
+
+	revoked, err := s.tokens.IsRevoked(claims.ID)
+	if err != nil {
+		s.logger.Errorf("failed to check token revocation : %s", err)
+		return TokenIdentity{}, TokenStoreFailed
+	}
+	if revoked {
+		return TokenIdentity{}, RevokedToken
+	}
+
+	id, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		s.logger.Warnf("failed to convert subject to user id : %s", err)
+		return TokenIdentity{}, NoSubject
+	}
+
+	// a role changed since the token was issued takes effect right away
+	roleScopes, err := s.UserScopes(id)
+	if err != nil {
+		return TokenIdentity{}, InvalidToken
+	}
+	scopes := roleScopes
+	if claims.Scopes != nil {
+		scopes = intersectScopes(claims.Scopes, roleScopes)
+	}
+	return TokenIdentity{UserId: id, Scopes: scopes}, nil
+}
+
+func (s *impl) UserScopes(userId uint64) ([]string, error) {
+	return userScopes(s.repo, userId)
+}
+
+// userScopes fails with UserNotFound for tokens and keys of deleted users
+func userScopes(repo *UserRepo, userId uint64) ([]string, error) {
+	user, err := repo.FindById(userId)
+	if err != nil {
+		return nil, err
+	}
+	return RoleScopes(user.Role), nil
+}
+
+func (s *impl) Jwks() api.Jwks {
+	return s.jm.Jwks()
+}
+
+func (s *impl) parseClaims(authToken api.AuthToken) (*AccessClaims, error) {
 	token, err := s.jm.ParseJwt(authToken)
+	if err == ExpiredToken {
+		return nil, ExpiredToken
+	}
 	if err != nil || !token.Valid {
 		s.logger.Warnf("failed to parse JWT : %s", err)
-		return 0, InvalidToken
+		return nil, InvalidToken
+	}
+
+	claims, ok := token.Claims.(*AccessClaims)
+	if !ok || claims.Subject == "" {
+		s.logger.Warnf("failed to get subject from token")
+		return nil, NoSubject
+	}
+	if claims.ID == "" {
+		return nil, InvalidToken
 	}
+	return claims, nil
+}
 
-	sub, err := token.Claims.GetSubject()
+// startSession issues tokens in a new refresh token family
+func (s *impl) startSession(userId uint64) (*api.AuthResponse, error) {
+	familyId, err := randomId()
 	if err != nil {
-		s.logger.Warnf("failed to get subject from token : %s", err)
-		return 0, NoSubject
+		s.logger.Warnf("failed to generate a refresh token family : %s", err)
+		return nil, TokenSigningFailed
+	}
+
+	return s.issueTokens(userId, familyId, func(refreshToken *models.RefreshToken) error {
+		return s.tokens.CreateRefreshToken(refreshToken)
+	})
+}
+
+// issueTokens creates an access token with the scopes the role of the user
+// grants now and a new refresh token in the given family, persisting the
+// refresh token with store
+func (s *impl) issueTokens(userId uint64, familyId string, store func(*models.RefreshToken) error) (*api.AuthResponse, error) {
+	scopes, err := s.UserScopes(userId)
+	if err == UserNotFound {
+		// deleted since the session started
+		return nil, InvalidRefreshToken
 	}
-	id, err := strconv.ParseUint(sub, 10, 64)
 	if err != nil {
-		s.logger.Warnf("failed to convert subject to user id : %s", err)
-		return 0, NoSubject
+		s.logger.Errorf("failed to load the scopes of user '%d' : %s", userId, err)
+		return nil, TokenStoreFailed
+	}
+
+	accessToken, err := s.jm.GenerateJwt(userId, scopes)
+	if err != nil {
+		s.logger.Warnf("failed to generate JWT for user '%d' : %s", userId, err)
+		return nil, err
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		s.logger.Warnf("failed to generate refresh token for user '%d' : %s", userId, err)
+		return nil, TokenSigningFailed
+	}
+
+	record := &models.RefreshToken{
+		UserId:    userId,
+		TokenHash: hashToken(refreshToken),
+		FamilyId:  familyId,
+		ExpiresAt: time.Now().Add(s.jm.cfg.RefreshTTL),
+	}
+	if err := store(record); err != nil {
+		if err == InvalidRefreshToken {
+			return nil, err
+		}
+		s.logger.Errorf("failed to store refresh token for user '%d' : %s", userId, err)
+		return nil, TokenStoreFailed
 	}
 
-	return id, nil
+	return &api.AuthResponse{
+		Token:        &accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    tokenType,
+		ExpiresIn:    int64(s.jm.cfg.AccessTTL.Seconds()),
+	}, nil
+}
+
+// newRefreshToken returns 256 random bits, URL safe base64 encoded
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how refresh tokens are stored, they are random enough that
+// a fast unsalted hash can't be brute forced
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
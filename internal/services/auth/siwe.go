@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"time"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/pkg/logging"
+	"ethereum_fetcher/pkg/siwe"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultNonceTTL = 5 * time.Minute
+	// clockSkew is how far in the future a message may have been issued
+	clockSkew = time.Minute
+)
+
+// SiweService signs users in with Sign-In with Ethereum (EIP-4361) messages
+type SiweService interface {
+	// Nonce hands out a nonce for the message to sign, each can be used once
+	Nonce() (api.SiweNonce, error)
+	// SignIn verifies a signed message and issues the same tokens as
+	// AuthService.Authenticate for the account linked to its address
+	SignIn(req api.SiweRequest) (*api.AuthResponse, error)
+	// Link links the address that signed the message to the account of the
+	// user, replacing the address linked before
+	Link(userId uint64, req api.SiweRequest) (api.User, error)
+}
+
+type SiweConfig struct {
+	// Domain is the host messages have to be issued for, empty disables
+	// Sign-In with Ethereum
+	Domain string
+	// ChainId restricts messages to one chain, zero accepts any
+	ChainId  uint64
+	NonceTTL time.Duration
+	// CreateAccounts creates an account on the first sign-in with an address
+	// that isn't linked to one
+	CreateAccounts bool
+}
+
+type siweServiceImpl struct {
+	siweVerifier
+	repo *UserRepo
+	// sessions issues the tokens once a user is signed in
+	sessions *impl
+}
+
+// siweVerifier checks signed messages for the configured domain and chain
+type siweVerifier struct {
+	tokens *TokenRepo
+	cfg    SiweConfig
+	logger *logrus.Logger
+}
+
+func NewSiweService(db *gorm.DB, tokens TokenConfig, cfg SiweConfig) (SiweService, error) {
+	sessions, err := newAuthService(db, tokens)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.NonceTTL <= 0 {
+		cfg.NonceTTL = defaultNonceTTL
+	}
+	return &siweServiceImpl{
+		siweVerifier: siweVerifier{tokens: sessions.tokens, cfg: cfg, logger: logging.New()},
+		repo:         sessions.repo,
+		sessions:     sessions,
+	}, nil
+}
+
+func (s *siweServiceImpl) Nonce() (api.SiweNonce, error) {
+	if s.cfg.Domain == "" {
+		return api.SiweNonce{}, SiweDisabled
+	}
+
+	nonce, err := randomId()
+	if err != nil {
+		s.logger.Warnf("failed to generate a nonce : %s", err)
+		return api.SiweNonce{}, TokenSigningFailed
+	}
+
+	expiresAt := time.Now().Add(s.cfg.NonceTTL)
+	if err := s.tokens.CreateNonce(nonce, expiresAt); err != nil {
+		s.logger.Errorf("failed to store nonce : %s", err)
+		return api.SiweNonce{}, TokenStoreFailed
+	}
+
+	return api.SiweNonce{Nonce: nonce, ExpiresAt: expiresAt}, nil
+}
+
+func (s *siweServiceImpl) SignIn(req api.SiweRequest) (*api.AuthResponse, error) {
+	address, err := s.verify(req)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.FindByAddress(address)
+	if err == UserNotFound {
+		if !s.cfg.CreateAccounts {
+			return nil, AddressNotLinked
+		}
+		if user, err = s.repo.CreateWithAddress(address); err != nil {
+			s.logger.Errorf("failed to create user for address '%s' : %s", address, err)
+			return nil, UserStoreFailed
+		}
+		s.logger.Infof("Registered user '%s' signing in with ethereum", address)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return s.sessions.startSession(user.ID)
+}
+
+func (s *siweServiceImpl) Link(userId uint64, req api.SiweRequest) (api.User, error) {
+	address, err := s.verify(req)
+	if err != nil {
+		return api.User{}, err
+	}
+
+	linked, err := s.repo.FindByAddress(address)
+	if err == nil && linked.ID != userId {
+		return api.User{}, AddressTaken
+	}
+
+	if err := s.repo.SetAddress(userId, address); err != nil {
+		s.logger.Errorf("failed to link address '%s' to user '%d' : %s", address, userId, err)
+		return api.User{}, UserStoreFailed
+	}
+
+	user, err := s.repo.FindById(userId)
+	if err != nil {
+		return api.User{}, err
+	}
+	s.logger.Infof("Linked address '%s' to user '%d'", address, userId)
+	return toApiUser(user), nil
+}
+
+// verify checks the message and its signature and uses up its nonce,
+// returning the checksummed address that signed it
+func (s *siweVerifier) verify(req api.SiweRequest) (string, error) {
+	if s.cfg.Domain == "" {
+		return "", SiweDisabled
+	}
+
+	msg, err := siwe.Parse(req.Message)
+	if err != nil {
+		return "", siweMessageError(err)
+	}
+
+	if msg.Domain != s.cfg.Domain || (s.cfg.ChainId != 0 && msg.ChainId != s.cfg.ChainId) {
+		return "", SiweDomainMismatch
+	}
+	now := time.Now()
+	if !msg.ValidAt(now) || msg.IssuedAt.After(now.Add(clockSkew)) {
+		return "", SiweMessageExpired
+	}
+	if err := msg.VerifySignature(req.Signature); err != nil {
+		return "", InvalidSiweSignature
+	}
+
+	// the nonce is used up last so that a bad request can't burn the nonce of
+	// a message someone else is signing
+	ok, err := s.tokens.ConsumeNonce(msg.Nonce)
+	if err != nil {
+		s.logger.Errorf("failed to use up nonce : %s", err)
+		return "", TokenStoreFailed
+	}
+	if !ok {
+		return "", InvalidSiweNonce
+	}
+
+	return msg.Address.Hex(), nil
+}
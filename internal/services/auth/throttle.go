@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"time"
+)
+
+// ThrottleConfig limits failed password sign-ins. Once a username or client
+// IP has failed more often than allowed within Window, each further attempt
+// has to wait, starting at a second and doubling up to MaxDelay.
+type ThrottleConfig struct {
+	MaxUserFailures int
+	MaxIpFailures   int
+	Window          time.Duration
+	MaxDelay        time.Duration
+}
+
+var DefaultThrottleConfig = ThrottleConfig{
+	MaxUserFailures: 5,
+	MaxIpFailures:   20,
+	Window:          15 * time.Minute,
+	MaxDelay:        15 * time.Minute,
+}
+
+const (
+	throttleBaseDelay = time.Second
+	// maxBackoffShift keeps the doubling from overflowing, MaxDelay caps it
+	// long before
+	maxBackoffShift = 20
+)
+
+// loginThrottle decides from the audit trail of failed sign-ins whether a
+// sign-in may be attempted, so that it holds across replicas
+type loginThrottle struct {
+	repo *AuditRepo
+	cfg  ThrottleConfig
+}
+
+func newLoginThrottle(repo *AuditRepo, cfg ThrottleConfig) *loginThrottle {
+	if cfg.MaxUserFailures <= 0 {
+		cfg.MaxUserFailures = DefaultThrottleConfig.MaxUserFailures
+	}
+	if cfg.MaxIpFailures <= 0 {
+		cfg.MaxIpFailures = DefaultThrottleConfig.MaxIpFailures
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultThrottleConfig.Window
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = DefaultThrottleConfig.MaxDelay
+	}
+	return &loginThrottle{repo: repo, cfg: cfg}
+}
+
+// retryAfter is how long the username and IP have to wait before the
+// reserved attempt, zero when they may try now. Attempts still pending count
+// as failures, so concurrent guesses can't all pass before any failed.
+func (t *loginThrottle) retryAfter(username string, ip string, attempt uint64, now time.Time) (time.Duration, error) {
+	since := now.Add(-t.cfg.Window)
+
+	userStats, err := t.repo.UserFailures(username, since, attempt)
+	if err != nil {
+		return 0, err
+	}
+	wait := t.delay(userStats, t.cfg.MaxUserFailures, now)
+
+	if ip != "" {
+		ipStats, err := t.repo.IpFailures(ip, since, attempt)
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, t.delay(ipStats, t.cfg.MaxIpFailures, now))
+	}
+	return wait, nil
+}
+
+func (t *loginThrottle) delay(stats failureStats, allowed int, now time.Time) time.Duration {
+	if stats.count < int64(allowed) {
+		return 0
+	}
+	backoff := throttleBaseDelay << min(stats.count-int64(allowed), maxBackoffShift)
+	backoff = min(backoff, t.cfg.MaxDelay)
+	return max(0, stats.last.Add(backoff).Sub(now))
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"time"
+
+	"ethereum_fetcher/db/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TokenRepo struct {
+	db *gorm.DB
+}
+
+func (r *TokenRepo) CreateRefreshToken(token *models.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *TokenRepo) FindRefreshToken(tokenHash string) (models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error
+	return token, err
+}
+
+// RotateRefreshToken revokes the token and stores its replacement atomically.
+// Only one of two concurrent refreshes with the same token succeeds.
+func (r *TokenRepo) RotateRefreshToken(id uint64, replacement *models.RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return InvalidRefreshToken
+		}
+		return tx.Create(replacement).Error
+	})
+}
+
+func (r *TokenRepo) RevokeFamily(familyId string) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *TokenRepo) RevokeUserRefreshTokens(userId uint64) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAccessToken adds the token to the revocation list until it expires
+// and drops entries for tokens that have expired since
+func (r *TokenRepo) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.RevokedToken{Jti: jti, ExpiresAt: expiresAt}).Error
+	})
+}
+
+func (r *TokenRepo) IsRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// CreateNonce stores a sign-in nonce and drops the ones that expired unused
+func (r *TokenRepo) CreateNonce(nonce string, expiresAt time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.SiweNonce{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.SiweNonce{Nonce: nonce, ExpiresAt: expiresAt}).Error
+	})
+}
+
+// ConsumeNonce deletes the nonce, reporting whether it was there and still
+// valid. Only one of two concurrent sign-ins with the same nonce succeeds.
+func (r *TokenRepo) ConsumeNonce(nonce string) (bool, error) {
+	result := r.db.Where("nonce = ? AND expires_at >= ?", nonce, time.Now()).Delete(&models.SiweNonce{})
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"regexp"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/pkg/logging"
+	"ethereum_fetcher/pkg/passwords"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{3,32}$`)
+
+type UserService interface {
+	Register(req api.CreateUserRequest) (api.User, error)
+	ChangePassword(userId uint64, req api.ChangePasswordRequest) error
+	Delete(userId uint64, req api.DeleteUserRequest) error
+	List() ([]api.User, error)
+	// SetRole and DeleteUser are admin operations, an admin can't use them on
+	// their own account so that there is always an admin left
+	SetRole(adminId uint64, userId uint64, role string) error
+	DeleteUser(adminId uint64, userId uint64) error
+	// PromoteToAdmin makes the named users admins, to bootstrap the first admin
+	PromoteToAdmin(usernames []string) error
+}
+
+type UserConfig struct {
+	RegistrationEnabled bool
+	Policy              passwords.Policy
+	// Siwe checks the messages users without a password confirm changes with
+	Siwe SiweConfig
+}
+
+type userServiceImpl struct {
+	repo   *UserRepo
+	tokens *TokenRepo
+	siwe   siweVerifier
+	cfg    UserConfig
+	logger *logrus.Logger
+}
+
+func NewUserService(db *gorm.DB, cfg UserConfig) UserService {
+	tokens := &TokenRepo{db: db}
+	logger := logging.New()
+	return &userServiceImpl{
+		repo:   &UserRepo{db: db},
+		tokens: tokens,
+		siwe:   siweVerifier{tokens: tokens, cfg: cfg.Siwe, logger: logger},
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+func (s *userServiceImpl) Register(req api.CreateUserRequest) (api.User, error) {
+	if !s.cfg.RegistrationEnabled {
+		return api.User{}, RegistrationDisabled
+	}
+	if !usernamePattern.MatchString(req.Username) {
+		return api.User{}, InvalidUsername
+	}
+
+	passwordHash, err := s.hashPassword(req.Password, req.Username)
+	if err != nil {
+		return api.User{}, err
+	}
+
+	user, err := s.repo.Create(req.Username, passwordHash)
+	if err == UsernameTaken {
+		return api.User{}, err
+	}
+	if err != nil {
+		s.logger.Errorf("failed to create user '%s':  %v", req.Username, err)
+		return api.User{}, UserStoreFailed
+	}
+
+	s.logger.Infof("Registered user '%s'", user.Username)
+	return toApiUser(user), nil
+}
+
+func (s *userServiceImpl) ChangePassword(userId uint64, req api.ChangePasswordRequest) error {
+	user, err := s.verifiedUser(userId, req.CurrentPassword, req.Siwe)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := s.hashPassword(req.NewPassword, user.Username)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdatePasswordHash(user.ID, passwordHash); err != nil {
+		s.logger.Errorf("failed to change the password of user '%d':  %v", userId, err)
+		return UserStoreFailed
+	}
+
+	// sessions started with the old password have to sign in again
+	if err := s.tokens.RevokeUserRefreshTokens(user.ID); err != nil {
+		s.logger.Errorf("failed to revoke the refresh tokens of user '%d':  %v", userId, err)
+		return TokenStoreFailed
+	}
+	return nil
+}
+
+func (s *userServiceImpl) Delete(userId uint64, req api.DeleteUserRequest) error {
+	user, err := s.verifiedUser(userId, req.Password, req.Siwe)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(user.ID); err != nil {
+		s.logger.Errorf("failed to delete user '%d':  %v", userId, err)
+		return UserStoreFailed
+	}
+
+	s.logger.Infof("Deleted user '%s'", user.Username)
+	return nil
+}
+
+func (s *userServiceImpl) List() ([]api.User, error) {
+	users, err := s.repo.List()
+	if err != nil {
+		s.logger.Errorf("failed to list users:  %v", err)
+		return nil, UserStoreFailed
+	}
+
+	apiUsers := make([]api.User, 0, len(users))
+	for _, user := range users {
+		apiUsers = append(apiUsers, toApiUser(user))
+	}
+	return apiUsers, nil
+}
+
+func (s *userServiceImpl) SetRole(adminId uint64, userId uint64, role string) error {
+	if !ValidRole(role) {
+		return InvalidRole
+	}
+	if adminId == userId {
+		return CannotModifySelf
+	}
+
+	if err := s.repo.SetRole(userId, role); err != nil {
+		if err == UserNotFound {
+			return err
+		}
+		s.logger.Errorf("failed to set the role of user '%d':  %v", userId, err)
+		return UserStoreFailed
+	}
+
+	s.logger.Infof("User '%d' set the role of user '%d' to '%s'", adminId, userId, role)
+	return nil
+}
+
+func (s *userServiceImpl) DeleteUser(adminId uint64, userId uint64) error {
+	if adminId == userId {
+		return CannotModifySelf
+	}
+	if _, err := s.repo.FindById(userId); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(userId); err != nil {
+		s.logger.Errorf("failed to delete user '%d':  %v", userId, err)
+		return UserStoreFailed
+	}
+
+	s.logger.Infof("User '%d' deleted user '%d'", adminId, userId)
+	return nil
+}
+
+func (s *userServiceImpl) PromoteToAdmin(usernames []string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+	return s.repo.PromoteToAdmin(usernames)
+}
+
+// verifiedUser loads the user and checks the password they confirmed the
+// change with, a stolen token alone can't take over or delete an account.
+// Users who only sign in with Ethereum confirm with a message signed by their
+// address instead.
+func (s *userServiceImpl) verifiedUser(userId uint64, password string, siwe *api.SiweRequest) (models.User, error) {
+	user, err := s.repo.FindById(userId)
+	if err != nil {
+		return user, err
+	}
+	if user.PasswordHash == "" {
+		if siwe == nil || user.Address == nil {
+			return user, SiweConfirmationRequired
+		}
+		address, err := s.siwe.verify(*siwe)
+		if err != nil {
+			return user, err
+		}
+		if address != *user.Address {
+			return user, InvalidSiweSignature
+		}
+		return user, nil
+	}
+	if err := passwords.ComparePasswords(user.PasswordHash, password); err != nil {
+		return user, InvalidPassword
+	}
+	return user, nil
+}
+
+func (s *userServiceImpl) hashPassword(password string, username string) (string, error) {
+	if err := s.cfg.Policy.Validate(password, username); err != nil {
+		return "", passwordPolicyError(err)
+	}
+
+	passwordHash, err := passwords.HashPassword(password)
+	if err != nil {
+		s.logger.Errorf("failed to hash password:  %v", err)
+		return "", UserStoreFailed
+	}
+	return passwordHash, nil
+}
+
+func toApiUser(user models.User) api.User {
+	return api.User{
+		Id:        user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		Address:   user.Address,
+		OrgId:     user.OrgId,
+		OrgRole:   user.OrgRole,
+		CreatedAt: user.CreatedAt,
+	}
+}
@@ -0,0 +1,26 @@
+package collections
+
+import "ethereum_fetcher/internal/services/errors"
+
+type CollectionError struct {
+	errors.ServiceError
+}
+
+func collectionError(msg string) CollectionError {
+	return CollectionError{errors.NewServiceError(msg)}
+}
+
+var (
+	InvalidCollectionName = collectionError("collection name must be 1 to 64 characters")
+	CollectionNameTaken   = collectionError("a collection with this name already exists")
+	CollectionNotFound    = collectionError("collection not found")
+	TooManyCollections    = collectionError("too many collections")
+	NotInCollection       = collectionError("transaction is not in the collection")
+	NotInHistory          = collectionError("transaction is not in the lookup history of the user")
+	InvalidTag            = collectionError("tags must be 1 to 32 lower case letters, digits, '.', '_', ':' or '-'")
+	TooManyTags           = collectionError("too many tags on the transaction")
+	NoteTooLong           = collectionError("note is too long")
+	NotInOrganization     = collectionError("user is not a member of an organization")
+	NotCollectionOwner    = collectionError("only the creator or an admin of the organization can delete the collection")
+	CollectionStoreFailed = collectionError("failed to store collection")
+)
@@ -0,0 +1,186 @@
+package collections
+
+import (
+	"time"
+
+	"ethereum_fetcher/db/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CollectionRepo struct {
+	db *gorm.DB
+}
+
+// Entry is a transaction in a collection
+type Entry struct {
+	ChainId         uint64
+	TransactionHash string
+}
+
+// collectionCount is a collection with the number of transactions in it
+type collectionCount struct {
+	models.Collection
+	TransactionCount int64
+}
+
+func (r *CollectionRepo) Create(collection *models.Collection) error {
+	return r.db.Create(collection).Error
+}
+
+// NameTaken checks the names of the collections of the organization, or of the
+// user when orgId is nil
+func (r *CollectionRepo) NameTaken(userId uint64, orgId *uint64, name string) (bool, error) {
+	var count int64
+	err := ownedBy(r.db.Model(&models.Collection{}), userId, orgId).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *CollectionRepo) Count(userId uint64, orgId *uint64) (int64, error) {
+	var count int64
+	err := ownedBy(r.db.Model(&models.Collection{}), userId, orgId).Count(&count).Error
+	return count, err
+}
+
+func ownedBy(query *gorm.DB, userId uint64, orgId *uint64) *gorm.DB {
+	if orgId != nil {
+		return query.Where("org_id = ?", *orgId)
+	}
+	return query.Where("org_id IS NULL AND user_id = ?", userId)
+}
+
+// visibleTo limits the query to the collections of the user and of their
+// organization
+func (r *CollectionRepo) visibleTo(query *gorm.DB, userId uint64) *gorm.DB {
+	orgOfUser := r.db.Model(&models.User{}).Select("org_id").Where("id = ? AND org_id IS NOT NULL", userId)
+	return query.Where("((collections.org_id IS NULL AND collections.user_id = ?) OR collections.org_id IN (?))", userId, orgOfUser)
+}
+
+// Find returns a collection the user can see, failing with
+// CollectionNotFound for collections of other users and organizations
+func (r *CollectionRepo) Find(userId uint64, id uint64) (collectionCount, error) {
+	var collections []collectionCount
+	err := r.visibleTo(r.withCounts(), userId).Where("collections.id = ?", id).Find(&collections).Error
+	if err != nil {
+		return collectionCount{}, err
+	}
+	if len(collections) == 0 {
+		return collectionCount{}, CollectionNotFound
+	}
+	return collections[0], nil
+}
+
+// ListForUser lists the collections of the user and of their organization
+func (r *CollectionRepo) ListForUser(userId uint64) ([]collectionCount, error) {
+	var collections []collectionCount
+	err := r.visibleTo(r.withCounts(), userId).Order("collections.name, collections.id").Find(&collections).Error
+	return collections, err
+}
+
+func (r *CollectionRepo) withCounts() *gorm.DB {
+	return r.db.Table("collections").
+		Select("collections.*, COUNT(collection_transactions.transaction_hash) AS transaction_count").
+		Joins("LEFT JOIN collection_transactions ON collection_transactions.collection_id = collections.id").
+		Group("collections.id")
+}
+
+func (r *CollectionRepo) FindUser(userId uint64) (models.User, error) {
+	var user models.User
+	err := r.db.First(&user, userId).Error
+	return user, err
+}
+
+func (r *CollectionRepo) Delete(id uint64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Collection{}, id).Error; err != nil {
+			return err
+		}
+		return tx.Where("collection_id = ?", id).Delete(&models.CollectionTransaction{}).Error
+	})
+}
+
+// AddTransactions adds the hashes on the chain to the collection, hashes
+// already in it are left alone
+func (r *CollectionRepo) AddTransactions(collectionId uint64, chainId uint64, hashes []string) error {
+	now := time.Now()
+	rows := make([]models.CollectionTransaction, 0, len(hashes))
+	for _, hash := range hashes {
+		rows = append(rows, models.CollectionTransaction{CollectionId: collectionId, ChainId: chainId, TransactionHash: hash, AddedAt: now})
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+func (r *CollectionRepo) Entries(collectionId uint64) ([]Entry, error) {
+	var entries []Entry
+	err := r.db.Model(&models.CollectionTransaction{}).
+		Select("chain_id, transaction_hash").
+		Where("collection_id = ?", collectionId).
+		Order("added_at, chain_id, transaction_hash").
+		Find(&entries).Error
+	return entries, err
+}
+
+func (r *CollectionRepo) RemoveTransaction(collectionId uint64, chainId uint64, hash string) error {
+	result := r.db.Where("collection_id = ? AND chain_id = ? AND transaction_hash = ?", collectionId, chainId, hash).
+		Delete(&models.CollectionTransaction{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return NotInCollection
+	}
+	return nil
+}
+
+// Requested returns the hashes the user looked up on the chain out of the
+// given ones
+func (r *CollectionRepo) Requested(userId uint64, chainId uint64, hashes []string) ([]string, error) {
+	var requested []string
+	err := r.db.Model(&models.UserTransaction{}).
+		Distinct("transaction_hash").
+		Where("user_id = ? AND chain_id = ? AND transaction_hash IN ?", userId, chainId, hashes).
+		Pluck("transaction_hash", &requested).Error
+	return requested, err
+}
+
+// SetTags replaces the tags of the user on the transaction
+func (r *CollectionRepo) SetTags(userId uint64, chainId uint64, hash string, tags []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND chain_id = ? AND transaction_hash = ?", userId, chainId, hash).Delete(&models.TransactionTag{}).Error
+		if err != nil || len(tags) == 0 {
+			return err
+		}
+
+		now := time.Now()
+		rows := make([]models.TransactionTag, 0, len(tags))
+		for _, tag := range tags {
+			rows = append(rows, models.TransactionTag{UserId: userId, ChainId: chainId, TransactionHash: hash, Tag: tag, CreatedAt: now})
+		}
+		return tx.Create(&rows).Error
+	})
+}
+
+// SetNote replaces the note of the user on the transaction, an empty note
+// removes it
+func (r *CollectionRepo) SetNote(userId uint64, chainId uint64, hash string, note string) error {
+	if note == "" {
+		return r.db.Where("user_id = ? AND chain_id = ? AND transaction_hash = ?", userId, chainId, hash).Delete(&models.TransactionNote{}).Error
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "chain_id"}, {Name: "transaction_hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"note", "updated_at"}),
+	}).Create(&models.TransactionNote{UserId: userId, ChainId: chainId, TransactionHash: hash, Note: note, UpdatedAt: time.Now()}).Error
+}
+
+func (r *CollectionRepo) Tags(userId uint64, hashes []string) ([]models.TransactionTag, error) {
+	var tags []models.TransactionTag
+	err := r.db.Where("user_id = ? AND transaction_hash IN ?", userId, hashes).Order("tag").Find(&tags).Error
+	return tags, err
+}
+
+func (r *CollectionRepo) Notes(userId uint64, hashes []string) ([]models.TransactionNote, error) {
+	var notes []models.TransactionNote
+	err := r.db.Where("user_id = ? AND transaction_hash IN ?", userId, hashes).Find(&notes).Error
+	return notes, err
+}
@@ -0,0 +1,303 @@
+package collections
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/pkg/logging"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	maxCollectionName      = 64
+	maxCollectionsPerOwner = 100
+	maxTagsPerTransaction  = 20
+	maxNoteLength          = 2000
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9._:-]{1,32}$`)
+
+// CollectionService keeps the collections, tags and notes users organize the
+// transactions they looked up with. Only transactions in the history of the
+// user can be added, tagged or noted. Collections of an organization are
+// shared by all of its members.
+type CollectionService interface {
+	// Create creates a collection of the user, or of their organization when
+	// req.Org is set
+	Create(userId uint64, req api.CreateCollectionRequest) (api.Collection, error)
+	// List lists the collections of the user and of their organization
+	List(userId uint64) ([]api.Collection, error)
+	// Delete deletes a collection, collections of an organization can be
+	// deleted by their creator and the admins
+	Delete(userId uint64, collectionId uint64) error
+	// AddTransactions adds transactions on the chain to the collection
+	AddTransactions(userId uint64, collectionId uint64, chainId uint64, hashes []string) (api.Collection, error)
+	RemoveTransaction(userId uint64, collectionId uint64, chainId uint64, hash string) error
+	// SetTags replaces the tags of the user on the transaction, they are
+	// lower cased
+	SetTags(userId uint64, chainId uint64, hash string, tags []string) error
+	// SetNote replaces the note of the user on the transaction, an empty note
+	// removes it
+	SetNote(userId uint64, chainId uint64, hash string, note string) error
+	// Annotate fills in the tags and notes of the user on the transactions
+	Annotate(userId uint64, txns []api.Transaction) error
+	// Entries lists the transactions in a collection of the user, in the
+	// order they were added
+	Entries(userId uint64, collectionId uint64) ([]Entry, error)
+	// InHistory fails with NotInHistory unless the user looked up every hash
+	// on the chain
+	InHistory(userId uint64, chainId uint64, hashes []string) error
+}
+
+type impl struct {
+	repo   *CollectionRepo
+	logger *logrus.Logger
+}
+
+func NewCollectionService(db *gorm.DB) CollectionService {
+	return &impl{repo: &CollectionRepo{db: db}, logger: logging.New()}
+}
+
+func (s *impl) Create(userId uint64, req api.CreateCollectionRequest) (api.Collection, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxCollectionName {
+		return api.Collection{}, InvalidCollectionName
+	}
+
+	var orgId *uint64
+	if req.Org {
+		user, err := s.repo.FindUser(userId)
+		if err != nil {
+			return api.Collection{}, s.storeError(err)
+		}
+		if user.OrgId == nil {
+			return api.Collection{}, NotInOrganization
+		}
+		orgId = user.OrgId
+	}
+
+	taken, err := s.repo.NameTaken(userId, orgId, name)
+	if err != nil {
+		return api.Collection{}, s.storeError(err)
+	}
+	if taken {
+		return api.Collection{}, CollectionNameTaken
+	}
+	count, err := s.repo.Count(userId, orgId)
+	if err != nil {
+		return api.Collection{}, s.storeError(err)
+	}
+	if count >= maxCollectionsPerOwner {
+		return api.Collection{}, TooManyCollections
+	}
+
+	collection := models.Collection{UserId: userId, OrgId: orgId, Name: name}
+	if err := s.repo.Create(&collection); err != nil {
+		return api.Collection{}, s.storeError(err)
+	}
+	return toApiCollection(collectionCount{Collection: collection}), nil
+}
+
+func (s *impl) List(userId uint64) ([]api.Collection, error) {
+	collections, err := s.repo.ListForUser(userId)
+	if err != nil {
+		return nil, s.storeError(err)
+	}
+
+	result := make([]api.Collection, 0, len(collections))
+	for _, collection := range collections {
+		result = append(result, toApiCollection(collection))
+	}
+	return result, nil
+}
+
+func (s *impl) Delete(userId uint64, collectionId uint64) error {
+	collection, err := s.repo.Find(userId, collectionId)
+	if err != nil {
+		return s.storeError(err)
+	}
+	if collection.UserId != userId {
+		// only visible to other members for collections of their organization
+		user, err := s.repo.FindUser(userId)
+		if err != nil {
+			return s.storeError(err)
+		}
+		if user.OrgRole != orgAdminRole {
+			return NotCollectionOwner
+		}
+	}
+
+	if err := s.repo.Delete(collectionId); err != nil {
+		return s.storeError(err)
+	}
+	return nil
+}
+
+// orgAdminRole is the organization role that manages its collections
+const orgAdminRole = "admin"
+
+func (s *impl) AddTransactions(userId uint64, collectionId uint64, chainId uint64, hashes []string) (api.Collection, error) {
+	if _, err := s.repo.Find(userId, collectionId); err != nil {
+		return api.Collection{}, s.storeError(err)
+	}
+	if err := s.requireRequested(userId, chainId, hashes...); err != nil {
+		return api.Collection{}, err
+	}
+
+	if err := s.repo.AddTransactions(collectionId, chainId, hashes); err != nil {
+		return api.Collection{}, s.storeError(err)
+	}
+
+	collection, err := s.repo.Find(userId, collectionId)
+	if err != nil {
+		return api.Collection{}, s.storeError(err)
+	}
+	return toApiCollection(collection), nil
+}
+
+func (s *impl) RemoveTransaction(userId uint64, collectionId uint64, chainId uint64, hash string) error {
+	if _, err := s.repo.Find(userId, collectionId); err != nil {
+		return s.storeError(err)
+	}
+	if err := s.repo.RemoveTransaction(collectionId, chainId, hash); err != nil {
+		return s.storeError(err)
+	}
+	return nil
+}
+
+func (s *impl) SetTags(userId uint64, chainId uint64, hash string, tags []string) error {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return InvalidTag
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxTagsPerTransaction {
+		return TooManyTags
+	}
+
+	if err := s.requireRequested(userId, chainId, hash); err != nil {
+		return err
+	}
+	if err := s.repo.SetTags(userId, chainId, hash, normalized); err != nil {
+		return s.storeError(err)
+	}
+	return nil
+}
+
+func (s *impl) SetNote(userId uint64, chainId uint64, hash string, note string) error {
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > maxNoteLength {
+		return NoteTooLong
+	}
+
+	if err := s.requireRequested(userId, chainId, hash); err != nil {
+		return err
+	}
+	if err := s.repo.SetNote(userId, chainId, hash, note); err != nil {
+		return s.storeError(err)
+	}
+	return nil
+}
+
+func (s *impl) Annotate(userId uint64, txns []api.Transaction) error {
+	if len(txns) == 0 {
+		return nil
+	}
+
+	hashes := make([]string, 0, len(txns))
+	for _, txn := range txns {
+		hashes = append(hashes, txn.TransactionHash)
+	}
+
+	tags, err := s.repo.Tags(userId, hashes)
+	if err != nil {
+		return s.storeError(err)
+	}
+	notes, err := s.repo.Notes(userId, hashes)
+	if err != nil {
+		return s.storeError(err)
+	}
+
+	// the hashes may be known on several chains
+	tagsByTxn := make(map[Entry][]string)
+	for _, tag := range tags {
+		entry := Entry{ChainId: tag.ChainId, TransactionHash: tag.TransactionHash}
+		tagsByTxn[entry] = append(tagsByTxn[entry], tag.Tag)
+	}
+	notesByTxn := make(map[Entry]string, len(notes))
+	for _, note := range notes {
+		notesByTxn[Entry{ChainId: note.ChainId, TransactionHash: note.TransactionHash}] = note.Note
+	}
+
+	for i := range txns {
+		entry := Entry{ChainId: txns[i].ChainId, TransactionHash: txns[i].TransactionHash}
+		txns[i].Tags = tagsByTxn[entry]
+		if note, ok := notesByTxn[entry]; ok {
+			txns[i].Note = &note
+		}
+	}
+	return nil
+}
+
+func (s *impl) Entries(userId uint64, collectionId uint64) ([]Entry, error) {
+	if _, err := s.repo.Find(userId, collectionId); err != nil {
+		return nil, s.storeError(err)
+	}
+	entries, err := s.repo.Entries(collectionId)
+	if err != nil {
+		return nil, s.storeError(err)
+	}
+	return entries, nil
+}
+
+func (s *impl) InHistory(userId uint64, chainId uint64, hashes []string) error {
+	return s.requireRequested(userId, chainId, hashes...)
+}
+
+// requireRequested fails with NotInHistory unless the user looked up every
+// hash on the chain
+func (s *impl) requireRequested(userId uint64, chainId uint64, hashes ...string) error {
+	if len(hashes) == 0 {
+		return NotInHistory
+	}
+	requested, err := s.repo.Requested(userId, chainId, hashes)
+	if err != nil {
+		return s.storeError(err)
+	}
+	for _, hash := range hashes {
+		if !slices.Contains(requested, hash) {
+			return NotInHistory
+		}
+	}
+	return nil
+}
+
+// storeError passes collection errors through and logs the rest
+func (s *impl) storeError(err error) error {
+	if _, ok := err.(CollectionError); ok {
+		return err
+	}
+	s.logger.Errorf("failed to access collections : %s", err)
+	return CollectionStoreFailed
+}
+
+func toApiCollection(collection collectionCount) api.Collection {
+	return api.Collection{
+		Id:               collection.ID,
+		OrgId:            collection.OrgId,
+		Name:             collection.Name,
+		TransactionCount: collection.TransactionCount,
+		CreatedAt:        collection.CreatedAt,
+	}
+}
@@ -0,0 +1,26 @@
+package orgs
+
+import "ethereum_fetcher/internal/services/errors"
+
+type OrgError struct {
+	errors.ServiceError
+}
+
+func orgError(msg string) OrgError {
+	return OrgError{errors.NewServiceError(msg)}
+}
+
+var (
+	InvalidOrgName = orgError("organization name must be 1 to 64 characters")
+	InvalidOrgRole = orgError("organization role must be admin or member")
+	OrgNameTaken   = orgError("organization name is already taken")
+	AlreadyInOrg   = orgError("user already belongs to an organization")
+	OrgNotFound    = orgError("user is not a member of an organization")
+	MemberNotFound = orgError("user is not a member of the organization")
+	UserNotFound   = orgError("user not found")
+	AlreadyInvited = orgError("user is already invited to the organization")
+	InviteNotFound = orgError("invite not found")
+	NotAnOrgAdmin  = orgError("only organization admins can manage the organization")
+	LastOrgAdmin   = orgError("the last admin can't leave or be demoted, promote another member first")
+	OrgStoreFailed = orgError("failed to store organization")
+)
@@ -0,0 +1,203 @@
+package orgs
+
+import (
+	"errors"
+
+	"ethereum_fetcher/db/models"
+
+	"gorm.io/gorm"
+)
+
+type OrgRepo struct {
+	db *gorm.DB
+}
+
+// Create creates the organization with the user as its admin, failing with
+// AlreadyInOrg when they belong to one
+func (r *OrgRepo) Create(name string, userId uint64) (models.Organization, error) {
+	org := models.Organization{Name: name}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return join(tx, org.ID, userId, RoleAdmin)
+	})
+	return org, err
+}
+
+func (r *OrgRepo) FindById(id uint64) (models.Organization, error) {
+	var org models.Organization
+	err := r.db.First(&org, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return org, OrgNotFound
+	}
+	return org, err
+}
+
+func (r *OrgRepo) NameTaken(name string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Organization{}).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *OrgRepo) FindUser(id uint64) (models.User, error) {
+	var user models.User
+	err := r.db.First(&user, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, UserNotFound
+	}
+	return user, err
+}
+
+func (r *OrgRepo) FindUserByUsername(username string) (models.User, error) {
+	var user models.User
+	err := r.db.Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, UserNotFound
+	}
+	return user, err
+}
+
+func (r *OrgRepo) Members(orgId uint64) ([]models.User, error) {
+	var users []models.User
+	err := r.db.Where("org_id = ?", orgId).Order("id").Find(&users).Error
+	return users, err
+}
+
+func (r *OrgRepo) CountAdmins(orgId uint64) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("org_id = ? AND org_role = ?", orgId, RoleAdmin).Count(&count).Error
+	return count, err
+}
+
+// inviteDetails is an invite with the names it is shown with
+type inviteDetails struct {
+	models.OrgInvite
+	OrgName       string
+	Username      string
+	InvitedByName string
+}
+
+func (r *OrgRepo) CreateInvite(invite *models.OrgInvite) error {
+	return r.db.Create(invite).Error
+}
+
+func (r *OrgRepo) FindInvite(id uint64) (models.OrgInvite, error) {
+	var invite models.OrgInvite
+	err := r.db.First(&invite, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return invite, InviteNotFound
+	}
+	return invite, err
+}
+
+func (r *OrgRepo) Invited(orgId uint64, userId uint64) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.OrgInvite{}).Where("org_id = ? AND user_id = ?", orgId, userId).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *OrgRepo) InvitesForOrg(orgId uint64) ([]inviteDetails, error) {
+	var invites []inviteDetails
+	err := r.withDetails().Where("org_invites.org_id = ?", orgId).Order("org_invites.id").Find(&invites).Error
+	return invites, err
+}
+
+func (r *OrgRepo) InvitesForUser(userId uint64) ([]inviteDetails, error) {
+	var invites []inviteDetails
+	err := r.withDetails().Where("org_invites.user_id = ?", userId).Order("org_invites.id").Find(&invites).Error
+	return invites, err
+}
+
+func (r *OrgRepo) withDetails() *gorm.DB {
+	return r.db.Table("org_invites").
+		Select("org_invites.*, organizations.name AS org_name, invitees.username AS username, inviters.username AS invited_by_name").
+		Joins("JOIN organizations ON organizations.id = org_invites.org_id").
+		Joins("JOIN users AS invitees ON invitees.id = org_invites.user_id").
+		Joins("LEFT JOIN users AS inviters ON inviters.id = org_invites.invited_by")
+}
+
+// Accept adds the invited user to the organization and drops the invite,
+// failing with AlreadyInOrg when they belong to one
+func (r *OrgRepo) Accept(invite models.OrgInvite) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := join(tx, invite.OrgId, invite.UserId, invite.Role); err != nil {
+			return err
+		}
+		return tx.Delete(&models.OrgInvite{}, invite.ID).Error
+	})
+}
+
+func (r *OrgRepo) DeleteInvite(id uint64) error {
+	return r.db.Delete(&models.OrgInvite{}, id).Error
+}
+
+// join adds the user to the organization, failing with AlreadyInOrg when they
+// belong to one
+func join(db *gorm.DB, orgId uint64, userId uint64, role string) error {
+	result := db.Model(&models.User{}).
+		Where("id = ? AND org_id IS NULL", userId).
+		Updates(map[string]interface{}{"org_id": orgId, "org_role": role})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return AlreadyInOrg
+	}
+	return nil
+}
+
+// SetRole changes the role of a member, failing with MemberNotFound for users
+// of other organizations
+func (r *OrgRepo) SetRole(orgId uint64, userId uint64, role string) error {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND org_id = ?", userId, orgId).
+		Update("org_role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return MemberNotFound
+	}
+	return nil
+}
+
+// Leave removes a member, their lookups stay in the history of the
+// organization
+func (r *OrgRepo) Leave(orgId uint64, userId uint64) error {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND org_id = ?", userId, orgId).
+		Updates(map[string]interface{}{"org_id": nil, "org_role": ""})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return MemberNotFound
+	}
+	return nil
+}
+
+// Delete removes every member, the open invites, the collections and the
+// organization. The lookups made for it stay in the history of the members who
+// made them.
+func (r *OrgRepo) Delete(orgId uint64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).
+			Where("org_id = ?", orgId).
+			Updates(map[string]interface{}{"org_id": nil, "org_role": ""}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ?", orgId).Delete(&models.OrgInvite{}).Error; err != nil {
+			return err
+		}
+		collections := tx.Model(&models.Collection{}).Select("id").Where("org_id = ?", orgId)
+		if err := tx.Where("collection_id IN (?)", collections).Delete(&models.CollectionTransaction{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ?", orgId).Delete(&models.Collection{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Organization{}, orgId).Error
+	})
+}
@@ -0,0 +1,351 @@
+package orgs
+
+import (
+	"strings"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/pkg/logging"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// RoleAdmin manages the members of the organization
+	RoleAdmin = "admin"
+	// RoleMember shares the lookup history of the organization
+	RoleMember = "member"
+
+	maxOrgName = 64
+)
+
+// OrgService manages organizations, whose members share their lookup
+// history. A user belongs to at most one organization, the operations act on
+// the organization of the calling user. Users only join by accepting an
+// invite, so their history isn't shared without their consent.
+type OrgService interface {
+	// Create creates an organization with the user as its admin
+	Create(userId uint64, req api.CreateOrgRequest) (api.Organization, error)
+	// Get returns the organization of the user with its members
+	Get(userId uint64) (api.Organization, error)
+	// Delete removes the organization, only its admins can
+	Delete(adminId uint64) error
+	// Invite invites a user that isn't in an organization yet to the one of
+	// the admin
+	Invite(adminId uint64, req api.InviteOrgMemberRequest) (api.OrgInvite, error)
+	// Invites lists the invites the user received
+	Invites(userId uint64) ([]api.OrgInvite, error)
+	// AcceptInvite makes the user a member of the organization they were
+	// invited to
+	AcceptInvite(userId uint64, inviteId uint64) (api.Organization, error)
+	// RemoveInvite lets the invited user decline and admins of the
+	// organization withdraw an invite
+	RemoveInvite(userId uint64, inviteId uint64) error
+	SetMemberRole(adminId uint64, userId uint64, role string) error
+	// RemoveMember lets admins remove any member and members leave, the last
+	// admin can't leave
+	RemoveMember(userId uint64, memberId uint64) error
+}
+
+type impl struct {
+	repo   *OrgRepo
+	logger *logrus.Logger
+}
+
+func NewOrgService(db *gorm.DB) OrgService {
+	return &impl{repo: &OrgRepo{db: db}, logger: logging.New()}
+}
+
+func (s *impl) Create(userId uint64, req api.CreateOrgRequest) (api.Organization, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxOrgName {
+		return api.Organization{}, InvalidOrgName
+	}
+
+	taken, err := s.repo.NameTaken(name)
+	if err != nil {
+		s.logger.Errorf("failed to check organization name '%s' : %s", name, err)
+		return api.Organization{}, OrgStoreFailed
+	}
+	if taken {
+		return api.Organization{}, OrgNameTaken
+	}
+
+	if _, err := s.repo.Create(name, userId); err != nil {
+		if err == AlreadyInOrg {
+			return api.Organization{}, err
+		}
+		s.logger.Errorf("failed to create organization '%s' : %s", name, err)
+		return api.Organization{}, OrgStoreFailed
+	}
+
+	s.logger.Infof("User '%d' created organization '%s'", userId, name)
+	return s.Get(userId)
+}
+
+func (s *impl) Get(userId uint64) (api.Organization, error) {
+	_, org, err := s.membership(userId)
+	if err != nil {
+		return api.Organization{}, err
+	}
+
+	members, err := s.repo.Members(org.ID)
+	if err != nil {
+		s.logger.Errorf("failed to list members of organization '%d' : %s", org.ID, err)
+		return api.Organization{}, OrgStoreFailed
+	}
+
+	invites, err := s.repo.InvitesForOrg(org.ID)
+	if err != nil {
+		s.logger.Errorf("failed to list invites of organization '%d' : %s", org.ID, err)
+		return api.Organization{}, OrgStoreFailed
+	}
+
+	result := api.Organization{
+		Id:        org.ID,
+		Name:      org.Name,
+		Members:   make([]api.OrgMember, 0, len(members)),
+		Invites:   toApiInvites(invites),
+		CreatedAt: org.CreatedAt,
+	}
+	for _, member := range members {
+		result.Members = append(result.Members, toApiMember(member))
+	}
+	return result, nil
+}
+
+func (s *impl) Delete(adminId uint64) error {
+	org, err := s.adminOrg(adminId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(org.ID); err != nil {
+		s.logger.Errorf("failed to delete organization '%d' : %s", org.ID, err)
+		return OrgStoreFailed
+	}
+	s.logger.Infof("User '%d' deleted organization '%s'", adminId, org.Name)
+	return nil
+}
+
+func (s *impl) Invite(adminId uint64, req api.InviteOrgMemberRequest) (api.OrgInvite, error) {
+	role := req.Role
+	if role == "" {
+		role = RoleMember
+	}
+	if !validRole(role) {
+		return api.OrgInvite{}, InvalidOrgRole
+	}
+
+	org, err := s.adminOrg(adminId)
+	if err != nil {
+		return api.OrgInvite{}, err
+	}
+
+	user, err := s.repo.FindUserByUsername(req.Username)
+	if err != nil {
+		return api.OrgInvite{}, s.storeError(err)
+	}
+	if user.OrgId != nil {
+		return api.OrgInvite{}, AlreadyInOrg
+	}
+	invited, err := s.repo.Invited(org.ID, user.ID)
+	if err != nil {
+		return api.OrgInvite{}, s.storeError(err)
+	}
+	if invited {
+		return api.OrgInvite{}, AlreadyInvited
+	}
+
+	invite := models.OrgInvite{OrgId: org.ID, UserId: user.ID, Role: role, InvitedBy: adminId}
+	if err := s.repo.CreateInvite(&invite); err != nil {
+		return api.OrgInvite{}, s.storeError(err)
+	}
+
+	s.logger.Infof("Invited user '%s' to organization '%s' as %s", user.Username, org.Name, role)
+	admin, err := s.repo.FindUser(adminId)
+	if err != nil {
+		return api.OrgInvite{}, s.storeError(err)
+	}
+	return toApiInvite(inviteDetails{OrgInvite: invite, OrgName: org.Name, Username: user.Username, InvitedByName: admin.Username}), nil
+}
+
+func (s *impl) Invites(userId uint64) ([]api.OrgInvite, error) {
+	invites, err := s.repo.InvitesForUser(userId)
+	if err != nil {
+		return nil, s.storeError(err)
+	}
+	return toApiInvites(invites), nil
+}
+
+func (s *impl) AcceptInvite(userId uint64, inviteId uint64) (api.Organization, error) {
+	invite, err := s.repo.FindInvite(inviteId)
+	if err != nil {
+		return api.Organization{}, s.storeError(err)
+	}
+	// invites of other users don't exist for the user
+	if invite.UserId != userId {
+		return api.Organization{}, InviteNotFound
+	}
+
+	if err := s.repo.Accept(invite); err != nil {
+		return api.Organization{}, s.storeError(err)
+	}
+
+	s.logger.Infof("User '%d' joined organization '%d' as %s", userId, invite.OrgId, invite.Role)
+	return s.Get(userId)
+}
+
+func (s *impl) RemoveInvite(userId uint64, inviteId uint64) error {
+	invite, err := s.repo.FindInvite(inviteId)
+	if err != nil {
+		return s.storeError(err)
+	}
+	if invite.UserId != userId {
+		org, err := s.adminOrg(userId)
+		if err == OrgNotFound || err == NotAnOrgAdmin || (err == nil && org.ID != invite.OrgId) {
+			return InviteNotFound
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.DeleteInvite(inviteId); err != nil {
+		return s.storeError(err)
+	}
+	return nil
+}
+
+func (s *impl) SetMemberRole(adminId uint64, userId uint64, role string) error {
+	if !validRole(role) {
+		return InvalidOrgRole
+	}
+
+	org, err := s.adminOrg(adminId)
+	if err != nil {
+		return err
+	}
+
+	if role != RoleAdmin {
+		if err := s.keepAnAdmin(org.ID, userId); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.SetRole(org.ID, userId, role); err != nil {
+		return s.storeError(err)
+	}
+	return nil
+}
+
+func (s *impl) RemoveMember(userId uint64, memberId uint64) error {
+	user, org, err := s.membership(userId)
+	if err != nil {
+		return err
+	}
+	if memberId != userId && user.OrgRole != RoleAdmin {
+		return NotAnOrgAdmin
+	}
+
+	if err := s.keepAnAdmin(org.ID, memberId); err != nil {
+		return err
+	}
+
+	if err := s.repo.Leave(org.ID, memberId); err != nil {
+		return s.storeError(err)
+	}
+	s.logger.Infof("Removed user '%d' from organization '%s'", memberId, org.Name)
+	return nil
+}
+
+// keepAnAdmin fails with LastOrgAdmin when the member is the only admin left
+func (s *impl) keepAnAdmin(orgId uint64, memberId uint64) error {
+	member, err := s.repo.FindUser(memberId)
+	if err != nil {
+		return s.storeError(err)
+	}
+	if member.OrgId == nil || *member.OrgId != orgId {
+		return MemberNotFound
+	}
+	if member.OrgRole != RoleAdmin {
+		return nil
+	}
+
+	admins, err := s.repo.CountAdmins(orgId)
+	if err != nil {
+		return s.storeError(err)
+	}
+	if admins <= 1 {
+		return LastOrgAdmin
+	}
+	return nil
+}
+
+// membership returns the user and their organization, failing with
+// OrgNotFound when they don't belong to one
+func (s *impl) membership(userId uint64) (models.User, models.Organization, error) {
+	user, err := s.repo.FindUser(userId)
+	if err != nil {
+		return models.User{}, models.Organization{}, s.storeError(err)
+	}
+	if user.OrgId == nil {
+		return models.User{}, models.Organization{}, OrgNotFound
+	}
+
+	org, err := s.repo.FindById(*user.OrgId)
+	if err != nil {
+		return models.User{}, models.Organization{}, s.storeError(err)
+	}
+	return user, org, nil
+}
+
+func (s *impl) adminOrg(userId uint64) (models.Organization, error) {
+	user, org, err := s.membership(userId)
+	if err != nil {
+		return models.Organization{}, err
+	}
+	if user.OrgRole != RoleAdmin {
+		return models.Organization{}, NotAnOrgAdmin
+	}
+	return org, nil
+}
+
+// storeError passes organization errors through and logs the rest
+func (s *impl) storeError(err error) error {
+	if _, ok := err.(OrgError); ok {
+		return err
+	}
+	s.logger.Errorf("failed to access organizations : %s", err)
+	return OrgStoreFailed
+}
+
+func validRole(role string) bool {
+	return role == RoleAdmin || role == RoleMember
+}
+
+func toApiInvites(invites []inviteDetails) []api.OrgInvite {
+	result := make([]api.OrgInvite, 0, len(invites))
+	for _, invite := range invites {
+		result = append(result, toApiInvite(invite))
+	}
+	return result
+}
+
+func toApiInvite(invite inviteDetails) api.OrgInvite {
+	return api.OrgInvite{
+		Id:        invite.ID,
+		OrgId:     invite.OrgId,
+		OrgName:   invite.OrgName,
+		UserId:    invite.UserId,
+		Username:  invite.Username,
+		Role:      invite.Role,
+		InvitedBy: invite.InvitedByName,
+		CreatedAt: invite.CreatedAt,
+	}
+}
+
+func toApiMember(user models.User) api.OrgMember {
+	return api.OrgMember{UserId: user.ID, Username: user.Username, Role: user.OrgRole}
+}
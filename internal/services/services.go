@@ -2,28 +2,141 @@ package services
 
 import (
 	"ethereum_fetcher/internal/config"
+	"ethereum_fetcher/internal/services/admin"
 	"ethereum_fetcher/internal/services/auth"
+	"ethereum_fetcher/internal/services/collections"
+	"ethereum_fetcher/internal/services/orgs"
+	"ethereum_fetcher/internal/services/shares"
 	"ethereum_fetcher/internal/services/transactions"
+	"ethereum_fetcher/internal/services/usage"
+	"ethereum_fetcher/pkg/passwords"
 	"fmt"
 
 	"gorm.io/gorm"
 )
 
 type Services struct {
-	Auth auth.AuthService
-	Tx   transactions.TxnService
+	Auth    auth.AuthService
+	Users   auth.UserService
+	ApiKeys auth.ApiKeyService
+	Siwe    auth.SiweService
+	Orgs    orgs.OrgService
+	Tx      transactions.TxnService
+	// Collections keeps the collections, tags and notes of users
+	Collections collections.CollectionService
+	// Shares hands out read-only links to transactions
+	Shares shares.ShareService
+	// Warmer fills the transaction cache on startup and saves it on shutdown
+	Warmer *transactions.CacheWarmer
+	Admin  admin.AdminService
+	Usage  usage.UsageService
 }
 
 func Init(db *gorm.DB, cfg config.Config) (*Services, error) {
-	authService, err := auth.NewAuthService(db, cfg.JWTSecret)
+	var keys *auth.KeySet
+	if cfg.JWTKeysDir != "" {
+		var err error
+		if keys, err = auth.LoadKeySet(cfg.JWTKeysDir); err != nil {
+			return nil, fmt.Errorf("failed to load JWT signing keys:  %w", err)
+		}
+	}
+
+	tokenConfig := auth.TokenConfig{
+		Secret:     cfg.JWTSecret,
+		Keys:       keys,
+		Issuer:     cfg.JWTIssuer,
+		AccessTTL:  cfg.AccessTokenTTL,
+		RefreshTTL: cfg.RefreshTokenTTL,
+		Throttle: auth.ThrottleConfig{
+			MaxUserFailures: cfg.LoginMaxUserFailures,
+			MaxIpFailures:   cfg.LoginMaxIpFailures,
+			Window:          cfg.LoginFailureWindow,
+			MaxDelay:        cfg.LoginMaxDelay,
+		},
+	}
+	authService, err := auth.NewAuthService(db, tokenConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create auth service:  %w", err)
 	}
 
-	txService, err := transactions.NewTxnService(db, cfg.EthNodeURL)
+	siweConfig := auth.SiweConfig{
+		Domain:         cfg.SiweDomain,
+		ChainId:        cfg.SiweChainId,
+		NonceTTL:       cfg.SiweNonceTTL,
+		CreateAccounts: cfg.RegistrationEnabled,
+	}
+	siweService, err := auth.NewSiweService(db, tokenConfig, siweConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sign-in with ethereum service:  %w", err)
+	}
+
+	userService := auth.NewUserService(db, auth.UserConfig{
+		RegistrationEnabled: cfg.RegistrationEnabled,
+		Policy:              passwords.DefaultPolicy,
+		Siwe:                siweConfig,
+	})
+	if err := userService.PromoteToAdmin(cfg.AdminUsers); err != nil {
+		return nil, fmt.Errorf("failed to promote admin users:  %w", err)
+	}
+
+	chains := make([]transactions.Chain, 0, len(cfg.Chains))
+	for _, chain := range cfg.Chains {
+		cache, err := transactions.NewChainCache(cfg.Cache, chain.ChainId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create txn cache for chain '%s':  %w", chain.Name, err)
+		}
+		chains = append(chains, transactions.Chain{
+			Id:      chain.ChainId,
+			Name:    chain.Name,
+			Type:    chain.Type,
+			NodeURL: chain.NodeURL,
+			Traces:  chain.Traces,
+			Cache:   cache,
+		})
+	}
+
+	txService, err := transactions.NewTxnService(db, chains)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create txn service:  %w", err)
 	}
 
-	return &Services{Auth: authService, Tx: txService}, nil
+	warmer, err := transactions.NewCacheWarmer(db, chains, cfg.Cache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache warmer:  %w", err)
+	}
+
+	adminService, err := admin.NewAdminService(db, chains, cfg.Cache.Backend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin service:  %w", err)
+	}
+
+	collectionService := collections.NewCollectionService(db)
+	shareService := shares.NewShareService(db, collectionService, txService, shares.ShareConfig{
+		Secret: cfg.ShareSecret,
+		MaxTTL: cfg.ShareMaxTTL,
+	})
+
+	return &Services{
+		Auth:        authService,
+		Users:       userService,
+		ApiKeys:     auth.NewApiKeyService(db),
+		Siwe:        siweService,
+		Orgs:        orgs.NewOrgService(db),
+		Tx:          txService,
+		Collections: collectionService,
+		Shares:      shareService,
+		Warmer:      warmer,
+		Admin:       adminService,
+		Usage: usage.NewUsageService(db, usage.Config{
+			UserRPS:                  cfg.Usage.UserRPS,
+			UserBurst:                cfg.Usage.UserBurst,
+			ApiKeyRPS:                cfg.Usage.ApiKeyRPS,
+			ApiKeyBurst:              cfg.Usage.ApiKeyBurst,
+			AnonymousRPS:             cfg.Usage.AnonymousRPS,
+			AnonymousBurst:           cfg.Usage.AnonymousBurst,
+			UserNodeCallsPerDay:      cfg.Usage.UserNodeCallsPerDay,
+			ApiKeyNodeCallsPerDay:    cfg.Usage.ApiKeyNodeCallsPerDay,
+			AnonymousNodeCallsPerDay: cfg.Usage.AnonymousNodeCallsPerDay,
+		}),
+	}, nil
 }
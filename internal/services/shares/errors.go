@@ -0,0 +1,22 @@
+package shares
+
+import "ethereum_fetcher/internal/services/errors"
+
+type ShareError struct {
+	errors.ServiceError
+}
+
+func shareError(msg string) ShareError {
+	return ShareError{errors.NewServiceError(msg)}
+}
+
+var (
+	SharingDisabled   = shareError("sharing is disabled")
+	InvalidShare      = shareError("a share needs either transaction hashes or a collection")
+	TooManyHashes     = shareError("too many transactions to share")
+	InvalidExpiry     = shareError("expiresAt must be in the future and within the longest allowed share lifetime")
+	ShareNotFound     = shareError("share not found")
+	InvalidShareToken = shareError("invalid or revoked share link")
+	ShareExpired      = shareError("share link has expired")
+	ShareStoreFailed  = shareError("failed to store share")
+)
@@ -0,0 +1,49 @@
+package shares
+
+import (
+	"errors"
+	"time"
+
+	"ethereum_fetcher/db/models"
+
+	"gorm.io/gorm"
+)
+
+type ShareRepo struct {
+	db *gorm.DB
+}
+
+func (r *ShareRepo) Create(link *models.ShareLink) error {
+	return r.db.Create(link).Error
+}
+
+func (r *ShareRepo) FindById(id uint64) (models.ShareLink, error) {
+	var link models.ShareLink
+	err := r.db.First(&link, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return link, ShareNotFound
+	}
+	return link, err
+}
+
+// ListForUser returns the shares of the user that aren't revoked, newest first
+func (r *ShareRepo) ListForUser(userId uint64) ([]models.ShareLink, error) {
+	var links []models.ShareLink
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL", userId).Order("id DESC").Find(&links).Error
+	return links, err
+}
+
+// Revoke revokes a share of the user, failing with ShareNotFound for shares of
+// other users
+func (r *ShareRepo) Revoke(userId uint64, id uint64) error {
+	result := r.db.Model(&models.ShareLink{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ShareNotFound
+	}
+	return nil
+}
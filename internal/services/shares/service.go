@@ -0,0 +1,220 @@
+package shares
+
+import (
+	"strings"
+	"time"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/internal/services/collections"
+	"ethereum_fetcher/internal/services/transactions"
+	"ethereum_fetcher/pkg/logging"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultShareTTL = 7 * 24 * time.Hour
+	DefaultMaxTTL   = 30 * 24 * time.Hour
+
+	maxSharedHashes = 1000
+)
+
+// ShareService gives people without an account read-only access to
+// transactions users looked up, through signed links that expire
+type ShareService interface {
+	// Create shares transactions on the chain from the history of the user or
+	// one of their collections, whose transactions carry their own chain
+	Create(userId uint64, chainId uint64, req api.CreateShareRequest) (api.Share, error)
+	List(userId uint64) ([]api.Share, error)
+	Revoke(userId uint64, shareId uint64) error
+	// Resolve returns the stored transactions a share link gives access to
+	Resolve(token string) ([]api.Transaction, error)
+}
+
+type ShareConfig struct {
+	// Secret signs the links, empty disables sharing
+	Secret string
+	// MaxTTL is the longest a link may be valid for
+	MaxTTL time.Duration
+}
+
+type impl struct {
+	repo        *ShareRepo
+	collections collections.CollectionService
+	txService   transactions.TxnService
+	signer      signer
+	cfg         ShareConfig
+	logger      *logrus.Logger
+}
+
+func NewShareService(db *gorm.DB, collections collections.CollectionService, txService transactions.TxnService, cfg ShareConfig) ShareService {
+	if cfg.MaxTTL <= 0 {
+		cfg.MaxTTL = DefaultMaxTTL
+	}
+	return &impl{
+		repo:        &ShareRepo{db: db},
+		collections: collections,
+		txService:   txService,
+		signer:      signer{secret: []byte(cfg.Secret)},
+		cfg:         cfg,
+		logger:      logging.New(),
+	}
+}
+
+func (s *impl) Create(userId uint64, chainId uint64, req api.CreateShareRequest) (api.Share, error) {
+	if s.cfg.Secret == "" {
+		return api.Share{}, SharingDisabled
+	}
+	if (len(req.TransactionHashes) == 0) == (req.CollectionId == nil) {
+		return api.Share{}, InvalidShare
+	}
+	if len(req.TransactionHashes) > maxSharedHashes {
+		return api.Share{}, TooManyHashes
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(min(DefaultShareTTL, s.cfg.MaxTTL))
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if !expiresAt.After(now) || expiresAt.After(now.Add(s.cfg.MaxTTL)) {
+		return api.Share{}, InvalidExpiry
+	}
+
+	var linkChain *uint64
+	if req.CollectionId != nil {
+		if _, err := s.collections.Entries(userId, *req.CollectionId); err != nil {
+			return api.Share{}, err
+		}
+	} else if err := s.collections.InHistory(userId, chainId, req.TransactionHashes); err != nil {
+		return api.Share{}, err
+	} else {
+		linkChain = &chainId
+	}
+
+	link := &models.ShareLink{
+		UserId:       userId,
+		CollectionId: req.CollectionId,
+		ChainId:      linkChain,
+		Hashes:       strings.Join(req.TransactionHashes, " "),
+		// the token carries the expiry in whole seconds
+		ExpiresAt: expiresAt.Truncate(time.Second),
+	}
+	if err := s.repo.Create(link); err != nil {
+		s.logger.Errorf("failed to store share of user '%d' : %s", userId, err)
+		return api.Share{}, ShareStoreFailed
+	}
+
+	s.logger.Infof("User '%d' created share '%d'", userId, link.ID)
+	return s.toApiShare(*link), nil
+}
+
+func (s *impl) List(userId uint64) ([]api.Share, error) {
+	links, err := s.repo.ListForUser(userId)
+	if err != nil {
+		s.logger.Errorf("failed to list shares of user '%d' : %s", userId, err)
+		return nil, ShareStoreFailed
+	}
+
+	shares := make([]api.Share, 0, len(links))
+	for _, link := range links {
+		shares = append(shares, s.toApiShare(link))
+	}
+	return shares, nil
+}
+
+func (s *impl) Revoke(userId uint64, shareId uint64) error {
+	err := s.repo.Revoke(userId, shareId)
+	if err != nil && err != ShareNotFound {
+		s.logger.Errorf("failed to revoke share '%d' : %s", shareId, err)
+		return ShareStoreFailed
+	}
+	return err
+}
+
+func (s *impl) Resolve(token string) ([]api.Transaction, error) {
+	if s.cfg.Secret == "" {
+		return nil, SharingDisabled
+	}
+
+	id, expiresAt, err := s.signer.verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(expiresAt) {
+		return nil, ShareExpired
+	}
+
+	link, err := s.repo.FindById(id)
+	if err == ShareNotFound {
+		return nil, InvalidShareToken
+	}
+	if err != nil {
+		s.logger.Errorf("failed to load share '%d' : %s", id, err)
+		return nil, ShareStoreFailed
+	}
+	if link.RevokedAt != nil || !link.ExpiresAt.Equal(expiresAt) {
+		return nil, InvalidShareToken
+	}
+
+	var entries []collections.Entry
+	if link.CollectionId != nil {
+		// a deleted collection takes its links with it
+		if entries, err = s.collections.Entries(link.UserId, *link.CollectionId); err == collections.CollectionNotFound {
+			return nil, InvalidShareToken
+		} else if err != nil {
+			return nil, err
+		}
+	} else if link.ChainId != nil {
+		for _, hash := range strings.Fields(link.Hashes) {
+			entries = append(entries, collections.Entry{ChainId: *link.ChainId, TransactionHash: hash})
+		}
+	}
+
+	// the transactions are resolved on the chain they were shared on
+	hashesByChain := make(map[uint64][]string)
+	for _, entry := range entries {
+		hashesByChain[entry.ChainId] = append(hashesByChain[entry.ChainId], entry.TransactionHash)
+	}
+	var txns []api.Transaction
+	for chainId, hashes := range hashesByChain {
+		stored, err := s.txService.Stored(chainId, hashes)
+		if err != nil {
+			return nil, err
+		}
+		txns = append(txns, stored...)
+	}
+	return inOrder(txns, entries), nil
+}
+
+// inOrder sorts the transactions in the order they were shared
+func inOrder(txns []api.Transaction, entries []collections.Entry) []api.Transaction {
+	byEntry := make(map[collections.Entry]api.Transaction, len(txns))
+	for _, txn := range txns {
+		byEntry[collections.Entry{ChainId: txn.ChainId, TransactionHash: txn.TransactionHash}] = txn
+	}
+
+	ordered := make([]api.Transaction, 0, len(txns))
+	for _, entry := range entries {
+		if txn, ok := byEntry[entry]; ok {
+			ordered = append(ordered, txn)
+			delete(byEntry, entry)
+		}
+	}
+	return ordered
+}
+
+func (s *impl) toApiShare(link models.ShareLink) api.Share {
+	return api.Share{
+		Id:                link.ID,
+		Token:             s.signer.sign(link.ID, link.ExpiresAt),
+		TransactionHashes: strings.Fields(link.Hashes),
+		ChainId:           link.ChainId,
+		CollectionId:      link.CollectionId,
+		ExpiresAt:         link.ExpiresAt,
+		RevokedAt:         link.RevokedAt,
+		CreatedAt:         link.CreatedAt,
+	}
+}
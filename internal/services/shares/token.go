@@ -0,0 +1,50 @@
+package shares
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// signer issues share tokens, base64url(id || expiry) "." base64url(HMAC).
+// The token only carries the id of the share and when it expires, not whose
+// it is.
+type signer struct {
+	secret []byte
+}
+
+func (s signer) sign(id uint64, expiresAt time.Time) string {
+	payload := make([]byte, 16)
+	binary.BigEndian.PutUint64(payload[:8], id)
+	binary.BigEndian.PutUint64(payload[8:], uint64(expiresAt.Unix()))
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+// verify checks the signature of a token and returns what it carries
+func (s signer) verify(token string) (uint64, time.Time, error) {
+	encodedPayload, encodedMac, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, time.Time{}, InvalidShareToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil || len(payload) != 16 {
+		return 0, time.Time{}, InvalidShareToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMac)
+	if err != nil || !hmac.Equal(mac, s.mac(payload)) {
+		return 0, time.Time{}, InvalidShareToken
+	}
+
+	id := binary.BigEndian.Uint64(payload[:8])
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[8:])), 0)
+	return id, expiresAt, nil
+}
+
+func (s signer) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(payload)
+	return h.Sum(nil)
+}
@@ -0,0 +1,161 @@
+package transactions
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"ethereum_fetcher/db/models"
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+const (
+	// blockCacheSize is how many final blocks every chain keeps in memory
+	blockCacheSize = 4096
+	// recentBlockCacheSize is how many blocks above the finalized head every
+	// chain keeps in memory
+	recentBlockCacheSize = 256
+	// recentBlockTTL is how long blocks above the finalized head are kept
+	recentBlockTTL = time.Minute
+)
+
+// blockCache keeps the blocks of a chain looked up last. Final blocks don't
+// change so they are kept by number and hash and never expire. Blocks a
+// reorg can still replace are kept for recentBlockTTL or until they are
+// final, by number only when they were looked up by it.
+type blockCache struct {
+	mu       sync.Mutex
+	capacity int
+	// order holds the final blocks, the most recently used first
+	order    *list.List
+	byNumber map[uint64]*list.Element
+	byHash   map[string]*list.Element
+	// recent holds the other blocks, the most recently added first
+	recent         *list.List
+	recentByHash   map[string]*list.Element
+	recentByNumber map[uint64]*list.Element
+}
+
+type recentBlock struct {
+	block     models.Block
+	expiresAt time.Time
+}
+
+func newBlockCache(capacity int) *blockCache {
+	return &blockCache{
+		capacity:       capacity,
+		order:          list.New(),
+		byNumber:       make(map[uint64]*list.Element),
+		byHash:         make(map[string]*list.Element),
+		recent:         list.New(),
+		recentByHash:   make(map[string]*list.Element),
+		recentByNumber: make(map[uint64]*list.Element),
+	}
+}
+
+func (c *blockCache) get(id types.BlockId) (models.Block, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.byNumber[id.Number]
+	if id.Hash != "" {
+		element, ok = c.byHash[id.Hash]
+	}
+	if ok {
+		c.order.MoveToFront(element)
+		return *element.Value.(*models.Block), true
+	}
+
+	element, ok = c.recentByNumber[id.Number]
+	if id.Hash != "" {
+		element, ok = c.recentByHash[id.Hash]
+	}
+	if !ok {
+		return models.Block{}, false
+	}
+	recent := element.Value.(*recentBlock)
+	if time.Now().After(recent.expiresAt) {
+		c.removeRecent(element)
+		return models.Block{}, false
+	}
+	return recent.block, true
+}
+
+// set keeps a final block, the canonical one at its number
+func (c *blockCache) set(block models.Block) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.recentByHash[block.Hash]; ok {
+		c.removeRecent(element)
+	}
+	if element, ok := c.byNumber[block.Number]; ok {
+		delete(c.byHash, element.Value.(*models.Block).Hash)
+		c.order.Remove(element)
+	}
+
+	element := c.order.PushFront(&block)
+	c.byNumber[block.Number] = element
+	c.byHash[block.Hash] = element
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		evicted := oldest.Value.(*models.Block)
+		delete(c.byNumber, evicted.Number)
+		delete(c.byHash, evicted.Hash)
+		c.order.Remove(oldest)
+	}
+}
+
+// setRecent keeps a block that may not be or stay canonical, by its number
+// too when it was looked up by it
+func (c *blockCache) setRecent(block models.Block, byNumber bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.byHash[block.Hash]; ok {
+		return
+	}
+	if element, ok := c.recentByHash[block.Hash]; ok {
+		c.removeRecent(element)
+	}
+
+	element := c.recent.PushFront(&recentBlock{block: block, expiresAt: time.Now().Add(recentBlockTTL)})
+	c.recentByHash[block.Hash] = element
+	if byNumber {
+		if replaced, ok := c.recentByNumber[block.Number]; ok {
+			c.removeRecent(replaced)
+		}
+		c.recentByNumber[block.Number] = element
+	}
+
+	for c.recent.Len() > recentBlockCacheSize {
+		c.removeRecent(c.recent.Back())
+	}
+}
+
+// finalize drops the recent blocks up to the finalized head, they are looked
+// up again to be kept as final
+func (c *blockCache) finalize(head uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for element := c.recent.Front(); element != nil; {
+		next := element.Next()
+		if element.Value.(*recentBlock).block.Number <= head {
+			c.removeRecent(element)
+		}
+		element = next
+	}
+}
+
+func (c *blockCache) removeRecent(element *list.Element) {
+	block := element.Value.(*recentBlock).block
+	if c.recentByHash[block.Hash] == element {
+		delete(c.recentByHash, block.Hash)
+	}
+	if c.recentByNumber[block.Number] == element {
+		delete(c.recentByNumber, block.Number)
+	}
+	c.recent.Remove(element)
+}
@@ -0,0 +1,380 @@
+package transactions
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/models"
+	types "ethereum_fetcher/internal/services/transactions/types"
+
+	geth "github.com/ethereum/go-ethereum"
+)
+
+// finalizedRefresh is how long the finalized head of a chain is trusted
+// before the node is asked again, about a slot
+const finalizedRefresh = 12 * time.Second
+
+// finalizedHead is the newest final block of a chain, as the node last
+// reported it
+type finalizedHead struct {
+	mu        sync.Mutex
+	number    uint64
+	checkedAt time.Time
+}
+
+type blockKey struct {
+	chainId uint64
+	number  uint64
+}
+
+// Block returns a block of the chain by its number or hash, from the cache,
+// the database or, costing one node call, the node
+func (s *impl) Block(chainId uint64, numberOrHash string, opts types.FetchOptions) (api.Block, error) {
+	chain, ok := s.chains.byId(chainId)
+	if !ok {
+		return api.Block{}, types.UnknownChain
+	}
+	id, err := parseBlockId(numberOrHash)
+	if err != nil {
+		return api.Block{}, err
+	}
+
+	if block, ok := chain.blocks.get(id); ok {
+		return toApiBlock(&block), nil
+	}
+
+	stored, err := s.repo.GetBlock(chainId, id)
+	if err != nil {
+		s.logger.Errorf("failed to load block '%s':  %v", id, err)
+		return api.Block{}, types.NewTxnError("failed to load block")
+	}
+	if stored != nil {
+		chain.blocks.set(*stored)
+		return toApiBlock(stored), nil
+	}
+
+	if opts.Budget != nil {
+		if err := opts.Budget.Charge(1); err != nil {
+			s.logger.Infof("Node call for block '%s' refused:  %v", id, err)
+			return api.Block{}, err
+		}
+	}
+
+	block, err := s.fetchBlock(chain, id)
+	if err != nil {
+		return api.Block{}, err
+	}
+	return toApiBlock(&block), nil
+}
+
+// EmbedBlocks adds their block to the transactions, looking the blocks not
+// stored yet up on the node for a node call each
+func (s *impl) EmbedBlocks(txns []api.Transaction, opts types.FetchOptions) error {
+	numbers := make(map[uint64][]uint64)
+	seen := make(map[blockKey]bool)
+	for _, txn := range txns {
+		key := blockKey{txn.ChainId, txn.BlockNumber.Uint64()}
+		if !seen[key] {
+			seen[key] = true
+			numbers[txn.ChainId] = append(numbers[txn.ChainId], key.number)
+		}
+	}
+
+	blocks := make(map[blockKey]api.Block, len(seen))
+	for chainId, chainNumbers := range numbers {
+		// transactions of chains no longer configured go without
+		chain, ok := s.chains.byId(chainId)
+		if !ok {
+			continue
+		}
+		found, err := s.blocksByNumber(chain, chainNumbers, opts)
+		if err != nil {
+			return err
+		}
+		for _, block := range found {
+			blocks[blockKey{chainId, block.Number}] = toApiBlock(&block)
+		}
+	}
+
+	for i := range txns {
+		if block, ok := blocks[blockKey{txns[i].ChainId, txns[i].BlockNumber.Uint64()}]; ok {
+			txns[i].Block = &block
+		}
+	}
+	return nil
+}
+
+// setBlockTimestamps copies the timestamp of their block to the transactions,
+// those of blocks not found stay undated
+func (s *impl) setBlockTimestamps(chain *chainBackend, txns []types.DbTxn, opts types.FetchOptions) {
+	numbers := make([]uint64, 0, len(txns))
+	seen := make(map[uint64]bool, len(txns))
+	for _, txn := range txns {
+		if !seen[txn.BlockNumber] {
+			seen[txn.BlockNumber] = true
+			numbers = append(numbers, txn.BlockNumber)
+		}
+	}
+
+	blocks, err := s.blocksByNumber(chain, numbers, opts)
+	if err != nil {
+		s.logger.Errorf("failed to fetch the blocks of transactions for hashes: '%s':  %v", txnHashes(txns), err)
+	}
+
+	timestamps := make(map[uint64]uint64, len(blocks))
+	for _, block := range blocks {
+		timestamps[block.Number] = block.Timestamp
+	}
+	for i := range txns {
+		if timestamp, ok := timestamps[txns[i].BlockNumber]; ok {
+			txns[i].BlockTimestamp = &timestamp
+		}
+	}
+}
+
+// stampUndated dates the transactions stored without a timestamp and stores
+// the ones dated
+func (s *impl) stampUndated(chain *chainBackend, txns []types.DbTxn, opts types.FetchOptions) {
+	undated := make([]int, 0)
+	for i := range txns {
+		if txns[i].BlockTimestamp == nil {
+			undated = append(undated, i)
+		}
+	}
+	if len(undated) == 0 {
+		return
+	}
+
+	stamped := make([]types.DbTxn, len(undated))
+	for j, i := range undated {
+		stamped[j] = txns[i]
+	}
+	s.setBlockTimestamps(chain, stamped, opts)
+
+	dated := make([]types.DbTxn, 0, len(stamped))
+	for j, i := range undated {
+		if stamped[j].BlockTimestamp != nil {
+			txns[i] = stamped[j]
+			dated = append(dated, stamped[j])
+		}
+	}
+	if len(dated) == 0 {
+		return
+	}
+	if err := s.repo.SetBlockTimestamps(dated); err != nil {
+		s.logger.Errorf("failed to store the block timestamps of transactions for hashes: '%s':  %v", txnHashes(dated), err)
+	}
+	chain.Cache.SetMany(dated)
+}
+
+// maxDatedBlocks bounds the blocks looked up to date a listing
+const maxDatedBlocks = 64
+
+// inPeriod keeps the transactions mined in the period, dating undated ones
+func (s *impl) inPeriod(txns []types.DbTxn, period types.MinedPeriod, opts types.FetchOptions) []types.DbTxn {
+	if period.From == 0 && period.To == 0 {
+		return txns
+	}
+
+	undated := make(map[uint64][]types.DbTxn)
+	blocks := make(map[blockKey]bool)
+	for _, txn := range txns {
+		key := blockKey{txn.ChainId, txn.BlockNumber}
+		if txn.BlockTimestamp == nil && (blocks[key] || len(blocks) < maxDatedBlocks) {
+			blocks[key] = true
+			undated[txn.ChainId] = append(undated[txn.ChainId], txn)
+		}
+	}
+
+	timestamps := make(map[blockKey]*uint64, len(blocks))
+	for chainId, chainTxns := range undated {
+		// transactions of chains no longer configured stay undated
+		chain, ok := s.chains.byId(chainId)
+		if !ok {
+			continue
+		}
+		s.stampUndated(chain, chainTxns, opts)
+		for _, txn := range chainTxns {
+			timestamps[blockKey{chainId, txn.BlockNumber}] = txn.BlockTimestamp
+		}
+	}
+
+	kept := make([]types.DbTxn, 0, len(txns))
+	for _, txn := range txns {
+		if txn.BlockTimestamp == nil {
+			txn.BlockTimestamp = timestamps[blockKey{txn.ChainId, txn.BlockNumber}]
+		}
+		minedAt := txn.BlockTimestamp
+		if minedAt != nil && *minedAt >= period.From && (period.To == 0 || *minedAt <= period.To) {
+			kept = append(kept, txn)
+		}
+	}
+	return kept
+}
+
+// blocksByNumber returns the blocks found before a failure with its error
+func (s *impl) blocksByNumber(chain *chainBackend, numbers []uint64, opts types.FetchOptions) ([]models.Block, error) {
+	found := make([]models.Block, 0, len(numbers))
+	missing := make([]uint64, 0, len(numbers))
+	for _, number := range numbers {
+		if block, ok := chain.blocks.get(types.BlockId{Number: number}); ok {
+			found = append(found, block)
+		} else {
+			missing = append(missing, number)
+		}
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	stored, err := s.repo.GetBlocks(chain.Id, missing)
+	if err != nil {
+		s.logger.Errorf("failed to load blocks '%v':  %v", missing, err)
+		return nil, types.NewTxnError("failed to load blocks")
+	}
+	storedNumbers := make(map[uint64]bool, len(stored))
+	for _, block := range stored {
+		chain.blocks.set(block)
+		storedNumbers[block.Number] = true
+	}
+	found = append(found, stored...)
+
+	unknown := make([]uint64, 0, len(missing))
+	for _, number := range missing {
+		if !storedNumbers[number] {
+			unknown = append(unknown, number)
+		}
+	}
+	if len(unknown) == 0 {
+		return found, nil
+	}
+
+	if opts.Budget != nil {
+		if err := opts.Budget.Charge(len(unknown)); err != nil {
+			s.logger.Infof("Node calls for blocks '%v' refused:  %v", unknown, err)
+			return found, err
+		}
+	}
+	for _, number := range unknown {
+		block, err := s.fetchBlock(chain, types.BlockId{Number: number})
+		if err != nil {
+			return found, err
+		}
+		found = append(found, block)
+	}
+	return found, nil
+}
+
+func (s *impl) fetchBlock(chain *chainBackend, id types.BlockId) (models.Block, error) {
+	s.logger.Infof("Fetching block '%s' from the '%s' node", id, chain.Name)
+	rpcBlock, err := chain.eth.Block(id)
+	if errors.Is(err, geth.NotFound) {
+		return models.Block{}, types.BlockNotFound
+	}
+	if err != nil {
+		s.logger.Errorf("failed to fetch block '%s':  %v", id, err)
+		return models.Block{}, types.FailedToFetchBlock
+	}
+
+	block := toDbBlock(chain.Id, rpcBlock)
+	// a reorg can replace blocks above the finalized head, and a hash may
+	// be of a block that never was canonical
+	if id.Hash != "" || !s.isFinal(chain, block.Number) {
+		chain.blocks.setRecent(block, id.Hash == "")
+		return block, nil
+	}
+	if err := s.repo.SaveBlock(&block); err != nil {
+		s.logger.Errorf("failed to store block '%s':  %v", id, err)
+		return models.Block{}, types.NewTxnError("failed to store block")
+	}
+	chain.blocks.set(block)
+	return block, nil
+}
+
+// isFinal tells whether a reorg can no longer replace the block at the
+// number, asking the node for its finalized head at most once per
+// finalizedRefresh
+func (s *impl) isFinal(chain *chainBackend, number uint64) bool {
+	head := chain.finalized
+	head.mu.Lock()
+	defer head.mu.Unlock()
+
+	if number <= head.number {
+		return true
+	}
+	if time.Since(head.checkedAt) < finalizedRefresh {
+		return false
+	}
+
+	finalized, err := chain.eth.FinalizedBlock()
+	head.checkedAt = time.Now()
+	if err != nil {
+		s.logger.Errorf("failed to fetch the finalized block of '%s':  %v", chain.Name, err)
+		return false
+	}
+	if finalized > head.number {
+		head.number = finalized
+		chain.blocks.finalize(finalized)
+	}
+	return number <= head.number
+}
+
+// parseBlockId reads a block number in decimal or a block hash
+func parseBlockId(numberOrHash string) (types.BlockId, error) {
+	numberOrHash = strings.ToLower(numberOrHash)
+	if txnHashPattern.MatchString(numberOrHash) {
+		return types.BlockId{Hash: numberOrHash}, nil
+	}
+	number, err := strconv.ParseUint(numberOrHash, 10, 64)
+	if err != nil {
+		return types.BlockId{}, types.InvalidBlockId
+	}
+	return types.BlockId{Number: number}, nil
+}
+
+func toDbBlock(chainId uint64, block *types.RpcBlock) models.Block {
+	var withdrawals *uint64
+	if block.Withdrawals != nil {
+		count := uint64(len(*block.Withdrawals))
+		withdrawals = &count
+	}
+
+	return models.Block{
+		ChainId:          chainId,
+		Number:           uint64(block.Number),
+		Hash:             block.Hash.Hex(),
+		ParentHash:       block.ParentHash.Hex(),
+		Timestamp:        uint64(block.Timestamp),
+		Miner:            block.Miner.Hex(),
+		BaseFeePerGas:    hexBigString(block.BaseFeePerGas),
+		GasUsed:          uint64(block.GasUsed),
+		GasLimit:         uint64(block.GasLimit),
+		BlobGasUsed:      (*uint64)(block.BlobGasUsed),
+		ExcessBlobGas:    (*uint64)(block.ExcessBlobGas),
+		WithdrawalsCount: withdrawals,
+		TransactionCount: uint64(len(block.Transactions)),
+		CreatedAt:        time.Now(),
+	}
+}
+
+func toApiBlock(block *models.Block) api.Block {
+	return api.Block{
+		ChainId:          block.ChainId,
+		Number:           block.Number,
+		Hash:             block.Hash,
+		ParentHash:       block.ParentHash,
+		Timestamp:        block.Timestamp,
+		Miner:            block.Miner,
+		BaseFeePerGas:    block.BaseFeePerGas,
+		GasUsed:          block.GasUsed,
+		GasLimit:         block.GasLimit,
+		BlobGasUsed:      block.BlobGasUsed,
+		ExcessBlobGas:    block.ExcessBlobGas,
+		WithdrawalsCount: block.WithdrawalsCount,
+		TransactionCount: block.TransactionCount,
+	}
+}
@@ -0,0 +1,408 @@
+package transactions
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+type EvictionPolicy string
+
+const (
+	// LRU evicts the least recently used transaction
+	LRU EvictionPolicy = "lru"
+	// LFU evicts the least frequently used transaction, oldest first on ties
+	LFU EvictionPolicy = "lfu"
+)
+
+type BoundedCacheConfig struct {
+	// MaxEntries and MaxBytes bound the cache, zero disables a bound
+	MaxEntries int
+	MaxBytes   int64
+	// TTL expires entries regardless of use, zero keeps them until evicted
+	TTL time.Duration
+	// NegativeTTL is how long not found hashes are remembered, zero disables it
+	NegativeTTL time.Duration
+	Policy      EvictionPolicy
+	// Admission rejects new transactions that have been requested less often
+	// than the one they would evict, keeping one-hit wonders out of a full cache
+	Admission bool
+}
+
+// CacheStats are cumulative counters of a cache since it was created
+type CacheStats struct {
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	Evictions  uint64 `json:"evictions"`
+	Rejections uint64 `json:"rejections"`
+	Entries    int    `json:"entries"`
+	Bytes      int64  `json:"bytes"`
+}
+
+// StatsReporter is implemented by caches that keep CacheStats
+type StatsReporter interface {
+	Stats() CacheStats
+}
+
+// BoundedCache is an in-process TxnCache bounded by entry count and by the
+// approximate memory taken by the cached transactions
+type BoundedCache struct {
+	mu       sync.Mutex
+	cfg      BoundedCacheConfig
+	items    map[string]*boundedEntry
+	policy   evictionPolicy
+	sketch   *frequencySketch
+	notFound *negativeCache
+	bytes    int64
+	stats    CacheStats
+}
+
+type boundedEntry struct {
+	txn       *types.DbTxn
+	size      int64
+	expiresAt time.Time
+	elem      *list.Element
+	freq      int
+}
+
+func NewBoundedCache(cfg BoundedCacheConfig) *BoundedCache {
+	c := &BoundedCache{
+		cfg:      cfg,
+		items:    make(map[string]*boundedEntry),
+		notFound: newNegativeCache(cfg.NegativeTTL, max(cfg.MaxEntries, maxNegativeEntries)),
+	}
+
+	c.policy = newEvictionPolicy(cfg.Policy)
+
+	if cfg.Admission {
+		c.sketch = newFrequencySketch(max(cfg.MaxEntries, 1024))
+	}
+
+	return c
+}
+
+// NewLRUCache creates a bounded cache with LRU eviction and no admission policy
+func NewLRUCache(maxEntries int, maxBytes int64, ttl time.Duration) TxnCache {
+	return NewBoundedCache(BoundedCacheConfig{
+		MaxEntries:  maxEntries,
+		MaxBytes:    maxBytes,
+		TTL:         ttl,
+		NegativeTTL: defaultNegativeTTL,
+		Policy:      LRU,
+	})
+}
+
+func (c *BoundedCache) Get(hash string) (*types.DbTxn, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sketch != nil {
+		c.sketch.increment(hash)
+	}
+
+	entry, ok := c.items[hash]
+	if ok && c.expired(entry) {
+		c.remove(entry)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	c.policy.touch(entry)
+	return entry.txn, true
+}
+
+func (c *BoundedCache) GetMany(hashes []string) types.TxnsResult {
+	return getEach(c, hashes)
+}
+
+func (c *BoundedCache) Set(txn *types.DbTxn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &boundedEntry{txn: txn, size: txnSize(txn)}
+	if c.cfg.TTL > 0 {
+		entry.expiresAt = time.Now().Add(c.cfg.TTL)
+	}
+
+	// a single transaction larger than the whole budget is never cached
+	if c.cfg.MaxBytes > 0 && entry.size > c.cfg.MaxBytes {
+		c.stats.Rejections++
+		return
+	}
+
+	if existing, ok := c.items[txn.TransactionHash]; ok {
+		c.bytes += entry.size - existing.size
+		existing.txn, existing.size, existing.expiresAt = entry.txn, entry.size, entry.expiresAt
+		c.policy.touch(existing)
+		c.evict()
+		return
+	}
+
+	if !c.admit(entry) {
+		c.stats.Rejections++
+		return
+	}
+
+	// make room first, a new entry is always the least frequently used one
+	for c.wouldOverflow(entry) && c.evictOne() {
+	}
+
+	c.items[txn.TransactionHash] = entry
+	c.policy.add(entry)
+	c.bytes += entry.size
+}
+
+func (c *BoundedCache) SetMany(txns []types.DbTxn) {
+	for _, txn := range txns {
+		c.Set(&txn)
+	}
+}
+
+func (c *BoundedCache) SetNotFound(hashes []string) {
+	c.notFound.add(hashes)
+}
+
+func (c *BoundedCache) SplitNotFound(hashes []string) ([]string, []string) {
+	return c.notFound.split(hashes)
+}
+
+// Purge empties the cache, the counters keep running
+func (c *BoundedCache) Purge() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*boundedEntry)
+	c.policy = newEvictionPolicy(c.cfg.Policy)
+	c.bytes = 0
+	c.notFound.clear()
+	return nil
+}
+
+func (c *BoundedCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.items)
+	stats.Bytes = c.bytes
+	return stats
+}
+
+// Snapshot returns up to limit live transactions, those the eviction policy
+// would keep longest first
+func (c *BoundedCache) Snapshot(limit int) []types.DbTxn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	txns := make([]types.DbTxn, 0, min(limit, len(c.items)))
+	c.policy.each(func(entry *boundedEntry) bool {
+		if !c.expired(entry) {
+			txns = append(txns, *entry.txn)
+		}
+		return len(txns) < limit
+	})
+	return txns
+}
+
+// admit decides whether a new entry may displace the current eviction
+// victim. Without an admission policy, or while there is room, it always can.
+func (c *BoundedCache) admit(entry *boundedEntry) bool {
+	if c.sketch == nil || !c.wouldOverflow(entry) {
+		return true
+	}
+
+	victim := c.policy.victim()
+	if victim == nil {
+		return true
+	}
+
+	return c.sketch.estimate(entry.txn.TransactionHash) > c.sketch.estimate(victim.txn.TransactionHash)
+}
+
+func (c *BoundedCache) wouldOverflow(entry *boundedEntry) bool {
+	return (c.cfg.MaxEntries > 0 && len(c.items)+1 > c.cfg.MaxEntries) ||
+		(c.cfg.MaxBytes > 0 && c.bytes+entry.size > c.cfg.MaxBytes)
+}
+
+func (c *BoundedCache) evict() {
+	for c.overBudget() && c.evictOne() {
+	}
+}
+
+func (c *BoundedCache) evictOne() bool {
+	victim := c.policy.victim()
+	if victim == nil {
+		return false
+	}
+	c.remove(victim)
+	c.stats.Evictions++
+	return true
+}
+
+func (c *BoundedCache) overBudget() bool {
+	return (c.cfg.MaxEntries > 0 && len(c.items) > c.cfg.MaxEntries) ||
+		(c.cfg.MaxBytes > 0 && c.bytes > c.cfg.MaxBytes)
+}
+
+func (c *BoundedCache) remove(entry *boundedEntry) {
+	c.policy.remove(entry)
+	delete(c.items, entry.txn.TransactionHash)
+	c.bytes -= entry.size
+}
+
+func (c *BoundedCache) expired(entry *boundedEntry) bool {
+	return !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt)
+}
+
+// l2Size is what the rollup receipt fields take when they are set
+const l2Size = 512
+
+// txnSize approximates the memory held by a cached transaction: the struct
+// itself, its variable length strings and the bookkeeping around it
+func txnSize(txn *types.DbTxn) int64 {
+	const overhead = 256
+
+	size := len(txn.TransactionHash) + len(txn.BlockHash) + len(txn.FromAddress) +
+		len(txn.Input) + len(txn.Value) + len(txn.EffectiveGasPrice) + len(txn.TotalFee)
+	if txn.L2 != (types.L2Receipt{}) {
+		size += l2Size
+	}
+	if txn.ToAddress != nil {
+		size += len(*txn.ToAddress)
+	}
+	if txn.ContractAddress != nil {
+		size += len(*txn.ContractAddress)
+	}
+
+	return int64(size + overhead)
+}
+
+type evictionPolicy interface {
+	add(entry *boundedEntry)
+	touch(entry *boundedEntry)
+	remove(entry *boundedEntry)
+	victim() *boundedEntry
+	// each visits the entries from the last to the first to be evicted until
+	// fn returns false
+	each(fn func(entry *boundedEntry) bool)
+}
+
+func newEvictionPolicy(policy EvictionPolicy) evictionPolicy {
+	if policy == LFU {
+		return newLfuPolicy()
+	}
+	return newLruPolicy()
+}
+
+// lruPolicy keeps entries in recency order, most recent at the front
+type lruPolicy struct {
+	order *list.List
+}
+
+func newLruPolicy() *lruPolicy {
+	return &lruPolicy{order: list.New()}
+}
+
+func (p *lruPolicy) add(entry *boundedEntry) {
+	entry.elem = p.order.PushFront(entry)
+}
+
+func (p *lruPolicy) touch(entry *boundedEntry) {
+	p.order.MoveToFront(entry.elem)
+}
+
+func (p *lruPolicy) remove(entry *boundedEntry) {
+	p.order.Remove(entry.elem)
+}
+
+func (p *lruPolicy) victim() *boundedEntry {
+	if back := p.order.Back(); back != nil {
+		return back.Value.(*boundedEntry)
+	}
+	return nil
+}
+
+func (p *lruPolicy) each(fn func(entry *boundedEntry) bool) {
+	for elem := p.order.Front(); elem != nil; elem = elem.Next() {
+		if !fn(elem.Value.(*boundedEntry)) {
+			return
+		}
+	}
+}
+
+// lfuPolicy keeps a recency ordered list of entries per use count so that
+// add, touch and victim are all O(1)
+type lfuPolicy struct {
+	buckets map[int]*list.List
+	minFreq int
+}
+
+func newLfuPolicy() *lfuPolicy {
+	return &lfuPolicy{buckets: make(map[int]*list.List)}
+}
+
+func (p *lfuPolicy) add(entry *boundedEntry) {
+	entry.freq = 1
+	entry.elem = p.bucket(1).PushFront(entry)
+	p.minFreq = 1
+}
+
+func (p *lfuPolicy) touch(entry *boundedEntry) {
+	p.remove(entry)
+	if p.minFreq == entry.freq && p.buckets[entry.freq] == nil {
+		p.minFreq++
+	}
+	entry.freq++
+	entry.elem = p.bucket(entry.freq).PushFront(entry)
+}
+
+func (p *lfuPolicy) remove(entry *boundedEntry) {
+	bucket := p.buckets[entry.freq]
+	bucket.Remove(entry.elem)
+	if bucket.Len() == 0 {
+		delete(p.buckets, entry.freq)
+	}
+}
+
+func (p *lfuPolicy) victim() *boundedEntry {
+	if len(p.buckets) == 0 {
+		return nil
+	}
+	// minFreq may be stale after removals, walk up to the next used bucket
+	for p.buckets[p.minFreq] == nil {
+		p.minFreq++
+	}
+	return p.buckets[p.minFreq].Back().Value.(*boundedEntry)
+}
+
+func (p *lfuPolicy) each(fn func(entry *boundedEntry) bool) {
+	freqs := make([]int, 0, len(p.buckets))
+	for freq := range p.buckets {
+		freqs = append(freqs, freq)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(freqs)))
+
+	for _, freq := range freqs {
+		for elem := p.buckets[freq].Front(); elem != nil; elem = elem.Next() {
+			if !fn(elem.Value.(*boundedEntry)) {
+				return
+			}
+		}
+	}
+}
+
+func (p *lfuPolicy) bucket(freq int) *list.List {
+	bucket, ok := p.buckets[freq]
+	if !ok {
+		bucket = list.New()
+		p.buckets[freq] = bucket
+	}
+	return bucket
+}
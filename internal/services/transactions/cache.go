@@ -1,8 +1,11 @@
 package transactions
 
 import (
+	"fmt"
+	"sort"
 	"time"
 
+	"ethereum_fetcher/internal/config"
 	types "ethereum_fetcher/internal/services/transactions/types"
 	"ethereum_fetcher/pkg/logging"
 
@@ -15,17 +18,88 @@ type TxnCache interface {
 	SetMany(txns []types.DbTxn)
 	Get(hash string) (*types.DbTxn, bool)
 	GetMany(hashes []string) types.TxnsResult
+	// SetNotFound remembers hashes the node doesn't know for the negative TTL
+	SetNotFound(hashes []string)
+	// SplitNotFound separates the hashes recently remembered as not found
+	// from the rest
+	SplitNotFound(hashes []string) (notFound []string, rest []string)
+	// Purge drops every cached transaction and not found hash
+	Purge() error
+}
+
+const (
+	MemoryBackend = "memory"
+	// BoundedBackend is a size bounded in-process cache, "lru" is accepted as
+	// an alias from before the eviction policy became configurable
+	BoundedBackend = "bounded"
+	RedisBackend   = "redis"
+	TieredBackend  = "tiered"
+)
+
+// NewCache creates the cache backend selected in the config
+func NewCache(cfg config.CacheConfig) (TxnCache, error) {
+	return NewChainCache(cfg, 0)
+}
+
+// NewChainCache creates the cache of one chain. Every chain has a cache of
+// its own, redis keeps their keys apart.
+func NewChainCache(cfg config.CacheConfig, chainId uint64) (TxnCache, error) {
+	switch cfg.Backend {
+	case MemoryBackend, "":
+		return newMemoryCache(cfg.TTL, cfg.NegativeTTL), nil
+	case BoundedBackend, "lru":
+		return newBoundedCacheFromConfig(cfg)
+	case RedisBackend:
+		return newRedisCache(cfg.RedisURL, cfg.TTL, cfg.NegativeTTL, chainId)
+	case TieredBackend:
+		local, err := newBoundedCacheFromConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		shared, err := newRedisCache(cfg.RedisURL, cfg.TTL, cfg.NegativeTTL, chainId)
+		if err != nil {
+			return nil, err
+		}
+		return NewTieredCache(local, shared)
+	default:
+		return nil, fmt.Errorf("unknown cache backend '%s'", cfg.Backend)
+	}
+}
+
+func newBoundedCacheFromConfig(cfg config.CacheConfig) (TxnCache, error) {
+	policy := EvictionPolicy(cfg.Eviction)
+	switch policy {
+	case "":
+		policy = LRU
+	case LRU, LFU:
+	default:
+		return nil, fmt.Errorf("unknown cache eviction policy '%s'", cfg.Eviction)
+	}
+
+	return NewBoundedCache(BoundedCacheConfig{
+		MaxEntries:  cfg.MaxEntries,
+		MaxBytes:    cfg.MaxBytes,
+		TTL:         cfg.TTL,
+		NegativeTTL: cfg.NegativeTTL,
+		Policy:      policy,
+		Admission:   cfg.Admission,
+	}), nil
 }
 
 type cacheimpl struct {
-	cache  *cache.Cache
-	logger *logrus.Logger
+	cache    *cache.Cache
+	notFound *negativeCache
+	logger   *logrus.Logger
 }
 
 func NewTxnCache() TxnCache {
+	return newMemoryCache(1*time.Hour, defaultNegativeTTL)
+}
+
+func newMemoryCache(ttl time.Duration, negativeTTL time.Duration) TxnCache {
 	logger := logging.New()
-	c := cache.New(1*time.Hour, 1*time.Minute)
-	return &cacheimpl{cache: c, logger: logger}
+	c := cache.New(ttl, 1*time.Minute)
+	return &cacheimpl{cache: c, notFound: newNegativeCache(negativeTTL, maxNegativeEntries), logger: logger}
 }
 
 func (tc *cacheimpl) Get(hash string) (*types.DbTxn, bool) {
@@ -47,6 +121,44 @@ func (tc *cacheimpl) SetMany(txns []types.DbTxn) {
 }
 
 func (tc *cacheimpl) GetMany(hashes []string) types.TxnsResult {
+	return getEach(tc, hashes)
+}
+
+func (tc *cacheimpl) SetNotFound(hashes []string) {
+	tc.notFound.add(hashes)
+}
+
+func (tc *cacheimpl) SplitNotFound(hashes []string) ([]string, []string) {
+	return tc.notFound.split(hashes)
+}
+
+func (tc *cacheimpl) Purge() error {
+	tc.cache.Flush()
+	tc.notFound.clear()
+	return nil
+}
+
+// Snapshot returns up to limit live transactions, the most recently cached first
+func (tc *cacheimpl) Snapshot(limit int) []types.DbTxn {
+	items := tc.cache.Items()
+	hashes := make([]string, 0, len(items))
+	for hash := range items {
+		hashes = append(hashes, hash)
+	}
+	// every entry has the same TTL so the latest expiration was cached last
+	sort.Slice(hashes, func(i, j int) bool {
+		return items[hashes[i]].Expiration > items[hashes[j]].Expiration
+	})
+
+	txns := make([]types.DbTxn, 0, min(limit, len(hashes)))
+	for _, hash := range hashes[:min(limit, len(hashes))] {
+		txns = append(txns, *items[hash].Object.(*types.DbTxn))
+	}
+	return txns
+}
+
+// getEach looks up the hashes one by one, for backends without a batch lookup
+func getEach(tc TxnCache, hashes []string) types.TxnsResult {
 	var results = make([]types.DbTxn, 0, len(hashes))
 	var existingHashes = make([]string, 0, len(hashes))
 	var missingHashes = make([]string, 0, len(hashes))
@@ -0,0 +1,109 @@
+package transactions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/internal/services/transactions/ethereum"
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+// Chain is a chain transactions are looked up on, through the node at
+// NodeURL and with a cache of its own. Type is ethereum (the default),
+// optimism or arbitrum. Traces needs a node serving debug_traceTransaction.
+type Chain struct {
+	Id      uint64
+	Name    string
+	Type    string
+	NodeURL string
+	Traces  bool
+	Cache   TxnCache
+}
+
+type chainBackend struct {
+	Chain
+	eth       ethereum.EthService
+	blocks    *blockCache
+	finalized *finalizedHead
+}
+
+// chainSet holds the configured chains, the first is the default
+type chainSet []chainBackend
+
+func newChainSet(chains []Chain) (chainSet, error) {
+	if len(chains) == 0 {
+		return nil, fmt.Errorf("at least one chain is required")
+	}
+
+	set := make(chainSet, 0, len(chains))
+	for _, chain := range chains {
+		if chain.Id == 0 {
+			return nil, fmt.Errorf("chain '%s' needs a chain id", chain.Name)
+		}
+		if _, err := strconv.ParseUint(chain.Name, 10, 64); err == nil || chain.Name == "" {
+			return nil, fmt.Errorf("chain '%s' needs a name that isn't a number", chain.Name)
+		}
+		if _, ok := set.byName(chain.Name); ok {
+			return nil, fmt.Errorf("chain '%s' is configured twice", chain.Name)
+		}
+		if _, ok := set.byId(chain.Id); ok {
+			return nil, fmt.Errorf("chain id %d is configured twice", chain.Id)
+		}
+
+		if chain.Type == "" {
+			chain.Type = ethereum.EthereumChain
+		}
+		eth, err := ethereum.NewEthereumService(chain.NodeURL, chain.Type)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Ethereum service for chain '%s':  %w", chain.Name, err)
+		}
+		chain.Name = strings.ToLower(chain.Name)
+		set = append(set, chainBackend{Chain: chain, eth: eth, blocks: newBlockCache(blockCacheSize), finalized: &finalizedHead{}})
+	}
+	return set, nil
+}
+
+func (cs chainSet) byId(chainId uint64) (*chainBackend, bool) {
+	for i := range cs {
+		if cs[i].Id == chainId {
+			return &cs[i], true
+		}
+	}
+	return nil, false
+}
+
+func (cs chainSet) byName(name string) (*chainBackend, bool) {
+	for i := range cs {
+		if strings.EqualFold(cs[i].Name, name) {
+			return &cs[i], true
+		}
+	}
+	return nil, false
+}
+
+// resolve finds a chain by its name or id, the default chain for ""
+func (cs chainSet) resolve(chain string) (*chainBackend, error) {
+	if chain == "" {
+		return &cs[0], nil
+	}
+	if chainId, err := strconv.ParseUint(chain, 10, 64); err == nil {
+		if backend, ok := cs.byId(chainId); ok {
+			return backend, nil
+		}
+		return nil, types.UnknownChain
+	}
+	if backend, ok := cs.byName(chain); ok {
+		return backend, nil
+	}
+	return nil, types.UnknownChain
+}
+
+func (cs chainSet) toApi() []api.Chain {
+	chains := make([]api.Chain, 0, len(cs))
+	for i, chain := range cs {
+		chains = append(chains, api.Chain{Id: chain.Id, Name: chain.Name, Type: chain.Type, Default: i == 0, Traces: chain.Traces})
+	}
+	return chains
+}
@@ -0,0 +1,154 @@
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"time"
+
+	custom "ethereum_fetcher/internal/services/transactions/types"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// pendingError is errPending with the sender and nonce of the transaction,
+// to find the transaction that replaces it later
+type pendingError struct {
+	txn custom.PendingTxn
+}
+
+func (e pendingError) Error() string {
+	return errPending.Error()
+}
+
+func (e pendingError) Unwrap() error {
+	return errPending
+}
+
+func (s *impl) pending(ctx context.Context, from common.Address, nonce uint64) error {
+	seenAt, err := s.client.BlockNumber(ctx)
+	if err != nil {
+		// without the block no replacement is searched for
+		s.logger.Errorf("Error fetching the latest block: %v", err)
+	}
+	return pendingError{custom.PendingTxn{From: from.Hex(), Nonce: nonce, SeenAtBlock: seenAt}}
+}
+
+// replacementScanBlocks bounds the blocks read one by one to find a
+// replacement when the node has no state for older blocks
+const replacementScanBlocks = 256
+
+func (s *impl) FindReplacement(from string, nonce uint64, sinceBlock uint64, budget custom.NodeCallBudget) (*custom.Replacement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := charge(budget, 2); err != nil {
+		return nil, err
+	}
+	sender := common.HexToAddress(from)
+	latest, err := s.client.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// every node keeps the state of the latest block
+	mined, err := s.client.NonceAt(ctx, sender, nil)
+	if err != nil {
+		return nil, err
+	}
+	if mined <= nonce {
+		return nil, nil
+	}
+
+	// the search reads the nonce at up to log2 blocks, then the block found
+	since := min(sinceBlock, latest)
+	if err := charge(budget, bits.Len64(latest-since)+1); err != nil {
+		return nil, err
+	}
+	number, err := s.nonceBlock(ctx, sender, nonce, since, latest)
+	if err != nil {
+		s.logger.Infof("No state to search for nonce %d of '%s', reading the blocks from %d:  %v", nonce, from, since, err)
+		last := min(latest, since+replacementScanBlocks-1)
+		if err := charge(budget, int(last-since+1)); err != nil {
+			return nil, err
+		}
+		return s.scanForNonce(ctx, sender, nonce, since, last)
+	}
+
+	replacement, err := s.nonceIn(ctx, sender, nonce, number)
+	if err != nil {
+		return nil, err
+	}
+	if replacement == nil {
+		return nil, fmt.Errorf("no transaction of '%s' with nonce %d in block %d", from, nonce, number)
+	}
+	return replacement, nil
+}
+
+// nonceBlock searches the first block after which the sender has used the
+// nonce, it holds the transaction that took it. Asking for the nonce at older
+// blocks needs an archive node.
+func (s *impl) nonceBlock(ctx context.Context, sender common.Address, nonce uint64, low uint64, high uint64) (uint64, error) {
+	for low < high {
+		mid := low + (high-low)/2
+		count, err := s.client.NonceAt(ctx, sender, new(big.Int).SetUint64(mid))
+		if err != nil {
+			return 0, err
+		}
+		if count > nonce {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return low, nil
+}
+
+// scanForNonce reads the blocks from since to last for the transaction of
+// the sender with the nonce
+func (s *impl) scanForNonce(ctx context.Context, sender common.Address, nonce uint64, since uint64, last uint64) (*custom.Replacement, error) {
+	for number := since; number <= last; number++ {
+		replacement, err := s.nonceIn(ctx, sender, nonce, number)
+		if err != nil || replacement != nil {
+			return replacement, err
+		}
+	}
+	return nil, fmt.Errorf("no transaction of '%s' with nonce %d in blocks %d to %d", sender.Hex(), nonce, since, last)
+}
+
+// nonceIn is the transaction of the sender with the nonce in the block, nil if
+// the block has none
+func (s *impl) nonceIn(ctx context.Context, sender common.Address, nonce uint64, number uint64) (*custom.Replacement, error) {
+	var block *struct {
+		Transactions []custom.RpcTxn `json:"transactions"`
+	}
+	if err := s.client.Client().CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeUint64(number), true); err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", number)
+	}
+
+	for _, txn := range block.Transactions {
+		if txn.From == sender && uint64(txn.Nonce) == nonce {
+			return &custom.Replacement{Hash: txn.Hash.Hex(), Cancelled: isCancellation(&txn)}, nil
+		}
+	}
+	return nil, nil
+}
+
+// charge asks the budget for the node calls, nil doesn't limit them
+func charge(budget custom.NodeCallBudget, calls int) error {
+	if budget == nil {
+		return nil
+	}
+	return budget.Charge(calls)
+}
+
+// isCancellation tells replacements that only send nothing to the sender,
+// what wallets send to cancel a transaction
+func isCancellation(txn *custom.RpcTxn) bool {
+	return txn.To != nil && *txn.To == txn.From && len(txn.Input) == 0 &&
+		(txn.Value == nil || txn.Value.ToInt().Sign() == 0)
+}
@@ -2,6 +2,7 @@ package ethereum
 
 import (
 	"context"
+	"errors"
 	"ethereum_fetcher/pkg/logging"
 	"fmt"
 	"sync"
@@ -11,43 +12,82 @@ import (
 
 	custom "ethereum_fetcher/internal/services/transactions/types"
 
+	geth "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
+var errPending = errors.New("transaction is pending")
+
+// Chain types, the transactions of rollups are read as RollupTxn
+const (
+	EthereumChain = "ethereum"
+	OptimismChain = "optimism"
+	ArbitrumChain = "arbitrum"
+)
+
 type EthService interface {
 	DecodeHashes(rlpHex string) ([]string, error)
-	ByHashes(hashes []string) ([]custom.EthTxnWithReceipt, error)
+	ByHashes(hashes []string) (custom.EthTxnsResult, error)
+	// Trace runs the callTracer of debug_traceTransaction, which nodes only
+	// serve with the debug namespace enabled
+	Trace(hash string) (*custom.RpcCallFrame, error)
+	// FindReplacement looks for the mined transaction of the sender with the
+	// nonce from the block a replaced transaction was seen pending at, nil
+	// while the nonce isn't used. Nodes without archive state have the blocks
+	// read one by one instead. The node calls are charged to the budget first,
+	// nil doesn't limit them.
+	FindReplacement(from string, nonce uint64, sinceBlock uint64, budget custom.NodeCallBudget) (*custom.Replacement, error)
+	// Block returns the header of a mined block, geth.NotFound for blocks the
+	// node doesn't know
+	Block(id custom.BlockId) (*custom.RpcBlock, error)
+	// FinalizedBlock returns the number of the newest block a reorg can't
+	// replace anymore
+	FinalizedBlock() (uint64, error)
 }
 
 type impl struct {
 	client *ethclient.Client
+	rollup bool
 	logger *logrus.Logger
 }
 
-func NewEthereumService(ethNodeURL string) (EthService, error) {
+// NewEthereumService connects to the node at ethNodeURL, chainType is one of
+// the chain types and "" for ethereum
+func NewEthereumService(ethNodeURL string, chainType string) (EthService, error) {
 	logger := logging.New()
 
+	var rollup bool
+	switch chainType {
+	case "", EthereumChain:
+	case OptimismChain, ArbitrumChain:
+		rollup = true
+	default:
+		return nil, fmt.Errorf("unknown chain type '%s'", chainType)
+	}
+
 	client, err := ethclient.Dial(ethNodeURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ethereum client:  %w", err)
 	}
 
-	return &impl{client: client, logger: logger}, nil
+	return &impl{client: client, rollup: rollup, logger: logger}, nil
 }
 
 func (s *impl) DecodeHashes(rlpHex string) ([]string, error) {
 	return DecodeHashes(rlpHex)
 }
 
-func (s *impl) ByHashes(hashes []string) ([]custom.EthTxnWithReceipt, error) {
+func (s *impl) ByHashes(hashes []string) (custom.EthTxnsResult, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	sem := make(chan struct{}, 10)
 
 	var (
-		txns    = make([]custom.EthTxnWithReceipt, 0, len(hashes))
+		result  = custom.EthTxnsResult{Txns: make([]custom.EthTxnWithReceipt, 0, len(hashes))}
 		errChan = make(chan error, len(hashes))
 		mu      sync.Mutex
 		wg      sync.WaitGroup
@@ -67,15 +107,27 @@ func (s *impl) ByHashes(hashes []string) ([]custom.EthTxnWithReceipt, error) {
 				return
 			}
 
-			result, err := s.fetchSingle(ctx, hash)
-			if err != nil {
-				errChan <- fmt.Errorf("failed to fetch tx %s: %w", hash, err)
-				return
-			}
+			txn, err := s.fetchSingle(ctx, hash)
 
 			mu.Lock()
-			txns = append(txns, result)
-			mu.Unlock()
+			defer mu.Unlock()
+
+			switch {
+			case errors.Is(err, geth.NotFound):
+				result.NotFound = append(result.NotFound, hash)
+			case errors.Is(err, errPending):
+				pending := custom.PendingTxn{}
+				var pendingErr pendingError
+				if errors.As(err, &pendingErr) {
+					pending = pendingErr.txn
+				}
+				pending.Hash = hash
+				result.Pending = append(result.Pending, pending)
+			case err != nil:
+				errChan <- fmt.Errorf("failed to fetch tx %s: %w", hash, err)
+			default:
+				result.Txns = append(result.Txns, txn)
+			}
 		}(hash)
 	}
 
@@ -88,31 +140,46 @@ func (s *impl) ByHashes(hashes []string) ([]custom.EthTxnWithReceipt, error) {
 	select {
 	case <-done:
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		return custom.EthTxnsResult{}, ctx.Err()
 	}
 
 	select {
 	case err := <-errChan:
-		return nil, err
+		return custom.EthTxnsResult{}, err
 	default:
-		return txns, nil
+		return result, nil
 	}
 }
 
 func (s *impl) fetchSingle(ctx context.Context, hash string) (custom.EthTxnWithReceipt, error) {
 	txHash := common.HexToHash(hash)
+	if s.rollup {
+		return s.fetchRollup(ctx, txHash)
+	}
 
 	tx, isPending, err := s.client.TransactionByHash(ctx, txHash)
+	if errors.Is(err, geth.NotFound) {
+		s.logger.Debugf("Transaction '%s' not found", txHash.Hex())
+		return custom.EthTxnWithReceipt{}, err
+	}
 	if err != nil {
 		s.logger.Errorf("Error fetching transaction '%s': %v", txHash.Hex(), err)
 		return custom.EthTxnWithReceipt{}, err
 	}
 
 	if isPending {
-		return custom.EthTxnWithReceipt{}, nil
+		from, err := gethtypes.Sender(gethtypes.LatestSignerForChainID(tx.ChainId()), tx)
+		if err != nil {
+			return custom.EthTxnWithReceipt{}, errPending
+		}
+		return custom.EthTxnWithReceipt{}, s.pending(ctx, from, tx.Nonce())
 	}
 
 	receipt, err := s.client.TransactionReceipt(ctx, tx.Hash())
+	if errors.Is(err, geth.NotFound) {
+		// the node knows the transaction but hasn't indexed its receipt yet
+		return custom.EthTxnWithReceipt{}, errPending
+	}
 	if err != nil {
 		s.logger.Errorf("Error fetching receipt for '%s': %v", tx.Hash().Hex(), err)
 		return custom.EthTxnWithReceipt{}, err
@@ -120,3 +187,99 @@ func (s *impl) fetchSingle(ctx context.Context, hash string) (custom.EthTxnWithR
 
 	return custom.EthTxnWithReceipt{Txn: tx, Receipt: receipt}, nil
 }
+
+// fetchRollup reads the transaction and its receipt as a RollupTxn
+func (s *impl) fetchRollup(ctx context.Context, txHash common.Hash) (custom.EthTxnWithReceipt, error) {
+	var tx *custom.RpcTxn
+	if err := s.client.Client().CallContext(ctx, &tx, "eth_getTransactionByHash", txHash); err != nil {
+		s.logger.Errorf("Error fetching transaction '%s': %v", txHash.Hex(), err)
+		return custom.EthTxnWithReceipt{}, err
+	}
+	if tx == nil {
+		s.logger.Debugf("Transaction '%s' not found", txHash.Hex())
+		return custom.EthTxnWithReceipt{}, geth.NotFound
+	}
+	if tx.BlockHash == nil {
+		return custom.EthTxnWithReceipt{}, s.pending(ctx, tx.From, uint64(tx.Nonce))
+	}
+
+	var receipt *custom.RpcReceipt
+	if err := s.client.Client().CallContext(ctx, &receipt, "eth_getTransactionReceipt", txHash); err != nil {
+		s.logger.Errorf("Error fetching receipt for '%s': %v", txHash.Hex(), err)
+		return custom.EthTxnWithReceipt{}, err
+	}
+	if receipt == nil {
+		// the node knows the transaction but hasn't indexed its receipt yet
+		return custom.EthTxnWithReceipt{}, errPending
+	}
+
+	return custom.EthTxnWithReceipt{Rollup: &custom.RollupTxn{Txn: *tx, Receipt: *receipt}}, nil
+}
+
+func (s *impl) Trace(hash string) (*custom.RpcCallFrame, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	txHash := common.HexToHash(hash)
+	tracer := map[string]string{"tracer": "callTracer"}
+
+	var frame *custom.RpcCallFrame
+	if err := s.client.Client().CallContext(ctx, &frame, "debug_traceTransaction", txHash, tracer); err != nil {
+		s.logger.Errorf("Error tracing transaction '%s': %v", txHash.Hex(), err)
+		return nil, err
+	}
+	if frame == nil {
+		return nil, geth.NotFound
+	}
+	return frame, nil
+}
+
+func (s *impl) Block(id custom.BlockId) (*custom.RpcBlock, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var (
+		block *custom.RpcBlock
+		err   error
+	)
+	if id.Hash != "" {
+		err = s.client.Client().CallContext(ctx, &block, "eth_getBlockByHash", common.HexToHash(id.Hash), false)
+	} else {
+		err = s.client.Client().CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeUint64(id.Number), false)
+	}
+	if err != nil {
+		s.logger.Errorf("Error fetching block '%s': %v", id, err)
+		return nil, err
+	}
+	if block == nil {
+		return nil, geth.NotFound
+	}
+	return block, nil
+}
+
+// finalityDepth is how many blocks below the head are treated as final on
+// nodes that know neither the finalized nor the safe block
+const finalityDepth = 64
+
+func (s *impl) FinalizedBlock() (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, tag := range []string{"finalized", "safe"} {
+		var block *custom.RpcBlock
+		err := s.client.Client().CallContext(ctx, &block, "eth_getBlockByNumber", tag, false)
+		if err == nil && block != nil {
+			return uint64(block.Number), nil
+		}
+	}
+
+	latest, err := s.client.BlockNumber(ctx)
+	if err != nil {
+		s.logger.Errorf("Error fetching the finalized block: %v", err)
+		return 0, err
+	}
+	if latest < finalityDepth {
+		return 0, nil
+	}
+	return latest - finalityDepth, nil
+}
@@ -0,0 +1,70 @@
+package transactions
+
+import (
+	"hash/maphash"
+)
+
+const sketchDepth = 4
+
+// frequencySketch is a count-min sketch estimating how often each hash has
+// been requested recently. Counters saturate at 15 and are all halved once
+// the number of increments reaches ten times the width, so old popularity
+// fades out.
+type frequencySketch struct {
+	seed      maphash.Seed
+	counters  [sketchDepth][]uint8
+	width     uint64
+	additions int
+	resetAt   int
+}
+
+func newFrequencySketch(width int) *frequencySketch {
+	s := &frequencySketch{
+		seed:    maphash.MakeSeed(),
+		width:   uint64(width),
+		resetAt: 10 * width,
+	}
+	for i := range s.counters {
+		s.counters[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *frequencySketch) increment(key string) {
+	h := maphash.String(s.seed, key)
+	for i := range s.counters {
+		idx := s.index(h, i)
+		if s.counters[i][idx] < 15 {
+			s.counters[i][idx]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.age()
+	}
+}
+
+func (s *frequencySketch) estimate(key string) uint8 {
+	h := maphash.String(s.seed, key)
+	estimate := uint8(15)
+	for i := range s.counters {
+		estimate = min(estimate, s.counters[i][s.index(h, i)])
+	}
+	return estimate
+}
+
+func (s *frequencySketch) age() {
+	for i := range s.counters {
+		for j := range s.counters[i] {
+			s.counters[i][j] /= 2
+		}
+	}
+	s.additions /= 2
+}
+
+// index derives the counter of each row from one hash by double hashing
+func (s *frequencySketch) index(h uint64, row int) uint64 {
+	h1, h2 := h, (h>>32)|(h<<32)
+	return (h1 + uint64(row)*h2) % s.width
+}
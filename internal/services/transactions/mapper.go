@@ -4,24 +4,31 @@ import (
 	"math/big"
 	"time"
 
+	"ethereum_fetcher/api"
+	db "ethereum_fetcher/db/models"
 	custom "ethereum_fetcher/internal/services/transactions/types"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
-func toDbTxns(txns []custom.EthTxnWithReceipt) ([]custom.DbTxn, error) {
+func toDbTxns(chainId uint64, txns []custom.EthTxnWithReceipt) ([]custom.DbTxn, error) {
 	dbTxs := make([]custom.DbTxn, 0, len(txns))
 
 	for _, pair := range txns {
-		dbTx := toDbTxn(pair.Txn, pair.Receipt)
+		if pair.Rollup != nil {
+			dbTxs = append(dbTxs, rollupToDbTxn(chainId, pair.Rollup))
+			continue
+		}
+		dbTx := toDbTxn(chainId, pair.Txn, pair.Receipt)
 		dbTxs = append(dbTxs, dbTx)
 	}
 
 	return dbTxs, nil
 }
 
-func toDbTxn(tx *custom.EthTxn, receipt *custom.EthReceipt) custom.DbTxn {
+func toDbTxn(chainId uint64, tx *custom.EthTxn, receipt *custom.EthReceipt) custom.DbTxn {
 	from, _ := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
 
 	var to *string
@@ -37,6 +44,7 @@ func toDbTxn(tx *custom.EthTxn, receipt *custom.EthReceipt) custom.DbTxn {
 	}
 
 	return custom.DbTxn{
+		ChainId:           chainId,
 		TransactionHash:   tx.Hash().Hex(),
 		TransactionStatus: int(receipt.Status),
 		BlockHash:         receipt.BlockHash.Hex(),
@@ -47,10 +55,114 @@ func toDbTxn(tx *custom.EthTxn, receipt *custom.EthReceipt) custom.DbTxn {
 		LogsCount:         len(receipt.Logs),
 		Input:             common.Bytes2Hex(tx.Data()),
 		Value:             tx.Value().String(),
+		TxType:            uint64(tx.Type()),
+		GasUsed:           receipt.GasUsed,
+		EffectiveGasPrice: bigString(receipt.EffectiveGasPrice),
+		TotalFee:          totalFee(receipt).String(),
 		CreatedAt:         time.Now(),
 	}
 }
 
+// totalFee is the execution fee plus the blob fee of blob transactions
+func totalFee(receipt *custom.EthReceipt) *big.Int {
+	fee := new(big.Int).SetUint64(receipt.GasUsed)
+	fee.Mul(fee, bigOrZero(receipt.EffectiveGasPrice))
+	if receipt.BlobGasPrice != nil {
+		blobFee := new(big.Int).SetUint64(receipt.BlobGasUsed)
+		fee.Add(fee, blobFee.Mul(blobFee, receipt.BlobGasPrice))
+	}
+	return fee
+}
+
+func rollupToDbTxn(chainId uint64, rollup *custom.RollupTxn) custom.DbTxn {
+	tx, receipt := rollup.Txn, rollup.Receipt
+
+	var to *string
+	if tx.To != nil {
+		toAddress := tx.To.Hex()
+		to = &toAddress
+	}
+
+	var contractAddress *string
+	if receipt.ContractAddress != nil && *receipt.ContractAddress != (common.Address{}) {
+		addr := receipt.ContractAddress.Hex()
+		contractAddress = &addr
+	}
+
+	// rollups charge gasUsed at the effective gas price, on the OP Stack the
+	// L1 data fee comes on top, Arbitrum counts it in gasUsed already
+	effectiveGasPrice := (*big.Int)(receipt.EffectiveGasPrice)
+	fee := new(big.Int).SetUint64(uint64(receipt.GasUsed))
+	fee.Mul(fee, bigOrZero(effectiveGasPrice))
+	if receipt.L1Fee != nil {
+		fee.Add(fee, (*big.Int)(receipt.L1Fee))
+	}
+
+	return custom.DbTxn{
+		ChainId:           chainId,
+		TransactionHash:   tx.Hash.Hex(),
+		TransactionStatus: int(receipt.Status),
+		BlockHash:         receipt.BlockHash.Hex(),
+		BlockNumber:       bigOrZero((*big.Int)(receipt.BlockNumber)).Uint64(),
+		FromAddress:       tx.From.Hex(),
+		ToAddress:         to,
+		ContractAddress:   contractAddress,
+		LogsCount:         len(receipt.Logs),
+		Input:             common.Bytes2Hex(tx.Input),
+		Value:             bigOrZero((*big.Int)(tx.Value)).String(),
+		TxType:            uint64(tx.Type),
+		GasUsed:           uint64(receipt.GasUsed),
+		EffectiveGasPrice: bigString(effectiveGasPrice),
+		TotalFee:          fee.String(),
+		L2: db.L2Receipt{
+			L1Fee:                 hexBigString(receipt.L1Fee),
+			L1GasUsed:             (*uint64)(receipt.L1GasUsed),
+			L1GasPrice:            hexBigString(receipt.L1GasPrice),
+			L1BlobBaseFee:         hexBigString(receipt.L1BlobBaseFee),
+			L1FeeScalar:           receipt.L1FeeScalar,
+			L1BaseFeeScalar:       (*uint64)(receipt.L1BaseFeeScalar),
+			L1BlobBaseFeeScalar:   (*uint64)(receipt.L1BlobBaseFeeScalar),
+			SourceHash:            hashString(tx.SourceHash),
+			Mint:                  hexBigString(tx.Mint),
+			IsSystemTx:            tx.IsSystemTx,
+			DepositNonce:          (*uint64)(receipt.DepositNonce),
+			DepositReceiptVersion: (*uint64)(receipt.DepositReceiptVersion),
+			GasUsedForL1:          (*uint64)(receipt.GasUsedForL1),
+		},
+		CreatedAt: time.Now(),
+	}
+}
+
+func bigOrZero(value *big.Int) *big.Int {
+	if value == nil {
+		return new(big.Int)
+	}
+	return value
+}
+
+func bigString(value *big.Int) string {
+	if value == nil {
+		return ""
+	}
+	return value.String()
+}
+
+func hexBigString(value *hexutil.Big) *string {
+	if value == nil {
+		return nil
+	}
+	str := value.ToInt().String()
+	return &str
+}
+
+func hashString(hash *common.Hash) *string {
+	if hash == nil {
+		return nil
+	}
+	str := hash.Hex()
+	return &str
+}
+
 func toApiTxns(dbTxs []custom.DbTxn) []custom.ApiTxn {
 	apiTxs := make([]custom.ApiTxn, 0, len(dbTxs))
 
@@ -64,15 +176,44 @@ func toApiTxns(dbTxs []custom.DbTxn) []custom.ApiTxn {
 
 func toApiTxn(txn *custom.DbTxn) custom.ApiTxn {
 	return custom.ApiTxn{
+		ChainId:           txn.ChainId,
 		TransactionHash:   txn.TransactionHash,
 		TransactionStatus: txn.TransactionStatus,
 		BlockHash:         txn.BlockHash,
 		BlockNumber:       big.NewInt(int64(txn.BlockNumber)),
+		BlockTimestamp:    txn.BlockTimestamp,
 		From:              txn.FromAddress,
 		To:                txn.ToAddress,
 		ContractAddress:   txn.ContractAddress,
 		LogsCount:         txn.LogsCount,
 		Input:             txn.Input,
 		Value:             txn.Value,
+		Type:              txn.TxType,
+		GasUsed:           txn.GasUsed,
+		EffectiveGasPrice: txn.EffectiveGasPrice,
+		TotalFee:          txn.TotalFee,
+		L2:                toApiL2Receipt(&txn.L2),
+	}
+}
+
+// toApiL2Receipt is nil for transactions on L1 chains
+func toApiL2Receipt(l2 *db.L2Receipt) *api.L2Receipt {
+	if *l2 == (db.L2Receipt{}) {
+		return nil
+	}
+	return &api.L2Receipt{
+		L1Fee:                 l2.L1Fee,
+		L1GasUsed:             l2.L1GasUsed,
+		L1GasPrice:            l2.L1GasPrice,
+		L1BlobBaseFee:         l2.L1BlobBaseFee,
+		L1FeeScalar:           l2.L1FeeScalar,
+		L1BaseFeeScalar:       l2.L1BaseFeeScalar,
+		L1BlobBaseFeeScalar:   l2.L1BlobBaseFeeScalar,
+		SourceHash:            l2.SourceHash,
+		Mint:                  l2.Mint,
+		IsSystemTx:            l2.IsSystemTx,
+		DepositNonce:          l2.DepositNonce,
+		DepositReceiptVersion: l2.DepositReceiptVersion,
+		GasUsedForL1:          l2.GasUsedForL1,
 	}
 }
@@ -0,0 +1,97 @@
+package transactions
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	defaultNegativeTTL = 30 * time.Second
+	maxNegativeEntries = 100_000
+)
+
+// negativeCache remembers hashes the node didn't know for a short while. It
+// is bounded, dropping the oldest entries first, so a flood of random hashes
+// can't grow it without limit. A zero ttl disables it.
+type negativeCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+}
+
+type negativeEntry struct {
+	hash      string
+	expiresAt time.Time
+}
+
+func newNegativeCache(ttl time.Duration, maxEntries int) *negativeCache {
+	return &negativeCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (c *negativeCache) add(hashes []string) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	for _, hash := range hashes {
+		if elem, ok := c.entries[hash]; ok {
+			elem.Value.(*negativeEntry).expiresAt = expiresAt
+			c.order.MoveToFront(elem)
+			continue
+		}
+		c.entries[hash] = c.order.PushFront(&negativeEntry{hash: hash, expiresAt: expiresAt})
+	}
+
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *negativeCache) split(hashes []string) ([]string, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	notFound := make([]string, 0)
+	rest := make([]string, 0, len(hashes))
+
+	for _, hash := range hashes {
+		elem, ok := c.entries[hash]
+		if ok && now.After(elem.Value.(*negativeEntry).expiresAt) {
+			c.remove(elem)
+			ok = false
+		}
+		if ok {
+			notFound = append(notFound, hash)
+		} else {
+			rest = append(rest, hash)
+		}
+	}
+
+	return notFound, rest
+}
+
+func (c *negativeCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+func (c *negativeCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*negativeEntry)
+	delete(c.entries, entry.hash)
+}
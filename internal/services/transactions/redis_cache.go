@@ -0,0 +1,276 @@
+package transactions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	types "ethereum_fetcher/internal/services/transactions/types"
+	"ethereum_fetcher/pkg/logging"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	redisKeyPrefix         = "txn:"
+	redisNotFoundKeyPrefix = "txn-not-found:"
+	redisPurgeChannel      = "txn-purged"
+	redisTimeout           = 2 * time.Second
+	redisPurgeTimeout      = time.Minute
+	redisScanCount         = 1000
+)
+
+// redisCache stores transactions as JSON in a Redis (or Valkey) server shared
+// by all replicas. Errors talking to the server are logged and treated as
+// cache misses so an unavailable cache never fails a request.
+type redisCache struct {
+	client *redis.Client
+	// keyPrefix and notFoundKeyPrefix keep the keys of each chain apart
+	keyPrefix         string
+	notFoundKeyPrefix string
+	ttl               time.Duration
+	negativeTTL       time.Duration
+	logger            *logrus.Logger
+
+	// purgeChannel tells the other replicas the keys were purged
+	purgeChannel string
+}
+
+func NewRedisCache(redisURL string, ttl time.Duration, negativeTTL time.Duration) (TxnCache, error) {
+	return newRedisCache(redisURL, ttl, negativeTTL, 0)
+}
+
+// newRedisCache namespaces the keys with the chain
+func newRedisCache(redisURL string, ttl time.Duration, negativeTTL time.Duration, chainId uint64) (TxnCache, error) {
+	if redisURL == "" {
+		return nil, fmt.Errorf("a redis URL is required for the redis cache")
+	}
+
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL:  %w", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to redis:  %w", err)
+	}
+
+	return &redisCache{
+		client:            client,
+		keyPrefix:         fmt.Sprintf("%s%d:", redisKeyPrefix, chainId),
+		notFoundKeyPrefix: fmt.Sprintf("%s%d:", redisNotFoundKeyPrefix, chainId),
+		purgeChannel:      fmt.Sprintf("%s:%d", redisPurgeChannel, chainId),
+		ttl:               ttl,
+		negativeTTL:       negativeTTL,
+		logger:            logging.New(),
+	}, nil
+}
+
+func (c *redisCache) Get(hash string) (*types.DbTxn, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	value, err := c.client.Get(ctx, c.keyPrefix+hash).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			c.logger.Warnf("failed to read transaction '%s' from redis:  %v", hash, err)
+		}
+		return nil, false
+	}
+
+	return c.decode(hash, value)
+}
+
+func (c *redisCache) GetMany(hashes []string) types.TxnsResult {
+	result := types.TxnsResult{
+		ExistingTxns:   make([]types.DbTxn, 0, len(hashes)),
+		ExistingHashes: make([]string, 0, len(hashes)),
+		MissingHashes:  make([]string, 0, len(hashes)),
+	}
+	if len(hashes) == 0 {
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	keys := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		keys = append(keys, c.keyPrefix+hash)
+	}
+
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		c.logger.Warnf("failed to read transactions from redis:  %v", err)
+		result.MissingHashes = append(result.MissingHashes, hashes...)
+		return result
+	}
+
+	for i, hash := range hashes {
+		value, ok := values[i].(string)
+		if !ok {
+			result.MissingHashes = append(result.MissingHashes, hash)
+			continue
+		}
+		txn, ok := c.decode(hash, []byte(value))
+		if !ok {
+			result.MissingHashes = append(result.MissingHashes, hash)
+			continue
+		}
+		result.ExistingTxns = append(result.ExistingTxns, *txn)
+		result.ExistingHashes = append(result.ExistingHashes, hash)
+	}
+
+	return result
+}
+
+func (c *redisCache) Set(txn *types.DbTxn) {
+	c.SetMany([]types.DbTxn{*txn})
+}
+
+func (c *redisCache) SetMany(txns []types.DbTxn) {
+	if len(txns) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	pipe := c.client.Pipeline()
+	for _, txn := range txns {
+		value, err := json.Marshal(txn)
+		if err != nil {
+			c.logger.Warnf("failed to encode transaction '%s' for redis:  %v", txn.TransactionHash, err)
+			continue
+		}
+		pipe.Set(ctx, c.keyPrefix+txn.TransactionHash, value, c.ttl)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		c.logger.Warnf("failed to write transactions to redis:  %v", err)
+	}
+}
+
+func (c *redisCache) SetNotFound(hashes []string) {
+	if c.negativeTTL <= 0 || len(hashes) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	pipe := c.client.Pipeline()
+	for _, hash := range hashes {
+		pipe.Set(ctx, c.notFoundKeyPrefix+hash, 1, c.negativeTTL)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		c.logger.Warnf("failed to write not found hashes to redis:  %v", err)
+	}
+}
+
+func (c *redisCache) SplitNotFound(hashes []string) ([]string, []string) {
+	if c.negativeTTL <= 0 || len(hashes) == 0 {
+		return []string{}, hashes
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	keys := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		keys = append(keys, c.notFoundKeyPrefix+hash)
+	}
+
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		c.logger.Warnf("failed to read not found hashes from redis:  %v", err)
+		return []string{}, hashes
+	}
+
+	notFound := make([]string, 0)
+	rest := make([]string, 0, len(hashes))
+	for i, hash := range hashes {
+		if values[i] != nil {
+			notFound = append(notFound, hash)
+		} else {
+			rest = append(rest, hash)
+		}
+	}
+	return notFound, rest
+}
+
+// Purge deletes the keys of this cache only, the server may be shared with
+// other applications, and announces it on the purge channel
+func (c *redisCache) Purge() error {
+	if err := c.purgeKeys(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return c.client.Publish(ctx, c.purgeChannel, 1).Err()
+}
+
+// OnPurge calls purged whenever a replica purges the cache, including this
+// one. It returns once subscribed.
+func (c *redisCache) OnPurge(purged func()) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	sub := c.client.Subscribe(ctx, c.purgeChannel)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return fmt.Errorf("failed to subscribe to cache purges:  %w", err)
+	}
+
+	go func() {
+		for range sub.Channel() {
+			purged()
+		}
+	}()
+	return nil
+}
+
+func (c *redisCache) purgeKeys() error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisPurgeTimeout)
+	defer cancel()
+
+	for _, prefix := range []string{c.keyPrefix, c.notFoundKeyPrefix} {
+		iter := c.client.Scan(ctx, 0, prefix+"*", redisScanCount).Iterator()
+		keys := make([]string, 0, redisScanCount)
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+			if len(keys) == redisScanCount {
+				if err := c.client.Unlink(ctx, keys...).Err(); err != nil {
+					return err
+				}
+				keys = keys[:0]
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := c.client.Unlink(ctx, keys...).Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *redisCache) decode(hash string, value []byte) (*types.DbTxn, bool) {
+	var txn types.DbTxn
+	if err := json.Unmarshal(value, &txn); err != nil {
+		c.logger.Warnf("failed to decode cached transaction '%s':  %v", hash, err)
+		return nil, false
+	}
+	return &txn, true
+}
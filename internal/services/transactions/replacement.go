@@ -0,0 +1,120 @@
+package transactions
+
+import (
+	"time"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/models"
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+// storedReplacements are the known replacements of the hashes, a failure
+// only lets the node be asked again
+func (s *impl) storedReplacements(chainId uint64, hashes []string) []api.Replacement {
+	pending, err := s.repo.GetPending(chainId, hashes)
+	if err != nil {
+		s.logger.Errorf("failed to load pending transactions for hashes: '%s':  %v", hashes, err)
+		return nil
+	}
+
+	var replacements []api.Replacement
+	for _, txn := range pending {
+		if txn.ReplacedBy != nil {
+			replacements = append(replacements, toApiReplacement(&txn))
+		}
+	}
+	return replacements
+}
+
+// recordPending keeps the sender and nonce of transactions seen pending, a
+// failure only loses the chance to tell they were replaced
+func (s *impl) recordPending(chainId uint64, pending []types.PendingTxn) {
+	records := make([]models.PendingTransaction, 0, len(pending))
+	for _, txn := range pending {
+		if txn.From == "" {
+			continue
+		}
+		records = append(records, models.PendingTransaction{
+			ChainId:         chainId,
+			TransactionHash: txn.Hash,
+			FromAddress:     txn.From,
+			Nonce:           txn.Nonce,
+			SeenAtBlock:     txn.SeenAtBlock,
+			CreatedAt:       time.Now(),
+		})
+	}
+
+	if err := s.repo.SavePending(records); err != nil {
+		s.logger.Errorf("failed to store pending transactions:  %v", err)
+	}
+}
+
+// findReplacements looks for the transactions that took the nonce of the
+// hashes the node doesn't know anymore, among those seen pending at a known
+// block. The hashes no replacement was found for are returned apart, their
+// nonce can still be taken. It fails when the budget refuses the search.
+func (s *impl) findReplacements(chain *chainBackend, hashes []string, opts types.FetchOptions) ([]api.Replacement, []string, error) {
+	pending, err := s.repo.GetPending(chain.Id, hashes)
+	if err != nil {
+		s.logger.Errorf("failed to load pending transactions for hashes: '%s':  %v", hashes, err)
+		return nil, nil, nil
+	}
+
+	budget := &searchBudget{budget: opts.Budget}
+	var replacements []api.Replacement
+	var unreplaced []string
+	for _, txn := range pending {
+		if txn.ReplacedBy == nil {
+			if txn.SeenAtBlock == 0 {
+				continue
+			}
+			replacement, err := chain.eth.FindReplacement(txn.FromAddress, txn.Nonce, txn.SeenAtBlock, budget)
+			if budget.refused != nil {
+				s.logger.Infof("Node calls to find the replacement of '%s' refused:  %v", txn.TransactionHash, budget.refused)
+				return nil, nil, budget.refused
+			}
+			if err != nil {
+				s.logger.Errorf("failed to look for the replacement of '%s':  %v", txn.TransactionHash, err)
+			}
+			if replacement == nil {
+				unreplaced = append(unreplaced, txn.TransactionHash)
+				continue
+			}
+
+			s.logger.Infof("Transaction '%s' was replaced by '%s'", txn.TransactionHash, replacement.Hash)
+			if err := s.repo.SetReplacedBy(chain.Id, txn.TransactionHash, *replacement); err != nil {
+				s.logger.Errorf("failed to store the replacement of '%s':  %v", txn.TransactionHash, err)
+			}
+			txn.ReplacedBy = &replacement.Hash
+			txn.Cancelled = replacement.Cancelled
+		}
+		replacements = append(replacements, toApiReplacement(&txn))
+	}
+	return replacements, unreplaced, nil
+}
+
+// searchBudget keeps the refusal of the budget of a lookup, to fail the lookup
+// with it rather than with the error of the search
+type searchBudget struct {
+	budget  types.NodeCallBudget
+	refused error
+}
+
+func (b *searchBudget) Charge(calls int) error {
+	if b.budget == nil {
+		return nil
+	}
+	if err := b.budget.Charge(calls); err != nil {
+		b.refused = err
+		return err
+	}
+	return nil
+}
+
+func toApiReplacement(txn *models.PendingTransaction) api.Replacement {
+	return api.Replacement{
+		TransactionHash: txn.TransactionHash,
+		ReplacedBy:      *txn.ReplacedBy,
+		Cancelled:       txn.Cancelled,
+	}
+}
@@ -1,19 +1,41 @@
 package transactions
 
 import (
-	"errors"
+	"math"
+
 	"ethereum_fetcher/db/models"
+	types "ethereum_fetcher/internal/services/transactions/types"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TxnRepo interface {
 	Save(txns []models.Transaction) error
-	AddUserTransactions(txnHashes []string, userId uint64) error
-	GetForHashes(txnHashes []string) ([]models.Transaction, error)
-	GetUserTransactions(userId uint64) ([]models.Transaction, error)
-	GetAll() ([]models.Transaction, error)
+	AddUserTransactions(chainId uint64, txnHashes []string, userId uint64, orgId uint64) error
+	GetForHashes(chainId uint64, txnHashes []string) ([]models.Transaction, error)
+	GetUserTransactions(userId uint64, filter types.HistoryFilter) ([]models.Transaction, error)
+	// GetOrgTransactions filters by the collections and tags of the user
+	GetOrgTransactions(orgId uint64, userId uint64, filter types.HistoryFilter) ([]models.Transaction, error)
+	// UserOrg is the organization of the user, 0 if they aren't in one
+	UserOrg(userId uint64) (uint64, error)
+	// GetAll returns the transactions on the chain mined in the period
+	GetAll(chainId uint64, period types.MinedPeriod) ([]models.Transaction, error)
+	GetMostRequested(limit int) ([]models.Transaction, error)
+	// GetTrace is the stored trace of the transaction, nil if it wasn't traced
+	GetTrace(chainId uint64, txnHash string) (*models.TransactionTrace, error)
+	SaveTrace(trace *models.TransactionTrace) error
+	// SavePending keeps the first record of transactions seen pending
+	SavePending(pending []models.PendingTransaction) error
+	GetPending(chainId uint64, txnHashes []string) ([]models.PendingTransaction, error)
+	SetReplacedBy(chainId uint64, txnHash string, replacement types.Replacement) error
+	// GetBlock is the stored block, nil if it wasn't looked up before
+	GetBlock(chainId uint64, id types.BlockId) (*models.Block, error)
+	GetBlocks(chainId uint64, numbers []uint64) ([]models.Block, error)
+	SaveBlock(block *models.Block) error
+	// SetBlockTimestamps stores the timestamps of undated transactions
+	SetBlockTimestamps(txns []models.Transaction) error
 }
 
 func NewTxnRepo(db *gorm.DB) TxnRepo {
@@ -28,52 +50,219 @@ func (r *repoImpl) Save(txns []models.Transaction) error {
 	return r.db.Create(&txns).Error
 }
 
-func (r *repoImpl) AddUserTransactions(txnHashes []string, userId uint64) error {
-	newUserTxns := make([]models.UserTransaction, 0, len(txnHashes))
+// AddUserTransactions records a lookup of every hash for the user and their
+// organization, bumping the count of hashes requested before
+func (r *repoImpl) AddUserTransactions(chainId uint64, txnHashes []string, userId uint64, orgId uint64) error {
+	now := time.Now()
+	seen := make(map[string]bool, len(txnHashes))
+	userTxns := make([]models.UserTransaction, 0, len(txnHashes))
 
 	for _, txnHash := range txnHashes {
-		var existingUserTxn models.UserTransaction
-		result := r.db.Where(&models.UserTransaction{
-			UserId:          userId,
-			TransactionHash: txnHash,
-		}).First(&existingUserTxn)
-
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			newUserTxns = append(newUserTxns, models.UserTransaction{
-				UserId:          userId,
-				TransactionHash: txnHash,
-				RequestedAt:     time.Now(),
-			})
+		// a batch upsert can't touch the same row twice
+		if seen[txnHash] {
+			continue
 		}
+		seen[txnHash] = true
+
+		userTxns = append(userTxns, models.UserTransaction{
+			UserId:           userId,
+			OrgId:            orgId,
+			ChainId:          chainId,
+			TransactionHash:  txnHash,
+			RequestCount:     1,
+			FirstRequestedAt: now,
+			LastRequestedAt:  now,
+		})
 	}
 
-	if len(newUserTxns) > 0 {
-		return r.db.Create(&newUserTxns).Error
+	if len(userTxns) == 0 {
+		return nil
 	}
 
-	return nil
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "org_id"}, {Name: "chain_id"}, {Name: "transaction_hash"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"request_count":     gorm.Expr("user_transactions.request_count + 1"),
+			"last_requested_at": gorm.Expr("excluded.last_requested_at"),
+		}),
+	}).Create(&userTxns).Error
 }
 
-func (r *repoImpl) GetForHashes(txnHashes []string) ([]models.Transaction, error) {
+func (r *repoImpl) GetForHashes(chainId uint64, txnHashes []string) ([]models.Transaction, error) {
 	var transactions []models.Transaction
-	err := r.db.Where("transaction_hash IN ?", txnHashes).Find(&transactions).Error
+	err := onChain(r.db, chainId).Where("transaction_hash IN ?", txnHashes).Find(&transactions).Error
 	return transactions, err
 }
 
-func (r *repoImpl) GetUserTransactions(userId uint64) ([]models.Transaction, error) {
+// GetUserTransactions returns the transactions the user requested, once even
+// if they requested them for several organizations
+func (r *repoImpl) GetUserTransactions(userId uint64, filter types.HistoryFilter) ([]models.Transaction, error) {
+	return r.getRequestedBy(r.db.Table("user_transactions").
+		Select("chain_id, transaction_hash").
+		Where("user_id = ?", userId), userId, filter)
+}
+
+// GetOrgTransactions returns the transactions any member requested for the
+// organization
+func (r *repoImpl) GetOrgTransactions(orgId uint64, userId uint64, filter types.HistoryFilter) ([]models.Transaction, error) {
+	return r.getRequestedBy(r.db.Table("user_transactions").
+		Select("chain_id, transaction_hash").
+		Where("org_id = ?", orgId), userId, filter)
+}
+
+func (r *repoImpl) getRequestedBy(requested *gorm.DB, userId uint64, filter types.HistoryFilter) ([]models.Transaction, error) {
+	query := minedIn(onChain(r.db, filter.ChainId), filter.MinedPeriod).
+		Where("(chain_id, transaction_hash) IN (?)", requested)
+
+	// collections of other users and organizations match nothing
+	if filter.CollectionId != 0 {
+		orgOfUser := r.db.Model(&models.User{}).Select("org_id").Where("id = ? AND org_id IS NOT NULL", userId)
+		query = query.Where("(chain_id, transaction_hash) IN (?)", r.db.Table("collection_transactions").
+			Select("collection_transactions.chain_id, collection_transactions.transaction_hash").
+			Joins("JOIN collections ON collections.id = collection_transactions.collection_id").
+			Where("collections.id = ?", filter.CollectionId).
+			Where("((collections.org_id IS NULL AND collections.user_id = ?) OR collections.org_id IN (?))", userId, orgOfUser))
+	}
+	if filter.Tag != "" {
+		query = query.Where("(chain_id, transaction_hash) IN (?)", r.db.Table("transaction_tags").
+			Select("chain_id, transaction_hash").
+			Where("user_id = ? AND tag = ?", userId, filter.Tag))
+	}
+
 	var transactions []models.Transaction
+	err := query.Find(&transactions).Error
+	return transactions, err
+}
+
+func (r *repoImpl) UserOrg(userId uint64) (uint64, error) {
+	var orgIds []uint64
+	err := r.db.Table("users").
+		Where("id = ? AND org_id IS NOT NULL", userId).
+		Pluck("org_id", &orgIds).Error
+	if err != nil || len(orgIds) == 0 {
+		return 0, err
+	}
+	return orgIds[0], nil
+}
+
+func (r *repoImpl) GetAll(chainId uint64, period types.MinedPeriod) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	err := minedIn(onChain(r.db, chainId), period).Find(&transactions).Error
+	return transactions, err
+}
+
+// GetMostRequested returns the stored transactions requested most often across
+// all users, most requested first
+func (r *repoImpl) GetMostRequested(limit int) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+
+	requests := r.db.Table("user_transactions").
+		Select("chain_id, transaction_hash, SUM(request_count) AS requests").
+		Group("chain_id, transaction_hash")
 
 	err := r.db.Table("transactions").
 		Select("transactions.*").
-		Joins("JOIN user_transactions ON transactions.transaction_hash = user_transactions.transaction_hash").
-		Where("user_transactions.user_id = ?", userId).
+		Joins("JOIN (?) AS requested ON transactions.chain_id = requested.chain_id AND transactions.transaction_hash = requested.transaction_hash", requests).
+		Order("requested.requests DESC").
+		Limit(limit).
 		Find(&transactions).Error
 
 	return transactions, err
 }
 
-func (r *repoImpl) GetAll() ([]models.Transaction, error) {
-	var transactions []models.Transaction
-	err := r.db.Find(&transactions).Error
-	return transactions, err
+func (r *repoImpl) GetTrace(chainId uint64, txnHash string) (*models.TransactionTrace, error) {
+	var traces []models.TransactionTrace
+	err := r.db.Where("chain_id = ? AND transaction_hash = ?", chainId, txnHash).Limit(1).Find(&traces).Error
+	if err != nil || len(traces) == 0 {
+		return nil, err
+	}
+	return &traces[0], nil
+}
+
+// SaveTrace keeps the trace stored first when two requests trace the same
+// transaction
+func (r *repoImpl) SaveTrace(trace *models.TransactionTrace) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(trace).Error
+}
+
+func (r *repoImpl) SavePending(pending []models.PendingTransaction) error {
+	if len(pending) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&pending).Error
+}
+
+func (r *repoImpl) GetPending(chainId uint64, txnHashes []string) ([]models.PendingTransaction, error) {
+	var pending []models.PendingTransaction
+	err := r.db.Where("chain_id = ? AND transaction_hash IN ?", chainId, txnHashes).Find(&pending).Error
+	return pending, err
+}
+
+func (r *repoImpl) SetReplacedBy(chainId uint64, txnHash string, replacement types.Replacement) error {
+	return r.db.Model(&models.PendingTransaction{}).
+		Where("chain_id = ? AND transaction_hash = ?", chainId, txnHash).
+		Updates(map[string]interface{}{"replaced_by": replacement.Hash, "cancelled": replacement.Cancelled}).Error
+}
+
+func (r *repoImpl) GetBlock(chainId uint64, id types.BlockId) (*models.Block, error) {
+	query := r.db.Where("chain_id = ?", chainId)
+	if id.Hash != "" {
+		query = query.Where("hash = ?", id.Hash)
+	} else {
+		query = query.Where("number = ?", id.Number)
+	}
+
+	var blocks []models.Block
+	if err := query.Limit(1).Find(&blocks).Error; err != nil || len(blocks) == 0 {
+		return nil, err
+	}
+	return &blocks[0], nil
+}
+
+func (r *repoImpl) GetBlocks(chainId uint64, numbers []uint64) ([]models.Block, error) {
+	var blocks []models.Block
+	err := r.db.Where("chain_id = ? AND number IN ?", chainId, numbers).Find(&blocks).Error
+	return blocks, err
+}
+
+func (r *repoImpl) SaveBlock(block *models.Block) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(block).Error
+}
+
+func (r *repoImpl) SetBlockTimestamps(txns []models.Transaction) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, txn := range txns {
+			if txn.BlockTimestamp == nil {
+				continue
+			}
+			err := tx.Model(&models.Transaction{}).
+				Where("chain_id = ? AND block_number = ? AND block_timestamp IS NULL", txn.ChainId, txn.BlockNumber).
+				Update("block_timestamp", *txn.BlockTimestamp).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// minedIn keeps the transactions mined in the period and those without a
+// block timestamp, for the service to date
+func minedIn(db *gorm.DB, period types.MinedPeriod) *gorm.DB {
+	if period.From == 0 && period.To == 0 {
+		return db
+	}
+	to := period.To
+	if to == 0 {
+		to = math.MaxInt64
+	}
+	return db.Where("(block_timestamp IS NULL OR block_timestamp BETWEEN ? AND ?)", period.From, to)
+}
+
+// onChain keeps the rows of the chain, every row for 0
+func onChain(db *gorm.DB, chainId uint64) *gorm.DB {
+	if chainId == 0 {
+		return db
+	}
+	return db.Where("chain_id = ?", chainId)
 }
@@ -1,99 +1,189 @@
 package transactions
 
 import (
-	"ethereum_fetcher/internal/services/transactions/ethereum"
+	"regexp"
+	"strings"
+
+	"ethereum_fetcher/api"
 	types "ethereum_fetcher/internal/services/transactions/types"
 	"ethereum_fetcher/pkg/logging"
-	"fmt"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+var txnHashPattern = regexp.MustCompile(`^0x[0-9a-f]{64}$`)
+
 type TxnService interface {
-	ByHashes(hashes []string, userId uint64) ([]types.ApiTxn, error)
-	FromRLPHex(rlpHex string, userId uint64) ([]types.ApiTxn, error)
-	ForUser(userId uint64) ([]types.ApiTxn, error)
-	All() ([]types.ApiTxn, error)
+	// ByHashes looks the hashes up on a chain, by its id
+	ByHashes(chainId uint64, hashes []string, userId uint64, opts types.FetchOptions) ([]types.ApiTxn, error)
+	FromRLPHex(chainId uint64, rlpHex string, userId uint64, opts types.FetchOptions) ([]types.ApiTxn, error)
+	// ForUser returns the lookups of the user or, with OrgHistory, of every
+	// member of their organization
+	ForUser(userId uint64, query types.HistoryQuery) ([]types.ApiTxn, error)
+	// All returns the stored transactions on a chain mined in the period
+	All(chainId uint64, period types.MinedPeriod, opts types.FetchOptions) ([]types.ApiTxn, error)
+	// Stored returns the stored transactions among the hashes on the chain,
+	// without asking the node or recording a lookup
+	Stored(chainId uint64, hashes []string) ([]types.ApiTxn, error)
+	// Chains lists the configured chains, the default first
+	Chains() []api.Chain
+	// ChainId resolves a chain by its name or id, the default chain for ""
+	ChainId(chain string) (uint64, error)
+	// Trace returns the call tree of a transaction and the ether its internal
+	// calls moved, on chains with traces enabled
+	Trace(chainId uint64, hash string, userId uint64, opts types.FetchOptions) (api.TransactionTrace, error)
+	// Block looks a block up by its number or hash
+	Block(chainId uint64, numberOrHash string, opts types.FetchOptions) (api.Block, error)
+	// EmbedBlocks sets the block of the transactions
+	EmbedBlocks(txns []types.ApiTxn, opts types.FetchOptions) error
 }
 
 type impl struct {
 	repo   TxnRepo
-	eth    ethereum.EthService
-	cache  TxnCache
+	chains chainSet
 	logger *logrus.Logger
 }
 
-func NewTxnService(db *gorm.DB, ethNodeURL string) (TxnService, error) {
+// NewTxnService looks transactions up on the chains, the first is the
+// default
+func NewTxnService(db *gorm.DB, chains []Chain) (TxnService, error) {
 	logger := logging.New()
 	TxnRepo := NewTxnRepo(db)
 
-	ethService, err := ethereum.NewEthereumService(ethNodeURL)
+	chainSet, err := newChainSet(chains)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Ethereum service:  %w", err)
+		return nil, err
 	}
 
-	cache := NewTxnCache()
+	return &impl{repo: TxnRepo, chains: chainSet, logger: logger}, nil
+}
+
+func (s *impl) Chains() []api.Chain {
+	return s.chains.toApi()
+}
 
-	return &impl{repo: TxnRepo, eth: ethService, cache: cache, logger: logger}, nil
+func (s *impl) ChainId(chain string) (uint64, error) {
+	backend, err := s.chains.resolve(chain)
+	if err != nil {
+		return 0, err
+	}
+	return backend.Id, nil
 }
 
-func (s *impl) FromRLPHex(rlpHex string, userId uint64) ([]types.ApiTxn, error) {
-	hashes, err := s.eth.DecodeHashes(rlpHex)
+func (s *impl) FromRLPHex(chainId uint64, rlpHex string, userId uint64, opts types.FetchOptions) ([]types.ApiTxn, error) {
+	chain, ok := s.chains.byId(chainId)
+	if !ok {
+		return nil, types.UnknownChain
+	}
+	hashes, err := chain.eth.DecodeHashes(rlpHex)
 	if err != nil {
 		return nil, types.InvalidRlpEncoding
 	}
-	return s.ByHashes(hashes, userId)
+	return s.ByHashes(chainId, hashes, userId, opts)
 }
 
-func (s *impl) ByHashes(hashes []string, userId uint64) ([]types.ApiTxn, error) {
-	// todo: implement ishex check
-	s.recordUserTransactions(hashes, userId)
+func (s *impl) ByHashes(chainId uint64, hashes []string, userId uint64, opts types.FetchOptions) ([]types.ApiTxn, error) {
+	chain, ok := s.chains.byId(chainId)
+	if !ok {
+		return nil, types.UnknownChain
+	}
 
-	cacheResult := s.loadFromCache(hashes)
+	hashes, err := normalizeHashes(hashes)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recordUserTransactions(chainId, hashes, userId); err != nil {
+		return nil, err
+	}
+
+	cacheResult := chain.Cache.GetMany(hashes)
 	if len(cacheResult.MissingHashes) == 0 {
 		s.logger.Infof("Fetched all transactions from the cache: '%s'", hashes)
+		s.stampUndated(chain, cacheResult.ExistingTxns, opts)
 		return toApiTxns(cacheResult.ExistingTxns), nil
 	} else {
 		s.logger.Infof("Transactions for hashes: '%s' found in the cache", cacheResult.ExistingHashes)
 	}
 
-	dbResult, err := s.loadFromDb(cacheResult.MissingHashes)
+	if !opts.BypassNegativeCache {
+		if notFound, _ := chain.Cache.SplitNotFound(cacheResult.MissingHashes); len(notFound) > 0 {
+			s.logger.Infof("Transactions for hashes: '%s' were recently not found", notFound)
+			return nil, types.FailedToFetchTransaction
+		}
+	}
+
+	dbResult, err := s.loadFromDb(chainId, cacheResult.MissingHashes)
 	if err != nil {
 		s.logger.Infof("failed to load existing transactions for hashes: '%s'", cacheResult.MissingHashes)
 		return nil, types.NewTxnError("failed to load existing transactions")
 	}
-	s.cacheTxns(dbResult.ExistingTxns)
+	chain.Cache.SetMany(dbResult.ExistingTxns)
+
+	found := append(cacheResult.ExistingTxns, dbResult.ExistingTxns...)
+	s.stampUndated(chain, found, opts)
 
 	if len(dbResult.MissingHashes) == 0 {
 		s.logger.Infof("Fetched all transactions from the database: '%s'", cacheResult.MissingHashes)
-		return toApiTxns(dbResult.ExistingTxns), nil
+		return toApiTxns(found), nil
 	} else {
 		s.logger.Infof("Transactions for hashes: '%s' fetched from the database", dbResult.ExistingHashes)
 	}
 
-	newTxns, err := s.getFromEth(dbResult.MissingHashes)
-	if err != nil || len(newTxns) == 0 {
-		return nil, types.FailedToFetchTransaction
+	// replaced transactions are answered without asking the node again
+	if replacements := s.storedReplacements(chainId, dbResult.MissingHashes); len(replacements) > 0 {
+		return nil, types.NewReplacedError(replacements)
+	}
+
+	if opts.Budget != nil {
+		if err := opts.Budget.Charge(len(dbResult.MissingHashes)); err != nil {
+			s.logger.Infof("Node calls for hashes: '%s' refused:  %v", dbResult.MissingHashes, err)
+			return nil, err
+		}
 	}
 
-	storeErr := s.storeTxns(newTxns)
-	if storeErr != nil {
-		return nil, storeErr
+	ethResult, newTxns, err := s.getFromEth(chain, dbResult.MissingHashes)
+	if err != nil {
+		return nil, types.FailedToFetchTransaction
 	}
-	s.cacheTxns(newTxns)
 
-	all := append(append(cacheResult.ExistingTxns, dbResult.ExistingTxns...), newTxns...)
+	if len(newTxns) > 0 {
+		s.setBlockTimestamps(chain, newTxns, opts)
+		if err := s.storeTxns(newTxns); err != nil {
+			return nil, err
+		}
+		chain.Cache.SetMany(newTxns)
+	}
 
-	return toApiTxns(all), nil
-}
+	// pending transactions are left out of the negative cache, they will be
+	// found as soon as they are mined or replaced
+	if len(ethResult.Pending) > 0 {
+		s.recordPending(chainId, ethResult.Pending)
+	}
+	if len(ethResult.NotFound) > 0 {
+		s.logger.Infof("Transactions for hashes: '%s' not found on the node", ethResult.NotFound)
+		replacements, unreplaced, err := s.findReplacements(chain, ethResult.NotFound, opts)
+		if err != nil {
+			return nil, err
+		}
+		if len(replacements) > 0 {
+			return nil, types.NewReplacedError(replacements)
+		}
+		// transactions seen pending are asked for again until a replacement
+		// takes their nonce
+		chain.Cache.SetNotFound(without(ethResult.NotFound, unreplaced))
+		return nil, types.FailedToFetchTransaction
+	}
+	if len(ethResult.Pending) > 0 {
+		s.logger.Infof("Transactions for hashes: '%s' are pending", pendingHashes(ethResult.Pending))
+		return nil, types.TransactionPending
+	}
 
-func (s *impl) loadFromCache(hashes []string) types.TxnsResult {
-	return s.cache.GetMany(hashes)
+	return toApiTxns(append(found, newTxns...)), nil
 }
 
-func (s *impl) loadFromDb(hashes []string) (types.TxnsResult, error) {
-	existingTxns, err := s.repo.GetForHashes(hashes)
+func (s *impl) loadFromDb(chainId uint64, hashes []string) (types.TxnsResult, error) {
+	existingTxns, err := s.repo.GetForHashes(chainId, hashes)
 	if err != nil {
 		return types.TxnsResult{}, err
 	}
@@ -120,60 +210,146 @@ func (s *impl) loadFromDb(hashes []string) (types.TxnsResult, error) {
 	}, nil
 }
 
-func (s *impl) getFromEth(hashes []string) ([]types.DbTxn, error) {
-	s.logger.Infof("Fetching transactions for hashes: '%s' from the Ethereum node", hashes)
-	ethTxnsResult, err := s.eth.ByHashes(hashes)
+func (s *impl) getFromEth(chain *chainBackend, hashes []string) (types.EthTxnsResult, []types.DbTxn, error) {
+	s.logger.Infof("Fetching transactions for hashes: '%s' from the '%s' node", hashes, chain.Name)
+	ethTxnsResult, err := chain.eth.ByHashes(hashes)
 	if err != nil {
-		s.logger.Errorf("failed to fetch missing transactions for hashes: '%s':  %w", hashes, err)
-		return nil, types.NewEthError("failed to fetch missing transactions")
+		s.logger.Errorf("failed to fetch missing transactions for hashes: '%s':  %v", hashes, err)
+		return types.EthTxnsResult{}, nil, types.NewEthError("failed to fetch missing transactions")
 	}
 
-	newTxns, err := toDbTxns(ethTxnsResult)
+	newTxns, err := toDbTxns(chain.Id, ethTxnsResult.Txns)
 	if err != nil {
-		s.logger.Errorf("failed to convert transactions to DB models for hashes: '%s':  %w", hashes, err)
-		return nil, types.NewTxnError("failed to convert transactions to DB models")
-
+		s.logger.Errorf("failed to convert transactions to DB models for hashes: '%s':  %v", hashes, err)
+		return types.EthTxnsResult{}, nil, types.NewTxnError("failed to convert transactions to DB models")
 	}
 
-	return newTxns, nil
-}
-
-func (s *impl) cacheTxns(txns []types.DbTxn) {
-	s.cache.SetMany(txns)
+	return ethTxnsResult, newTxns, nil
 }
 
 func (s *impl) storeTxns(txns []types.DbTxn) error {
-	s.logger.Infof("Saving new transactions for hashes: '%s' to the database", txns)
+	s.logger.Infof("Saving new transactions for hashes: '%s' to the database", txnHashes(txns))
 	if err := s.repo.Save(txns); err != nil {
-		s.logger.Errorf("failed to store new transactions for hashes: '%s':  %w", txns, err)
+		s.logger.Errorf("failed to store new transactions for hashes: '%s':  %v", txnHashes(txns), err)
 		return types.NewTxnError("failed to store new transactions")
 	}
 	return nil
 }
 
-func (s *impl) recordUserTransactions(hashes []string, userId uint64) error {
+func (s *impl) recordUserTransactions(chainId uint64, hashes []string, userId uint64) error {
 	if userId == 0 {
 		return nil
 	}
-	s.logger.Infof("Storing user transactions for user: '%s' and hashes: '%s'", userId, hashes)
-	return s.repo.AddUserTransactions(hashes, userId)
+	orgId, err := s.repo.UserOrg(userId)
+	if err != nil {
+		s.logger.Errorf("failed to find the organization of user '%d':  %v", userId, err)
+		return types.NewTxnError("failed to store user transactions")
+	}
+
+	s.logger.Infof("Storing user transactions for user: '%d' and hashes: '%s'", userId, hashes)
+	if err := s.repo.AddUserTransactions(chainId, hashes, userId, orgId); err != nil {
+		s.logger.Errorf("failed to store user transactions for user '%d':  %v", userId, err)
+		return types.NewTxnError("failed to store user transactions")
+	}
+	return nil
+}
+
+// normalizeHashes lowercases the hashes, they are cached, stored and sent to
+// the node that way
+func normalizeHashes(hashes []string) ([]string, error) {
+	normalized := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		hash = strings.ToLower(hash)
+		if !txnHashPattern.MatchString(hash) {
+			return nil, types.InvalidTxnHash
+		}
+		normalized = append(normalized, hash)
+	}
+	return normalized, nil
+}
+
+func pendingHashes(pending []types.PendingTxn) []string {
+	hashes := make([]string, 0, len(pending))
+	for _, txn := range pending {
+		hashes = append(hashes, txn.Hash)
+	}
+	return hashes
 }
 
-func (s *impl) ForUser(userId uint64) ([]types.ApiTxn, error) {
-	txns, err := s.repo.GetUserTransactions(userId)
+// without is the hashes not among the excluded ones
+func without(hashes []string, excluded []string) []string {
+	if len(excluded) == 0 {
+		return hashes
+	}
+	skip := make(map[string]bool, len(excluded))
+	for _, hash := range excluded {
+		skip[hash] = true
+	}
+
+	kept := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		if !skip[hash] {
+			kept = append(kept, hash)
+		}
+	}
+	return kept
+}
+
+func txnHashes(txns []types.DbTxn) []string {
+	hashes := make([]string, 0, len(txns))
+	for _, txn := range txns {
+		hashes = append(hashes, txn.TransactionHash)
+	}
+	return hashes
+}
+
+func (s *impl) ForUser(userId uint64, query types.HistoryQuery) ([]types.ApiTxn, error) {
+	if query.Scope == types.OrgHistory {
+		return s.forOrg(userId, query)
+	}
+
+	txns, err := s.repo.GetUserTransactions(userId, query.HistoryFilter)
 	if err != nil {
-		s.logger.Errorf("failed to fetch user transactions for user '%s':  %v", userId, err)
+		s.logger.Errorf("failed to fetch user transactions for user '%d':  %v", userId, err)
 		return nil, types.NewTxnError("failed to fetch user transactions")
 	}
 
-	return toApiTxns(txns), nil
+	return toApiTxns(s.inPeriod(txns, query.MinedPeriod, query.Fetch)), nil
+}
+
+func (s *impl) forOrg(userId uint64, query types.HistoryQuery) ([]types.ApiTxn, error) {
+	orgId, err := s.repo.UserOrg(userId)
+	if err != nil {
+		s.logger.Errorf("failed to find the organization of user '%d':  %v", userId, err)
+		return nil, types.NewTxnError("failed to fetch organization transactions")
+	}
+	if orgId == 0 {
+		return nil, types.NotInOrganization
+	}
+
+	txns, err := s.repo.GetOrgTransactions(orgId, userId, query.HistoryFilter)
+	if err != nil {
+		s.logger.Errorf("failed to fetch transactions of organization '%d':  %v", orgId, err)
+		return nil, types.NewTxnError("failed to fetch organization transactions")
+	}
+
+	return toApiTxns(s.inPeriod(txns, query.MinedPeriod, query.Fetch)), nil
 }
 
-func (s *impl) All() ([]types.ApiTxn, error) {
-	txns, err := s.repo.GetAll()
+func (s *impl) All(chainId uint64, period types.MinedPeriod, opts types.FetchOptions) ([]types.ApiTxn, error) {
+	txns, err := s.repo.GetAll(chainId, period)
 	if err != nil {
-		s.logger.Errorf("failed to fetch all transactions:  %w", err)
+		s.logger.Errorf("failed to fetch all transactions:  %v", err)
 		return nil, types.NewTxnError("failed to fetch all transactions")
 	}
+	return toApiTxns(s.inPeriod(txns, period, opts)), nil
+}
+
+func (s *impl) Stored(chainId uint64, hashes []string) ([]types.ApiTxn, error) {
+	txns, err := s.repo.GetForHashes(chainId, hashes)
+	if err != nil {
+		s.logger.Errorf("failed to fetch stored transactions for hashes: '%s':  %v", hashes, err)
+		return nil, types.NewTxnError("failed to fetch stored transactions")
+	}
 	return toApiTxns(txns), nil
 }
@@ -0,0 +1,114 @@
+package transactions
+
+import (
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+// tieredCache serves from a small local cache first and falls back to a
+// cache shared between replicas, copying shared hits into the local tier
+type tieredCache struct {
+	local  TxnCache
+	shared TxnCache
+}
+
+// purgeNotifier is a shared cache that tells every replica when it was
+// purged
+type purgeNotifier interface {
+	OnPurge(purged func()) error
+}
+
+// NewTieredCache purges the local tier whenever any replica purges the shared
+// one, when the shared tier announces purges
+func NewTieredCache(local TxnCache, shared TxnCache) (TxnCache, error) {
+	cache := &tieredCache{local: local, shared: shared}
+	if notifier, ok := shared.(purgeNotifier); ok {
+		err := notifier.OnPurge(func() {
+			// only fails for remote caches, the local tier is in-process
+			_ = local.Purge()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cache, nil
+}
+
+func (c *tieredCache) Get(hash string) (*types.DbTxn, bool) {
+	if txn, ok := c.local.Get(hash); ok {
+		return txn, true
+	}
+
+	txn, ok := c.shared.Get(hash)
+	if ok {
+		c.local.Set(txn)
+	}
+	return txn, ok
+}
+
+func (c *tieredCache) GetMany(hashes []string) types.TxnsResult {
+	local := c.local.GetMany(hashes)
+	if len(local.MissingHashes) == 0 {
+		return local
+	}
+
+	shared := c.shared.GetMany(local.MissingHashes)
+	c.local.SetMany(shared.ExistingTxns)
+
+	return types.TxnsResult{
+		ExistingTxns:   append(local.ExistingTxns, shared.ExistingTxns...),
+		ExistingHashes: append(local.ExistingHashes, shared.ExistingHashes...),
+		MissingHashes:  shared.MissingHashes,
+	}
+}
+
+func (c *tieredCache) Set(txn *types.DbTxn) {
+	c.local.Set(txn)
+	c.shared.Set(txn)
+}
+
+func (c *tieredCache) SetMany(txns []types.DbTxn) {
+	c.local.SetMany(txns)
+	c.shared.SetMany(txns)
+}
+
+func (c *tieredCache) SetNotFound(hashes []string) {
+	c.local.SetNotFound(hashes)
+	c.shared.SetNotFound(hashes)
+}
+
+func (c *tieredCache) SplitNotFound(hashes []string) ([]string, []string) {
+	localNotFound, rest := c.local.SplitNotFound(hashes)
+	if len(rest) == 0 {
+		return localNotFound, rest
+	}
+
+	sharedNotFound, rest := c.shared.SplitNotFound(rest)
+	c.local.SetNotFound(sharedNotFound)
+
+	return append(localNotFound, sharedNotFound...), rest
+}
+
+// Purge drops both tiers, the other replicas drop their local tier when the
+// shared one announces the purge
+func (c *tieredCache) Purge() error {
+	if err := c.shared.Purge(); err != nil {
+		return err
+	}
+	return c.local.Purge()
+}
+
+// Stats reports the local tier, the shared tier keeps no counters
+func (c *tieredCache) Stats() CacheStats {
+	if reporter, ok := c.local.(StatsReporter); ok {
+		return reporter.Stats()
+	}
+	return CacheStats{}
+}
+
+// Snapshot saves the local tier, the shared tier survives restarts on its own
+func (c *tieredCache) Snapshot(limit int) []types.DbTxn {
+	if snapshotter, ok := c.local.(Snapshotter); ok {
+		return snapshotter.Snapshot(limit)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package transactions
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/models"
+	types "ethereum_fetcher/internal/services/transactions/types"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Trace returns the call tree of a mined transaction, traced on the node the
+// first time and stored. The transaction is looked up like by ByHashes first,
+// tracing it costs one more node call.
+func (s *impl) Trace(chainId uint64, hash string, userId uint64, opts types.FetchOptions) (api.TransactionTrace, error) {
+	chain, ok := s.chains.byId(chainId)
+	if !ok {
+		return api.TransactionTrace{}, types.UnknownChain
+	}
+	if !chain.Traces {
+		return api.TransactionTrace{}, types.TracesDisabled
+	}
+	hash = strings.ToLower(hash)
+	if !txnHashPattern.MatchString(hash) {
+		return api.TransactionTrace{}, types.InvalidTxnHash
+	}
+
+	if _, err := s.ByHashes(chainId, []string{hash}, userId, opts); err != nil {
+		return api.TransactionTrace{}, err
+	}
+
+	calls, err := s.loadTrace(chain, hash, opts)
+	if err != nil {
+		return api.TransactionTrace{}, err
+	}
+
+	return api.TransactionTrace{
+		ChainId:         chainId,
+		TransactionHash: hash,
+		Calls:           calls,
+		Transfers:       internalTransfers(calls),
+	}, nil
+}
+
+func (s *impl) loadTrace(chain *chainBackend, hash string, opts types.FetchOptions) (api.CallFrame, error) {
+	stored, err := s.repo.GetTrace(chain.Id, hash)
+	if err != nil {
+		s.logger.Errorf("failed to load the trace of '%s':  %v", hash, err)
+		return api.CallFrame{}, types.NewTxnError("failed to load trace")
+	}
+	if stored != nil {
+		var calls api.CallFrame
+		if err := json.Unmarshal([]byte(stored.Trace), &calls); err != nil {
+			s.logger.Errorf("failed to decode the stored trace of '%s':  %v", hash, err)
+			return api.CallFrame{}, types.NewTxnError("failed to load trace")
+		}
+		return calls, nil
+	}
+
+	if opts.Budget != nil {
+		if err := opts.Budget.Charge(1); err != nil {
+			s.logger.Infof("Node call to trace '%s' refused:  %v", hash, err)
+			return api.CallFrame{}, err
+		}
+	}
+
+	s.logger.Infof("Tracing transaction '%s' on the '%s' node", hash, chain.Name)
+	frame, err := chain.eth.Trace(hash)
+	if err != nil {
+		s.logger.Errorf("failed to trace transaction '%s':  %v", hash, err)
+		return api.CallFrame{}, types.FailedToFetchTrace
+	}
+
+	calls := toApiCallFrame(frame)
+	encoded, err := json.Marshal(calls)
+	if err != nil {
+		s.logger.Errorf("failed to encode the trace of '%s':  %v", hash, err)
+		return api.CallFrame{}, types.NewTxnError("failed to store trace")
+	}
+
+	trace := models.TransactionTrace{ChainId: chain.Id, TransactionHash: hash, Trace: string(encoded), CreatedAt: time.Now()}
+	if err := s.repo.SaveTrace(&trace); err != nil {
+		s.logger.Errorf("failed to store the trace of '%s':  %v", hash, err)
+		return api.CallFrame{}, types.NewTxnError("failed to store trace")
+	}
+	return calls, nil
+}
+
+func toApiCallFrame(frame *types.RpcCallFrame) api.CallFrame {
+	calls := api.CallFrame{
+		Type:         strings.ToUpper(frame.Type),
+		From:         frame.From.Hex(),
+		Gas:          uint64(frame.Gas),
+		GasUsed:      uint64(frame.GasUsed),
+		Input:        common.Bytes2Hex(frame.Input),
+		Output:       common.Bytes2Hex(frame.Output),
+		Error:        frame.Error,
+		RevertReason: frame.RevertReason,
+	}
+	if frame.To != nil {
+		calls.To = frame.To.Hex()
+	}
+	if frame.Value != nil {
+		calls.Value = frame.Value.ToInt().String()
+	}
+	for i := range frame.Calls {
+		calls.Calls = append(calls.Calls, toApiCallFrame(&frame.Calls[i]))
+	}
+	return calls
+}
+
+// internalTransfers flattens the calls below the transaction that moved
+// ether, in the order they were made. Reverted calls and the calls they made
+// moved nothing, delegate and static calls can't move ether.
+func internalTransfers(root api.CallFrame) []api.InternalTransfer {
+	transfers := make([]api.InternalTransfer, 0)
+
+	var walk func(frame api.CallFrame, depth int)
+	walk = func(frame api.CallFrame, depth int) {
+		if frame.Error != "" {
+			return
+		}
+		if depth > 0 && movesValue(frame) {
+			transfers = append(transfers, api.InternalTransfer{
+				Type:  frame.Type,
+				From:  frame.From,
+				To:    frame.To,
+				Value: frame.Value,
+				Depth: depth,
+			})
+		}
+		for _, call := range frame.Calls {
+			walk(call, depth+1)
+		}
+	}
+	walk(root, 0)
+
+	return transfers
+}
+
+func movesValue(frame api.CallFrame) bool {
+	if frame.Type == "DELEGATECALL" || frame.Type == "STATICCALL" || frame.Value == "" {
+		return false
+	}
+	value, ok := new(big.Int).SetString(frame.Value, 10)
+	return ok && value.Sign() > 0
+}
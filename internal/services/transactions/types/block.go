@@ -0,0 +1,38 @@
+package transactions
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// RpcBlock are the header fields of eth_getBlockByNumber the fetcher keeps
+type RpcBlock struct {
+	Number        hexutil.Uint64     `json:"number"`
+	Hash          common.Hash        `json:"hash"`
+	ParentHash    common.Hash        `json:"parentHash"`
+	Timestamp     hexutil.Uint64     `json:"timestamp"`
+	Miner         common.Address     `json:"miner"`
+	BaseFeePerGas *hexutil.Big       `json:"baseFeePerGas"`
+	GasUsed       hexutil.Uint64     `json:"gasUsed"`
+	GasLimit      hexutil.Uint64     `json:"gasLimit"`
+	BlobGasUsed   *hexutil.Uint64    `json:"blobGasUsed"`
+	ExcessBlobGas *hexutil.Uint64    `json:"excessBlobGas"`
+	Withdrawals   *[]json.RawMessage `json:"withdrawals"`
+	Transactions  []json.RawMessage  `json:"transactions"`
+}
+
+// BlockId is a block by its number or, when Hash is set, by its hash
+type BlockId struct {
+	Number uint64
+	Hash   string
+}
+
+func (id BlockId) String() string {
+	if id.Hash != "" {
+		return id.Hash
+	}
+	return strconv.FormatUint(id.Number, 10)
+}
@@ -1,6 +1,9 @@
 package transactions
 
-import "ethereum_fetcher/internal/services/errors"
+import (
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/internal/services/errors"
+)
 
 type TxnError struct {
 	errors.ServiceError
@@ -20,8 +23,35 @@ func NewEthError(msg string) EthError {
 
 var (
 	FailedToFetchTransaction = NewEthError("failed to fetch transaction")
+	TransactionPending       = NewEthError("transaction is pending")
+	FailedToFetchTrace       = NewEthError("failed to trace transaction")
+	FailedToFetchBlock       = NewEthError("failed to fetch block")
+	BlockNotFound            = NewEthError("block not found")
 )
 
+var (
+	InvalidHistoryScope = NewTxnError("'scope' must be user or org")
+	NotInOrganization   = NewTxnError("user is not a member of an organization")
+	UnknownChain        = NewTxnError("unknown chain")
+	TracesDisabled      = NewTxnError("transactions can't be traced on this chain")
+	InvalidTxnHash      = NewTxnError("invalid transaction hash")
+	InvalidBlockId      = NewTxnError("a block is looked up by its number or hash")
+	InvalidEmbed        = NewTxnError("'embed' must be block")
+	UnboundedEmbed      = NewTxnError("'embed=block' on all transactions needs 'from' or 'to'")
+	InvalidMinedPeriod  = NewTxnError("'from' and 'to' must be days like 2006-01-02 or times like 2006-01-02T15:04:05Z and 'from' can't be after 'to'")
+)
+
+// ReplacedError is returned for transactions that will never be mined
+// because another transaction of their sender took their nonce
+type ReplacedError struct {
+	TxnError
+	Replacements []api.Replacement
+}
+
+func NewReplacedError(replacements []api.Replacement) ReplacedError {
+	return ReplacedError{NewTxnError("transaction was replaced"), replacements}
+}
+
 type RlpError struct {
 	EthError
 }
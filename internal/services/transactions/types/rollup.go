@@ -0,0 +1,63 @@
+package transactions
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// RollupTxn is a transaction and its receipt as a rollup node returns them
+// over JSON-RPC
+type RollupTxn struct {
+	Txn     RpcTxn
+	Receipt RpcReceipt
+}
+
+// RpcTxn are the fields of eth_getTransactionByHash the fetcher keeps.
+// Transactions, receipts and blocks are read raw where rollups are involved,
+// go-ethereum neither decodes the deposit and system transaction types of
+// rollups nor keeps the fields their receipts add.
+type RpcTxn struct {
+	Hash      common.Hash     `json:"hash"`
+	Type      hexutil.Uint64  `json:"type"`
+	Nonce     hexutil.Uint64  `json:"nonce"`
+	From      common.Address  `json:"from"`
+	To        *common.Address `json:"to"`
+	Input     hexutil.Bytes   `json:"input"`
+	Value     *hexutil.Big    `json:"value"`
+	BlockHash *common.Hash    `json:"blockHash"`
+
+	// SourceHash, Mint and IsSystemTx are set on OP Stack deposits
+	SourceHash *common.Hash `json:"sourceHash"`
+	Mint       *hexutil.Big `json:"mint"`
+	IsSystemTx *bool        `json:"isSystemTx"`
+}
+
+// RpcReceipt are the fields of eth_getTransactionReceipt the fetcher keeps
+type RpcReceipt struct {
+	Status            hexutil.Uint64    `json:"status"`
+	BlockHash         common.Hash       `json:"blockHash"`
+	BlockNumber       *hexutil.Big      `json:"blockNumber"`
+	ContractAddress   *common.Address   `json:"contractAddress"`
+	Logs              []json.RawMessage `json:"logs"`
+	GasUsed           hexutil.Uint64    `json:"gasUsed"`
+	EffectiveGasPrice *hexutil.Big      `json:"effectiveGasPrice"`
+
+	// OP Stack L1 data fee, l1FeeScalar is a decimal string and only set
+	// before the Ecotone upgrade, the base fee scalars after it
+	L1Fee               *hexutil.Big    `json:"l1Fee"`
+	L1GasUsed           *hexutil.Uint64 `json:"l1GasUsed"`
+	L1GasPrice          *hexutil.Big    `json:"l1GasPrice"`
+	L1BlobBaseFee       *hexutil.Big    `json:"l1BlobBaseFee"`
+	L1FeeScalar         *string         `json:"l1FeeScalar"`
+	L1BaseFeeScalar     *hexutil.Uint64 `json:"l1BaseFeeScalar"`
+	L1BlobBaseFeeScalar *hexutil.Uint64 `json:"l1BlobBaseFeeScalar"`
+
+	// OP Stack deposits
+	DepositNonce          *hexutil.Uint64 `json:"depositNonce"`
+	DepositReceiptVersion *hexutil.Uint64 `json:"depositReceiptVersion"`
+
+	// Arbitrum, the part of gasUsed charged for posting to L1
+	GasUsedForL1 *hexutil.Uint64 `json:"gasUsedForL1"`
+}
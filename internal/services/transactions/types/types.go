@@ -11,6 +11,7 @@ type EthTxn = eth.Transaction
 type EthReceipt = eth.Receipt
 
 type DbTxn = db.Transaction
+type L2Receipt = db.L2Receipt
 type ApiTxn = api.Transaction
 
 type TxnsResult struct {
@@ -19,7 +20,80 @@ type TxnsResult struct {
 	MissingHashes  []string
 }
 
+// EthTxnWithReceipt is a mined transaction, Rollup is set instead of Txn and
+// Receipt for transactions read from a rollup node
 type EthTxnWithReceipt struct {
 	Txn     *EthTxn
 	Receipt *EthReceipt
+	Rollup  *RollupTxn
+}
+
+// EthTxnsResult splits a node lookup into mined transactions, hashes the node
+// doesn't know and hashes of transactions that are not mined yet
+type EthTxnsResult struct {
+	Txns     []EthTxnWithReceipt
+	NotFound []string
+	Pending  []PendingTxn
+}
+
+// PendingTxn is a transaction the node knows but hasn't mined, From is empty
+// when it is mined but the node hasn't indexed its receipt yet
+type PendingTxn struct {
+	Hash  string
+	From  string
+	Nonce uint64
+	// SeenAtBlock is the latest block when the transaction was seen pending
+	SeenAtBlock uint64
+}
+
+// Replacement is the mined transaction that took the nonce of a replaced one
+type Replacement struct {
+	Hash      string
+	Cancelled bool
+}
+
+// HistoryScope selects whose lookups ForUser returns
+type HistoryScope string
+
+const (
+	// UserHistory are the lookups of the user, in and outside of organizations
+	UserHistory HistoryScope = "user"
+	// OrgHistory are the lookups every member made for the organization the
+	// user belongs to
+	OrgHistory HistoryScope = "org"
+)
+
+// HistoryQuery selects whose lookups ForUser returns and narrows them down
+type HistoryQuery struct {
+	Scope HistoryScope
+	HistoryFilter
+	// Fetch is charged for the blocks that date the transactions
+	Fetch FetchOptions
+}
+
+// HistoryFilter narrows the lookups down, zero values don't filter
+type HistoryFilter struct {
+	ChainId      uint64
+	CollectionId uint64
+	Tag          string
+	MinedPeriod
+}
+
+// MinedPeriod bounds when transactions were mined, in seconds since the epoch
+type MinedPeriod struct {
+	From uint64
+	To   uint64
+}
+
+// FetchOptions tune how a lookup by hash is resolved
+type FetchOptions struct {
+	// BypassNegativeCache asks the node again for hashes recently not found
+	BypassNegativeCache bool
+	// Budget is charged for the node calls, nil doesn't limit them
+	Budget NodeCallBudget
+}
+
+// NodeCallBudget refuses the node calls the quota of the caller doesn't cover
+type NodeCallBudget interface {
+	Charge(calls int) error
 }
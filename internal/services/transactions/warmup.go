@@ -0,0 +1,182 @@
+package transactions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"ethereum_fetcher/internal/config"
+	types "ethereum_fetcher/internal/services/transactions/types"
+	"ethereum_fetcher/pkg/logging"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	NoWarmUp       = "none"
+	PopularWarmUp  = "popular"
+	SnapshotWarmUp = "snapshot"
+
+	warmUpBatchSize = 500
+)
+
+// Snapshotter is implemented by in-process caches that can be saved to disk
+type Snapshotter interface {
+	Snapshot(limit int) []types.DbTxn
+}
+
+type cacheSnapshot struct {
+	SavedAt      time.Time     `json:"savedAt"`
+	Transactions []types.DbTxn `json:"transactions"`
+}
+
+// CacheWarmer fills the caches of the chains on startup and saves them on
+// shutdown. It reports ready once the warm-up has finished or run out of time.
+type CacheWarmer struct {
+	db     *gorm.DB
+	caches map[uint64]TxnCache
+	cfg    config.CacheConfig
+	ready  atomic.Bool
+	logger *logrus.Logger
+}
+
+func NewCacheWarmer(db *gorm.DB, chains []Chain, cfg config.CacheConfig) (*CacheWarmer, error) {
+	switch cfg.WarmUp {
+	case NoWarmUp, "", PopularWarmUp:
+	case SnapshotWarmUp:
+		if cfg.SnapshotPath == "" {
+			return nil, fmt.Errorf("a snapshot path is required to warm the cache from a snapshot")
+		}
+	default:
+		return nil, fmt.Errorf("unknown cache warm-up source '%s'", cfg.WarmUp)
+	}
+
+	caches := make(map[uint64]TxnCache, len(chains))
+	for _, chain := range chains {
+		caches[chain.Id] = chain.Cache
+	}
+	return &CacheWarmer{db: db, caches: caches, cfg: cfg, logger: logging.New()}, nil
+}
+
+func (w *CacheWarmer) Ready() bool {
+	return w.ready.Load()
+}
+
+// WarmUp loads transactions into the cache for at most the warm-up timeout.
+// Failures are only logged, a cold cache just makes the first requests slower.
+func (w *CacheWarmer) WarmUp(ctx context.Context) {
+	defer w.ready.Store(true)
+
+	if w.cfg.WarmUpTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.cfg.WarmUpTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	var txns []types.DbTxn
+	var err error
+
+	switch w.cfg.WarmUp {
+	case PopularWarmUp:
+		txns, err = NewTxnRepo(w.db.WithContext(ctx)).GetMostRequested(w.cfg.WarmUpLimit)
+	case SnapshotWarmUp:
+		txns, err = readSnapshot(w.cfg.SnapshotPath)
+	default:
+		return
+	}
+	if err != nil {
+		w.logger.Warnf("failed to load transactions to warm up the cache:  %v", err)
+		return
+	}
+
+	loaded := w.fill(ctx, txns)
+	w.logger.Infof("Warmed up the cache with %d of %d transactions from %s in %s", loaded, len(txns), w.cfg.WarmUp, time.Since(start))
+}
+
+// fill caches the transactions in batches until done or out of time.
+// Transactions of chains that aren't configured, or from snapshots saved
+// before chains were, are left out.
+func (w *CacheWarmer) fill(ctx context.Context, txns []types.DbTxn) int {
+	loaded := 0
+	for start := 0; start < len(txns); start += warmUpBatchSize {
+		if ctx.Err() != nil {
+			return loaded
+		}
+
+		byChain := make(map[uint64][]types.DbTxn)
+		for _, txn := range txns[start:min(start+warmUpBatchSize, len(txns))] {
+			if _, ok := w.caches[txn.ChainId]; ok {
+				byChain[txn.ChainId] = append(byChain[txn.ChainId], txn)
+			}
+		}
+		for chainId, batch := range byChain {
+			w.caches[chainId].SetMany(batch)
+			loaded += len(batch)
+		}
+	}
+	return loaded
+}
+
+// SaveSnapshot writes the cached transactions of every chain to the snapshot
+// file. Caches that can't be listed, like the shared redis cache, are not
+// saved.
+func (w *CacheWarmer) SaveSnapshot() error {
+	if w.cfg.SnapshotPath == "" {
+		return nil
+	}
+
+	txns := make([]types.DbTxn, 0)
+	for _, cache := range w.caches {
+		snapshotter, ok := cache.(Snapshotter)
+		if !ok {
+			w.logger.Infof("The '%s' cache doesn't support snapshots", w.cfg.Backend)
+			return nil
+		}
+		txns = append(txns, snapshotter.Snapshot(w.cfg.WarmUpLimit)...)
+	}
+	if err := writeSnapshot(w.cfg.SnapshotPath, txns); err != nil {
+		return fmt.Errorf("failed to write cache snapshot:  %w", err)
+	}
+
+	w.logger.Infof("Saved %d cached transactions to '%s'", len(txns), w.cfg.SnapshotPath)
+	return nil
+}
+
+func readSnapshot(path string) ([]types.DbTxn, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// nothing was saved yet, e.g. on the first deploy
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot cacheSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid cache snapshot '%s':  %w", path, err)
+	}
+	return snapshot.Transactions, nil
+}
+
+// writeSnapshot replaces the snapshot atomically so a crash while saving
+// never leaves a truncated file behind
+func writeSnapshot(path string, txns []types.DbTxn) error {
+	data, err := json.Marshal(cacheSnapshot{SavedAt: time.Now(), Transactions: txns})
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,34 @@
+package usage
+
+import (
+	"time"
+
+	"ethereum_fetcher/internal/services/errors"
+)
+
+type UsageError struct {
+	errors.ServiceError
+}
+
+func usageError(msg string) UsageError {
+	return UsageError{errors.NewServiceError(msg)}
+}
+
+// LimitError is returned when a rate limit or a quota is used up
+type LimitError struct {
+	UsageError
+	RetryAfter time.Duration
+}
+
+func rateLimited(retryAfter time.Duration) LimitError {
+	return LimitError{usageError("rate limit exceeded"), retryAfter}
+}
+
+func quotaExceeded(retryAfter time.Duration) LimitError {
+	return LimitError{usageError("daily node call quota exceeded"), retryAfter}
+}
+
+var (
+	UsageStoreFailed   = usageError("failed to record usage")
+	InvalidUsagePeriod = usageError("'from' and 'to' must be days like 2006-01-02 and 'from' can't be after 'to'")
+)
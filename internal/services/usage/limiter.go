@@ -0,0 +1,65 @@
+package usage
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// idleBucketTTL drops buckets unused for this long, by then they have
+	// refilled for any sensible rate and behave like new ones
+	idleBucketTTL = 10 * time.Minute
+	sweepInterval = time.Minute
+)
+
+// rateLimiter keeps a token bucket per principal in memory, each replica
+// enforces the limits on its own
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*bucket)}
+}
+
+// take removes a token from the bucket of the key, returning how long to wait
+// for one when it is empty
+func (l *rateLimiter) take(key string, rate float64, burst int, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(float64(burst), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) > idleBucketTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
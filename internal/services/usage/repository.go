@@ -0,0 +1,73 @@
+package usage
+
+import (
+	"time"
+
+	"ethereum_fetcher/db/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LedgerRepo struct {
+	db *gorm.DB
+}
+
+// Add increments the counters of the user and key on the day
+func (r *LedgerRepo) Add(userId uint64, apiKeyId uint64, day string, requests uint64, nodeCalls uint64) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "api_key_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":   gorm.Expr("usage_records.requests + ?", requests),
+			"node_calls": gorm.Expr("usage_records.node_calls + ?", nodeCalls),
+			"updated_at": gorm.Expr("excluded.updated_at"),
+		}),
+	}).Create(&models.UsageRecord{
+		UserId:    userId,
+		ApiKeyId:  apiKeyId,
+		Day:       day,
+		Requests:  requests,
+		NodeCalls: nodeCalls,
+		UpdatedAt: time.Now(),
+	}).Error
+}
+
+// UserNodeCalls sums the node calls of the user across their keys on the day
+func (r *LedgerRepo) UserNodeCalls(userId uint64, day string) (uint64, error) {
+	var total uint64
+	err := r.db.Model(&models.UsageRecord{}).
+		Select("COALESCE(SUM(node_calls), 0)").
+		Where("user_id = ? AND day = ?", userId, day).
+		Scan(&total).Error
+	return total, err
+}
+
+func (r *LedgerRepo) KeyNodeCalls(userId uint64, apiKeyId uint64, day string) (uint64, error) {
+	var total uint64
+	err := r.db.Model(&models.UsageRecord{}).
+		Select("COALESCE(SUM(node_calls), 0)").
+		Where("user_id = ? AND api_key_id = ? AND day = ?", userId, apiKeyId, day).
+		Scan(&total).Error
+	return total, err
+}
+
+// ForUser returns the records of the user between the days, inclusive
+func (r *LedgerRepo) ForUser(userId uint64, from string, to string) ([]models.UsageRecord, error) {
+	var records []models.UsageRecord
+	err := r.db.Where("user_id = ? AND day BETWEEN ? AND ?", userId, from, to).
+		Order("day, api_key_id").
+		Find(&records).Error
+	return records, err
+}
+
+// Totals sums the records of every user between the days, inclusive
+func (r *LedgerRepo) Totals(from string, to string) ([]models.UsageRecord, error) {
+	var records []models.UsageRecord
+	err := r.db.Model(&models.UsageRecord{}).
+		Select("user_id, SUM(requests) AS requests, SUM(node_calls) AS node_calls").
+		Where("day BETWEEN ? AND ?", from, to).
+		Group("user_id").
+		Order("user_id").
+		Find(&records).Error
+	return records, err
+}
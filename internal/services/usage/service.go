@@ -0,0 +1,224 @@
+package usage
+
+import (
+	"fmt"
+	"time"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/models"
+	types "ethereum_fetcher/internal/services/transactions/types"
+	"ethereum_fetcher/pkg/logging"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const dayFormat = "2006-01-02"
+
+// Config sets the limits, a zero rate or quota disables it
+type Config struct {
+	// UserRPS and UserBurst limit the requests of each user signed in with a
+	// password, ApiKeyRPS and ApiKeyBurst those of each API key and
+	// AnonymousRPS and AnonymousBurst those of each anonymous client IP
+	UserRPS        float64
+	UserBurst      int
+	ApiKeyRPS      float64
+	ApiKeyBurst    int
+	AnonymousRPS   float64
+	AnonymousBurst int
+	// UserNodeCallsPerDay caps the node calls of a user across their sessions
+	// and keys, ApiKeyNodeCallsPerDay those of each key on its own and
+	// AnonymousNodeCallsPerDay those of all anonymous callers together
+	UserNodeCallsPerDay      uint64
+	ApiKeyNodeCallsPerDay    uint64
+	AnonymousNodeCallsPerDay uint64
+}
+
+// Principal is who a request is accounted to
+type Principal struct {
+	UserId   uint64
+	ApiKeyId uint64
+	// IP is only used for anonymous requests
+	IP string
+}
+
+// UsageService enforces rate limits and node call quotas and keeps a daily
+// ledger of the usage of each user and API key
+type UsageService interface {
+	// Allow takes a request from the rate limit of the principal and records
+	// it, failing with a LimitError when the limit is used up
+	Allow(p Principal) error
+	// Budget charges node calls of the principal against its daily quotas,
+	// failing with a LimitError when they don't cover them
+	Budget(p Principal) types.NodeCallBudget
+	// ForUser reports the usage of the user per day and API key between the
+	// days, inclusive
+	ForUser(userId uint64, from string, to string) (api.UsageResponse, error)
+	// Report sums up the usage of every user between the days, inclusive
+	Report(from string, to string) (api.UsageResponse, error)
+}
+
+type impl struct {
+	repo    *LedgerRepo
+	limiter *rateLimiter
+	cfg     Config
+	logger  *logrus.Logger
+}
+
+func NewUsageService(db *gorm.DB, cfg Config) UsageService {
+	return &impl{repo: &LedgerRepo{db: db}, limiter: newRateLimiter(), cfg: cfg, logger: logging.New()}
+}
+
+func (s *impl) Allow(p Principal) error {
+	now := time.Now()
+
+	rate, burst, key := s.rateLimit(p)
+	if rate > 0 {
+		if wait := s.limiter.take(key, rate, max(burst, 1), now); wait > 0 {
+			return rateLimited(wait)
+		}
+	}
+
+	// a lost ledger entry shouldn't fail the request
+	if err := s.repo.Add(p.UserId, p.ApiKeyId, day(now), 1, 0); err != nil {
+		s.logger.Errorf("failed to record request of user '%d' : %s", p.UserId, err)
+	}
+	return nil
+}
+
+func (s *impl) rateLimit(p Principal) (float64, int, string) {
+	switch {
+	case p.ApiKeyId != 0:
+		return s.cfg.ApiKeyRPS, s.cfg.ApiKeyBurst, fmt.Sprintf("key:%d", p.ApiKeyId)
+	case p.UserId != 0:
+		return s.cfg.UserRPS, s.cfg.UserBurst, fmt.Sprintf("user:%d", p.UserId)
+	default:
+		return s.cfg.AnonymousRPS, s.cfg.AnonymousBurst, "ip:" + p.IP
+	}
+}
+
+func (s *impl) Budget(p Principal) types.NodeCallBudget {
+	return &budget{service: s, principal: p}
+}
+
+// budget checks the quotas before charging, concurrent requests of the same
+// principal may overshoot them a little
+type budget struct {
+	service   *impl
+	principal Principal
+}
+
+func (b *budget) Charge(calls int) error {
+	if calls <= 0 {
+		return nil
+	}
+	s, p := b.service, b.principal
+	now := time.Now()
+	today := day(now)
+
+	userQuota := s.cfg.UserNodeCallsPerDay
+	if p.UserId == 0 {
+		userQuota = s.cfg.AnonymousNodeCallsPerDay
+	}
+	if userQuota > 0 {
+		used, err := s.repo.UserNodeCalls(p.UserId, today)
+		if err != nil {
+			s.logger.Errorf("failed to read node calls of user '%d' : %s", p.UserId, err)
+			return UsageStoreFailed
+		}
+		if used+uint64(calls) > userQuota {
+			return quotaExceeded(untilTomorrow(now))
+		}
+	}
+
+	if p.ApiKeyId != 0 && s.cfg.ApiKeyNodeCallsPerDay > 0 {
+		used, err := s.repo.KeyNodeCalls(p.UserId, p.ApiKeyId, today)
+		if err != nil {
+			s.logger.Errorf("failed to read node calls of api key '%d' : %s", p.ApiKeyId, err)
+			return UsageStoreFailed
+		}
+		if used+uint64(calls) > s.cfg.ApiKeyNodeCallsPerDay {
+			return quotaExceeded(untilTomorrow(now))
+		}
+	}
+
+	if err := s.repo.Add(p.UserId, p.ApiKeyId, today, 0, uint64(calls)); err != nil {
+		s.logger.Errorf("failed to record node calls of user '%d' : %s", p.UserId, err)
+		return UsageStoreFailed
+	}
+	return nil
+}
+
+func (s *impl) ForUser(userId uint64, from string, to string) (api.UsageResponse, error) {
+	from, to, err := s.period(from, to)
+	if err != nil {
+		return api.UsageResponse{}, err
+	}
+
+	records, err := s.repo.ForUser(userId, from, to)
+	if err != nil {
+		s.logger.Errorf("failed to read usage of user '%d' : %s", userId, err)
+		return api.UsageResponse{}, UsageStoreFailed
+	}
+	return toUsageResponse(from, to, records), nil
+}
+
+func (s *impl) Report(from string, to string) (api.UsageResponse, error) {
+	from, to, err := s.period(from, to)
+	if err != nil {
+		return api.UsageResponse{}, err
+	}
+
+	records, err := s.repo.Totals(from, to)
+	if err != nil {
+		s.logger.Errorf("failed to read usage report : %s", err)
+		return api.UsageResponse{}, UsageStoreFailed
+	}
+	return toUsageResponse(from, to, records), nil
+}
+
+// period validates the days of a report, defaulting to the current month
+func (s *impl) period(from string, to string) (string, string, error) {
+	now := time.Now().UTC()
+	if from == "" {
+		from = now.Format("2006-01") + "-01"
+	}
+	if to == "" {
+		to = day(now)
+	}
+
+	fromDay, err := time.Parse(dayFormat, from)
+	if err != nil {
+		return "", "", InvalidUsagePeriod
+	}
+	toDay, err := time.Parse(dayFormat, to)
+	if err != nil || toDay.Before(fromDay) {
+		return "", "", InvalidUsagePeriod
+	}
+	return from, to, nil
+}
+
+func toUsageResponse(from string, to string, records []models.UsageRecord) api.UsageResponse {
+	usage := make([]api.Usage, 0, len(records))
+	for _, record := range records {
+		usage = append(usage, api.Usage{
+			UserId:    record.UserId,
+			ApiKeyId:  record.ApiKeyId,
+			Day:       record.Day,
+			Requests:  record.Requests,
+			NodeCalls: record.NodeCalls,
+		})
+	}
+	return api.UsageResponse{From: from, To: to, Usage: usage}
+}
+
+// day is the UTC day of the ledger the time falls on
+func day(t time.Time) string {
+	return t.UTC().Format(dayFormat)
+}
+
+func untilTomorrow(now time.Time) time.Duration {
+	utc := now.UTC()
+	tomorrow := time.Date(utc.Year(), utc.Month(), utc.Day()+1, 0, 0, 0, 0, time.UTC)
+	return tomorrow.Sub(utc)
+}
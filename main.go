@@ -1,10 +1,16 @@
 package main
 
-import "ethereum_fetcher/cmd/server"
+import (
+	"os"
+
+	"ethereum_fetcher/cmd/migrate"
+	"ethereum_fetcher/cmd/server"
+)
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate.Run(os.Args[2:])
+		return
+	}
 	server.Run()
 }
-
-
-
@@ -0,0 +1,60 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrTooShort          = errors.New("password is too short")
+	ErrTooLong           = errors.New("password is too long")
+	ErrTooFewCharClasses = errors.New("password must mix lower case, upper case, digits or symbols")
+	ErrContainsUsername  = errors.New("password must not contain the username")
+)
+
+// Policy describes what a password needs to look like to be accepted
+type Policy struct {
+	MinLength int
+	// MaxLength can't exceed 72 bytes, bcrypt ignores anything past that
+	MaxLength int
+	// MinCharClasses is how many of lower case, upper case, digits and
+	// symbols a password has to contain
+	MinCharClasses int
+}
+
+var DefaultPolicy = Policy{MinLength: 10, MaxLength: 72, MinCharClasses: 3}
+
+// Validate checks the password of the given user against the policy
+func (p Policy) Validate(password string, username string) error {
+	if len(password) < p.MinLength {
+		return ErrTooShort
+	}
+	if len(password) > p.MaxLength {
+		return ErrTooLong
+	}
+	if charClasses(password) < p.MinCharClasses {
+		return ErrTooFewCharClasses
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return ErrContainsUsername
+	}
+	return nil
+}
+
+func charClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
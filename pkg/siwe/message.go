@@ -0,0 +1,212 @@
+// Package siwe parses and verifies Sign-In with Ethereum (EIP-4361) messages
+package siwe
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const headerSuffix = " wants you to sign in with your Ethereum account:"
+
+var (
+	ErrMalformed        = errors.New("malformed sign-in with ethereum message")
+	ErrInvalidAddress   = errors.New("address must be an EIP-55 checksummed ethereum address")
+	ErrInvalidVersion   = errors.New("version must be 1")
+	ErrInvalidNonce     = errors.New("nonce must be at least 8 letters or digits")
+	ErrInvalidSignature = errors.New("signature doesn't match the address")
+)
+
+var noncePattern = regexp.MustCompile(`^[a-zA-Z0-9]{8,}$`)
+
+// Message is a parsed EIP-4361 message
+type Message struct {
+	// Scheme is only set when the message names one, e.g. https
+	Scheme    string
+	Domain    string
+	Address   common.Address
+	Statement string
+	URI       string
+	Version   string
+	ChainId   uint64
+	Nonce     string
+	IssuedAt  time.Time
+	// ExpirationTime and NotBefore are zero when the message doesn't set them
+	ExpirationTime time.Time
+	NotBefore      time.Time
+	RequestId      string
+	Resources      []string
+
+	raw string
+}
+
+// Parse reads a message in the EIP-4361 format
+func Parse(raw string) (*Message, error) {
+	lines := strings.Split(strings.TrimSuffix(raw, "\n"), "\n")
+	msg := &Message{raw: raw}
+
+	if len(lines) < 4 || !strings.HasSuffix(lines[0], headerSuffix) {
+		return nil, ErrMalformed
+	}
+	msg.Domain = strings.TrimSuffix(lines[0], headerSuffix)
+	if scheme, domain, ok := strings.Cut(msg.Domain, "://"); ok {
+		msg.Scheme, msg.Domain = scheme, domain
+	}
+	if msg.Domain == "" {
+		return nil, ErrMalformed
+	}
+
+	if !common.IsHexAddress(lines[1]) || common.HexToAddress(lines[1]).Hex() != lines[1] {
+		return nil, ErrInvalidAddress
+	}
+	msg.Address = common.HexToAddress(lines[1])
+
+	// the statement is optional, without it the address is followed by two
+	// empty lines
+	if lines[2] != "" {
+		return nil, ErrMalformed
+	}
+	rest := lines[3:]
+	if rest[0] != "" {
+		if len(rest) < 2 || rest[1] != "" {
+			return nil, ErrMalformed
+		}
+		msg.Statement = rest[0]
+		rest = rest[2:]
+	} else {
+		rest = rest[1:]
+	}
+
+	fields := &fieldReader{lines: rest}
+	var err error
+
+	msg.URI = fields.required("URI")
+	msg.Version = fields.required("Version")
+	chainId := fields.required("Chain ID")
+	msg.Nonce = fields.required("Nonce")
+	issuedAt := fields.required("Issued At")
+	expirationTime := fields.optional("Expiration Time")
+	notBefore := fields.optional("Not Before")
+	msg.RequestId = fields.optional("Request ID")
+	if fields.err != nil {
+		return nil, fields.err
+	}
+	if msg.Resources, err = fields.resources(); err != nil {
+		return nil, err
+	}
+
+	if msg.Version != "1" {
+		return nil, ErrInvalidVersion
+	}
+	if msg.ChainId, err = strconv.ParseUint(chainId, 10, 64); err != nil {
+		return nil, fmt.Errorf("%w: invalid chain id", ErrMalformed)
+	}
+	if !noncePattern.MatchString(msg.Nonce) {
+		return nil, ErrInvalidNonce
+	}
+	if msg.IssuedAt, err = parseTime("issued at", issuedAt); err != nil {
+		return nil, err
+	}
+	if msg.ExpirationTime, err = parseTime("expiration time", expirationTime); err != nil {
+		return nil, err
+	}
+	if msg.NotBefore, err = parseTime("not before", notBefore); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// ValidAt reports whether the message may be used at the given time
+func (m *Message) ValidAt(now time.Time) bool {
+	if !m.ExpirationTime.IsZero() && !now.Before(m.ExpirationTime) {
+		return false
+	}
+	if !m.NotBefore.IsZero() && now.Before(m.NotBefore) {
+		return false
+	}
+	return true
+}
+
+// VerifySignature checks that the hex encoded personal_sign signature of the
+// message was made by the key of its address. Signatures of smart contract
+// wallets (EIP-1271) need a node to verify and aren't supported.
+func (m *Message) VerifySignature(signature string) error {
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return ErrInvalidSignature
+	}
+
+	// wallets produce a recovery id of 27 or 28, go-ethereum expects 0 or 1
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(m.raw)), sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != m.Address {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// fieldReader reads the "Name: value" lines in the order EIP-4361 requires
+type fieldReader struct {
+	lines []string
+	err   error
+}
+
+func (r *fieldReader) optional(name string) string {
+	if len(r.lines) == 0 || !strings.HasPrefix(r.lines[0], name+": ") {
+		return ""
+	}
+	value := strings.TrimPrefix(r.lines[0], name+": ")
+	r.lines = r.lines[1:]
+	return value
+}
+
+func (r *fieldReader) required(name string) string {
+	value := r.optional(name)
+	if value == "" && r.err == nil {
+		r.err = fmt.Errorf("%w: missing %s", ErrMalformed, strings.ToLower(name))
+	}
+	return value
+}
+
+// resources reads the optional resource list, which has to end the message
+func (r *fieldReader) resources() ([]string, error) {
+	if len(r.lines) == 0 {
+		return nil, nil
+	}
+	if r.lines[0] != "Resources:" {
+		return nil, fmt.Errorf("%w: unexpected line '%s'", ErrMalformed, r.lines[0])
+	}
+
+	resources := make([]string, 0, len(r.lines)-1)
+	for _, line := range r.lines[1:] {
+		resource, ok := strings.CutPrefix(line, "- ")
+		if !ok {
+			return nil, fmt.Errorf("%w: unexpected line '%s'", ErrMalformed, line)
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+func parseTime(name string, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid %s", ErrMalformed, name)
+	}
+	return t, nil
+}
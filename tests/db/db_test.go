@@ -114,6 +114,7 @@ func TestTransactionOperations(t *testing.T) {
 	t.Run("UpdateTransaction", func(t *testing.T) {
 		// Create a transaction
 		transaction := models.Transaction{
+			ChainId:           1,
 			TransactionHash:   "0x9876543210fedcba",
 			TransactionStatus: 0,
 			CreatedAt:         time.Now(),
@@ -153,9 +154,10 @@ func TestUserTransactionOperations(t *testing.T) {
 
 		// Create a user transaction
 		userTransaction := models.UserTransaction{
-			UserId:          user.ID,
-			TransactionHash: transaction.TransactionHash,
-			RequestedAt:     time.Now(),
+			UserId:           user.ID,
+			TransactionHash:  transaction.TransactionHash,
+			FirstRequestedAt: time.Now(),
+			LastRequestedAt:  time.Now(),
 		}
 		result := db.Create(&userTransaction)
 		assert.NoError(t, result.Error)
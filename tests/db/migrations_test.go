@@ -0,0 +1,101 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ethereum_fetcher/db/migrations"
+	"ethereum_fetcher/db/models"
+)
+
+func setupMigrator(t *testing.T, name string) (*gorm.DB, *migrations.Migrator) {
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+
+	migrator, err := migrations.New(db)
+	require.NoError(t, err)
+
+	return db, migrator
+}
+
+func TestMigrations(t *testing.T) {
+	t.Run("UpAppliesAllMigrations", func(t *testing.T) {
+		db, migrator := setupMigrator(t, "migrations_up")
+
+		require.NoError(t, migrator.Up())
+		require.NoError(t, migrator.Verify())
+
+		version, err := migrator.Version()
+		require.NoError(t, err)
+		assert.Equal(t, migrator.Latest(), version)
+
+		for _, model := range []interface{}{&models.User{}, &models.Transaction{}, &models.UserTransaction{}} {
+			assert.True(t, db.Migrator().HasTable(model))
+		}
+		assert.True(t, db.Migrator().HasIndex(&models.UserTransaction{}, "idx_user_transactions_user_org_chain_hash"))
+	})
+
+	t.Run("DownAndToRollBack", func(t *testing.T) {
+		db, migrator := setupMigrator(t, "migrations_down")
+		require.NoError(t, migrator.Up())
+
+		require.NoError(t, migrator.Down(1))
+		version, err := migrator.Version()
+		require.NoError(t, err)
+		assert.Equal(t, migrator.Latest()-1, version)
+		assert.ErrorIs(t, migrator.Verify(), migrations.ErrPendingMigrations)
+
+		require.NoError(t, migrator.To(0))
+		assert.False(t, db.Migrator().HasTable(&models.User{}))
+
+		require.NoError(t, migrator.To(migrator.Latest()))
+		assert.True(t, db.Migrator().HasTable(&models.User{}))
+	})
+
+	t.Run("UpgradesLegacyUserTransactions", func(t *testing.T) {
+		db, migrator := setupMigrator(t, "migrations_legacy")
+		require.NoError(t, migrator.To(1))
+
+		requestedAt := time.Now().UTC().Truncate(time.Second)
+		require.NoError(t, db.Exec("INSERT INTO user_transactions (user_id, transaction_hash, requested_at) VALUES (?, ?, ?)",
+			1, "0xabc", requestedAt).Error)
+
+		require.NoError(t, migrator.Up())
+
+		var userTxn models.UserTransaction
+		require.NoError(t, db.First(&userTxn).Error)
+		assert.Equal(t, uint64(1), userTxn.RequestCount)
+		assert.True(t, requestedAt.Equal(userTxn.FirstRequestedAt))
+		columns, err := db.Migrator().ColumnTypes(&models.UserTransaction{})
+		require.NoError(t, err)
+		for _, column := range columns {
+			assert.NotEqual(t, "requested_at", column.Name())
+		}
+	})
+
+	t.Run("RefusesUnknownVersion", func(t *testing.T) {
+		db, migrator := setupMigrator(t, "migrations_unknown")
+		require.NoError(t, migrator.Up())
+
+		require.NoError(t, db.Create(&migrations.SchemaMigration{Version: 9999, Name: "future", AppliedAt: time.Now()}).Error)
+
+		assert.ErrorIs(t, migrator.Verify(), migrations.ErrUnknownVersion)
+		assert.ErrorIs(t, migrator.Up(), migrations.ErrUnknownVersion)
+	})
+
+	t.Run("StatusListsEveryMigration", func(t *testing.T) {
+		_, migrator := setupMigrator(t, "migrations_status")
+		require.NoError(t, migrator.To(1))
+
+		statuses, err := migrator.Status()
+		require.NoError(t, err)
+		require.Len(t, statuses, int(migrator.Latest()))
+		assert.NotNil(t, statuses[0].AppliedAt)
+		assert.Nil(t, statuses[len(statuses)-1].AppliedAt)
+	})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/migrations"
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/internal/handlers"
+	"ethereum_fetcher/internal/services/auth"
+	"ethereum_fetcher/pkg/passwords"
+)
+
+func setupRouter(t *testing.T) (*gin.Engine, api.AuthToken, string) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	migrator, err := migrations.New(db)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
+
+	hash, err := passwords.HashPassword("secret")
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&models.User{Username: "alice", PasswordHash: hash, Role: auth.RoleIngester}).Error)
+
+	authService, err := auth.NewAuthService(db, auth.TokenConfig{Secret: "test_secret_key"})
+	require.NoError(t, err)
+	apiKeys := auth.NewApiKeyService(db)
+
+	tokens, err := authService.Authenticate(api.AuthRequest{Username: "alice", Password: "secret"})
+	require.NoError(t, err)
+	userId, err := authService.GetUserId(*tokens.Token)
+	require.NoError(t, err)
+	key, err := apiKeys.Create(userId, api.CreateApiKeyRequest{Name: "ci"})
+	require.NoError(t, err)
+
+	whoami := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user": c.GetUint64(auth.UserClaim)})
+	}
+	r := gin.New()
+	r.GET("/optional", handlers.OptionalAuth(authService, apiKeys), whoami)
+	r.GET("/required", handlers.RequireAuth(authService, apiKeys), whoami)
+	r.GET("/scoped", handlers.RequireAuth(authService, apiKeys), handlers.RequireScope(auth.ScopeMaintenance), whoami)
+
+	return r, *tokens.Token, key.Key
+}
+
+func get(r *gin.Engine, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthMiddlewares(t *testing.T) {
+	r, token, key := setupRouter(t)
+
+	t.Run("Anonymous", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get(r, "/optional", nil).Code)
+
+		w := get(r, "/required", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Credentials", func(t *testing.T) {
+		for name, headers := range map[string]map[string]string{
+			"Bearer":       {handlers.AuthorizationHeader: "Bearer " + token},
+			"LowerCase":    {handlers.AuthorizationHeader: "bearer " + token},
+			"LegacyHeader": {handlers.AuthTokenHeader: token},
+			"ApiKey":       {auth.ApiKeyHeader: key},
+		} {
+			w := get(r, "/required", headers)
+			assert.Equal(t, http.StatusOK, w.Code, name)
+			assert.JSONEq(t, `{"user": 1}`, w.Body.String(), name)
+		}
+	})
+
+	t.Run("InvalidCredentials", func(t *testing.T) {
+		// credentials that are sent have to be valid even where they are optional
+		for name, headers := range map[string]map[string]string{
+			"BadToken":    {handlers.AuthorizationHeader: "Bearer not-a-jwt"},
+			"EmptyBearer": {handlers.AuthorizationHeader: "Bearer "},
+			"BasicScheme": {handlers.AuthorizationHeader: "Basic YWxpY2U6c2VjcmV0"},
+			"LegacyToken": {handlers.AuthTokenHeader: "not-a-jwt"},
+			"BadApiKey":   {auth.ApiKeyHeader: "lime_00000000_nope"},
+		} {
+			assert.Equal(t, http.StatusUnauthorized, get(r, "/optional", headers).Code, name)
+			assert.Equal(t, http.StatusUnauthorized, get(r, "/required", headers).Code, name)
+		}
+	})
+
+	t.Run("InsufficientScope", func(t *testing.T) {
+		w := get(r, "/scoped", map[string]string{handlers.AuthorizationHeader: "Bearer " + token})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"ethereum_fetcher/internal/handlers"
+	"ethereum_fetcher/internal/services/transactions"
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+// countingTxns counts the lookups, the other methods aren't called
+type countingTxns struct {
+	transactions.TxnService
+	lookups int
+}
+
+func (s *countingTxns) ChainId(string) (uint64, error) {
+	return 1, nil
+}
+
+func (s *countingTxns) ByHashes(uint64, []string, uint64, types.FetchOptions) ([]types.ApiTxn, error) {
+	s.lookups++
+	return nil, nil
+}
+
+func (s *countingTxns) All(uint64, types.MinedPeriod, types.FetchOptions) ([]types.ApiTxn, error) {
+	s.lookups++
+	return nil, nil
+}
+
+func (s *countingTxns) EmbedBlocks([]types.ApiTxn, types.FetchOptions) error {
+	return nil
+}
+
+func TestEmbed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	txService := &countingTxns{}
+	h := handlers.NewTxnHandler(txService, nil)
+	r := gin.New()
+	r.GET("/lime/eth", h.FetchTransactions)
+	r.GET("/lime/all", h.AllTransactions)
+
+	t.Run("RefusesAnInvalidEmbedBeforeTheLookup", func(t *testing.T) {
+		txService.lookups = 0
+		assert.Equal(t, http.StatusBadRequest, get(r, "/lime/eth?transactionHashes=0x01&embed=bogus", nil).Code)
+		assert.Equal(t, http.StatusBadRequest, get(r, "/lime/all?embed=bogus", nil).Code)
+		assert.Zero(t, txService.lookups)
+	})
+
+	t.Run("RefusesBlocksOfAllTransactionsWithoutAPeriod", func(t *testing.T) {
+		txService.lookups = 0
+		assert.Equal(t, http.StatusBadRequest, get(r, "/lime/all?embed=block", nil).Code)
+		assert.Zero(t, txService.lookups)
+
+		assert.Equal(t, http.StatusOK, get(r, "/lime/all?embed=block&from=2024-06-01", nil).Code)
+		assert.Equal(t, 1, txService.lookups)
+	})
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ethereum_fetcher/db/migrations"
+	"ethereum_fetcher/internal/handlers"
+	"ethereum_fetcher/internal/services/usage"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	migrator, err := migrations.New(db)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
+
+	usageService := usage.NewUsageService(db, usage.Config{AnonymousRPS: 0.5, AnonymousBurst: 1})
+	r := gin.New()
+	r.GET("/limited", handlers.RateLimit(usageService), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	assert.Equal(t, http.StatusNoContent, get(r, "/limited", nil).Code)
+
+	w := get(r, "/limited", nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+}
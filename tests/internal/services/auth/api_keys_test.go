@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/internal/services/auth"
+)
+
+func TestApiKeyService(t *testing.T) {
+	db := setupUsersDB(t)
+	apiKeys := auth.NewApiKeyService(db)
+
+	owner := models.User{Username: "owner", PasswordHash: "-", Role: auth.RoleIngester}
+	other := models.User{Username: "other", PasswordHash: "-", Role: auth.RoleIngester}
+	require.NoError(t, db.Create(&owner).Error)
+	require.NoError(t, db.Create(&other).Error)
+	userId, otherUserId := owner.ID, other.ID
+
+	t.Run("CreateAndAuthenticate", func(t *testing.T) {
+		created, err := apiKeys.Create(userId, api.CreateApiKeyRequest{Name: "batch job"})
+		require.NoError(t, err)
+		assert.Contains(t, created.Key, created.Prefix)
+		assert.Equal(t, auth.DefaultApiKeyScopes, created.Scopes)
+
+		identity, err := apiKeys.Authenticate(created.Key)
+		require.NoError(t, err)
+		assert.Equal(t, userId, identity.UserId)
+		assert.Equal(t, created.Id, identity.KeyId)
+		assert.Equal(t, auth.DefaultApiKeyScopes, identity.Scopes)
+
+		var stored models.ApiKey
+		require.NoError(t, db.First(&stored, created.Id).Error)
+		assert.NotEqual(t, created.Key, stored.KeyHash)
+		assert.NotNil(t, stored.LastUsedAt)
+	})
+
+	t.Run("Scopes", func(t *testing.T) {
+		created, err := apiKeys.Create(userId, api.CreateApiKeyRequest{Name: "reader", Scopes: []string{auth.ScopeReadTransactions}})
+		require.NoError(t, err)
+
+		identity, err := apiKeys.Authenticate(created.Key)
+		require.NoError(t, err)
+		assert.Equal(t, []string{auth.ScopeReadTransactions}, identity.Scopes)
+
+		_, err = apiKeys.Create(userId, api.CreateApiKeyRequest{Name: "admin", Scopes: []string{"everything"}})
+		assert.Equal(t, auth.InvalidScope, err)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		created, err := apiKeys.Create(userId, api.CreateApiKeyRequest{Name: "revoked"})
+		require.NoError(t, err)
+
+		assert.Equal(t, auth.ApiKeyNotFound, apiKeys.Revoke(otherUserId, created.Id))
+		require.NoError(t, apiKeys.Revoke(userId, created.Id))
+
+		_, err = apiKeys.Authenticate(created.Key)
+		assert.Equal(t, auth.InvalidApiKey, err)
+
+		keys, err := apiKeys.List(userId)
+		require.NoError(t, err)
+		for _, key := range keys {
+			assert.NotEqual(t, created.Id, key.Id)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour)
+		created, err := apiKeys.Create(userId, api.CreateApiKeyRequest{Name: "expired", ExpiresAt: &expiresAt})
+		require.NoError(t, err)
+		require.NoError(t, db.Model(&models.ApiKey{}).Where("id = ?", created.Id).
+			Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+		_, err = apiKeys.Authenticate(created.Key)
+		assert.Equal(t, auth.InvalidApiKey, err)
+	})
+
+	t.Run("RefusesPastExpiry", func(t *testing.T) {
+		for _, expiresAt := range []time.Time{time.Now().Add(-time.Minute), time.Now()} {
+			_, err := apiKeys.Create(userId, api.CreateApiKeyRequest{Name: "dead", ExpiresAt: &expiresAt})
+			assert.Equal(t, auth.InvalidApiKeyExpiry, err)
+		}
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		_, err := apiKeys.Authenticate("lime_00000000_unknown")
+		assert.Equal(t, auth.InvalidApiKey, err)
+	})
+}
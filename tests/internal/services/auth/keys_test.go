@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ethereum_fetcher/internal/services/auth"
+)
+
+func writeKey(t *testing.T, dir string, name string, key crypto.Signer, headers map[string]string) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Headers: headers, Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".pem"), data, 0o600))
+}
+
+func TestKeySet(t *testing.T) {
+	now := time.Now()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	// the RSA key was rotated out but still verifies during the overlap, the
+	// EC key signs and the Ed25519 key is published ahead of its activation
+	writeKey(t, dir, "2024-rsa", rsaKey, map[string]string{
+		auth.ActivatesAtHeader: now.Add(-48 * time.Hour).Format(time.RFC3339),
+		auth.ExpiresAtHeader:   now.Add(time.Hour).Format(time.RFC3339),
+	})
+	writeKey(t, dir, "2025-ec", ecKey, map[string]string{
+		auth.ActivatesAtHeader: now.Add(-time.Hour).Format(time.RFC3339),
+	})
+	writeKey(t, dir, "2026-ed", edKey, map[string]string{
+		auth.ActivatesAtHeader: now.Add(24 * time.Hour).Format(time.RFC3339),
+	})
+
+	keys, err := auth.LoadKeySet(dir)
+	require.NoError(t, err)
+	jwtManager := auth.NewJwtManager(auth.TokenConfig{Keys: keys})
+
+	t.Run("SignsWithActiveKey", func(t *testing.T) {
+		tokenString, err := jwtManager.GenerateJwt(1, nil)
+		require.NoError(t, err)
+
+		token, err := jwtManager.ParseJwt(tokenString)
+		require.NoError(t, err)
+		assert.Equal(t, "2025-ec", token.Header["kid"])
+		assert.Equal(t, "ES256", token.Method.Alg())
+	})
+
+	t.Run("VerifiesRotatedKeyDuringOverlap", func(t *testing.T) {
+		signed := signWith(t, jwt.SigningMethodRS256, "2024-rsa", rsaKey)
+		_, err := jwtManager.ParseJwt(signed)
+		assert.NoError(t, err)
+	})
+
+	t.Run("RejectsUnknownKid", func(t *testing.T) {
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		_, err = jwtManager.ParseJwt(signWith(t, jwt.SigningMethodES256, "unknown", otherKey))
+		assert.Error(t, err)
+	})
+
+	t.Run("RejectsAlgorithmOfOtherKey", func(t *testing.T) {
+		_, err := jwtManager.ParseJwt(signWith(t, jwt.SigningMethodRS256, "2025-ec", rsaKey))
+		assert.Error(t, err)
+	})
+
+	t.Run("Jwks", func(t *testing.T) {
+		jwks := jwtManager.Jwks()
+		require.Len(t, jwks.Keys, 3)
+
+		byKid := map[string]int{}
+		for i, key := range jwks.Keys {
+			byKid[key.Kid] = i
+			assert.Equal(t, "sig", key.Use)
+		}
+
+		rsaJwk := jwks.Keys[byKid["2024-rsa"]]
+		assert.Equal(t, "RSA", rsaJwk.Kty)
+		assert.Equal(t, "AQAB", rsaJwk.E)
+
+		ecJwk := jwks.Keys[byKid["2025-ec"]]
+		assert.Equal(t, "EC", ecJwk.Kty)
+		x, err := base64.RawURLEncoding.DecodeString(ecJwk.X)
+		require.NoError(t, err)
+		assert.Equal(t, ecKey.X.FillBytes(make([]byte, 32)), x)
+
+		edJwk := jwks.Keys[byKid["2026-ed"]]
+		assert.Equal(t, "OKP", edJwk.Kty)
+		assert.Equal(t, "EdDSA", edJwk.Alg)
+		pub, err := base64.RawURLEncoding.DecodeString(edJwk.X)
+		require.NoError(t, err)
+		assert.Equal(t, []byte(edKey.Public().(ed25519.PublicKey)), pub)
+	})
+
+	t.Run("ExpiredKeyIsDropped", func(t *testing.T) {
+		expired, err := auth.NewSigningKey("expired", rsaKey, now.Add(-48*time.Hour), now.Add(-time.Hour))
+		require.NoError(t, err)
+		current, err := auth.NewSigningKey("current", ecKey, now.Add(-time.Hour), time.Time{})
+		require.NoError(t, err)
+		keys, err := auth.NewKeySet(expired, current)
+		require.NoError(t, err)
+		jwtManager := auth.NewJwtManager(auth.TokenConfig{Keys: keys})
+
+		require.Len(t, jwtManager.Jwks().Keys, 1)
+		_, err = jwtManager.ParseJwt(signWith(t, jwt.SigningMethodRS256, "expired", rsaKey))
+		assert.Error(t, err)
+	})
+}
+
+func signWith(t *testing.T, method jwt.SigningMethod, kid string, key crypto.Signer) string {
+	now := time.Now()
+	token := jwt.NewWithClaims(method, jwt.RegisteredClaims{
+		Subject:   "1",
+		Issuer:    auth.DefaultIssuer,
+		ID:        "test",
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
@@ -19,7 +19,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
 	require.NoError(t, err)
 
-	err = db.AutoMigrate(&models.User{})
+	err = db.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.AuthFailure{})
 	require.NoError(t, err)
 
 	return db
@@ -40,7 +40,7 @@ func TestAuthService(t *testing.T) {
 	err := db.Create(&user).Error
 	require.NoError(t, err)
 
-	authService, err := auth.NewAuthService(db, secretKey)
+	authService, err := auth.NewAuthService(db, auth.TokenConfig{Secret: secretKey})
 	require.NoError(t, err)
 
 	t.Run("Authenticate", func(t *testing.T) {
@@ -54,14 +54,14 @@ func TestAuthService(t *testing.T) {
 
 	t.Run("GetUserId", func(t *testing.T) {
 		// Generate a token for the user
-		tokenString, err := authService.Authenticate(api.AuthRequest{
+		tokens, err := authService.Authenticate(api.AuthRequest{
 			Username: user.Username,
 			Password: "password",
 		})
 		require.NoError(t, err)
 
 		// Extract user ID from the token
-		userId, err := authService.GetUserId(*tokenString)
+		userId, err := authService.GetUserId(*tokens.Token)
 		assert.NoError(t, err)
 		assert.Equal(t, user.ID, userId)
 	})
@@ -73,4 +73,73 @@ func TestAuthService(t *testing.T) {
 		})
 		assert.Error(t, err)
 	})
+
+	t.Run("RefreshRotatesToken", func(t *testing.T) {
+		tokens, err := authService.Authenticate(api.AuthRequest{Username: user.Username, Password: "password"})
+		require.NoError(t, err)
+		require.NotEmpty(t, tokens.RefreshToken)
+
+		refreshed, err := authService.Refresh(tokens.RefreshToken)
+		require.NoError(t, err)
+		assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+
+		userId, err := authService.GetUserId(*refreshed.Token)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, userId)
+
+		// replaying the rotated token revokes the whole family
+		_, err = authService.Refresh(tokens.RefreshToken)
+		assert.Equal(t, auth.InvalidRefreshToken, err)
+		_, err = authService.Refresh(refreshed.RefreshToken)
+		assert.Equal(t, auth.InvalidRefreshToken, err)
+	})
+
+	t.Run("ScopesFollowTheRole", func(t *testing.T) {
+		tokens, err := authService.Authenticate(api.AuthRequest{Username: user.Username, Password: "password"})
+		require.NoError(t, err)
+
+		identity, err := authService.Identify(*tokens.Token)
+		require.NoError(t, err)
+		assert.Equal(t, auth.RoleScopes(auth.RoleIngester), identity.Scopes)
+
+		// a demoted user loses the scopes of their tokens right away
+		require.NoError(t, db.Model(&user).Update("role", auth.RoleReader).Error)
+		identity, err = authService.Identify(*tokens.Token)
+		require.NoError(t, err)
+		assert.Equal(t, auth.RoleScopes(auth.RoleReader), identity.Scopes)
+
+		// a promotion only widens the tokens issued after it
+		require.NoError(t, db.Model(&user).Update("role", auth.RoleAdmin).Error)
+		defer db.Model(&user).Update("role", auth.RoleIngester)
+		identity, err = authService.Identify(*tokens.Token)
+		require.NoError(t, err)
+		assert.Equal(t, auth.RoleScopes(auth.RoleIngester), identity.Scopes)
+
+		refreshed, err := authService.Refresh(tokens.RefreshToken)
+		require.NoError(t, err)
+		identity, err = authService.Identify(*refreshed.Token)
+		require.NoError(t, err)
+		assert.Equal(t, auth.RoleScopes(auth.RoleAdmin), identity.Scopes)
+	})
+
+	t.Run("RefreshTokensAreStoredHashed", func(t *testing.T) {
+		tokens, err := authService.Authenticate(api.AuthRequest{Username: user.Username, Password: "password"})
+		require.NoError(t, err)
+
+		var count int64
+		require.NoError(t, db.Model(&models.RefreshToken{}).Where("token_hash = ?", tokens.RefreshToken).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+
+	t.Run("Logout", func(t *testing.T) {
+		tokens, err := authService.Authenticate(api.AuthRequest{Username: user.Username, Password: "password"})
+		require.NoError(t, err)
+
+		require.NoError(t, authService.Logout(*tokens.Token, tokens.RefreshToken))
+
+		_, err = authService.GetUserId(*tokens.Token)
+		assert.Equal(t, auth.RevokedToken, err)
+		_, err = authService.Refresh(tokens.RefreshToken)
+		assert.Equal(t, auth.InvalidRefreshToken, err)
+	})
 }
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/internal/services/auth"
+	"ethereum_fetcher/pkg/passwords"
+)
+
+const siweDomain = "fetcher.example"
+
+// signIn builds an EIP-4361 message for the nonce and signs it with the key
+func signIn(t *testing.T, key *ecdsa.PrivateKey, domain string, nonce string, issuedAt time.Time) api.SiweRequest {
+	message := fmt.Sprintf("%s wants you to sign in with your Ethereum account:\n%s\n\nSign in to the fetcher\n\n"+
+		"URI: https://%s\nVersion: 1\nChain ID: 1\nNonce: %s\nIssued At: %s",
+		domain, crypto.PubkeyToAddress(key.PublicKey).Hex(), domain, nonce, issuedAt.UTC().Format(time.RFC3339))
+
+	sig, err := crypto.Sign(accounts.TextHash([]byte(message)), key)
+	require.NoError(t, err)
+	sig[crypto.RecoveryIDOffset] += 27
+
+	return api.SiweRequest{Message: message, Signature: hexutil.Encode(sig)}
+}
+
+func newNonce(t *testing.T, siwe auth.SiweService) string {
+	nonce, err := siwe.Nonce()
+	require.NoError(t, err)
+	return nonce.Nonce
+}
+
+func TestSiweService(t *testing.T) {
+	db := setupUsersDB(t)
+	tokens := auth.TokenConfig{Secret: "test_secret_key"}
+	authService, err := auth.NewAuthService(db, tokens)
+	require.NoError(t, err)
+	siwe, err := auth.NewSiweService(db, tokens, auth.SiweConfig{Domain: siweDomain, ChainId: 1, CreateAccounts: true})
+	require.NoError(t, err)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	t.Run("SignInCreatesAccount", func(t *testing.T) {
+		resp, err := siwe.SignIn(signIn(t, key, siweDomain, newNonce(t, siwe), time.Now()))
+		require.NoError(t, err)
+
+		userId, err := authService.GetUserId(*resp.Token)
+		require.NoError(t, err)
+
+		again, err := siwe.SignIn(signIn(t, key, siweDomain, newNonce(t, siwe), time.Now()))
+		require.NoError(t, err)
+		sameId, err := authService.GetUserId(*again.Token)
+		require.NoError(t, err)
+		assert.Equal(t, userId, sameId)
+
+		// the account has no password to sign in with
+		_, err = authService.Authenticate(api.AuthRequest{Username: address, Password: ""})
+		assert.Equal(t, auth.InvalidCredentials, err)
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		req := signIn(t, key, siweDomain, newNonce(t, siwe), time.Now())
+		_, err := siwe.SignIn(req)
+		require.NoError(t, err)
+
+		_, err = siwe.SignIn(req)
+		assert.Equal(t, auth.InvalidSiweNonce, err)
+
+		_, err = siwe.SignIn(signIn(t, key, siweDomain, "unknownnonce1234", time.Now()))
+		assert.Equal(t, auth.InvalidSiweNonce, err)
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		nonce := newNonce(t, siwe)
+
+		_, err := siwe.SignIn(signIn(t, key, "phishing.example", nonce, time.Now()))
+		assert.Equal(t, auth.SiweDomainMismatch, err)
+
+		_, err = siwe.SignIn(signIn(t, key, siweDomain, nonce, time.Now().Add(time.Hour)))
+		assert.Equal(t, auth.SiweMessageExpired, err)
+
+		forged := signIn(t, key, siweDomain, nonce, time.Now())
+		other, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		forged.Signature = signIn(t, other, siweDomain, nonce, time.Now()).Signature
+		_, err = siwe.SignIn(forged)
+		assert.Equal(t, auth.InvalidSiweSignature, err)
+
+		_, err = siwe.SignIn(api.SiweRequest{Message: "hello", Signature: "0x00"})
+		var messageErr auth.SiweMessageError
+		assert.ErrorAs(t, err, &messageErr)
+
+		// none of the above used up the nonce
+		_, err = siwe.SignIn(signIn(t, key, siweDomain, nonce, time.Now()))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Link", func(t *testing.T) {
+		users := auth.NewUserService(db, auth.UserConfig{RegistrationEnabled: true, Policy: passwords.DefaultPolicy})
+		user, err := users.Register(api.CreateUserRequest{Username: "frank", Password: strongPassword})
+		require.NoError(t, err)
+
+		_, err = siwe.Link(user.Id, signIn(t, key, siweDomain, newNonce(t, siwe), time.Now()))
+		assert.Equal(t, auth.AddressTaken, err)
+
+		linkedKey, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		linked, err := siwe.Link(user.Id, signIn(t, linkedKey, siweDomain, newNonce(t, siwe), time.Now()))
+		require.NoError(t, err)
+		require.NotNil(t, linked.Address)
+		assert.Equal(t, crypto.PubkeyToAddress(linkedKey.PublicKey).Hex(), *linked.Address)
+
+		resp, err := siwe.SignIn(signIn(t, linkedKey, siweDomain, newNonce(t, siwe), time.Now()))
+		require.NoError(t, err)
+		userId, err := authService.GetUserId(*resp.Token)
+		require.NoError(t, err)
+		assert.Equal(t, user.Id, userId)
+	})
+
+	t.Run("ConfirmsChangesWithASignature", func(t *testing.T) {
+		users := auth.NewUserService(db, auth.UserConfig{Policy: passwords.DefaultPolicy, Siwe: auth.SiweConfig{Domain: siweDomain}})
+		owner, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		resp, err := siwe.SignIn(signIn(t, owner, siweDomain, newNonce(t, siwe), time.Now()))
+		require.NoError(t, err)
+		userId, err := authService.GetUserId(*resp.Token)
+		require.NoError(t, err)
+
+		// the token alone doesn't confirm a change
+		err = users.ChangePassword(userId, api.ChangePasswordRequest{NewPassword: strongPassword})
+		assert.Equal(t, auth.SiweConfirmationRequired, err)
+		err = users.Delete(userId, api.DeleteUserRequest{})
+		assert.Equal(t, auth.SiweConfirmationRequired, err)
+
+		other := signIn(t, key, siweDomain, newNonce(t, siwe), time.Now())
+		err = users.ChangePassword(userId, api.ChangePasswordRequest{NewPassword: strongPassword, Siwe: &other})
+		assert.Equal(t, auth.InvalidSiweSignature, err)
+
+		signed := signIn(t, owner, siweDomain, newNonce(t, siwe), time.Now())
+		err = users.ChangePassword(userId, api.ChangePasswordRequest{NewPassword: strongPassword, Siwe: &signed})
+		require.NoError(t, err)
+		_, err = authService.Authenticate(api.AuthRequest{Username: crypto.PubkeyToAddress(owner.PublicKey).Hex(), Password: strongPassword})
+		assert.NoError(t, err)
+	})
+
+	t.Run("WithoutAccountCreation", func(t *testing.T) {
+		closed, err := auth.NewSiweService(db, tokens, auth.SiweConfig{Domain: siweDomain})
+		require.NoError(t, err)
+
+		stranger, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		_, err = closed.SignIn(signIn(t, stranger, siweDomain, newNonce(t, closed), time.Now()))
+		assert.Equal(t, auth.AddressNotLinked, err)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		disabled, err := auth.NewSiweService(db, tokens, auth.SiweConfig{})
+		require.NoError(t, err)
+
+		_, err = disabled.Nonce()
+		assert.Equal(t, auth.SiweDisabled, err)
+	})
+}
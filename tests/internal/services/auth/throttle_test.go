@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/internal/services/auth"
+	"ethereum_fetcher/pkg/passwords"
+)
+
+func addFailures(t *testing.T, db *gorm.DB, username string, ip string, count int, at time.Time) {
+	for i := 0; i < count; i++ {
+		require.NoError(t, db.Create(&models.AuthFailure{Username: username, IP: ip, Reason: "bad_password", CreatedAt: at}).Error)
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	db := setupUsersDB(t)
+	users := auth.NewUserService(db, auth.UserConfig{RegistrationEnabled: true, Policy: passwords.DefaultPolicy})
+	authService, err := auth.NewAuthService(db, auth.TokenConfig{
+		Secret:   "test_secret_key",
+		Throttle: auth.ThrottleConfig{MaxUserFailures: 2, MaxIpFailures: 3, Window: time.Hour, MaxDelay: time.Minute},
+	})
+	require.NoError(t, err)
+
+	for _, username := range []string{"ivan", "judy", "mallory"} {
+		_, err := users.Register(api.CreateUserRequest{Username: username, Password: strongPassword})
+		require.NoError(t, err)
+	}
+
+	t.Run("UniformErrors", func(t *testing.T) {
+		_, err := authService.Authenticate(api.AuthRequest{Username: "nobody", Password: strongPassword, ClientIP: "192.0.2.1"})
+		assert.Equal(t, auth.InvalidCredentials, err)
+		_, err = authService.Authenticate(api.AuthRequest{Username: "mallory", Password: "wrong", ClientIP: "192.0.2.1"})
+		assert.Equal(t, auth.InvalidCredentials, err)
+
+		var reasons []string
+		require.NoError(t, db.Model(&models.AuthFailure{}).Where("ip = ?", "192.0.2.1").Order("id").Pluck("reason", &reasons).Error)
+		assert.Equal(t, []string{"unknown_user", "bad_password"}, reasons)
+	})
+
+	t.Run("PerUsername", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := authService.Authenticate(api.AuthRequest{Username: "ivan", Password: "wrong", ClientIP: "192.0.2.10"})
+			assert.Equal(t, auth.InvalidCredentials, err)
+		}
+
+		// even the right password has to wait, from any IP
+		_, err := authService.Authenticate(api.AuthRequest{Username: "ivan", Password: strongPassword, ClientIP: "192.0.2.11"})
+		var throttled auth.ThrottledError
+		require.ErrorAs(t, err, &throttled)
+		assert.Greater(t, throttled.RetryAfter, time.Duration(0))
+		assert.LessOrEqual(t, throttled.RetryAfter, time.Second)
+
+		var count int64
+		require.NoError(t, db.Model(&models.AuthFailure{}).Where("username = ? AND reason = ?", "ivan", "throttled").Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("PerIp", func(t *testing.T) {
+		for _, username := range []string{"a", "b", "c"} {
+			_, err := authService.Authenticate(api.AuthRequest{Username: username, Password: "wrong", ClientIP: "192.0.2.20"})
+			assert.Equal(t, auth.InvalidCredentials, err)
+		}
+
+		_, err := authService.Authenticate(api.AuthRequest{Username: "judy", Password: strongPassword, ClientIP: "192.0.2.20"})
+		assert.ErrorAs(t, err, &auth.ThrottledError{})
+
+		_, err = authService.Authenticate(api.AuthRequest{Username: "judy", Password: strongPassword, ClientIP: "192.0.2.21"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Backoff", func(t *testing.T) {
+		// three failures three seconds ago wait two seconds, four wait four
+		addFailures(t, db, "walter", "", 3, time.Now().Add(-3*time.Second))
+		_, err := authService.Authenticate(api.AuthRequest{Username: "walter", Password: "wrong"})
+		assert.Equal(t, auth.InvalidCredentials, err)
+
+		addFailures(t, db, "trent", "", 4, time.Now().Add(-3*time.Second))
+		_, err = authService.Authenticate(api.AuthRequest{Username: "trent", Password: "wrong"})
+		assert.ErrorAs(t, err, &auth.ThrottledError{})
+
+		// failures outside the window are forgotten
+		addFailures(t, db, "peggy", "", 10, time.Now().Add(-2*time.Hour))
+		_, err = authService.Authenticate(api.AuthRequest{Username: "peggy", Password: "wrong"})
+		assert.Equal(t, auth.InvalidCredentials, err)
+	})
+
+	t.Run("ConcurrentGuesses", func(t *testing.T) {
+		// one connection still lets the attempts interleave between the
+		// password checks, sqlite just can't write from several at once
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		sqlDB.SetMaxOpenConns(1)
+
+		const guesses = 10
+		errs := make([]error, guesses)
+		var wg sync.WaitGroup
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = authService.Authenticate(api.AuthRequest{Username: "mallory", Password: "wrong", ClientIP: "192.0.2.30"})
+			}()
+		}
+		wg.Wait()
+
+		checked := 0
+		for _, err := range errs {
+			if err == auth.InvalidCredentials {
+				checked++
+			} else {
+				assert.ErrorAs(t, err, &auth.ThrottledError{})
+			}
+		}
+		// mallory failed once before
+		assert.Equal(t, 1, checked, "only the guesses allowed were checked")
+
+		var pending int64
+		require.NoError(t, db.Model(&models.AuthFailure{}).Where("reason = ?", "pending").Count(&pending).Error)
+		assert.Zero(t, pending)
+	})
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/migrations"
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/internal/services/auth"
+	"ethereum_fetcher/pkg/passwords"
+)
+
+const strongPassword = "Correct-Horse-9"
+
+func setupUsersDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	migrator, err := migrations.New(db)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
+	return db
+}
+
+func TestUserService(t *testing.T) {
+	db := setupUsersDB(t)
+	users := auth.NewUserService(db, auth.UserConfig{
+		RegistrationEnabled: true,
+		Policy:              passwords.DefaultPolicy,
+	})
+	authService, err := auth.NewAuthService(db, auth.TokenConfig{Secret: "test_secret_key"})
+	require.NoError(t, err)
+
+	admin, err := users.Register(api.CreateUserRequest{Username: "admin", Password: strongPassword})
+	require.NoError(t, err)
+	require.NoError(t, users.PromoteToAdmin([]string{"admin"}))
+
+	t.Run("Register", func(t *testing.T) {
+		user, err := users.Register(api.CreateUserRequest{Username: "erin", Password: strongPassword})
+		require.NoError(t, err)
+		assert.NotZero(t, user.Id)
+
+		_, err = authService.Authenticate(api.AuthRequest{Username: "erin", Password: strongPassword})
+		assert.NoError(t, err)
+	})
+
+	t.Run("RegisterTakenUsername", func(t *testing.T) {
+		_, err := users.Register(api.CreateUserRequest{Username: "admin", Password: strongPassword})
+		assert.Equal(t, auth.UsernameTaken, err)
+	})
+
+	t.Run("RegisterInvalidUsername", func(t *testing.T) {
+		_, err := users.Register(api.CreateUserRequest{Username: "no spaces", Password: strongPassword})
+		assert.Equal(t, auth.InvalidUsername, err)
+	})
+
+	t.Run("RegisterWeakPassword", func(t *testing.T) {
+		_, err := users.Register(api.CreateUserRequest{Username: "frank", Password: "frank"})
+		assert.ErrorAs(t, err, &auth.PasswordPolicyError{})
+	})
+
+	t.Run("ChangePassword", func(t *testing.T) {
+		user, err := users.Register(api.CreateUserRequest{Username: "grace", Password: strongPassword})
+		require.NoError(t, err)
+		session, err := authService.Authenticate(api.AuthRequest{Username: "grace", Password: strongPassword})
+		require.NoError(t, err)
+
+		err = users.ChangePassword(user.Id, api.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "Battery-Staple-7"})
+		assert.Equal(t, auth.InvalidPassword, err)
+
+		require.NoError(t, users.ChangePassword(user.Id, api.ChangePasswordRequest{CurrentPassword: strongPassword, NewPassword: "Battery-Staple-7"}))
+
+		_, err = authService.Authenticate(api.AuthRequest{Username: "grace", Password: strongPassword})
+		assert.Error(t, err)
+		_, err = authService.Authenticate(api.AuthRequest{Username: "grace", Password: "Battery-Staple-7"})
+		assert.NoError(t, err)
+
+		_, err = authService.Refresh(session.RefreshToken)
+		assert.Equal(t, auth.InvalidRefreshToken, err, "sessions from before the change are signed out")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		user, err := users.Register(api.CreateUserRequest{Username: "heidi", Password: strongPassword})
+		require.NoError(t, err)
+		require.NoError(t, db.Create(&models.UserTransaction{UserId: user.Id, TransactionHash: "0xaaa", RequestCount: 1}).Error)
+
+		assert.Equal(t, auth.InvalidPassword, users.Delete(user.Id, api.DeleteUserRequest{Password: "wrong"}))
+		require.NoError(t, users.Delete(user.Id, api.DeleteUserRequest{Password: strongPassword}))
+
+		var count int64
+		require.NoError(t, db.Model(&models.UserTransaction{}).Where("user_id = ?", user.Id).Count(&count).Error)
+		assert.Zero(t, count)
+
+		_, err = authService.Authenticate(api.AuthRequest{Username: "heidi", Password: strongPassword})
+		assert.Equal(t, auth.InvalidCredentials, err)
+	})
+
+	t.Run("Roles", func(t *testing.T) {
+		user, err := users.Register(api.CreateUserRequest{Username: "ivy", Password: strongPassword})
+		require.NoError(t, err)
+		assert.Equal(t, auth.RoleIngester, user.Role)
+
+		scopes, err := authService.UserScopes(user.Id)
+		require.NoError(t, err)
+		assert.NotContains(t, scopes, auth.ScopeReadAllTransactions)
+
+		require.NoError(t, users.SetRole(admin.Id, user.Id, auth.RoleReader))
+		scopes, err = authService.UserScopes(user.Id)
+		require.NoError(t, err)
+		assert.Equal(t, auth.RoleScopes(auth.RoleReader), scopes)
+
+		adminScopes, err := authService.UserScopes(admin.Id)
+		require.NoError(t, err)
+		assert.Contains(t, adminScopes, auth.ScopeManageUsers)
+
+		assert.Equal(t, auth.InvalidRole, users.SetRole(admin.Id, user.Id, "owner"))
+		assert.Equal(t, auth.CannotModifySelf, users.SetRole(admin.Id, admin.Id, auth.RoleReader))
+		assert.Equal(t, auth.UserNotFound, users.SetRole(admin.Id, 9999, auth.RoleReader))
+	})
+
+	t.Run("ApiKeyScopesAreLimitedByRole", func(t *testing.T) {
+		user, err := users.Register(api.CreateUserRequest{Username: "judy", Password: strongPassword})
+		require.NoError(t, err)
+		apiKeys := auth.NewApiKeyService(db)
+		created, err := apiKeys.Create(user.Id, api.CreateApiKeyRequest{Name: "all", Scopes: auth.AllScopes})
+		require.NoError(t, err)
+
+		identity, err := apiKeys.Authenticate(created.Key)
+		require.NoError(t, err)
+		assert.Equal(t, auth.RoleScopes(auth.RoleIngester), identity.Scopes)
+	})
+
+	t.Run("AdminDeletesUser", func(t *testing.T) {
+		user, err := users.Register(api.CreateUserRequest{Username: "mallory", Password: strongPassword})
+		require.NoError(t, err)
+
+		assert.Equal(t, auth.CannotModifySelf, users.DeleteUser(admin.Id, admin.Id))
+		require.NoError(t, users.DeleteUser(admin.Id, user.Id))
+		assert.Equal(t, auth.UserNotFound, users.DeleteUser(admin.Id, user.Id))
+
+		list, err := users.List()
+		require.NoError(t, err)
+		for _, listed := range list {
+			assert.NotEqual(t, "mallory", listed.Username)
+		}
+	})
+}
+
+func TestRegistrationDisabled(t *testing.T) {
+	users := auth.NewUserService(setupUsersDB(t), auth.UserConfig{Policy: passwords.DefaultPolicy})
+
+	_, err := users.Register(api.CreateUserRequest{Username: "ivan", Password: strongPassword})
+	assert.Equal(t, auth.RegistrationDisabled, err)
+}
@@ -0,0 +1,299 @@
+package collections
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/migrations"
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/internal/services/auth"
+	"ethereum_fetcher/internal/services/collections"
+	txns "ethereum_fetcher/internal/services/transactions"
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+const sepolia = 11155111
+
+// hashes of the transactions the tests look up
+const (
+	hashA = "0x0000000000000000000000000000000000000000000000000000000000000aaa"
+	hashB = "0x0000000000000000000000000000000000000000000000000000000000000bbb"
+	hashC = "0x0000000000000000000000000000000000000000000000000000000000000ccc"
+	hashD = "0x0000000000000000000000000000000000000000000000000000000000000ddd"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	migrator, err := migrations.New(db)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
+	return db
+}
+
+func hashesOf(transactions []api.Transaction) []string {
+	hashes := make([]string, 0, len(transactions))
+	for _, txn := range transactions {
+		hashes = append(hashes, txn.TransactionHash)
+	}
+	return hashes
+}
+
+func TestCollectionService(t *testing.T) {
+	db := setupTestDB(t)
+	service := collections.NewCollectionService(db)
+
+	owner := models.User{Username: "nina", PasswordHash: "-", Role: auth.RoleIngester}
+	other := models.User{Username: "oscar", PasswordHash: "-", Role: auth.RoleIngester}
+	require.NoError(t, db.Create(&owner).Error)
+	require.NoError(t, db.Create(&other).Error)
+
+	require.NoError(t, db.Create(&[]models.Transaction{
+		{ChainId: sepolia, TransactionHash: hashA}, {ChainId: sepolia, TransactionHash: hashB}, {ChainId: sepolia, TransactionHash: hashC},
+	}).Error)
+	txService, err := txns.NewTxnService(db, []txns.Chain{{Id: sepolia, Name: "sepolia", NodeURL: "https://sepolia.infura.io/v3/dummy", Cache: txns.NewTxnCache()}})
+	require.NoError(t, err)
+	_, err = txService.ByHashes(sepolia, []string{hashA, hashB, hashC}, owner.ID, types.FetchOptions{})
+	require.NoError(t, err)
+
+	var collectionId uint64
+	t.Run("Collections", func(t *testing.T) {
+		_, err := service.Create(owner.ID, api.CreateCollectionRequest{Name: ""})
+		assert.Equal(t, collections.InvalidCollectionName, err)
+
+		created, err := service.Create(owner.ID, api.CreateCollectionRequest{Name: "Exploits"})
+		require.NoError(t, err)
+		collectionId = created.Id
+		_, err = service.Create(owner.ID, api.CreateCollectionRequest{Name: "Exploits"})
+		assert.Equal(t, collections.CollectionNameTaken, err)
+
+		updated, err := service.AddTransactions(owner.ID, collectionId, sepolia, []string{hashA, hashB})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), updated.TransactionCount)
+
+		// adding twice is fine, hashes the user didn't look up aren't
+		_, err = service.AddTransactions(owner.ID, collectionId, sepolia, []string{hashA})
+		assert.NoError(t, err)
+		_, err = service.AddTransactions(owner.ID, collectionId, sepolia, []string{hashD})
+		assert.Equal(t, collections.NotInHistory, err)
+
+		// collections of other users can't be touched
+		_, err = service.AddTransactions(other.ID, collectionId, sepolia, []string{hashA})
+		assert.Equal(t, collections.CollectionNotFound, err)
+		assert.Equal(t, collections.CollectionNotFound, service.Delete(other.ID, collectionId))
+
+		require.NoError(t, service.RemoveTransaction(owner.ID, collectionId, sepolia, hashB))
+		assert.Equal(t, collections.NotInCollection, service.RemoveTransaction(owner.ID, collectionId, sepolia, hashB))
+
+		listed, err := service.List(owner.ID)
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, int64(1), listed[0].TransactionCount)
+	})
+
+	t.Run("TagsAndNotes", func(t *testing.T) {
+		assert.Equal(t, collections.InvalidTag, service.SetTags(owner.ID, sepolia, hashA, []string{"has space"}))
+		assert.Equal(t, collections.NotInHistory, service.SetTags(other.ID, sepolia, hashA, []string{"mev"}))
+
+		require.NoError(t, service.SetTags(owner.ID, sepolia, hashA, []string{"MEV", "flash-loan", "mev"}))
+		require.NoError(t, service.SetTags(owner.ID, sepolia, hashC, []string{"mev"}))
+		require.NoError(t, service.SetNote(owner.ID, sepolia, hashA, "  sandwiched  "))
+
+		history, err := txService.ForUser(owner.ID, types.HistoryQuery{Scope: types.UserHistory})
+		require.NoError(t, err)
+		require.NoError(t, service.Annotate(owner.ID, history))
+		for _, txn := range history {
+			switch txn.TransactionHash {
+			case hashA:
+				assert.Equal(t, []string{"flash-loan", "mev"}, txn.Tags)
+				require.NotNil(t, txn.Note)
+				assert.Equal(t, "sandwiched", *txn.Note)
+			case hashB:
+				assert.Empty(t, txn.Tags)
+				assert.Nil(t, txn.Note)
+			}
+		}
+
+		require.NoError(t, service.SetNote(owner.ID, sepolia, hashA, ""))
+		var notes int64
+		require.NoError(t, db.Model(&models.TransactionNote{}).Count(&notes).Error)
+		assert.Zero(t, notes)
+	})
+
+	t.Run("FilterHistory", func(t *testing.T) {
+		tagged, err := txService.ForUser(owner.ID, types.HistoryQuery{Scope: types.UserHistory, HistoryFilter: types.HistoryFilter{Tag: "mev"}})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{hashA, hashC}, hashesOf(tagged))
+
+		inCollection, err := txService.ForUser(owner.ID, types.HistoryQuery{
+			Scope:         types.UserHistory,
+			HistoryFilter: types.HistoryFilter{CollectionId: collectionId, Tag: "mev"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{hashA}, hashesOf(inCollection))
+
+		// the collection of another user matches nothing
+		_, err = txService.ByHashes(sepolia, []string{hashA}, other.ID, types.FetchOptions{})
+		require.NoError(t, err)
+		foreign, err := txService.ForUser(other.ID, types.HistoryQuery{Scope: types.UserHistory, HistoryFilter: types.HistoryFilter{CollectionId: collectionId}})
+		require.NoError(t, err)
+		assert.Empty(t, foreign)
+	})
+
+	t.Run("DeletedWithTheAccount", func(t *testing.T) {
+		users := auth.NewUserService(db, auth.UserConfig{})
+		require.NoError(t, users.DeleteUser(other.ID, owner.ID))
+
+		for _, model := range []interface{}{&models.Collection{}, &models.CollectionTransaction{}, &models.TransactionTag{}} {
+			var count int64
+			require.NoError(t, db.Model(model).Count(&count).Error)
+			assert.Zero(t, count)
+		}
+	})
+}
+
+func TestOrgCollections(t *testing.T) {
+	db := setupTestDB(t)
+	service := collections.NewCollectionService(db)
+
+	org := models.Organization{Name: "Lime Labs"}
+	require.NoError(t, db.Create(&org).Error)
+	admin := models.User{Username: "pat", PasswordHash: "-", Role: auth.RoleIngester, OrgId: &org.ID, OrgRole: "admin"}
+	member := models.User{Username: "quinn", PasswordHash: "-", Role: auth.RoleIngester, OrgId: &org.ID, OrgRole: "member"}
+	outsider := models.User{Username: "rob", PasswordHash: "-", Role: auth.RoleIngester}
+	require.NoError(t, db.Create(&[]*models.User{&admin, &member, &outsider}).Error)
+
+	require.NoError(t, db.Create(&[]models.Transaction{{ChainId: sepolia, TransactionHash: hashA}, {ChainId: sepolia, TransactionHash: hashB}}).Error)
+	txService, err := txns.NewTxnService(db, []txns.Chain{{Id: sepolia, Name: "sepolia", NodeURL: "https://sepolia.infura.io/v3/dummy", Cache: txns.NewTxnCache()}})
+	require.NoError(t, err)
+	_, err = txService.ByHashes(sepolia, []string{hashA}, admin.ID, types.FetchOptions{})
+	require.NoError(t, err)
+	_, err = txService.ByHashes(sepolia, []string{hashB}, member.ID, types.FetchOptions{})
+	require.NoError(t, err)
+
+	_, err = service.Create(outsider.ID, api.CreateCollectionRequest{Name: "Exploits", Org: true})
+	assert.Equal(t, collections.NotInOrganization, err)
+
+	shared, err := service.Create(admin.ID, api.CreateCollectionRequest{Name: "Exploits", Org: true})
+	require.NoError(t, err)
+	assert.Equal(t, &org.ID, shared.OrgId)
+	_, err = service.AddTransactions(admin.ID, shared.Id, sepolia, []string{hashA})
+	require.NoError(t, err)
+
+	// names are unique per owner
+	_, err = service.Create(member.ID, api.CreateCollectionRequest{Name: "Exploits", Org: true})
+	assert.Equal(t, collections.CollectionNameTaken, err)
+	own, err := service.Create(member.ID, api.CreateCollectionRequest{Name: "Exploits"})
+	require.NoError(t, err)
+
+	t.Run("MembersReadIt", func(t *testing.T) {
+		listed, err := service.List(member.ID)
+		require.NoError(t, err)
+		assert.Len(t, listed, 2)
+
+		entries, err := service.Entries(member.ID, shared.Id)
+		require.NoError(t, err)
+		assert.Equal(t, []collections.Entry{{ChainId: sepolia, TransactionHash: hashA}}, entries)
+
+		_, err = service.AddTransactions(member.ID, shared.Id, sepolia, []string{hashB})
+		require.NoError(t, err)
+		inCollection, err := txService.ForUser(member.ID, types.HistoryQuery{
+			Scope:         types.OrgHistory,
+			HistoryFilter: types.HistoryFilter{CollectionId: shared.Id},
+		})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{hashA, hashB}, hashesOf(inCollection))
+
+		// the personal collections of members stay their own
+		_, err = service.Entries(admin.ID, own.Id)
+		assert.Equal(t, collections.CollectionNotFound, err)
+	})
+
+	t.Run("OutsidersDont", func(t *testing.T) {
+		listed, err := service.List(outsider.ID)
+		require.NoError(t, err)
+		assert.Empty(t, listed)
+		_, err = service.Entries(outsider.ID, shared.Id)
+		assert.Equal(t, collections.CollectionNotFound, err)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		memberCollection, err := service.Create(member.ID, api.CreateCollectionRequest{Name: "Phishing", Org: true})
+		require.NoError(t, err)
+
+		assert.Equal(t, collections.NotCollectionOwner, service.Delete(member.ID, shared.Id))
+		require.NoError(t, service.Delete(admin.ID, memberCollection.Id))
+
+		// collections of the organization outlive the accounts of their creators
+		users := auth.NewUserService(db, auth.UserConfig{})
+		require.NoError(t, users.DeleteUser(outsider.ID, admin.ID))
+		listed, err := service.List(member.ID)
+		require.NoError(t, err)
+		assert.Len(t, listed, 2)
+	})
+}
+
+func TestChains(t *testing.T) {
+	const mainnet = 1
+	db := setupTestDB(t)
+	service := collections.NewCollectionService(db)
+
+	user := models.User{Username: "uma", PasswordHash: "-", Role: auth.RoleIngester}
+	require.NoError(t, db.Create(&user).Error)
+
+	// the same hash is known on both chains
+	require.NoError(t, db.Create(&[]models.Transaction{{ChainId: sepolia, TransactionHash: hashA}, {ChainId: mainnet, TransactionHash: hashA}}).Error)
+	txService, err := txns.NewTxnService(db, []txns.Chain{
+		{Id: sepolia, Name: "sepolia", NodeURL: "https://sepolia.infura.io/v3/dummy", Cache: txns.NewTxnCache()},
+		{Id: mainnet, Name: "mainnet", NodeURL: "https://mainnet.infura.io/v3/dummy", Cache: txns.NewTxnCache()},
+	})
+	require.NoError(t, err)
+	_, err = txService.ByHashes(sepolia, []string{hashA}, user.ID, types.FetchOptions{})
+	require.NoError(t, err)
+
+	// only the chain it was looked up on counts as history
+	assert.Equal(t, collections.NotInHistory, service.SetTags(user.ID, mainnet, hashA, []string{"l1"}))
+	_, err = txService.ByHashes(mainnet, []string{hashA}, user.ID, types.FetchOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, service.SetTags(user.ID, sepolia, hashA, []string{"l2"}))
+	require.NoError(t, service.SetTags(user.ID, mainnet, hashA, []string{"l1"}))
+	require.NoError(t, service.SetNote(user.ID, mainnet, hashA, "on mainnet"))
+
+	history, err := txService.ForUser(user.ID, types.HistoryQuery{Scope: types.UserHistory})
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	require.NoError(t, service.Annotate(user.ID, history))
+	for _, txn := range history {
+		if txn.ChainId == mainnet {
+			assert.Equal(t, []string{"l1"}, txn.Tags)
+			require.NotNil(t, txn.Note)
+		} else {
+			assert.Equal(t, []string{"l2"}, txn.Tags)
+			assert.Nil(t, txn.Note)
+		}
+	}
+
+	tagged, err := txService.ForUser(user.ID, types.HistoryQuery{Scope: types.UserHistory, HistoryFilter: types.HistoryFilter{Tag: "l2"}})
+	require.NoError(t, err)
+	require.Len(t, tagged, 1)
+	assert.Equal(t, uint64(sepolia), tagged[0].ChainId)
+
+	collection, err := service.Create(user.ID, api.CreateCollectionRequest{Name: "Bridged"})
+	require.NoError(t, err)
+	_, err = service.AddTransactions(user.ID, collection.Id, sepolia, []string{hashA})
+	require.NoError(t, err)
+	updated, err := service.AddTransactions(user.ID, collection.Id, mainnet, []string{hashA})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.TransactionCount)
+
+	require.NoError(t, service.RemoveTransaction(user.ID, collection.Id, mainnet, hashA))
+	entries, err := service.Entries(user.ID, collection.Id)
+	require.NoError(t, err)
+	assert.Equal(t, []collections.Entry{{ChainId: sepolia, TransactionHash: hashA}}, entries)
+}
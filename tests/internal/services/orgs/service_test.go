@@ -0,0 +1,215 @@
+package orgs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/migrations"
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/internal/services/auth"
+	"ethereum_fetcher/internal/services/orgs"
+	txns "ethereum_fetcher/internal/services/transactions"
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+const sepolia = 11155111
+
+// hashes of the transactions the tests look up
+const (
+	hashA = "0x0000000000000000000000000000000000000000000000000000000000000aaa"
+	hashB = "0x0000000000000000000000000000000000000000000000000000000000000bbb"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	migrator, err := migrations.New(db)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
+	return db
+}
+
+func createUsers(t *testing.T, db *gorm.DB, usernames ...string) []uint64 {
+	ids := make([]uint64, 0, len(usernames))
+	for _, username := range usernames {
+		user := models.User{Username: username, PasswordHash: "-", Role: auth.RoleIngester}
+		require.NoError(t, db.Create(&user).Error)
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
+
+// join invites the user to the organization of the admin and accepts the
+// invite on their behalf
+func join(t *testing.T, service orgs.OrgService, adminId uint64, userId uint64, username string) {
+	invite, err := service.Invite(adminId, api.InviteOrgMemberRequest{Username: username})
+	require.NoError(t, err)
+	_, err = service.AcceptInvite(userId, invite.Id)
+	require.NoError(t, err)
+}
+
+func TestOrgService(t *testing.T) {
+	db := setupTestDB(t)
+	service := orgs.NewOrgService(db)
+	ids := createUsers(t, db, "grace", "heidi", "ivan", "judy")
+	grace, heidi, ivan, judy := ids[0], ids[1], ids[2], ids[3]
+
+	t.Run("Create", func(t *testing.T) {
+		_, err := service.Create(grace, api.CreateOrgRequest{Name: "  "})
+		assert.Equal(t, orgs.InvalidOrgName, err)
+
+		org, err := service.Create(grace, api.CreateOrgRequest{Name: "Lime Labs"})
+		require.NoError(t, err)
+		assert.Equal(t, "Lime Labs", org.Name)
+		assert.Equal(t, []api.OrgMember{{UserId: grace, Username: "grace", Role: orgs.RoleAdmin}}, org.Members)
+
+		_, err = service.Create(grace, api.CreateOrgRequest{Name: "Other"})
+		assert.Equal(t, orgs.AlreadyInOrg, err)
+		_, err = service.Create(judy, api.CreateOrgRequest{Name: "Lime Labs"})
+		assert.Equal(t, orgs.OrgNameTaken, err)
+	})
+
+	t.Run("Members", func(t *testing.T) {
+		invite, err := service.Invite(grace, api.InviteOrgMemberRequest{Username: "heidi"})
+		require.NoError(t, err)
+		assert.Equal(t, orgs.RoleMember, invite.Role)
+		assert.Equal(t, "Lime Labs", invite.OrgName)
+		assert.Equal(t, "grace", invite.InvitedBy)
+
+		// invited users aren't members until they accept
+		org, err := service.Get(grace)
+		require.NoError(t, err)
+		assert.Len(t, org.Members, 1)
+		assert.Len(t, org.Invites, 1)
+		_, err = service.Get(heidi)
+		assert.Equal(t, orgs.OrgNotFound, err)
+
+		invites, err := service.Invites(heidi)
+		require.NoError(t, err)
+		require.Len(t, invites, 1)
+		_, err = service.AcceptInvite(ivan, invites[0].Id)
+		assert.Equal(t, orgs.InviteNotFound, err)
+		org, err = service.AcceptInvite(heidi, invites[0].Id)
+		require.NoError(t, err)
+		assert.Len(t, org.Members, 2)
+		assert.Empty(t, org.Invites)
+
+		join(t, service, grace, ivan, "ivan")
+
+		_, err = service.Invite(grace, api.InviteOrgMemberRequest{Username: "nobody"})
+		assert.Equal(t, orgs.UserNotFound, err)
+		_, err = service.Invite(grace, api.InviteOrgMemberRequest{Username: "heidi"})
+		assert.Equal(t, orgs.AlreadyInOrg, err)
+		_, err = service.Invite(heidi, api.InviteOrgMemberRequest{Username: "judy"})
+		assert.Equal(t, orgs.NotAnOrgAdmin, err)
+		_, err = service.Get(judy)
+		assert.Equal(t, orgs.OrgNotFound, err)
+
+		org, err = service.Get(heidi)
+		require.NoError(t, err)
+		assert.Len(t, org.Members, 3)
+	})
+
+	t.Run("Invites", func(t *testing.T) {
+		invite, err := service.Invite(grace, api.InviteOrgMemberRequest{Username: "judy", Role: orgs.RoleAdmin})
+		require.NoError(t, err)
+		_, err = service.Invite(grace, api.InviteOrgMemberRequest{Username: "judy"})
+		assert.Equal(t, orgs.AlreadyInvited, err)
+
+		// only the invited user and admins of the organization see the invite
+		assert.Equal(t, orgs.InviteNotFound, service.RemoveInvite(ivan, invite.Id))
+
+		// admins withdraw invites
+		require.NoError(t, service.RemoveInvite(grace, invite.Id))
+		_, err = service.AcceptInvite(judy, invite.Id)
+		assert.Equal(t, orgs.InviteNotFound, err)
+
+		// invited users decline them
+		invite, err = service.Invite(grace, api.InviteOrgMemberRequest{Username: "judy"})
+		require.NoError(t, err)
+		require.NoError(t, service.RemoveInvite(judy, invite.Id))
+		invites, err := service.Invites(judy)
+		require.NoError(t, err)
+		assert.Empty(t, invites)
+		_, err = service.Get(judy)
+		assert.Equal(t, orgs.OrgNotFound, err)
+	})
+
+	t.Run("LastAdmin", func(t *testing.T) {
+		assert.Equal(t, orgs.LastOrgAdmin, service.RemoveMember(grace, grace))
+		assert.Equal(t, orgs.LastOrgAdmin, service.SetMemberRole(grace, grace, orgs.RoleMember))
+		assert.Equal(t, orgs.InvalidOrgRole, service.SetMemberRole(grace, heidi, "owner"))
+
+		require.NoError(t, service.SetMemberRole(grace, heidi, orgs.RoleAdmin))
+		require.NoError(t, service.RemoveMember(grace, grace))
+		_, err := service.Get(grace)
+		assert.Equal(t, orgs.OrgNotFound, err)
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		assert.Equal(t, orgs.MemberNotFound, service.RemoveMember(heidi, judy))
+		assert.Equal(t, orgs.NotAnOrgAdmin, service.SetMemberRole(ivan, ivan, orgs.RoleAdmin))
+
+		// members can leave on their own
+		require.NoError(t, service.RemoveMember(ivan, ivan))
+		org, err := service.Get(heidi)
+		require.NoError(t, err)
+		assert.Equal(t, []api.OrgMember{{UserId: heidi, Username: "heidi", Role: orgs.RoleAdmin}}, org.Members)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		join(t, service, heidi, judy, "judy")
+		assert.Equal(t, orgs.NotAnOrgAdmin, service.Delete(judy))
+
+		require.NoError(t, service.Delete(heidi))
+		_, err := service.Get(judy)
+		assert.Equal(t, orgs.OrgNotFound, err)
+		_, err = service.Create(judy, api.CreateOrgRequest{Name: "Lime Labs"})
+		assert.NoError(t, err)
+	})
+}
+
+func TestSharedHistory(t *testing.T) {
+	db := setupTestDB(t)
+	service := orgs.NewOrgService(db)
+	ids := createUsers(t, db, "kate", "leo", "mia")
+	kate, leo, mia := ids[0], ids[1], ids[2]
+
+	_, err := service.Create(kate, api.CreateOrgRequest{Name: "Lime Labs"})
+	require.NoError(t, err)
+	join(t, service, kate, leo, "leo")
+
+	require.NoError(t, db.Create(&[]models.Transaction{{ChainId: sepolia, TransactionHash: hashA}, {ChainId: sepolia, TransactionHash: hashB}}).Error)
+	txService, err := txns.NewTxnService(db, []txns.Chain{{Id: sepolia, Name: "sepolia", NodeURL: "https://sepolia.infura.io/v3/dummy", Cache: txns.NewTxnCache()}})
+	require.NoError(t, err)
+
+	_, err = txService.ByHashes(sepolia, []string{hashA}, kate, types.FetchOptions{})
+	require.NoError(t, err)
+	_, err = txService.ByHashes(sepolia, []string{hashB}, leo, types.FetchOptions{})
+	require.NoError(t, err)
+
+	own, err := txService.ForUser(kate, types.HistoryQuery{Scope: types.UserHistory})
+	require.NoError(t, err)
+	assert.Len(t, own, 1)
+
+	shared, err := txService.ForUser(kate, types.HistoryQuery{Scope: types.OrgHistory})
+	require.NoError(t, err)
+	assert.Len(t, shared, 2)
+
+	_, err = txService.ForUser(mia, types.HistoryQuery{Scope: types.OrgHistory})
+	assert.Equal(t, types.NotInOrganization, err)
+
+	t.Run("DeletingTheLastAdminHandsOver", func(t *testing.T) {
+		users := auth.NewUserService(db, auth.UserConfig{})
+		require.NoError(t, users.DeleteUser(mia, kate))
+
+		org, err := service.Get(leo)
+		require.NoError(t, err)
+		assert.Equal(t, []api.OrgMember{{UserId: leo, Username: "leo", Role: orgs.RoleAdmin}}, org.Members)
+	})
+}
@@ -0,0 +1,206 @@
+package shares
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/migrations"
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/internal/services/auth"
+	"ethereum_fetcher/internal/services/collections"
+	"ethereum_fetcher/internal/services/shares"
+	txns "ethereum_fetcher/internal/services/transactions"
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+const sepolia = 11155111
+
+// hashes of the transactions the tests look up
+const (
+	hashA = "0x0000000000000000000000000000000000000000000000000000000000000aaa"
+	hashB = "0x0000000000000000000000000000000000000000000000000000000000000bbb"
+	hashC = "0x0000000000000000000000000000000000000000000000000000000000000ccc"
+	hashD = "0x0000000000000000000000000000000000000000000000000000000000000ddd"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	migrator, err := migrations.New(db)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
+	return db
+}
+
+func hashesOf(transactions []api.Transaction) []string {
+	hashes := make([]string, 0, len(transactions))
+	for _, txn := range transactions {
+		hashes = append(hashes, txn.TransactionHash)
+	}
+	return hashes
+}
+
+func TestShareService(t *testing.T) {
+	db := setupTestDB(t)
+
+	owner := models.User{Username: "pia", PasswordHash: "-", Role: auth.RoleIngester}
+	require.NoError(t, db.Create(&owner).Error)
+	require.NoError(t, db.Create(&[]models.Transaction{
+		{ChainId: sepolia, TransactionHash: hashA}, {ChainId: sepolia, TransactionHash: hashB}, {ChainId: sepolia, TransactionHash: hashC},
+	}).Error)
+
+	txService, err := txns.NewTxnService(db, []txns.Chain{{Id: sepolia, Name: "sepolia", NodeURL: "https://sepolia.infura.io/v3/dummy", Cache: txns.NewTxnCache()}})
+	require.NoError(t, err)
+	_, err = txService.ByHashes(sepolia, []string{hashA, hashB, hashC}, owner.ID, types.FetchOptions{})
+	require.NoError(t, err)
+
+	collectionService := collections.NewCollectionService(db)
+	service := shares.NewShareService(db, collectionService, txService, shares.ShareConfig{Secret: "share-secret", MaxTTL: 24 * time.Hour})
+
+	t.Run("Hashes", func(t *testing.T) {
+		_, err := service.Create(owner.ID, sepolia, api.CreateShareRequest{})
+		assert.Equal(t, shares.InvalidShare, err)
+		_, err = service.Create(owner.ID, sepolia, api.CreateShareRequest{TransactionHashes: []string{hashD}})
+		assert.Equal(t, collections.NotInHistory, err)
+		tooLate := time.Now().Add(48 * time.Hour)
+		_, err = service.Create(owner.ID, sepolia, api.CreateShareRequest{TransactionHashes: []string{hashA}, ExpiresAt: &tooLate})
+		assert.Equal(t, shares.InvalidExpiry, err)
+
+		share, err := service.Create(owner.ID, sepolia, api.CreateShareRequest{TransactionHashes: []string{hashC, hashA}})
+		require.NoError(t, err)
+
+		shared, err := service.Resolve(share.Token)
+		require.NoError(t, err)
+		assert.Equal(t, []string{hashC, hashA}, hashesOf(shared))
+
+		// a token signed with another secret or edited isn't accepted
+		other := shares.NewShareService(db, collectionService, txService, shares.ShareConfig{Secret: "other-secret"})
+		_, err = other.Resolve(share.Token)
+		assert.Equal(t, shares.InvalidShareToken, err)
+		_, err = service.Resolve(strings.ToUpper(share.Token))
+		assert.Equal(t, shares.InvalidShareToken, err)
+
+		require.NoError(t, service.Revoke(owner.ID, share.Id))
+		_, err = service.Resolve(share.Token)
+		assert.Equal(t, shares.InvalidShareToken, err)
+		assert.Equal(t, shares.ShareNotFound, service.Revoke(owner.ID+1, share.Id))
+	})
+
+	t.Run("Collection", func(t *testing.T) {
+		collection, err := collectionService.Create(owner.ID, api.CreateCollectionRequest{Name: "Shared"})
+		require.NoError(t, err)
+		_, err = collectionService.AddTransactions(owner.ID, collection.Id, sepolia, []string{hashB})
+		require.NoError(t, err)
+
+		_, err = service.Create(owner.ID+1, sepolia, api.CreateShareRequest{CollectionId: &collection.Id})
+		assert.Equal(t, collections.CollectionNotFound, err)
+		share, err := service.Create(owner.ID, sepolia, api.CreateShareRequest{CollectionId: &collection.Id})
+		require.NoError(t, err)
+
+		// the link follows the collection as it changes
+		_, err = collectionService.AddTransactions(owner.ID, collection.Id, sepolia, []string{hashA})
+		require.NoError(t, err)
+		shared, err := service.Resolve(share.Token)
+		require.NoError(t, err)
+		assert.Equal(t, []string{hashB, hashA}, hashesOf(shared))
+
+		listed, err := service.List(owner.ID)
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, share.Token, listed[0].Token)
+
+		require.NoError(t, collectionService.Delete(owner.ID, collection.Id))
+		_, err = service.Resolve(share.Token)
+		assert.Equal(t, shares.InvalidShareToken, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expiresAt := time.Now().Add(1500 * time.Millisecond)
+		share, err := service.Create(owner.ID, sepolia, api.CreateShareRequest{TransactionHashes: []string{hashA}, ExpiresAt: &expiresAt})
+		require.NoError(t, err)
+
+		time.Sleep(1600 * time.Millisecond)
+		_, err = service.Resolve(share.Token)
+		assert.Equal(t, shares.ShareExpired, err)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		disabled := shares.NewShareService(db, collectionService, txService, shares.ShareConfig{})
+		_, err := disabled.Create(owner.ID, sepolia, api.CreateShareRequest{TransactionHashes: []string{hashA}})
+		assert.Equal(t, shares.SharingDisabled, err)
+	})
+}
+
+func TestOrgCollectionShare(t *testing.T) {
+	db := setupTestDB(t)
+
+	org := models.Organization{Name: "Lime Labs"}
+	require.NoError(t, db.Create(&org).Error)
+	admin := models.User{Username: "sam", PasswordHash: "-", Role: auth.RoleIngester, OrgId: &org.ID, OrgRole: "admin"}
+	member := models.User{Username: "tess", PasswordHash: "-", Role: auth.RoleIngester, OrgId: &org.ID, OrgRole: "member"}
+	require.NoError(t, db.Create(&[]*models.User{&admin, &member}).Error)
+	require.NoError(t, db.Create(&models.Transaction{ChainId: sepolia, TransactionHash: hashA}).Error)
+
+	txService, err := txns.NewTxnService(db, []txns.Chain{{Id: sepolia, Name: "sepolia", NodeURL: "https://sepolia.infura.io/v3/dummy", Cache: txns.NewTxnCache()}})
+	require.NoError(t, err)
+	_, err = txService.ByHashes(sepolia, []string{hashA}, admin.ID, types.FetchOptions{})
+	require.NoError(t, err)
+
+	collectionService := collections.NewCollectionService(db)
+	service := shares.NewShareService(db, collectionService, txService, shares.ShareConfig{Secret: "share-secret"})
+
+	collection, err := collectionService.Create(admin.ID, api.CreateCollectionRequest{Name: "Exploits", Org: true})
+	require.NoError(t, err)
+	_, err = collectionService.AddTransactions(admin.ID, collection.Id, sepolia, []string{hashA})
+	require.NoError(t, err)
+
+	// any member shares the collections of the organization
+	share, err := service.Create(member.ID, sepolia, api.CreateShareRequest{CollectionId: &collection.Id})
+	require.NoError(t, err)
+	shared, err := service.Resolve(share.Token)
+	require.NoError(t, err)
+	assert.Equal(t, []string{hashA}, hashesOf(shared))
+
+	// and their links stop working when they leave
+	require.NoError(t, db.Model(&member).Updates(map[string]interface{}{"org_id": nil, "org_role": ""}).Error)
+	_, err = service.Resolve(share.Token)
+	assert.Equal(t, shares.InvalidShareToken, err)
+}
+
+func TestShareChain(t *testing.T) {
+	const mainnet = 1
+	db := setupTestDB(t)
+
+	owner := models.User{Username: "vera", PasswordHash: "-", Role: auth.RoleIngester}
+	require.NoError(t, db.Create(&owner).Error)
+	require.NoError(t, db.Create(&[]models.Transaction{{ChainId: sepolia, TransactionHash: hashA}, {ChainId: mainnet, TransactionHash: hashA}}).Error)
+
+	txService, err := txns.NewTxnService(db, []txns.Chain{
+		{Id: sepolia, Name: "sepolia", NodeURL: "https://sepolia.infura.io/v3/dummy", Cache: txns.NewTxnCache()},
+		{Id: mainnet, Name: "mainnet", NodeURL: "https://mainnet.infura.io/v3/dummy", Cache: txns.NewTxnCache()},
+	})
+	require.NoError(t, err)
+	_, err = txService.ByHashes(sepolia, []string{hashA}, owner.ID, types.FetchOptions{})
+	require.NoError(t, err)
+
+	service := shares.NewShareService(db, collections.NewCollectionService(db), txService, shares.ShareConfig{Secret: "share-secret"})
+
+	_, err = service.Create(owner.ID, mainnet, api.CreateShareRequest{TransactionHashes: []string{hashA}})
+	assert.Equal(t, collections.NotInHistory, err)
+
+	// the link resolves on the chain it was created on, not every chain
+	// that knows the hash
+	share, err := service.Create(owner.ID, sepolia, api.CreateShareRequest{TransactionHashes: []string{hashA}})
+	require.NoError(t, err)
+	shared, err := service.Resolve(share.Token)
+	require.NoError(t, err)
+	require.Len(t, shared, 1)
+	assert.Equal(t, uint64(sepolia), shared[0].ChainId)
+}
@@ -0,0 +1,290 @@
+package transactions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ethereum_fetcher/db/models"
+	txns "ethereum_fetcher/internal/services/transactions"
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+const (
+	headerHash = "0x00000000000000000000000000000000000000000000000000000000000000a1"
+	header     = `{
+		"number": "0x64", "hash": "` + headerHash + `",
+		"parentHash": "0x00000000000000000000000000000000000000000000000000000000000000a0",
+		"timestamp": "0x665f1a00", "miner": "0x9999999999999999999999999999999999999999",
+		"baseFeePerGas": "0x3b9aca00", "gasUsed": "0x1c9c380", "gasLimit": "0x1c9c380",
+		"blobGasUsed": "0x20000", "excessBlobGas": "0x0",
+		"withdrawals": [{}, {}, {}],
+		"transactions": ["0x01", "0x02"]
+	}`
+	// embeddedHash is a transaction stored in the block of header
+	embeddedHash = "0x00000000000000000000000000000000000000000000000000000000000000e1"
+	// finalizedHeader is the finalized head, blocks above it can be reorged
+	finalizedHeader = `{"number": "0x80", "hash": "0x00000000000000000000000000000000000000000000000000000000000000f0"}`
+)
+
+func TestBlocks(t *testing.T) {
+	db := setupTestDB(t)
+
+	node := newFakeNode(t)
+	node.On("eth_getBlockByNumber", "finalized", finalizedHeader)
+	node.On("eth_getBlockByNumber", "0x64", header)
+	node.On("eth_getBlockByHash", headerHash, header)
+
+	txService, err := txns.NewTxnService(db, []txns.Chain{
+		{Id: sepolia, Name: "sepolia", NodeURL: node.URL(), Cache: txns.NewTxnCache()},
+	})
+	require.NoError(t, err)
+
+	t.Run("ReadsTheHeader", func(t *testing.T) {
+		block, err := txService.Block(sepolia, "100", types.FetchOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, uint64(100), block.Number)
+		assert.Equal(t, headerHash, block.Hash)
+		assert.Equal(t, uint64(1717508608), block.Timestamp)
+		assert.Equal(t, "0x9999999999999999999999999999999999999999", block.Miner)
+		assert.Equal(t, "1000000000", *block.BaseFeePerGas)
+		assert.Equal(t, uint64(131072), *block.BlobGasUsed)
+		assert.Equal(t, uint64(3), *block.WithdrawalsCount)
+		assert.Equal(t, uint64(2), block.TransactionCount)
+	})
+
+	t.Run("CachesBlocks", func(t *testing.T) {
+		block, err := txService.Block(sepolia, headerHash, types.FetchOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(100), block.Number)
+		assert.Equal(t, 1, node.CallsWith("eth_getBlockByNumber", "0x64"))
+		assert.Zero(t, node.Calls("eth_getBlockByHash"), "the block is cached by its hash too")
+	})
+
+	t.Run("StoresBlocks", func(t *testing.T) {
+		// a new service starts with empty caches
+		restarted, err := txns.NewTxnService(db, []txns.Chain{
+			{Id: sepolia, Name: "sepolia", NodeURL: node.URL(), Cache: txns.NewTxnCache()},
+		})
+		require.NoError(t, err)
+
+		budget := &refusingBudget{}
+		block, err := restarted.Block(sepolia, "100", types.FetchOptions{Budget: budget})
+		require.NoError(t, err)
+		assert.Equal(t, headerHash, block.Hash)
+		assert.Empty(t, budget.charged)
+	})
+
+	t.Run("EmbedsBlocks", func(t *testing.T) {
+		require.NoError(t, db.Create(&models.Transaction{ChainId: sepolia, TransactionHash: embeddedHash, BlockNumber: 100}).Error)
+
+		found, err := txService.ByHashes(sepolia, []string{embeddedHash}, 0, types.FetchOptions{})
+		require.NoError(t, err)
+		require.NoError(t, txService.EmbedBlocks(found, types.FetchOptions{}))
+
+		require.NotNil(t, found[0].Block)
+		assert.Equal(t, uint64(1717508608), found[0].Block.Timestamp)
+	})
+
+	t.Run("ChargesNodeCallBudget", func(t *testing.T) {
+		budget := &refusingBudget{}
+		_, err := txService.Block(sepolia, "101", types.FetchOptions{Budget: budget})
+		assert.Equal(t, errNoBudget, err)
+		assert.Equal(t, []int{1}, budget.charged)
+	})
+
+	t.Run("KeepsRecentBlocksUntilFinal", func(t *testing.T) {
+		recentHash := "0x00000000000000000000000000000000000000000000000000000000000002a1"
+		node.On("eth_getBlockByNumber", "0x200", `{"number": "0x200", "hash": "`+recentHash+`", "transactions": []}`)
+
+		block, err := txService.Block(sepolia, "512", types.FetchOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, recentHash, block.Hash)
+
+		budget := &refusingBudget{}
+		_, err = txService.Block(sepolia, "512", types.FetchOptions{Budget: budget})
+		require.NoError(t, err)
+		_, err = txService.Block(sepolia, recentHash, types.FetchOptions{Budget: budget})
+		require.NoError(t, err)
+		assert.Equal(t, 1, node.CallsWith("eth_getBlockByNumber", "0x200"))
+		assert.Zero(t, node.CallsWith("eth_getBlockByHash", recentHash))
+		assert.Empty(t, budget.charged)
+
+		// a block at the same height looked up by its hash may not be canonical
+		forkHash := "0x00000000000000000000000000000000000000000000000000000000000002a2"
+		node.On("eth_getBlockByHash", forkHash, `{"number": "0x200", "hash": "`+forkHash+`", "transactions": []}`)
+		_, err = txService.Block(sepolia, forkHash, types.FetchOptions{})
+		require.NoError(t, err)
+		block, err = txService.Block(sepolia, "512", types.FetchOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, recentHash, block.Hash)
+
+		var stored int64
+		require.NoError(t, db.Model(&models.Block{}).Where("number = ?", 512).Count(&stored).Error)
+		assert.Zero(t, stored)
+	})
+
+	t.Run("KeepsTheCanonicalBlockAtItsNumber", func(t *testing.T) {
+		// an uncle at the height of a final block
+		uncleHash := "0x00000000000000000000000000000000000000000000000000000000000000b1"
+		node.On("eth_getBlockByHash", uncleHash, `{"number": "0x64", "hash": "`+uncleHash+`", "transactions": []}`)
+
+		uncle, err := txService.Block(sepolia, uncleHash, types.FetchOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(100), uncle.Number)
+
+		block, err := txService.Block(sepolia, "100", types.FetchOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, headerHash, block.Hash)
+
+		var stored models.Block
+		require.NoError(t, db.Where("chain_id = ? AND number = ?", sepolia, 100).First(&stored).Error)
+		assert.Equal(t, headerHash, stored.Hash)
+	})
+
+	t.Run("FailsForUnknownBlocks", func(t *testing.T) {
+		_, err := txService.Block(sepolia, "101", types.FetchOptions{})
+		assert.Equal(t, types.BlockNotFound, err)
+	})
+
+	t.Run("RefusesInvalidBlocks", func(t *testing.T) {
+		_, err := txService.Block(sepolia, "latest", types.FetchOptions{})
+		assert.Equal(t, types.InvalidBlockId, err)
+	})
+}
+
+func TestBlockTimestamps(t *testing.T) {
+	db := setupTestDB(t)
+
+	user := models.User{Username: "miner", PasswordHash: "hashedpassword"}
+	require.NoError(t, db.Create(&user).Error)
+
+	// the chain is read raw so the transaction needs no signature
+	minedHash := "0x00000000000000000000000000000000000000000000000000000000000000a2"
+	node := newFakeNode(t)
+	node.On("eth_getTransactionByHash", minedHash, `{
+		"hash": "`+minedHash+`", "type": "0x2", "nonce": "0x0", "blockHash": "`+headerHash+`",
+		"from": "0x1111111111111111111111111111111111111111",
+		"to": "0x2222222222222222222222222222222222222222", "input": "0x", "value": "0x1"
+	}`)
+	node.On("eth_getTransactionReceipt", minedHash, `{
+		"status": "0x1", "blockHash": "`+headerHash+`", "blockNumber": "0x64",
+		"contractAddress": null, "logs": [], "gasUsed": "0x5208", "effectiveGasPrice": "0x1"
+	}`)
+	node.On("eth_getBlockByNumber", "finalized", finalizedHeader)
+	node.On("eth_getBlockByNumber", "0x64", header)
+	node.On("eth_getBlockByNumber", "0x1", `{
+		"number": "0x1", "hash": "0x00000000000000000000000000000000000000000000000000000000000000b1",
+		"parentHash": "0x00000000000000000000000000000000000000000000000000000000000000b0",
+		"timestamp": "0x5f5e1000", "miner": "0x9999999999999999999999999999999999999999",
+		"gasUsed": "0x0", "gasLimit": "0x1c9c380", "transactions": []
+	}`)
+
+	cache := txns.NewTxnCache()
+	txService, err := txns.NewTxnService(db, []txns.Chain{
+		{Id: opSepolia, Name: "op-sepolia", Type: "optimism", NodeURL: node.URL(), Cache: cache},
+	})
+	require.NoError(t, err)
+
+	// stored before block timestamps were recorded
+	require.NoError(t, db.Create(&models.Transaction{ChainId: opSepolia, TransactionHash: "0xold", BlockNumber: 1}).Error)
+
+	t.Run("StampsFetchedTransactions", func(t *testing.T) {
+		found, err := txService.ByHashes(opSepolia, []string{minedHash}, user.ID, types.FetchOptions{})
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		require.NotNil(t, found[0].BlockTimestamp)
+		assert.Equal(t, uint64(1717508608), *found[0].BlockTimestamp)
+	})
+
+	t.Run("RetriesMissingTimestamps", func(t *testing.T) {
+		require.NoError(t, db.Model(&models.Transaction{}).Where("transaction_hash = ?", minedHash).
+			Update("block_timestamp", nil).Error)
+		require.NoError(t, cache.Purge())
+
+		found, err := txService.ByHashes(opSepolia, []string{minedHash}, user.ID, types.FetchOptions{})
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		require.NotNil(t, found[0].BlockTimestamp)
+
+		var stored models.Transaction
+		require.NoError(t, db.Where("transaction_hash = ?", minedHash).First(&stored).Error)
+		require.NotNil(t, stored.BlockTimestamp)
+		assert.Equal(t, uint64(1717508608), *stored.BlockTimestamp)
+	})
+
+	t.Run("DatesFromStoredBlocks", func(t *testing.T) {
+		require.NoError(t, db.Create(&models.Transaction{ChainId: opSepolia, TransactionHash: "0xsameblock", BlockNumber: 100}).Error)
+		defer db.Where("transaction_hash = ?", "0xsameblock").Delete(&models.Transaction{})
+		calls := node.CallsWith("eth_getBlockByNumber", "0x64")
+
+		mined, err := txService.All(opSepolia, types.MinedPeriod{From: 1717508608, To: 1717508608}, types.FetchOptions{Budget: &refusingBudget{}})
+		require.NoError(t, err)
+		assert.Len(t, mined, 2)
+		assert.Equal(t, calls, node.CallsWith("eth_getBlockByNumber", "0x64"))
+
+		var stored models.Transaction
+		require.NoError(t, db.Where("transaction_hash = ?", "0xsameblock").First(&stored).Error)
+		require.NotNil(t, stored.BlockTimestamp)
+		assert.Equal(t, uint64(1717508608), *stored.BlockTimestamp)
+	})
+
+	t.Run("LeavesOutTransactionsItCantDate", func(t *testing.T) {
+		budget := &refusingBudget{}
+		mined, err := txService.All(opSepolia, types.MinedPeriod{From: 1}, types.FetchOptions{Budget: budget})
+		require.NoError(t, err)
+		require.Len(t, mined, 1)
+		assert.Equal(t, minedHash, mined[0].TransactionHash)
+		assert.Equal(t, []int{1}, budget.charged)
+		assert.Zero(t, node.CallsWith("eth_getBlockByNumber", "0x1"))
+	})
+
+	t.Run("DatesOnlyTheListedTransactions", func(t *testing.T) {
+		budget := &refusingBudget{}
+		history, err := txService.ForUser(user.ID, types.HistoryQuery{
+			Scope:         types.UserHistory,
+			HistoryFilter: types.HistoryFilter{MinedPeriod: types.MinedPeriod{From: 1}},
+			Fetch:         types.FetchOptions{Budget: budget},
+		})
+		require.NoError(t, err)
+		assert.Len(t, history, 1)
+		assert.Empty(t, budget.charged)
+	})
+
+	t.Run("FiltersByMinedTime", func(t *testing.T) {
+		all, err := txService.All(opSepolia, types.MinedPeriod{}, types.FetchOptions{})
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+
+		mined, err := txService.All(opSepolia, types.MinedPeriod{From: 1717508608, To: 1717508608}, types.FetchOptions{})
+		require.NoError(t, err)
+		require.Len(t, mined, 1)
+		assert.Equal(t, minedHash, mined[0].TransactionHash)
+
+		later, err := txService.All(opSepolia, types.MinedPeriod{From: 1717508609}, types.FetchOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, later)
+
+		// stored before block timestamps were recorded, dated from the node
+		earlier, err := txService.All(opSepolia, types.MinedPeriod{To: 1600000000}, types.FetchOptions{})
+		require.NoError(t, err)
+		require.Len(t, earlier, 1)
+		assert.Equal(t, "0xold", earlier[0].TransactionHash)
+
+		history, err := txService.ForUser(user.ID, types.HistoryQuery{
+			Scope:         types.UserHistory,
+			HistoryFilter: types.HistoryFilter{MinedPeriod: types.MinedPeriod{To: 1717500000}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, history)
+
+		history, err = txService.ForUser(user.ID, types.HistoryQuery{
+			Scope:         types.UserHistory,
+			HistoryFilter: types.HistoryFilter{MinedPeriod: types.MinedPeriod{From: 1717500000}},
+		})
+		require.NoError(t, err)
+		assert.Len(t, history, 1)
+	})
+}
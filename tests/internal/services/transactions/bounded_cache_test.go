@@ -0,0 +1,78 @@
+package transactions
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ethereum_fetcher/db/models"
+	txns "ethereum_fetcher/internal/services/transactions"
+)
+
+func TestBoundedCache(t *testing.T) {
+	t.Run("LFUEvictsLeastFrequentlyUsed", func(t *testing.T) {
+		cache := txns.NewBoundedCache(txns.BoundedCacheConfig{MaxEntries: 2, Policy: txns.LFU})
+
+		cache.Set(&models.Transaction{TransactionHash: "0xhot"})
+		cache.Set(&models.Transaction{TransactionHash: "0xcold"})
+		for i := 0; i < 3; i++ {
+			_, _ = cache.Get("0xhot")
+		}
+		_, _ = cache.Get("0xcold")
+		cache.Set(&models.Transaction{TransactionHash: "0xnew"})
+
+		_, ok := cache.Get("0xcold")
+		assert.False(t, ok)
+		_, ok = cache.Get("0xhot")
+		assert.True(t, ok)
+	})
+
+	t.Run("AdmissionRejectsOneHitWonders", func(t *testing.T) {
+		cache := txns.NewBoundedCache(txns.BoundedCacheConfig{MaxEntries: 2, Policy: txns.LRU, Admission: true})
+
+		for _, hash := range []string{"0xa", "0xb"} {
+			_, _ = cache.Get(hash)
+			_, _ = cache.Get(hash)
+			cache.Set(&models.Transaction{TransactionHash: hash})
+		}
+
+		_, _ = cache.Get("0xonce")
+		cache.Set(&models.Transaction{TransactionHash: "0xonce"})
+
+		_, ok := cache.Get("0xonce")
+		assert.False(t, ok)
+		_, ok = cache.Get("0xa")
+		assert.True(t, ok)
+		assert.Equal(t, uint64(1), cache.Stats().Rejections)
+	})
+
+	t.Run("AdmissionAcceptsPopularTransactions", func(t *testing.T) {
+		cache := txns.NewBoundedCache(txns.BoundedCacheConfig{MaxEntries: 1, Policy: txns.LRU, Admission: true})
+
+		cache.Set(&models.Transaction{TransactionHash: "0xa"})
+		for i := 0; i < 3; i++ {
+			_, _ = cache.Get("0xpopular")
+		}
+		cache.Set(&models.Transaction{TransactionHash: "0xpopular"})
+
+		_, ok := cache.Get("0xpopular")
+		assert.True(t, ok)
+	})
+
+	t.Run("CountsHitsMissesAndEvictions", func(t *testing.T) {
+		cache := txns.NewBoundedCache(txns.BoundedCacheConfig{MaxEntries: 3, Policy: txns.LRU})
+
+		for i := 0; i < 5; i++ {
+			cache.Set(&models.Transaction{TransactionHash: fmt.Sprintf("0x%d", i)})
+		}
+		cache.GetMany([]string{"0x0", "0x3", "0x4"})
+
+		stats := cache.Stats()
+		assert.Equal(t, uint64(2), stats.Hits)
+		assert.Equal(t, uint64(1), stats.Misses)
+		assert.Equal(t, uint64(2), stats.Evictions)
+		assert.Equal(t, 3, stats.Entries)
+		assert.Positive(t, stats.Bytes)
+	})
+}
@@ -0,0 +1,169 @@
+package transactions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/internal/config"
+	txns "ethereum_fetcher/internal/services/transactions"
+)
+
+func TestCacheBackends(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+
+	backends := []config.CacheConfig{
+		{Backend: txns.MemoryBackend, TTL: time.Hour},
+		{Backend: txns.BoundedBackend, TTL: time.Hour, MaxEntries: 100, MaxBytes: 1 << 20},
+		{Backend: txns.RedisBackend, TTL: time.Hour, RedisURL: "redis://" + redisServer.Addr()},
+		{Backend: txns.TieredBackend, TTL: time.Hour, MaxEntries: 100, MaxBytes: 1 << 20, RedisURL: "redis://" + redisServer.Addr()},
+	}
+
+	for _, cfg := range backends {
+		t.Run(cfg.Backend, func(t *testing.T) {
+			redisServer.FlushAll()
+
+			cache, err := txns.NewCache(cfg)
+			require.NoError(t, err)
+
+			to := "0xReceiver"
+			cache.Set(&models.Transaction{TransactionHash: "0x123", BlockNumber: 100, ToAddress: &to})
+			cache.SetMany([]models.Transaction{
+				{TransactionHash: "0x456", BlockNumber: 200},
+				{TransactionHash: "0x789", BlockNumber: 300},
+			})
+
+			cached, ok := cache.Get("0x123")
+			require.True(t, ok)
+			assert.Equal(t, uint64(100), cached.BlockNumber)
+			assert.Equal(t, to, *cached.ToAddress)
+
+			result := cache.GetMany([]string{"0x456", "0xmissing", "0x789"})
+			assert.ElementsMatch(t, []string{"0x456", "0x789"}, result.ExistingHashes)
+			assert.Equal(t, []string{"0xmissing"}, result.MissingHashes)
+			assert.Len(t, result.ExistingTxns, 2)
+
+			redisServer.Set("unrelated", "kept")
+			cache.SetNotFound([]string{"0xmissing"})
+			require.NoError(t, cache.Purge())
+
+			result = cache.GetMany([]string{"0x123", "0x456", "0x789"})
+			assert.Empty(t, result.ExistingHashes)
+			notFound, _ := cache.SplitNotFound([]string{"0xmissing"})
+			assert.Empty(t, notFound)
+			assert.True(t, redisServer.Exists("unrelated"))
+		})
+	}
+
+	t.Run("UnknownBackend", func(t *testing.T) {
+		_, err := txns.NewCache(config.CacheConfig{Backend: "memcached"})
+		assert.Error(t, err)
+	})
+
+	t.Run("PurgesEveryReplica", func(t *testing.T) {
+		redisServer.FlushAll()
+		cfg := backends[3]
+
+		// two replicas with a local tier each
+		replica, err := txns.NewChainCache(cfg, 1)
+		require.NoError(t, err)
+		other, err := txns.NewChainCache(cfg, 1)
+		require.NoError(t, err)
+
+		replica.Set(&models.Transaction{TransactionHash: "0x123"})
+		_, ok := other.Get("0x123")
+		require.True(t, ok, "copied into the local tier of the other replica")
+
+		require.NoError(t, replica.Purge())
+		assert.Eventually(t, func() bool {
+			_, ok := other.Get("0x123")
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("KeepsChainsApart", func(t *testing.T) {
+		redisServer.FlushAll()
+		cfg := backends[2]
+
+		defaultChain, err := txns.NewCache(cfg)
+		require.NoError(t, err)
+		chain, err := txns.NewChainCache(cfg, 1)
+		require.NoError(t, err)
+
+		chain.Set(&models.Transaction{TransactionHash: "0x123"})
+		_, ok := defaultChain.Get("0x123")
+		assert.False(t, ok)
+
+		require.NoError(t, defaultChain.Purge())
+		_, ok = chain.Get("0x123")
+		assert.True(t, ok)
+	})
+
+	t.Run("RedisRequiresURL", func(t *testing.T) {
+		_, err := txns.NewCache(config.CacheConfig{Backend: txns.RedisBackend})
+		assert.Error(t, err)
+	})
+}
+
+func TestLRUCacheBounds(t *testing.T) {
+	t.Run("EvictsLeastRecentlyUsedEntry", func(t *testing.T) {
+		cache := txns.NewLRUCache(2, 0, 0)
+
+		cache.Set(&models.Transaction{TransactionHash: "0x1"})
+		cache.Set(&models.Transaction{TransactionHash: "0x2"})
+		_, _ = cache.Get("0x1")
+		cache.Set(&models.Transaction{TransactionHash: "0x3"})
+
+		_, ok := cache.Get("0x2")
+		assert.False(t, ok)
+		_, ok = cache.Get("0x1")
+		assert.True(t, ok)
+		_, ok = cache.Get("0x3")
+		assert.True(t, ok)
+	})
+
+	t.Run("EvictsToStayWithinMemoryBound", func(t *testing.T) {
+		cache := txns.NewLRUCache(0, 4096, 0)
+
+		input := make([]byte, 1024)
+		for i := 0; i < 10; i++ {
+			cache.Set(&models.Transaction{TransactionHash: string(rune('a' + i)), Input: string(input)})
+		}
+
+		result := cache.GetMany([]string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"})
+		assert.Less(t, len(result.ExistingHashes), 4)
+		assert.Contains(t, result.ExistingHashes, "j")
+	})
+
+	t.Run("ExpiresEntries", func(t *testing.T) {
+		cache := txns.NewLRUCache(10, 0, time.Millisecond)
+		cache.Set(&models.Transaction{TransactionHash: "0x1"})
+
+		time.Sleep(5 * time.Millisecond)
+		_, ok := cache.Get("0x1")
+		assert.False(t, ok)
+	})
+}
+
+func TestTieredCache(t *testing.T) {
+	local := txns.NewLRUCache(10, 0, 0)
+	shared := txns.NewTxnCache()
+	cache, err := txns.NewTieredCache(local, shared)
+	require.NoError(t, err)
+
+	shared.Set(&models.Transaction{TransactionHash: "0xshared"})
+
+	result := cache.GetMany([]string{"0xshared"})
+	assert.Equal(t, []string{"0xshared"}, result.ExistingHashes)
+
+	_, ok := local.Get("0xshared")
+	assert.True(t, ok, "shared hits are copied into the local tier")
+
+	cache.Set(&models.Transaction{TransactionHash: "0xboth"})
+	_, ok = shared.Get("0xboth")
+	assert.True(t, ok)
+}
@@ -0,0 +1,104 @@
+package transactions
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"ethereum_fetcher/db/models"
+	txns "ethereum_fetcher/internal/services/transactions"
+)
+
+const (
+	benchKeySpace = 100_000
+	benchCapacity = benchKeySpace / 10
+)
+
+// skewedKeys draws hashes from a Zipf distribution, so a few transactions
+// are requested very often while most are requested once or twice
+func skewedKeys(n int) []string {
+	zipf := rand.NewZipf(rand.New(rand.NewSource(42)), 1.1, 1, benchKeySpace-1)
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("0x%064x", zipf.Uint64())
+	}
+	return keys
+}
+
+func benchCaches() map[string]func() txns.TxnCache {
+	return map[string]func() txns.TxnCache{
+		"memory": func() txns.TxnCache { return txns.NewTxnCache() },
+		"bounded-lru": func() txns.TxnCache {
+			return txns.NewBoundedCache(txns.BoundedCacheConfig{MaxEntries: benchCapacity, TTL: time.Hour, Policy: txns.LRU})
+		},
+		"bounded-lfu": func() txns.TxnCache {
+			return txns.NewBoundedCache(txns.BoundedCacheConfig{MaxEntries: benchCapacity, TTL: time.Hour, Policy: txns.LFU})
+		},
+		"bounded-lru-admission": func() txns.TxnCache {
+			return txns.NewBoundedCache(txns.BoundedCacheConfig{MaxEntries: benchCapacity, TTL: time.Hour, Policy: txns.LRU, Admission: true})
+		},
+	}
+}
+
+// BenchmarkSkewedReadThrough replays a read-through workload: every miss is
+// followed by a Set, as the transaction service does after a DB or node fetch.
+// Besides ns/op it reports the hit ratio and the heap retained by the cache.
+func BenchmarkSkewedReadThrough(b *testing.B) {
+	keys := skewedKeys(1 << 20)
+
+	for name, newCache := range benchCaches() {
+		b.Run(name, func(b *testing.B) {
+			runtime.GC()
+			var before runtime.MemStats
+			runtime.ReadMemStats(&before)
+
+			cache := newCache()
+			hits := 0
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := keys[i%len(keys)]
+				if _, ok := cache.Get(key); ok {
+					hits++
+					continue
+				}
+				// a fresh input per transaction, like rows decoded from the DB or node
+				cache.Set(&models.Transaction{TransactionHash: key, Input: strings.Repeat("a", 512)})
+			}
+			b.StopTimer()
+
+			runtime.GC()
+			var after runtime.MemStats
+			runtime.ReadMemStats(&after)
+
+			b.ReportMetric(float64(hits)/float64(b.N), "hit-ratio")
+			b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc))/(1<<20), "retained-MiB")
+			runtime.KeepAlive(cache)
+		})
+	}
+}
+
+func BenchmarkSkewedParallelGet(b *testing.B) {
+	keys := skewedKeys(1 << 16)
+
+	for name, newCache := range benchCaches() {
+		b.Run(name, func(b *testing.B) {
+			cache := newCache()
+			for _, key := range keys {
+				cache.Set(&models.Transaction{TransactionHash: key})
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Intn(len(keys))
+				for pb.Next() {
+					cache.Get(keys[i%len(keys)])
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package transactions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeNode is a minimal JSON-RPC server standing in for an Ethereum node.
+// Methods without a registered result answer null, which the client treats
+// as not found.
+type fakeNode struct {
+	mu        sync.Mutex
+	results   map[string]json.RawMessage
+	failures  map[string]string
+	calls     map[string]int
+	callsWith map[string]int
+	server    *httptest.Server
+}
+
+type rpcRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+func newFakeNode(t *testing.T) *fakeNode {
+	node := &fakeNode{
+		results:   map[string]json.RawMessage{},
+		failures:  map[string]string{},
+		calls:     map[string]int{},
+		callsWith: map[string]int{},
+	}
+	node.server = httptest.NewServer(http.HandlerFunc(node.handle))
+	t.Cleanup(node.server.Close)
+	return node
+}
+
+func (n *fakeNode) URL() string {
+	return n.server.URL
+}
+
+// On registers the result for a method called with the given first parameter
+func (n *fakeNode) On(method string, param string, result string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.results[method+param] = json.RawMessage(result)
+}
+
+// OnAt registers the result for a method called with the given first
+// parameter at a block, the second parameter, taking over from On
+func (n *fakeNode) OnAt(method string, param string, block string, result string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.results[method+param+"@"+block] = json.RawMessage(result)
+}
+
+// Fail answers a method called with the given first parameter with an error,
+// results registered with OnAt still take over
+func (n *fakeNode) Fail(method string, param string, message string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.failures[method+param] = message
+}
+
+func (n *fakeNode) Calls(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls[method]
+}
+
+// CallsWith counts the calls of a method with the given first parameter
+func (n *fakeNode) CallsWith(method string, param string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.callsWith[method+param]
+}
+
+func (n *fakeNode) handle(w http.ResponseWriter, r *http.Request) {
+	var req rpcRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var param, block string
+	if len(req.Params) > 0 {
+		_ = json.Unmarshal(req.Params[0], &param)
+	}
+	if len(req.Params) > 1 {
+		_ = json.Unmarshal(req.Params[1], &block)
+	}
+
+	n.mu.Lock()
+	n.calls[req.Method]++
+	n.callsWith[req.Method+param]++
+	result, ok := n.results[req.Method+param+"@"+block]
+	failure, failed := n.failures[req.Method+param]
+	if !ok && !failed {
+		result, ok = n.results[req.Method+param]
+	}
+	n.mu.Unlock()
+	if !ok {
+		result = json.RawMessage("null")
+	}
+
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result}
+	if !ok && failed {
+		response = map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "error": map[string]interface{}{"code": -32000, "message": failure}}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
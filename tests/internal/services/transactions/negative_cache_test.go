@@ -0,0 +1,82 @@
+package transactions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ethereum_fetcher/internal/config"
+	txns "ethereum_fetcher/internal/services/transactions"
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+func TestNegativeCache(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+
+	backends := []config.CacheConfig{
+		{Backend: txns.MemoryBackend, TTL: time.Hour, NegativeTTL: time.Minute},
+		{Backend: txns.BoundedBackend, TTL: time.Hour, NegativeTTL: time.Minute, MaxEntries: 100},
+		{Backend: txns.RedisBackend, TTL: time.Hour, NegativeTTL: time.Minute, RedisURL: "redis://" + redisServer.Addr()},
+		{Backend: txns.TieredBackend, TTL: time.Hour, NegativeTTL: time.Minute, MaxEntries: 100, RedisURL: "redis://" + redisServer.Addr()},
+	}
+
+	for _, cfg := range backends {
+		t.Run(cfg.Backend, func(t *testing.T) {
+			redisServer.FlushAll()
+
+			cache, err := txns.NewCache(cfg)
+			require.NoError(t, err)
+
+			cache.SetNotFound([]string{"0xbad"})
+
+			notFound, rest := cache.SplitNotFound([]string{"0xbad", "0xgood"})
+			assert.Equal(t, []string{"0xbad"}, notFound)
+			assert.Equal(t, []string{"0xgood"}, rest)
+
+			_, ok := cache.Get("0xbad")
+			assert.False(t, ok, "not found hashes are not cached transactions")
+		})
+	}
+
+	t.Run("Expires", func(t *testing.T) {
+		cache := txns.NewBoundedCache(txns.BoundedCacheConfig{MaxEntries: 10, NegativeTTL: time.Millisecond})
+		cache.SetNotFound([]string{"0xbad"})
+
+		time.Sleep(5 * time.Millisecond)
+		notFound, _ := cache.SplitNotFound([]string{"0xbad"})
+		assert.Empty(t, notFound)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		cache := txns.NewBoundedCache(txns.BoundedCacheConfig{MaxEntries: 10})
+		cache.SetNotFound([]string{"0xbad"})
+
+		notFound, _ := cache.SplitNotFound([]string{"0xbad"})
+		assert.Empty(t, notFound)
+	})
+}
+
+func TestServiceNegativeCaching(t *testing.T) {
+	db := setupTestDB(t)
+
+	node := newFakeNode(t)
+	txService, err := txns.NewTxnService(db, []txns.Chain{{Id: 1, Name: "mainnet", NodeURL: node.URL(), Cache: txns.NewTxnCache()}})
+	require.NoError(t, err)
+
+	unknown := "0x00000000000000000000000000000000000000000000000000000000000000aa"
+
+	_, err = txService.ByHashes(1, []string{unknown}, 0, types.FetchOptions{})
+	assert.Equal(t, types.FailedToFetchTransaction, err)
+	assert.Equal(t, 1, node.Calls("eth_getTransactionByHash"))
+
+	_, err = txService.ByHashes(1, []string{unknown}, 0, types.FetchOptions{})
+	assert.Equal(t, types.FailedToFetchTransaction, err)
+	assert.Equal(t, 1, node.Calls("eth_getTransactionByHash"), "repeated lookups are answered from the negative cache")
+
+	_, err = txService.ByHashes(1, []string{unknown}, 0, types.FetchOptions{BypassNegativeCache: true})
+	assert.Equal(t, types.FailedToFetchTransaction, err)
+	assert.Equal(t, 2, node.Calls("eth_getTransactionByHash"))
+}
@@ -0,0 +1,233 @@
+package transactions
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ethereum_fetcher/db/models"
+	txns "ethereum_fetcher/internal/services/transactions"
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+const (
+	sender       = "0x7777777777777777777777777777777777777777"
+	replacedHash = "0x00000000000000000000000000000000000000000000000000000000000000f1"
+	droppedHash  = "0x00000000000000000000000000000000000000000000000000000000000000f2"
+	cancelHash   = "0x00000000000000000000000000000000000000000000000000000000000000f3"
+	// prunedSender is served by a node that only keeps the latest state
+	prunedSender = "0x7777777777777777777777777777777777777778"
+	prunedHash   = "0x00000000000000000000000000000000000000000000000000000000000000f4"
+	speedUpHash  = "0x00000000000000000000000000000000000000000000000000000000000000f5"
+)
+
+func pendingTxn(from string, hash string, nonce string) string {
+	return `{"hash": "` + hash + `", "type": "0x2", "nonce": "` + nonce + `", "blockHash": null,
+		"from": "` + from + `", "to": "0x8888888888888888888888888888888888888888", "input": "0x", "value": "0x1"}`
+}
+
+// cappedBudget records the node calls it is charged and refuses them once its
+// calls are used up
+type cappedBudget struct {
+	left    int
+	charged []int
+}
+
+func (b *cappedBudget) Charge(calls int) error {
+	b.charged = append(b.charged, calls)
+	if calls > b.left {
+		return errNoBudget
+	}
+	b.left -= calls
+	return nil
+}
+
+func TestReplacements(t *testing.T) {
+	db := setupTestDB(t)
+
+	node := newFakeNode(t)
+	node.On("eth_blockNumber", "", `"0x64"`)
+	node.On("eth_getTransactionByHash", replacedHash, pendingTxn(sender, replacedHash, "0x5"))
+	node.On("eth_getTransactionByHash", droppedHash, pendingTxn(sender, droppedHash, "0x9"))
+	node.On("eth_getTransactionByHash", prunedHash, pendingTxn(prunedSender, prunedHash, "0x7"))
+
+	// the chain is read raw so the pending transactions need no signature
+	txService, err := txns.NewTxnService(db, []txns.Chain{
+		{Id: opSepolia, Name: "op-sepolia", Type: "optimism", NodeURL: node.URL(), Cache: txns.NewTxnCache()},
+	})
+	require.NoError(t, err)
+
+	for _, hash := range []string{replacedHash, droppedHash, prunedHash} {
+		_, err = txService.ByHashes(opSepolia, []string{hash}, 0, types.FetchOptions{})
+		require.Equal(t, types.TransactionPending, err)
+	}
+
+	// the sender cancelled nonce 5 with a transfer of nothing to itself, nonce
+	// 9 is still unused
+	node.On("eth_getTransactionByHash", replacedHash, "null")
+	node.On("eth_getTransactionByHash", droppedHash, "null")
+	node.On("eth_getTransactionByHash", prunedHash, "null")
+	node.On("eth_blockNumber", "", `"0x6e"`)
+	node.On("eth_getTransactionCount", sender, `"0x6"`)
+	node.On("eth_getBlockByNumber", "0x64", `{"transactions": [{
+		"hash": "`+cancelHash+`", "type": "0x2", "nonce": "0x5", "blockHash": "`+blockHash+`",
+		"from": "`+sender+`", "to": "`+sender+`", "input": "0x", "value": "0x0"
+	}]}`)
+	// the other sender sped nonce 7 up a block later, on a node without the
+	// state of older blocks
+	node.OnAt("eth_getTransactionCount", prunedSender, "latest", `"0x8"`)
+	node.Fail("eth_getTransactionCount", prunedSender, "missing trie node")
+	node.On("eth_getBlockByNumber", "0x65", `{"transactions": [{
+		"hash": "`+speedUpHash+`", "type": "0x2", "nonce": "0x7", "blockHash": "`+blockHash+`",
+		"from": "`+prunedSender+`", "to": "0x8888888888888888888888888888888888888888", "input": "0x", "value": "0x1"
+	}]}`)
+
+	t.Run("ChargesTheSearch", func(t *testing.T) {
+		calls := node.Calls("eth_getTransactionCount")
+		budget := &cappedBudget{left: 1}
+
+		_, err := txService.ByHashes(opSepolia, []string{replacedHash}, 0, types.FetchOptions{Budget: budget})
+		assert.Equal(t, errNoBudget, err)
+		assert.Equal(t, []int{1, 2}, budget.charged)
+		assert.Equal(t, calls, node.Calls("eth_getTransactionCount"))
+	})
+
+	t.Run("LinksTheReplacement", func(t *testing.T) {
+		_, err := txService.ByHashes(opSepolia, []string{replacedHash}, 0, types.FetchOptions{})
+
+		var replacedErr types.ReplacedError
+		require.True(t, errors.As(err, &replacedErr))
+		require.Len(t, replacedErr.Replacements, 1)
+		assert.Equal(t, replacedHash, replacedErr.Replacements[0].TransactionHash)
+		assert.Equal(t, cancelHash, replacedErr.Replacements[0].ReplacedBy)
+		assert.True(t, replacedErr.Replacements[0].Cancelled)
+	})
+
+	t.Run("StoresReplacements", func(t *testing.T) {
+		calls := node.Calls("eth_getTransactionByHash")
+
+		_, err := txService.ByHashes(opSepolia, []string{replacedHash}, 0, types.FetchOptions{})
+
+		var replacedErr types.ReplacedError
+		require.True(t, errors.As(err, &replacedErr))
+		assert.Equal(t, cancelHash, replacedErr.Replacements[0].ReplacedBy)
+		assert.Equal(t, calls, node.Calls("eth_getTransactionByHash"))
+	})
+
+	t.Run("ReadsBlocksWithoutArchiveState", func(t *testing.T) {
+		_, err := txService.ByHashes(opSepolia, []string{prunedHash}, 0, types.FetchOptions{})
+
+		var replacedErr types.ReplacedError
+		require.True(t, errors.As(err, &replacedErr))
+		require.Len(t, replacedErr.Replacements, 1)
+		assert.Equal(t, speedUpHash, replacedErr.Replacements[0].ReplacedBy)
+		assert.False(t, replacedErr.Replacements[0].Cancelled)
+		assert.Zero(t, node.CallsWith("eth_getBlockByNumber", "0x66"))
+	})
+
+	t.Run("SkipsTransactionsSeenAtAnUnknownBlock", func(t *testing.T) {
+		unseenHash := "0x00000000000000000000000000000000000000000000000000000000000000f6"
+		require.NoError(t, db.Create(&models.PendingTransaction{
+			ChainId: opSepolia, TransactionHash: unseenHash, FromAddress: sender, Nonce: 5, CreatedAt: time.Now(),
+		}).Error)
+		calls := node.Calls("eth_getTransactionCount")
+
+		_, err := txService.ByHashes(opSepolia, []string{unseenHash}, 0, types.FetchOptions{})
+		assert.Equal(t, types.FailedToFetchTransaction, err)
+		assert.Equal(t, calls, node.Calls("eth_getTransactionCount"))
+	})
+
+	t.Run("FailsWhileTheNonceIsUnused", func(t *testing.T) {
+		_, err := txService.ByHashes(opSepolia, []string{droppedHash}, 0, types.FetchOptions{})
+		assert.Equal(t, types.FailedToFetchTransaction, err)
+	})
+
+	t.Run("AsksAgainUntilTheNonceIsTaken", func(t *testing.T) {
+		// nonce 9 is taken in the same block as nonce 5
+		node.On("eth_getTransactionCount", sender, `"0xa"`)
+		node.On("eth_getBlockByNumber", "0x64", `{"transactions": [{
+			"hash": "`+cancelHash+`", "type": "0x2", "nonce": "0x5", "blockHash": "`+blockHash+`",
+			"from": "`+sender+`", "to": "`+sender+`", "input": "0x", "value": "0x0"
+		}, {
+			"hash": "`+speedUpHash+`", "type": "0x2", "nonce": "0x9", "blockHash": "`+blockHash+`",
+			"from": "`+sender+`", "to": "0x8888888888888888888888888888888888888888", "input": "0x", "value": "0x1"
+		}]}`)
+
+		_, err := txService.ByHashes(opSepolia, []string{droppedHash}, 0, types.FetchOptions{})
+
+		var replacedErr types.ReplacedError
+		require.True(t, errors.As(err, &replacedErr))
+		assert.Equal(t, speedUpHash, replacedErr.Replacements[0].ReplacedBy)
+	})
+}
+
+func TestSignedReplacements(t *testing.T) {
+	db := setupTestDB(t)
+
+	// the sender of pending transactions on L1 is recovered from their
+	// signature
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	// addresses are sent in lower case
+	from := strings.ToLower(address.Hex())
+	signed, err := gethtypes.SignTx(gethtypes.NewTx(&gethtypes.DynamicFeeTx{
+		ChainID: big.NewInt(sepolia), Nonce: 3, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Gas: 21000,
+		To: &address, Value: big.NewInt(1),
+	}), gethtypes.LatestSignerForChainID(big.NewInt(sepolia)), key)
+	require.NoError(t, err)
+	pending, err := signed.MarshalJSON()
+	require.NoError(t, err)
+	pendingHash := signed.Hash().Hex()
+
+	node := newFakeNode(t)
+	node.On("eth_blockNumber", "", `"0x64"`)
+	node.On("eth_getTransactionByHash", pendingHash, string(pending))
+
+	txService, err := txns.NewTxnService(db, []txns.Chain{
+		{Id: sepolia, Name: "sepolia", NodeURL: node.URL(), Cache: txns.NewTxnCache()},
+	})
+	require.NoError(t, err)
+
+	_, err = txService.ByHashes(sepolia, []string{pendingHash}, 0, types.FetchOptions{})
+	require.Equal(t, types.TransactionPending, err)
+
+	// nonce 3 was taken in block 0x6b, the nonce is read at every block from
+	// the one the transaction was seen pending at
+	node.On("eth_getTransactionByHash", pendingHash, "null")
+	node.On("eth_blockNumber", "", `"0x74"`)
+	node.OnAt("eth_getTransactionCount", from, "latest", `"0x4"`)
+	for number := 0x64; number <= 0x74; number++ {
+		nonce := `"0x3"`
+		if number >= 0x6b {
+			nonce = `"0x4"`
+		}
+		node.OnAt("eth_getTransactionCount", from, fmt.Sprintf("0x%x", number), nonce)
+	}
+	node.On("eth_getBlockByNumber", "0x6b", `{"transactions": [{
+		"hash": "`+cancelHash+`", "type": "0x2", "nonce": "0x3", "blockHash": "`+blockHash+`",
+		"from": "`+from+`", "to": "`+from+`", "input": "0x", "value": "0x0"
+	}]}`)
+
+	_, err = txService.ByHashes(sepolia, []string{pendingHash}, 0, types.FetchOptions{})
+
+	var replacedErr types.ReplacedError
+	require.True(t, errors.As(err, &replacedErr))
+	require.Len(t, replacedErr.Replacements, 1)
+	assert.Equal(t, cancelHash, replacedErr.Replacements[0].ReplacedBy)
+	assert.True(t, replacedErr.Replacements[0].Cancelled)
+
+	// the search reads the nonce at a few blocks and only the block that holds
+	// the replacement
+	assert.Less(t, node.Calls("eth_getTransactionCount"), 8)
+	assert.Equal(t, 1, node.Calls("eth_getBlockByNumber"))
+}
@@ -0,0 +1,92 @@
+package transactions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ethereum_fetcher/db/models"
+	txns "ethereum_fetcher/internal/services/transactions"
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+func TestAddUserTransactions(t *testing.T) {
+	db := setupTestDB(t)
+
+	repo := txns.NewTxnRepo(db)
+
+	t.Run("CountsRepeatedRequests", func(t *testing.T) {
+		require.NoError(t, repo.AddUserTransactions(1, []string{"0xaaa", "0xbbb", "0xaaa"}, 1, 0))
+		require.NoError(t, repo.AddUserTransactions(1, []string{"0xaaa"}, 1, 0))
+
+		var userTxn models.UserTransaction
+		require.NoError(t, db.Where("user_id = ? AND transaction_hash = ?", 1, "0xaaa").First(&userTxn).Error)
+		assert.Equal(t, uint64(2), userTxn.RequestCount)
+		assert.False(t, userTxn.LastRequestedAt.Before(userTxn.FirstRequestedAt))
+
+		var otherTxn models.UserTransaction
+		require.NoError(t, db.Where("user_id = ? AND transaction_hash = ?", 1, "0xbbb").First(&otherTxn).Error)
+		assert.Equal(t, uint64(1), otherTxn.RequestCount)
+	})
+
+	t.Run("SameHashForDifferentUsers", func(t *testing.T) {
+		require.NoError(t, repo.AddUserTransactions(1, []string{"0xccc"}, 1, 0))
+		require.NoError(t, repo.AddUserTransactions(1, []string{"0xccc"}, 2, 0))
+
+		var count int64
+		require.NoError(t, db.Model(&models.UserTransaction{}).Where("transaction_hash = ?", "0xccc").Count(&count).Error)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("SameHashOnDifferentChains", func(t *testing.T) {
+		require.NoError(t, repo.AddUserTransactions(1, []string{"0x111"}, 1, 0))
+		require.NoError(t, repo.AddUserTransactions(10, []string{"0x111"}, 1, 0))
+
+		var count int64
+		require.NoError(t, db.Model(&models.UserTransaction{}).Where("transaction_hash = ?", "0x111").Count(&count).Error)
+		assert.Equal(t, int64(2), count)
+
+		require.NoError(t, db.Create(&[]models.Transaction{
+			{ChainId: 1, TransactionHash: "0x111", BlockNumber: 1}, {ChainId: 10, TransactionHash: "0x111", BlockNumber: 10},
+		}).Error)
+		onOptimism, err := repo.GetUserTransactions(1, types.HistoryFilter{ChainId: 10})
+		require.NoError(t, err)
+		require.Len(t, onOptimism, 1)
+		assert.Equal(t, uint64(10), onOptimism[0].BlockNumber)
+
+		stored, err := repo.GetForHashes(0, []string{"0x111"})
+		require.NoError(t, err)
+		assert.Len(t, stored, 2)
+	})
+
+	t.Run("OrgHistory", func(t *testing.T) {
+		require.NoError(t, db.Create(&[]models.Transaction{
+			{ChainId: 1, TransactionHash: "0xddd"}, {ChainId: 1, TransactionHash: "0xeee"}, {ChainId: 1, TransactionHash: "0xfff"},
+		}).Error)
+		require.NoError(t, repo.AddUserTransactions(1, []string{"0xddd"}, 3, 0))
+		require.NoError(t, repo.AddUserTransactions(1, []string{"0xddd", "0xeee"}, 3, 5))
+		require.NoError(t, repo.AddUserTransactions(1, []string{"0xfff"}, 4, 5))
+
+		// a row per organization the hash was requested for
+		var count int64
+		require.NoError(t, db.Model(&models.UserTransaction{}).Where("user_id = ? AND transaction_hash = ?", 3, "0xddd").Count(&count).Error)
+		assert.Equal(t, int64(2), count)
+
+		userTxns, err := repo.GetUserTransactions(3, types.HistoryFilter{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"0xddd", "0xeee"}, hashesOf(userTxns))
+
+		orgTxns, err := repo.GetOrgTransactions(5, 3, types.HistoryFilter{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"0xddd", "0xeee", "0xfff"}, hashesOf(orgTxns))
+	})
+}
+
+func hashesOf(txns []models.Transaction) []string {
+	hashes := make([]string, 0, len(txns))
+	for _, txn := range txns {
+		hashes = append(hashes, txn.TransactionHash)
+	}
+	return hashes
+}
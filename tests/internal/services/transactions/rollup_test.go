@@ -0,0 +1,131 @@
+package transactions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	txns "ethereum_fetcher/internal/services/transactions"
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+const (
+	opSepolia       = 11155420
+	arbitrumSepolia = 421614
+)
+
+const (
+	blockHash   = "0x00000000000000000000000000000000000000000000000000000000000000b1"
+	opTxHash    = "0x00000000000000000000000000000000000000000000000000000000000000c1"
+	depositHash = "0x00000000000000000000000000000000000000000000000000000000000000c2"
+	arbTxHash   = "0x00000000000000000000000000000000000000000000000000000000000000c3"
+	sourceHash  = "0x00000000000000000000000000000000000000000000000000000000000000d1"
+)
+
+func TestRollupReceipts(t *testing.T) {
+	db := setupTestDB(t)
+
+	node := newFakeNode(t)
+	node.On("eth_getTransactionByHash", opTxHash, `{
+		"hash": "`+opTxHash+`", "type": "0x2", "blockHash": "`+blockHash+`",
+		"from": "0x1111111111111111111111111111111111111111",
+		"to": "0x2222222222222222222222222222222222222222",
+		"input": "0x", "value": "0x64"
+	}`)
+	node.On("eth_getTransactionReceipt", opTxHash, `{
+		"status": "0x1", "blockHash": "`+blockHash+`", "blockNumber": "0x10",
+		"contractAddress": null, "logs": [], "gasUsed": "0x5208", "effectiveGasPrice": "0x3e8",
+		"l1Fee": "0x2710", "l1GasUsed": "0x640", "l1GasPrice": "0x5",
+		"l1BlobBaseFee": "0x1", "l1BaseFeeScalar": "0x558", "l1BlobBaseFeeScalar": "0xc5fc5"
+	}`)
+	node.On("eth_getTransactionByHash", depositHash, `{
+		"hash": "`+depositHash+`", "type": "0x7e", "blockHash": "`+blockHash+`",
+		"from": "0xdeaddeaddeaddeaddeaddeaddeaddeaddead0001",
+		"to": "0x4200000000000000000000000000000000000015",
+		"input": "0x440a5e20", "value": "0x0",
+		"sourceHash": "`+sourceHash+`", "mint": "0x0", "isSystemTx": false
+	}`)
+	node.On("eth_getTransactionReceipt", depositHash, `{
+		"status": "0x1", "blockHash": "`+blockHash+`", "blockNumber": "0x10",
+		"contractAddress": null, "logs": [], "gasUsed": "0xb0c4", "effectiveGasPrice": "0x0",
+		"depositNonce": "0x7", "depositReceiptVersion": "0x1"
+	}`)
+	node.On("eth_getTransactionByHash", arbTxHash, `{
+		"hash": "`+arbTxHash+`", "type": "0x2", "blockHash": "`+blockHash+`",
+		"from": "0x3333333333333333333333333333333333333333",
+		"to": "0x4444444444444444444444444444444444444444",
+		"input": "0x", "value": "0x1"
+	}`)
+	node.On("eth_getTransactionReceipt", arbTxHash, `{
+		"status": "0x1", "blockHash": "`+blockHash+`", "blockNumber": "0x20",
+		"contractAddress": null, "logs": [], "gasUsed": "0x7530", "effectiveGasPrice": "0x5f5e100",
+		"gasUsedForL1": "0x2710"
+	}`)
+
+	txService, err := txns.NewTxnService(db, []txns.Chain{
+		{Id: opSepolia, Name: "op-sepolia", Type: "optimism", NodeURL: node.URL(), Cache: txns.NewTxnCache()},
+		{Id: arbitrumSepolia, Name: "arbitrum-sepolia", Type: "arbitrum", NodeURL: node.URL(), Cache: txns.NewTxnCache()},
+	})
+	require.NoError(t, err)
+
+	t.Run("AddsL1FeeToTotalFee", func(t *testing.T) {
+		found, err := txService.ByHashes(opSepolia, []string{opTxHash}, 0, types.FetchOptions{})
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+
+		txn := found[0]
+		assert.Equal(t, uint64(2), txn.Type)
+		assert.Equal(t, uint64(21000), txn.GasUsed)
+		assert.Equal(t, "1000", txn.EffectiveGasPrice)
+		assert.Equal(t, "21010000", txn.TotalFee, "21000 gas at 1000 wei plus the 10000 wei L1 fee")
+		require.NotNil(t, txn.L2)
+		assert.Equal(t, "10000", *txn.L2.L1Fee)
+		assert.Equal(t, uint64(1600), *txn.L2.L1GasUsed)
+		assert.Equal(t, uint64(1368), *txn.L2.L1BaseFeeScalar)
+		assert.Nil(t, txn.L2.SourceHash)
+	})
+
+	t.Run("DecodesDeposits", func(t *testing.T) {
+		found, err := txService.ByHashes(opSepolia, []string{depositHash}, 0, types.FetchOptions{})
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+
+		txn := found[0]
+		assert.Equal(t, uint64(0x7e), txn.Type)
+		assert.Equal(t, "0", txn.TotalFee)
+		require.NotNil(t, txn.L2)
+		assert.Equal(t, sourceHash, *txn.L2.SourceHash)
+		assert.Equal(t, uint64(7), *txn.L2.DepositNonce)
+		assert.False(t, *txn.L2.IsSystemTx)
+	})
+
+	t.Run("KeepsArbitrumL1Gas", func(t *testing.T) {
+		found, err := txService.ByHashes(arbitrumSepolia, []string{arbTxHash}, 0, types.FetchOptions{})
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+
+		txn := found[0]
+		assert.Equal(t, "3000000000000", txn.TotalFee, "the L1 gas is part of gasUsed")
+		require.NotNil(t, txn.L2)
+		assert.Equal(t, uint64(10000), *txn.L2.GasUsedForL1)
+		assert.Nil(t, txn.L2.L1Fee)
+	})
+
+	t.Run("ReadsStoredFields", func(t *testing.T) {
+		stored, err := txService.All(opSepolia, types.MinedPeriod{}, types.FetchOptions{})
+		require.NoError(t, err)
+		require.Len(t, stored, 2)
+		for _, txn := range stored {
+			require.NotNil(t, txn.L2)
+			assert.NotEmpty(t, txn.TotalFee)
+		}
+	})
+
+	t.Run("RefusesUnknownChainType", func(t *testing.T) {
+		_, err := txns.NewTxnService(db, []txns.Chain{
+			{Id: 10, Name: "optimism", Type: "zksync", NodeURL: node.URL(), Cache: txns.NewTxnCache()},
+		})
+		assert.Error(t, err)
+	})
+}
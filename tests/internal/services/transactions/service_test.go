@@ -1,6 +1,8 @@
 package transactions
 
 import (
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -9,20 +11,49 @@ import (
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
+	"ethereum_fetcher/db/migrations"
 	"ethereum_fetcher/db/models"
 	txns "ethereum_fetcher/internal/services/transactions"
+	types "ethereum_fetcher/internal/services/transactions/types"
 )
 
+const (
+	sepolia = 11155111
+	holesky = 17000
+
+	// hash123 and hash456 are stored on sepolia, hash999 on holesky
+	hash123 = "0x0000000000000000000000000000000000000000000000000000000000000123"
+	hash456 = "0x0000000000000000000000000000000000000000000000000000000000000456"
+	hash789 = "0x0000000000000000000000000000000000000000000000000000000000000789"
+	hash999 = "0x0000000000000000000000000000000000000000000000000000000000000999"
+	hashAbc = "0x0000000000000000000000000000000000000000000000000000000000000abc"
+)
+
+// setupTestDB opens an in-memory database of its own for the test, with every
+// migration applied
 func setupTestDB(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
 	require.NoError(t, err)
 
-	err = db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.UserTransaction{})
+	migrator, err := migrations.New(db)
 	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
 
 	return db
 }
 
+// refusingBudget records the node calls it is charged and refuses them all
+type refusingBudget struct {
+	charged []int
+}
+
+var errNoBudget = errors.New("no budget")
+
+func (b *refusingBudget) Charge(calls int) error {
+	b.charged = append(b.charged, calls)
+	return errNoBudget
+}
+
 func TestTransactionService(t *testing.T) {
 	db := setupTestDB(t)
 	ethNodeURL := "https://sepolia.infura.io/v3/dummy"
@@ -36,19 +67,32 @@ func TestTransactionService(t *testing.T) {
 	err := db.Create(&user).Error
 	require.NoError(t, err)
 
+	minedAt := uint64(1717508608)
 	transactions := []models.Transaction{
 		{
-			TransactionHash:   "0x123",
+			ChainId:           sepolia,
+			TransactionHash:   hash123,
 			TransactionStatus: 1,
 			BlockNumber:       100,
+			BlockTimestamp:    &minedAt,
 			FromAddress:       "0xSender1",
 		},
 		{
-			TransactionHash:   "0x456",
+			ChainId:           sepolia,
+			TransactionHash:   hash456,
 			TransactionStatus: 1,
 			BlockNumber:       200,
+			BlockTimestamp:    &minedAt,
 			FromAddress:       "0xSender2",
 		},
+		{
+			ChainId:           holesky,
+			TransactionHash:   hash999,
+			TransactionStatus: 1,
+			BlockNumber:       300,
+			BlockTimestamp:    &minedAt,
+			FromAddress:       "0xSender3",
+		},
 	}
 	err = db.Create(&transactions).Error
 	require.NoError(t, err)
@@ -56,37 +100,107 @@ func TestTransactionService(t *testing.T) {
 	// Create user transactions
 	userTransactions := []models.UserTransaction{
 		{
-			UserId:          user.ID,
-			TransactionHash: "0x123",
-			RequestedAt:     time.Now(),
+			UserId:           user.ID,
+			ChainId:          sepolia,
+			TransactionHash:  hash123,
+			FirstRequestedAt: time.Now(),
+			LastRequestedAt:  time.Now(),
 		},
 		{
-			UserId:          user.ID,
-			TransactionHash: "0x456",
-			RequestedAt:     time.Now(),
+			UserId:           user.ID,
+			ChainId:          sepolia,
+			TransactionHash:  hash456,
+			FirstRequestedAt: time.Now(),
+			LastRequestedAt:  time.Now(),
 		},
 	}
 	err = db.Create(&userTransactions).Error
 	require.NoError(t, err)
 
-	txService, err := txns.NewTxnService(db, ethNodeURL)
+	txService, err := txns.NewTxnService(db, []txns.Chain{
+		{Id: sepolia, Name: "sepolia", NodeURL: ethNodeURL, Cache: txns.NewTxnCache()},
+		{Id: holesky, Name: "holesky", NodeURL: ethNodeURL, Cache: txns.NewTxnCache()},
+	})
 	require.NoError(t, err)
 
 	t.Run("GetTransactionsByHashes", func(t *testing.T) {
-		txns, err := txService.ByHashes([]string{"0x123", "0x456"}, user.ID)
+		txns, err := txService.ByHashes(sepolia, []string{hash123, hash456}, user.ID, types.FetchOptions{})
 		assert.NoError(t, err)
 		assert.Len(t, txns, 2)
 	})
 
+	t.Run("ValidatesHashes", func(t *testing.T) {
+		budget := &refusingBudget{}
+		_, err := txService.ByHashes(sepolia, []string{hash123, "0x123"}, user.ID, types.FetchOptions{Budget: budget})
+		assert.Equal(t, types.InvalidTxnHash, err)
+		assert.Empty(t, budget.charged)
+
+		txns, err := txService.ByHashes(sepolia, []string{"0x" + strings.ToUpper(hash123[2:])}, user.ID, types.FetchOptions{})
+		assert.NoError(t, err)
+		require.Len(t, txns, 1)
+		assert.Equal(t, hash123, txns[0].TransactionHash)
+	})
+
+	t.Run("ChargesNodeCallBudget", func(t *testing.T) {
+		budget := &refusingBudget{}
+
+		// stored transactions don't cost node calls
+		txns, err := txService.ByHashes(sepolia, []string{hash123}, user.ID, types.FetchOptions{Budget: budget})
+		assert.NoError(t, err)
+		assert.Len(t, txns, 1)
+
+		// the node isn't asked once the budget refuses
+		_, err = txService.ByHashes(sepolia, []string{hash456, hash789, hashAbc}, user.ID, types.FetchOptions{Budget: budget})
+		assert.Equal(t, errNoBudget, err)
+		assert.Equal(t, []int{2}, budget.charged)
+
+		// transactions are stored per chain
+		_, err = txService.ByHashes(holesky, []string{hash123}, 0, types.FetchOptions{Budget: budget})
+		assert.Equal(t, errNoBudget, err)
+		assert.Equal(t, []int{2, 1}, budget.charged)
+	})
+
 	t.Run("GetUserTransactions", func(t *testing.T) {
-		txns, err := txService.ForUser(user.ID)
+		txns, err := txService.ForUser(user.ID, types.HistoryQuery{Scope: types.UserHistory})
 		assert.NoError(t, err)
 		assert.Len(t, txns, 2)
 	})
 
 	t.Run("GetAllTransactions", func(t *testing.T) {
-		txns, err := txService.All()
+		txns, err := txService.All(0, types.MinedPeriod{}, types.FetchOptions{})
 		assert.NoError(t, err)
-		assert.Len(t, txns, 2)
+		assert.Len(t, txns, 3)
+
+		txns, err = txService.All(holesky, types.MinedPeriod{}, types.FetchOptions{})
+		assert.NoError(t, err)
+		require.Len(t, txns, 1)
+		assert.Equal(t, uint64(holesky), txns[0].ChainId)
+	})
+
+	t.Run("ResolvesChains", func(t *testing.T) {
+		for chain, expected := range map[string]uint64{"": sepolia, "sepolia": sepolia, "Holesky": holesky, "17000": holesky} {
+			chainId, err := txService.ChainId(chain)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, chainId)
+		}
+
+		_, err := txService.ChainId("mainnet")
+		assert.Equal(t, types.UnknownChain, err)
+		_, err = txService.ChainId("1")
+		assert.Equal(t, types.UnknownChain, err)
+		_, err = txService.ByHashes(1, []string{hash123}, 0, types.FetchOptions{})
+		assert.Equal(t, types.UnknownChain, err)
+	})
+
+	t.Run("RefusesInvalidChains", func(t *testing.T) {
+		_, err := txns.NewTxnService(db, nil)
+		assert.Error(t, err)
+		_, err = txns.NewTxnService(db, []txns.Chain{
+			{Id: sepolia, Name: "sepolia", NodeURL: ethNodeURL, Cache: txns.NewTxnCache()},
+			{Id: sepolia, Name: "other", NodeURL: ethNodeURL, Cache: txns.NewTxnCache()},
+		})
+		assert.Error(t, err)
+		_, err = txns.NewTxnService(db, []txns.Chain{{Id: sepolia, Name: "5", NodeURL: ethNodeURL, Cache: txns.NewTxnCache()}})
+		assert.Error(t, err)
 	})
 }
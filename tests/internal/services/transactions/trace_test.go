@@ -0,0 +1,125 @@
+package transactions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ethereum_fetcher/db/models"
+	txns "ethereum_fetcher/internal/services/transactions"
+	types "ethereum_fetcher/internal/services/transactions/types"
+)
+
+const tracedHash = "0x00000000000000000000000000000000000000000000000000000000000000e1"
+
+// a call to a router that forwards ether twice, once through a call that
+// reverts, and reads a price with a static call
+const callTrace = `{
+	"type": "CALL", "from": "0x1111111111111111111111111111111111111111",
+	"to": "0x2222222222222222222222222222222222222222", "value": "0x3e8",
+	"gas": "0x30d40", "gasUsed": "0x9c40", "input": "0x12345678",
+	"calls": [
+		{"type": "STATICCALL", "from": "0x2222222222222222222222222222222222222222",
+		 "to": "0x3333333333333333333333333333333333333333", "gas": "0x2710", "gasUsed": "0x3e8", "input": "0x"},
+		{"type": "CALL", "from": "0x2222222222222222222222222222222222222222",
+		 "to": "0x4444444444444444444444444444444444444444", "value": "0x1f4",
+		 "gas": "0x2710", "gasUsed": "0x0", "input": "0x",
+		 "calls": [
+			{"type": "CALL", "from": "0x4444444444444444444444444444444444444444",
+			 "to": "0x5555555555555555555555555555555555555555", "value": "0x64",
+			 "gas": "0x2710", "gasUsed": "0x0", "input": "0x"}
+		 ]},
+		{"type": "CALL", "from": "0x2222222222222222222222222222222222222222",
+		 "to": "0x6666666666666666666666666666666666666666", "value": "0xc8",
+		 "gas": "0x2710", "gasUsed": "0x2710", "input": "0x", "error": "execution reverted"}
+	]
+}`
+
+func TestTraces(t *testing.T) {
+	db := setupTestDB(t)
+
+	minedAt := uint64(1717508608)
+	require.NoError(t, db.Create(&models.Transaction{
+		ChainId:           sepolia,
+		TransactionHash:   tracedHash,
+		TransactionStatus: 1,
+		BlockNumber:       100,
+		BlockTimestamp:    &minedAt,
+		FromAddress:       "0x1111111111111111111111111111111111111111",
+	}).Error)
+
+	node := newFakeNode(t)
+	node.On("debug_traceTransaction", tracedHash, callTrace)
+
+	txService, err := txns.NewTxnService(db, []txns.Chain{
+		{Id: sepolia, Name: "sepolia", NodeURL: node.URL(), Traces: true, Cache: txns.NewTxnCache()},
+		{Id: holesky, Name: "holesky", NodeURL: node.URL(), Cache: txns.NewTxnCache()},
+	})
+	require.NoError(t, err)
+
+	t.Run("FlattensInternalTransfers", func(t *testing.T) {
+		trace, err := txService.Trace(sepolia, tracedHash, 0, types.FetchOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, tracedHash, trace.TransactionHash)
+		assert.Equal(t, "CALL", trace.Calls.Type)
+		assert.Equal(t, "1000", trace.Calls.Value)
+		require.Len(t, trace.Calls.Calls, 3)
+		assert.Equal(t, "execution reverted", trace.Calls.Calls[2].Error)
+
+		require.Len(t, trace.Transfers, 2, "static and reverted calls move no ether")
+		assert.Equal(t, "500", trace.Transfers[0].Value)
+		assert.Equal(t, 1, trace.Transfers[0].Depth)
+		assert.Equal(t, "0x5555555555555555555555555555555555555555", trace.Transfers[1].To)
+		assert.Equal(t, 2, trace.Transfers[1].Depth)
+	})
+
+	t.Run("StoresTraces", func(t *testing.T) {
+		calls := node.Calls("debug_traceTransaction")
+
+		trace, err := txService.Trace(sepolia, tracedHash, 0, types.FetchOptions{})
+		require.NoError(t, err)
+		assert.Len(t, trace.Transfers, 2)
+		assert.Equal(t, calls, node.Calls("debug_traceTransaction"))
+	})
+
+	t.Run("ChargesNodeCallBudget", func(t *testing.T) {
+		budget := &refusingBudget{}
+
+		// stored traces don't cost node calls
+		_, err := txService.Trace(sepolia, tracedHash, 0, types.FetchOptions{Budget: budget})
+		assert.NoError(t, err)
+		assert.Empty(t, budget.charged)
+
+		// tracing a stored transaction the first time costs one
+		untraced := "0x00000000000000000000000000000000000000000000000000000000000000e3"
+		require.NoError(t, db.Create(&models.Transaction{
+			ChainId: sepolia, TransactionHash: untraced, BlockNumber: 100, BlockTimestamp: &minedAt,
+		}).Error)
+		node.On("debug_traceTransaction", untraced, callTrace)
+
+		_, err = txService.Trace(sepolia, untraced, 0, types.FetchOptions{Budget: budget})
+		assert.Equal(t, errNoBudget, err)
+		assert.Equal(t, []int{1}, budget.charged)
+		assert.Zero(t, node.CallsWith("debug_traceTransaction", untraced))
+	})
+
+	t.Run("RefusesChainsWithoutTraces", func(t *testing.T) {
+		_, err := txService.Trace(holesky, tracedHash, 0, types.FetchOptions{})
+		assert.Equal(t, types.TracesDisabled, err)
+	})
+
+	t.Run("RefusesInvalidHashes", func(t *testing.T) {
+		_, err := txService.Trace(sepolia, "0x1234", 0, types.FetchOptions{})
+		assert.Equal(t, types.InvalidTxnHash, err)
+	})
+
+	t.Run("FailsWhenTheNodeCantTrace", func(t *testing.T) {
+		untraced := "0x00000000000000000000000000000000000000000000000000000000000000e2"
+		require.NoError(t, db.Create(&models.Transaction{ChainId: sepolia, TransactionHash: untraced}).Error)
+
+		_, err := txService.Trace(sepolia, untraced, 0, types.FetchOptions{})
+		assert.Equal(t, types.FailedToFetchTrace, err)
+	})
+}
@@ -0,0 +1,105 @@
+package transactions
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"ethereum_fetcher/db/models"
+	"ethereum_fetcher/internal/config"
+	txns "ethereum_fetcher/internal/services/transactions"
+)
+
+func newWarmUpDb(t *testing.T) *gorm.DB {
+	db := setupTestDB(t)
+	return db
+}
+
+func TestWarmUpFromMostRequested(t *testing.T) {
+	db := newWarmUpDb(t)
+	repo := txns.NewTxnRepo(db)
+
+	require.NoError(t, repo.Save([]models.Transaction{
+		{ChainId: 1, TransactionHash: "0xaaa"}, {ChainId: 1, TransactionHash: "0xbbb"}, {ChainId: 1, TransactionHash: "0xccc"},
+		{ChainId: 10, TransactionHash: "0xddd"},
+	}))
+	// 0xddd is requested most in total across users and chains, 0xccc least
+	require.NoError(t, repo.AddUserTransactions(1, []string{"0xaaa", "0xbbb", "0xccc"}, 1, 0))
+	require.NoError(t, repo.AddUserTransactions(1, []string{"0xaaa", "0xbbb"}, 1, 0))
+	require.NoError(t, repo.AddUserTransactions(1, []string{"0xbbb"}, 2, 0))
+	for range 4 {
+		require.NoError(t, repo.AddUserTransactions(10, []string{"0xddd"}, 3, 0))
+	}
+
+	mostRequested, err := repo.GetMostRequested(3)
+	require.NoError(t, err)
+	require.Len(t, mostRequested, 3)
+	assert.Equal(t, "0xddd", mostRequested[0].TransactionHash)
+	assert.Equal(t, "0xbbb", mostRequested[1].TransactionHash)
+	assert.Equal(t, "0xaaa", mostRequested[2].TransactionHash)
+
+	// transactions are warmed up in the cache of their chain
+	cache, optimismCache := txns.NewTxnCache(), txns.NewTxnCache()
+	warmer, err := txns.NewCacheWarmer(db, []txns.Chain{{Id: 1, Cache: cache}, {Id: 10, Cache: optimismCache}}, config.CacheConfig{
+		WarmUp: txns.PopularWarmUp, WarmUpLimit: 3, WarmUpTimeout: time.Second,
+	})
+	require.NoError(t, err)
+	assert.False(t, warmer.Ready())
+
+	warmer.WarmUp(context.Background())
+	assert.True(t, warmer.Ready())
+
+	result := cache.GetMany([]string{"0xaaa", "0xbbb", "0xccc", "0xddd"})
+	assert.ElementsMatch(t, []string{"0xaaa", "0xbbb"}, result.ExistingHashes)
+	assert.Equal(t, []string{"0xccc", "0xddd"}, result.MissingHashes)
+	_, found := optimismCache.Get("0xddd")
+	assert.True(t, found)
+}
+
+func TestWarmUpFromSnapshot(t *testing.T) {
+	db := newWarmUpDb(t)
+	cfg := config.CacheConfig{
+		Backend:       txns.BoundedBackend,
+		WarmUp:        txns.SnapshotWarmUp,
+		WarmUpLimit:   2,
+		WarmUpTimeout: time.Second,
+		SnapshotPath:  filepath.Join(t.TempDir(), "cache.json"),
+	}
+
+	// the snapshot keeps the most recently used transactions
+	previous := txns.NewLRUCache(10, 0, time.Hour)
+	previous.SetMany([]models.Transaction{{ChainId: 1, TransactionHash: "0xaaa"}, {ChainId: 1, TransactionHash: "0xbbb"}, {ChainId: 1, TransactionHash: "0xccc"}})
+	previous.Get("0xaaa")
+
+	saver, err := txns.NewCacheWarmer(db, []txns.Chain{{Id: 1, Cache: previous}}, cfg)
+	require.NoError(t, err)
+	require.NoError(t, saver.SaveSnapshot())
+
+	cache := txns.NewLRUCache(10, 0, time.Hour)
+	warmer, err := txns.NewCacheWarmer(db, []txns.Chain{{Id: 1, Cache: cache}}, cfg)
+	require.NoError(t, err)
+	warmer.WarmUp(context.Background())
+
+	result := cache.GetMany([]string{"0xaaa", "0xbbb", "0xccc"})
+	assert.ElementsMatch(t, []string{"0xaaa", "0xccc"}, result.ExistingHashes)
+
+	t.Run("MissingSnapshot", func(t *testing.T) {
+		cfg := cfg
+		cfg.SnapshotPath = filepath.Join(t.TempDir(), "missing.json")
+
+		warmer, err := txns.NewCacheWarmer(db, []txns.Chain{{Id: 1, Cache: txns.NewTxnCache()}}, cfg)
+		require.NoError(t, err)
+		warmer.WarmUp(context.Background())
+		assert.True(t, warmer.Ready())
+	})
+
+	t.Run("RequiresPath", func(t *testing.T) {
+		_, err := txns.NewCacheWarmer(db, []txns.Chain{{Id: 1, Cache: txns.NewTxnCache()}}, config.CacheConfig{WarmUp: txns.SnapshotWarmUp})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,131 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ethereum_fetcher/api"
+	"ethereum_fetcher/db/migrations"
+	"ethereum_fetcher/internal/services/usage"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	migrator, err := migrations.New(db)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
+	return db
+}
+
+func today() string {
+	return time.Now().UTC().Format("2006-01-02")
+}
+
+func TestRateLimits(t *testing.T) {
+	db := setupTestDB(t)
+	service := usage.NewUsageService(db, usage.Config{
+		UserRPS: 1, UserBurst: 2,
+		ApiKeyRPS: 1, ApiKeyBurst: 1,
+		AnonymousRPS: 1, AnonymousBurst: 1,
+	})
+
+	t.Run("User", func(t *testing.T) {
+		user := usage.Principal{UserId: 1}
+		require.NoError(t, service.Allow(user))
+		require.NoError(t, service.Allow(user))
+
+		err := service.Allow(user)
+		var limitErr usage.LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Greater(t, limitErr.RetryAfter, time.Duration(0))
+		assert.LessOrEqual(t, limitErr.RetryAfter, time.Second)
+
+		// other users, keys of the user and anonymous clients have their own
+		assert.NoError(t, service.Allow(usage.Principal{UserId: 2}))
+		assert.NoError(t, service.Allow(usage.Principal{UserId: 1, ApiKeyId: 7}))
+		assert.NoError(t, service.Allow(usage.Principal{IP: "192.0.2.1"}))
+	})
+
+	t.Run("AnonymousPerIP", func(t *testing.T) {
+		require.NoError(t, service.Allow(usage.Principal{IP: "192.0.2.2"}))
+		assert.ErrorAs(t, service.Allow(usage.Principal{IP: "192.0.2.2"}), &usage.LimitError{})
+		assert.NoError(t, service.Allow(usage.Principal{IP: "192.0.2.3"}))
+	})
+
+	t.Run("RecordsRequests", func(t *testing.T) {
+		report, err := service.ForUser(1, "", "")
+		require.NoError(t, err)
+		assert.Equal(t, []api.Usage{
+			{UserId: 1, Day: today(), Requests: 2},
+			{UserId: 1, ApiKeyId: 7, Day: today(), Requests: 1},
+		}, report.Usage)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		unlimited := usage.NewUsageService(db, usage.Config{})
+		for i := 0; i < 10; i++ {
+			require.NoError(t, unlimited.Allow(usage.Principal{UserId: 3}))
+		}
+	})
+}
+
+func TestNodeCallQuotas(t *testing.T) {
+	db := setupTestDB(t)
+	service := usage.NewUsageService(db, usage.Config{
+		UserNodeCallsPerDay:      5,
+		ApiKeyNodeCallsPerDay:    3,
+		AnonymousNodeCallsPerDay: 2,
+	})
+
+	t.Run("PerUser", func(t *testing.T) {
+		budget := service.Budget(usage.Principal{UserId: 1})
+		require.NoError(t, budget.Charge(4))
+		require.NoError(t, budget.Charge(0))
+
+		err := budget.Charge(2)
+		var limitErr usage.LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.LessOrEqual(t, limitErr.RetryAfter, 24*time.Hour)
+
+		// keys count against the quota of their user
+		assert.ErrorAs(t, service.Budget(usage.Principal{UserId: 1, ApiKeyId: 9}).Charge(2), &limitErr)
+		assert.NoError(t, service.Budget(usage.Principal{UserId: 1, ApiKeyId: 9}).Charge(1))
+	})
+
+	t.Run("PerApiKey", func(t *testing.T) {
+		require.NoError(t, service.Budget(usage.Principal{UserId: 2, ApiKeyId: 10}).Charge(3))
+		assert.ErrorAs(t, service.Budget(usage.Principal{UserId: 2, ApiKeyId: 10}).Charge(1), &usage.LimitError{})
+
+		// the user has quota left for their other keys
+		assert.NoError(t, service.Budget(usage.Principal{UserId: 2, ApiKeyId: 11}).Charge(2))
+	})
+
+	t.Run("AnonymousShared", func(t *testing.T) {
+		require.NoError(t, service.Budget(usage.Principal{IP: "192.0.2.1"}).Charge(2))
+		assert.ErrorAs(t, service.Budget(usage.Principal{IP: "192.0.2.2"}).Charge(1), &usage.LimitError{})
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		report, err := service.Report(today(), today())
+		require.NoError(t, err)
+		assert.Equal(t, today(), report.From)
+		assert.Equal(t, []api.Usage{
+			{UserId: 0, NodeCalls: 2},
+			{UserId: 1, NodeCalls: 5},
+			{UserId: 2, NodeCalls: 5},
+		}, report.Usage)
+	})
+
+	t.Run("InvalidPeriod", func(t *testing.T) {
+		_, err := service.Report("2024-02-01", "2024-01-01")
+		assert.Equal(t, usage.InvalidUsagePeriod, err)
+		_, err = service.ForUser(1, "last week", "")
+		assert.Equal(t, usage.InvalidUsagePeriod, err)
+	})
+}
@@ -0,0 +1,32 @@
+package passwords
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ethereum_fetcher/pkg/passwords"
+)
+
+func TestPolicy(t *testing.T) {
+	policy := passwords.DefaultPolicy
+
+	tests := []struct {
+		name     string
+		password string
+		err      error
+	}{
+		{"Valid", "Correct-Horse-9", nil},
+		{"TooShort", "Ab1!", passwords.ErrTooShort},
+		{"TooLong", "Aa1!" + strings.Repeat("x", 72), passwords.ErrTooLong},
+		{"TooFewCharClasses", "alllowercaseletters", passwords.ErrTooFewCharClasses},
+		{"ContainsUsername", "Alice-Secret-9", passwords.ErrContainsUsername},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.err, policy.Validate(test.password, "alice"))
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package siwe
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ethereum_fetcher/pkg/siwe"
+)
+
+const fullMessage = `https://example.com wants you to sign in with your Ethereum account:
+0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2
+
+I accept the Terms of Service: https://example.com/tos
+
+URI: https://example.com/login
+Version: 1
+Chain ID: 1
+Nonce: 32891756abcd
+Issued At: 2021-09-30T16:25:24Z
+Expiration Time: 2021-10-01T16:25:24Z
+Not Before: 2021-09-30T16:25:24.000Z
+Request ID: some-request
+Resources:
+- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/
+- https://example.com/my-web2-claim.json`
+
+func TestParse(t *testing.T) {
+	t.Run("AllFields", func(t *testing.T) {
+		msg, err := siwe.Parse(fullMessage)
+		require.NoError(t, err)
+
+		assert.Equal(t, "https", msg.Scheme)
+		assert.Equal(t, "example.com", msg.Domain)
+		assert.Equal(t, "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", msg.Address.Hex())
+		assert.Equal(t, "I accept the Terms of Service: https://example.com/tos", msg.Statement)
+		assert.Equal(t, "https://example.com/login", msg.URI)
+		assert.Equal(t, uint64(1), msg.ChainId)
+		assert.Equal(t, "32891756abcd", msg.Nonce)
+		assert.Equal(t, time.Date(2021, 9, 30, 16, 25, 24, 0, time.UTC), msg.IssuedAt)
+		assert.Equal(t, time.Date(2021, 10, 1, 16, 25, 24, 0, time.UTC), msg.ExpirationTime)
+		assert.Equal(t, "some-request", msg.RequestId)
+		assert.Len(t, msg.Resources, 2)
+	})
+
+	t.Run("WithoutStatement", func(t *testing.T) {
+		msg, err := siwe.Parse("example.com wants you to sign in with your Ethereum account:\n" +
+			"0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2\n\n\n" +
+			"URI: https://example.com\nVersion: 1\nChain ID: 10\nNonce: abcdefgh\nIssued At: 2021-09-30T16:25:24Z")
+		require.NoError(t, err)
+		assert.Empty(t, msg.Statement)
+		assert.Empty(t, msg.Scheme)
+		assert.Equal(t, uint64(10), msg.ChainId)
+		assert.True(t, msg.ExpirationTime.IsZero())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		cases := []struct {
+			name    string
+			message string
+			err     error
+		}{
+			{"NoHeader", "hello", siwe.ErrMalformed},
+			{"LowercaseAddress", replaceLine(fullMessage, 1, "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"), siwe.ErrInvalidAddress},
+			{"Version2", replaceLine(fullMessage, 6, "Version: 2"), siwe.ErrInvalidVersion},
+			{"ShortNonce", replaceLine(fullMessage, 8, "Nonce: abc"), siwe.ErrInvalidNonce},
+			{"MissingIssuedAt", replaceLine(fullMessage, 9, "Not Issued: 2021-09-30T16:25:24Z"), siwe.ErrMalformed},
+			{"FieldsOutOfOrder", replaceLine(fullMessage, 12, "Issued At: 2021-09-30T16:25:24Z"), siwe.ErrMalformed},
+		}
+		for _, tc := range cases {
+			_, err := siwe.Parse(tc.message)
+			assert.ErrorIs(t, err, tc.err, tc.name)
+		}
+	})
+}
+
+func TestValidAt(t *testing.T) {
+	msg, err := siwe.Parse(fullMessage)
+	require.NoError(t, err)
+
+	assert.False(t, msg.ValidAt(msg.NotBefore.Add(-time.Second)))
+	assert.True(t, msg.ValidAt(msg.NotBefore))
+	assert.False(t, msg.ValidAt(msg.ExpirationTime))
+}
+
+func TestVerifySignature(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	raw := replaceLine(fullMessage, 1, address)
+	msg, err := siwe.Parse(raw)
+	require.NoError(t, err)
+
+	sig, err := crypto.Sign(accounts.TextHash([]byte(raw)), key)
+	require.NoError(t, err)
+	sig[crypto.RecoveryIDOffset] += 27
+
+	assert.NoError(t, msg.VerifySignature(hexutil.Encode(sig)))
+
+	other, err := siwe.Parse(fullMessage)
+	require.NoError(t, err)
+	assert.ErrorIs(t, other.VerifySignature(hexutil.Encode(sig)), siwe.ErrInvalidSignature)
+	assert.ErrorIs(t, msg.VerifySignature("0x1234"), siwe.ErrInvalidSignature)
+}
+
+func replaceLine(message string, line int, replacement string) string {
+	lines := strings.Split(message, "\n")
+	lines[line] = replacement
+	return strings.Join(lines, "\n")
+}